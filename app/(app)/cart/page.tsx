@@ -14,10 +14,21 @@ const PAYMENT_METHODS = [
 ];
 
 interface CartEntry {
+  key: string; // productId:variantId — one product can sit in the cart once per variant
   productId: string;
+  variantId: number; // 0 for products without variants
   quantity: number;
 }
 
+interface Variant {
+  id: number;
+  sku: string;
+  options: { name: string; value: string }[];
+  price: number;
+  stock: number;
+  image?: string;
+}
+
 interface Product {
   id: string;
   name: string;
@@ -27,6 +38,7 @@ interface Product {
   stock: number;
   image?: string;
   brand: string;
+  variants?: Variant[];
 }
 
 interface Voucher {
@@ -58,6 +70,24 @@ function imageSrc(path?: string) {
   return path.startsWith("http") ? path : `${BACKEND}${path}`;
 }
 
+function variantOf(product: Product, entry: CartEntry) {
+  return entry.variantId ? product.variants?.find((v) => v.id === entry.variantId) : undefined;
+}
+
+// Variant lines are charged the variant's price
+function unitPrice(product: Product, entry: CartEntry) {
+  return variantOf(product, entry)?.price ?? product.price;
+}
+
+function variantLabel(variant?: Variant) {
+  return variant ? variant.options.map((o) => o.value).join(" / ") : "";
+}
+
+function cartLineUrl(userId: number, entry: CartEntry) {
+  const base = `${BACKEND}/api/users/${userId}/cart/${entry.productId}`;
+  return entry.variantId ? `${base}?variant_id=${entry.variantId}` : base;
+}
+
 export default function CartPage() {
   const router = useRouter();
 
@@ -97,20 +127,25 @@ export default function CartPage() {
       const cartRes = await authFetch(`${BACKEND}/api/users/${u.id}/cart`);
       const cartData = await cartRes.json();
       const entries: CartEntry[] = cartData.success && cartData.data
-        ? cartData.data.map((item: { product_id: string; quantity: number }) => ({ productId: item.product_id, quantity: item.quantity }))
+        ? cartData.data.map((item: { product_id: string; variant_id?: number; quantity: number }) => ({
+          key: `${item.product_id}:${item.variant_id ?? 0}`,
+          productId: item.product_id,
+          variantId: item.variant_id ?? 0,
+          quantity: item.quantity,
+        }))
         : [];
       setCartEntries(entries);
 
       // Fetch product details for each entry
       const productMap: Record<string, Product> = {};
       await Promise.all(
-        entries.map(async (entry) => {
+        [...new Set(entries.map((e) => e.productId))].map(async (productId) => {
           try {
-            const res = await publicFetch(`${BACKEND}/api/products/${entry.productId}`);
+            const res = await publicFetch(`${BACKEND}/api/products/${productId}`);
             if (res.ok) {
               const data = await res.json();
               if (data.success && data.data) {
-                productMap[entry.productId] = data.data;
+                productMap[productId] = data.data;
               }
             }
           } catch {
@@ -157,10 +192,10 @@ export default function CartPage() {
   // Derived data
   const validEntries = cartEntries.filter((e) => products[e.productId]);
 
-  const selectedEntries = validEntries.filter((e) => selectedIds.has(e.productId));
+  const selectedEntries = validEntries.filter((e) => selectedIds.has(e.key));
   const subtotal = selectedEntries.reduce((sum, e) => {
     const p = products[e.productId];
-    return sum + (p ? p.price * e.quantity : 0);
+    return sum + (p ? unitPrice(p, e) * e.quantity : 0);
   }, 0);
 
   // Calculate discount
@@ -179,11 +214,11 @@ export default function CartPage() {
   const total = Math.max(0, subtotal - discount);
 
   // Handlers
-  const toggleSelect = (productId: string) => {
+  const toggleSelect = (key: string) => {
     setSelectedIds((prev) => {
       const next = new Set(prev);
-      if (next.has(productId)) next.delete(productId);
-      else next.add(productId);
+      if (next.has(key)) next.delete(key);
+      else next.add(key);
       return next;
     });
   };
@@ -192,35 +227,35 @@ export default function CartPage() {
     if (selectedIds.size === validEntries.length) {
       setSelectedIds(new Set());
     } else {
-      setSelectedIds(new Set(validEntries.map((e) => e.productId)));
+      setSelectedIds(new Set(validEntries.map((e) => e.key)));
     }
   };
 
-  const removeItem = async (productId: string) => {
+  const removeItem = async (entry: CartEntry) => {
     if (!userId) return;
     try {
-      await authFetch(`${BACKEND}/api/users/${userId}/cart/${productId}`, { method: "DELETE" });
-      setCartEntries((prev) => prev.filter((e) => e.productId !== productId));
+      await authFetch(cartLineUrl(userId, entry), { method: "DELETE" });
+      setCartEntries((prev) => prev.filter((e) => e.key !== entry.key));
       setSelectedIds((prev) => {
         const next = new Set(prev);
-        next.delete(productId);
+        next.delete(entry.key);
         return next;
       });
       window.dispatchEvent(new Event("cartUpdated"));
     } catch { }
   };
 
-  const updateQuantity = async (productId: string, qty: number) => {
+  const updateQuantity = async (entry: CartEntry, qty: number) => {
     const newQty = Math.max(1, qty);
     if (!userId) return;
     try {
-      await authFetch(`${BACKEND}/api/users/${userId}/cart/${productId}`, {
+      await authFetch(cartLineUrl(userId, entry), {
         method: "PUT",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ quantity: newQty }),
       });
       setCartEntries((prev) =>
-        prev.map((e) => e.productId === productId ? { ...e, quantity: newQty } : e)
+        prev.map((e) => e.key === entry.key ? { ...e, quantity: newQty } : e)
       );
     } catch { }
   };
@@ -248,7 +283,7 @@ export default function CartPage() {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({
-          product_ids: selectedEntries.map((e) => e.productId),
+          items: selectedEntries.map((e) => ({ product_id: Number(e.productId), variant_id: e.variantId })),
           voucher_id: selectedVoucher ? String(selectedVoucher.id) : "",
         }),
       });
@@ -381,12 +416,13 @@ export default function CartPage() {
               {validEntries.map((entry) => {
                 const product = products[entry.productId];
                 if (!product) return null;
-                const isSelected = selectedIds.has(entry.productId);
-                const imgUrl = imageSrc(product.image);
+                const isSelected = selectedIds.has(entry.key);
+                const variant = variantOf(product, entry);
+                const imgUrl = imageSrc(variant?.image || product.image);
 
                 return (
                   <div
-                    key={entry.productId}
+                    key={entry.key}
                     className={`group bg-slate-800/30 backdrop-blur-sm rounded-xl border transition-all duration-300 hover:shadow-lg ${isSelected
                       ? "border-primary-400/40 shadow-primary-400/10"
                       : "border-slate-700/50 hover:border-slate-600/60"
@@ -399,7 +435,7 @@ export default function CartPage() {
                           <input
                             type="checkbox"
                             checked={isSelected}
-                            onChange={() => toggleSelect(entry.productId)}
+                            onChange={() => toggleSelect(entry.key)}
                             className="sr-only peer"
                           />
                           <div className="w-5 h-5 rounded-md border-2 border-slate-600 peer-checked:border-primary-400 peer-checked:bg-primary-400 transition-all flex items-center justify-center hover:border-slate-400">
@@ -445,6 +481,9 @@ export default function CartPage() {
                           <h3 className="text-white font-semibold text-sm sm:text-base md:text-lg leading-tight mb-0.5 sm:mb-1 truncate group-hover:text-primary-400 transition-colors">
                             {product.name}
                           </h3>
+                          {variant && (
+                            <p className="text-slate-300 text-xs sm:text-sm mb-0.5 sm:mb-1">{variantLabel(variant)}</p>
+                          )}
                           {product.brand && (
                             <p className="text-slate-400 text-xs sm:text-sm mb-2 sm:mb-3">
                               by <span className="text-slate-300 font-medium">{product.brand}</span>
@@ -453,7 +492,7 @@ export default function CartPage() {
 
                           {/* Price */}
                           <p className="text-base sm:text-lg font-bold bg-gradient-to-r from-primary-400 to-secondary-400 bg-clip-text text-transparent">
-                            {formatPrice(product.price)}
+                            {formatPrice(unitPrice(product, entry))}
                           </p>
                         </div>
                       </Link>
@@ -465,7 +504,7 @@ export default function CartPage() {
                           onClick={(e) => {
                             e.preventDefault();
                             e.stopPropagation();
-                            removeItem(entry.productId);
+                            removeItem(entry);
                           }}
                           className="p-2 text-slate-500 hover:text-red-400 hover:bg-red-400/10 rounded-lg transition-all min-w-[44px] min-h-[44px] sm:min-w-0 sm:min-h-0 flex items-center justify-center"
                           title="Remove item"
@@ -481,7 +520,7 @@ export default function CartPage() {
                             onClick={(e) => {
                               e.preventDefault();
                               e.stopPropagation();
-                              updateQuantity(entry.productId, entry.quantity - 1);
+                              updateQuantity(entry, entry.quantity - 1);
                             }}
                             className="px-2.5 sm:px-2.5 py-2 sm:py-1.5 text-slate-400 hover:text-white transition-colors min-w-[40px] sm:min-w-0 flex items-center justify-center"
                           >
@@ -496,7 +535,7 @@ export default function CartPage() {
                             onClick={(e) => {
                               e.preventDefault();
                               e.stopPropagation();
-                              updateQuantity(entry.productId, entry.quantity + 1);
+                              updateQuantity(entry, entry.quantity + 1);
                             }}
                             className="px-2.5 sm:px-2.5 py-2 sm:py-1.5 text-slate-400 hover:text-white transition-colors min-w-[40px] sm:min-w-0 flex items-center justify-center"
                           >
@@ -540,13 +579,17 @@ export default function CartPage() {
                         {selectedEntries.map((entry) => {
                           const product = products[entry.productId];
                           if (!product) return null;
+                          const variant = variantOf(product, entry);
                           return (
                             <div
-                              key={entry.productId}
+                              key={entry.key}
                               className="flex items-start justify-between gap-3 pb-3 border-b border-slate-700/30 last:border-b-0 last:pb-0"
                             >
                               <div className="min-w-0 flex-1">
                                 <p className="text-white text-sm font-medium truncate">{product.name}</p>
+                                {variant && (
+                                  <p className="text-slate-300 text-xs">{variantLabel(variant)}</p>
+                                )}
                                 {product.brand && (
                                   <p className="text-slate-400 text-xs">
                                     by {product.brand}
@@ -557,7 +600,7 @@ export default function CartPage() {
                                 )}
                               </div>
                               <p className="text-white text-sm font-semibold whitespace-nowrap">
-                                {formatPrice(product.price * entry.quantity)}
+                                {formatPrice(unitPrice(product, entry) * entry.quantity)}
                               </p>
                             </div>
                           );
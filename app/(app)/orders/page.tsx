@@ -25,6 +25,9 @@ interface OrderDetailItem {
   quantity: number;
   price: number;
   subtotal: number;
+  variant_id?: number;
+  sku?: string;
+  options?: { name: string; value: string }[];
 }
 
 interface OrderDetail {
@@ -58,7 +61,9 @@ const STATUS_NEXT_ADMIN: Record<string, { label: string; next: string; style: st
     { label: "Mark as Shipped", next: "shipped", style: "border-purple-500/40 text-purple-400 hover:bg-purple-500/20" },
     { label: "Cancel Order", next: "cancelled", style: "border-red-500/40 text-red-400 hover:bg-red-500/20" },
   ],
-  shipped: [],
+  shipped: [
+    { label: "Mark as Delivered", next: "delivered", style: "border-green-500/40 text-green-400 hover:bg-green-500/20" },
+  ],
   delivered: [],
   cancelled: [],
 };
@@ -68,9 +73,7 @@ const STATUS_NEXT_CUSTOMER: Record<string, { label: string; next: string; style:
     { label: "Cancel Order", next: "cancelled", style: "border-red-500/40 text-red-400 hover:bg-red-500/20" },
   ],
   processing: [],
-  shipped: [
-    { label: "Confirm Delivery", next: "delivered", style: "border-green-500/40 text-green-400 hover:bg-green-500/20" },
-  ],
+  shipped: [],
   delivered: [],
   cancelled: [],
 };
@@ -460,6 +463,9 @@ export default function OrdersPage() {
                           </div>
                           <div className="flex-1 min-w-0">
                             <p className="text-white text-sm font-medium truncate">{item.product_name}</p>
+                            {item.options && (
+                              <p className="text-slate-300 text-xs">{item.options.map((o) => o.value).join(" / ")}</p>
+                            )}
                             <p className="text-slate-400 text-xs mt-0.5">
                               {item.quantity} × {formatIDR(item.price)}
                             </p>
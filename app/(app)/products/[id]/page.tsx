@@ -10,9 +10,32 @@ interface ProductSpec {
   value: string;
 }
 
+interface ProductOption {
+  name: string;
+  values: string[];
+}
+
+interface ProductVariant {
+  id: number;
+  sku: string;
+  options: { name: string; value: string }[];
+  price: number;
+  stock: number;
+  available_stock: number; // stock minus units held by checkouts in progress
+  image?: string;
+}
+
+interface ProductImage {
+  id: number;
+  url: string;
+  alt_text: string;
+  is_primary: boolean;
+}
+
 interface Product {
   id: string;
   name: string;
+  slug?: string;
   price: number;
   category: string;
   rating: number;
@@ -20,8 +43,13 @@ interface Product {
   description: string;
   image?: string;
   stock: number;
+  available_stock: number;
   brand: string;
   specifications?: ProductSpec[];
+  options?: ProductOption[];
+  variants?: ProductVariant[];
+  images?: ProductImage[];
+  deleted_at?: string;
 }
 
 export default function ProductDetailPage({ params }: { params: Promise<{ id: string }> }) {
@@ -31,6 +59,9 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
   const [loading, setLoading] = useState(true);
   const [imageError, setImageError] = useState(false);
   const [quantity, setQuantity] = useState(1);
+  // Chosen value per option name, for products with variants
+  const [selectedOptions, setSelectedOptions] = useState<Record<string, string>>({});
+  const [activeImage, setActiveImage] = useState<ProductImage | null>(null);
   const [activeTab, setActiveTab] = useState<'description' | 'specs' | 'reviews'>('description');
   const [showEditModal, setShowEditModal] = useState(false);
   const [showDeleteModal, setShowDeleteModal] = useState(false);
@@ -67,7 +98,7 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
   const fetchReviews = async () => {
     setReviewsLoading(true);
     try {
-      const res = await publicFetch(`${BACKEND}/api/products/${resolvedParams.id}/reviews`);
+      const res = await publicFetch(`${BACKEND}/api/products/${product?.id ?? resolvedParams.id}/reviews`);
       const data = await res.json();
       if (data.success) setReviews(data.data || []);
     } catch (e) {
@@ -81,11 +112,26 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
   const fetchProduct = async () => {
     setLoading(true);
     try {
-      const response = await publicFetch(`${BACKEND}/api/products/${resolvedParams.id}`);
+      // The route takes an id or a slug; old slugs redirect to the current one
+      const bySlug = !/^\d+$/.test(resolvedParams.id);
+      const url = bySlug
+        ? `${BACKEND}/api/products/by-slug/${encodeURIComponent(resolvedParams.id)}`
+        : `${BACKEND}/api/products/${resolvedParams.id}`;
+      // Archived products are only returned to admins, so they send their token
+      const stored = localStorage.getItem("user");
+      const asAdmin = stored ? (JSON.parse(stored) as { role?: string }).role === "admin" : false;
+      const response = await (asAdmin ? authFetch(url) : publicFetch(url));
       if (response.ok) {
         const data = await response.json();
         if (data.success && data.data) {
           setProduct(data.data);
+          if (bySlug && data.data.slug && data.data.slug !== resolvedParams.id) {
+            router.replace(`/products/${data.data.slug}`);
+          }
+          // Preselect the first variant that is in stock
+          const variants: ProductVariant[] = data.data.variants ?? [];
+          const first = variants.find((v) => v.available_stock > 0) ?? variants[0];
+          setSelectedOptions(first ? Object.fromEntries(first.options.map((o) => [o.name, o.value])) : {});
         }
       }
     } catch (error) {
@@ -108,8 +154,20 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
     return path.startsWith("http") ? path : `${BACKEND}${path}`;
   };
 
+  // Uploaded images are stored as large/medium/thumb renditions next to each other
+  const thumbSrc = (path: string) =>
+    imageSrc(path.startsWith("/assets/uploads/") ? path.replace(/_large\.(jpg|png)$/, "_thumb.$1") : path);
+
+  // Products with variants are bought as one variant, with its own price and stock
+  const hasVariants = (product?.variants?.length ?? 0) > 0;
+  const variant = product?.variants?.find((v) =>
+    v.options.every((o) => selectedOptions[o.name] === o.value)
+  );
+  const price = variant?.price ?? product?.price ?? 0;
+  const stock = hasVariants ? variant?.available_stock ?? 0 : product?.available_stock ?? 0;
+
   const handleAddToCart = async () => {
-    if (!product) return;
+    if (!product || (hasVariants && !variant)) return;
 
     // ── Demo mode: show notification, don't call API ──────────────────────────
     if (DEMO_MODE) {
@@ -130,15 +188,16 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
       const res = await authFetch(`${BACKEND}/api/users/${user.id}/cart`, {
         method: "POST",
         headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ product_id: String(product.id), quantity }),
+        body: JSON.stringify({ product_id: String(product.id), variant_id: variant?.id ?? 0, quantity }),
       });
       const data = await res.json();
       if (data.success) {
         window.dispatchEvent(new Event("cartUpdated"));
-        setSuccessMsg(`${product.name} (×${quantity}) ditambahkan ke keranjang!`);
+        const label = variant ? ` ${variant.options.map((o) => o.value).join(" / ")}` : "";
+        setSuccessMsg(`${product.name}${label} (×${quantity}) ditambahkan ke keranjang!`);
         setTimeout(() => setSuccessMsg(''), 3000);
       } else {
-        setSuccessMsg(data.message || "Failed to add to cart");
+        setSuccessMsg(data.error || data.message || "Failed to add to cart");
         setTimeout(() => setSuccessMsg(''), 3000);
       }
     } catch (err) {
@@ -148,6 +207,18 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
     }
   };
 
+  const handleRestore = async () => {
+    try {
+      const res = await authFetch(`${BACKEND}/api/products/${product?.id ?? resolvedParams.id}/restore`, { method: 'POST' });
+      const data = await res.json();
+      setSuccessMsg(res.ok && data.success ? 'Produk berhasil dipulihkan!' : `Error: ${data.error || 'Gagal memulihkan produk'}`);
+      if (res.ok && data.success) fetchProduct();
+    } catch {
+      setSuccessMsg("Error: could not reach server");
+    }
+    setTimeout(() => setSuccessMsg(''), 3000);
+  };
+
   const handleProductSaved = () => {
     setShowEditModal(false);
     setSuccessMsg('Produk berhasil diperbarui!');
@@ -186,7 +257,10 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
     );
   }
 
-  const fullImageSrc = imageSrc(product.image);
+  // A picked thumbnail wins over the variant's own image, which wins over the primary image
+  const gallery = product.images ?? [];
+  const fullImageSrc = imageSrc(activeImage?.url || variant?.image || product.image);
+  const fullImageAlt = activeImage?.alt_text || gallery.find((img) => img.is_primary)?.alt_text || product.name;
   const specs = product.specifications ?? [];
 
   return (
@@ -246,6 +320,21 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
           <span className="hidden sm:inline">Back to Products</span>
         </Link>
 
+        {/* Archived notice — the page stays reachable from orders and reviews */}
+        {product.deleted_at && (
+          <div className="mb-6 flex items-center justify-between gap-4 flex-wrap px-5 py-4 bg-amber-500/10 border border-amber-500/30 rounded-xl">
+            <span className="text-amber-300 text-sm font-medium">Produk ini sudah diarsipkan dan tidak dijual lagi.</span>
+            {role === 'admin' && (
+              <button
+                onClick={handleRestore}
+                className="px-4 py-2 bg-amber-500 hover:bg-amber-600 text-white rounded-lg text-sm font-semibold transition-colors"
+              >
+                Pulihkan Produk
+              </button>
+            )}
+          </div>
+        )}
+
         {/* Product Details */}
         <div className="grid grid-cols-1 lg:grid-cols-2 gap-8">
           {/* Image */}
@@ -253,7 +342,7 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
             <div className="relative bg-slate-800 border border-slate-700 rounded-2xl overflow-hidden">
               <div className="aspect-square relative">
                 {fullImageSrc && !imageError ? (
-                  <img src={fullImageSrc} alt={product.name} className="w-full h-full object-cover" onError={() => setImageError(true)} />
+                  <img src={fullImageSrc} alt={fullImageAlt} className="w-full h-full object-cover" onError={() => setImageError(true)} />
                 ) : (
                   <div className="w-full h-full flex items-center justify-center bg-slate-800">
                     <svg className="w-24 h-24 text-slate-600" fill="none" stroke="currentColor" viewBox="0 0 24 24">
@@ -263,11 +352,27 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
                 )}
               </div>
               <div className="absolute top-4 right-4">
-                <span className={`px-4 py-2 rounded-lg text-sm font-bold backdrop-blur-sm shadow-lg ${product.stock > 20 ? 'bg-green-500/90 text-white' : product.stock > 0 ? 'bg-amber-500/90 text-white' : 'bg-red-500/90 text-white'}`}>
-                  {product.stock > 0 ? `${product.stock} in stock` : 'Out of stock'}
+                <span className={`px-4 py-2 rounded-lg text-sm font-bold backdrop-blur-sm shadow-lg ${stock > 20 ? 'bg-green-500/90 text-white' : stock > 0 ? 'bg-amber-500/90 text-white' : 'bg-red-500/90 text-white'}`}>
+                  {stock > 0 ? `${stock} in stock` : 'Out of stock'}
                 </span>
               </div>
             </div>
+            {gallery.length > 1 && (
+              <div className="grid grid-cols-5 gap-3">
+                {gallery.map((img) => {
+                  const active = activeImage ? activeImage.id === img.id : !variant?.image && img.is_primary;
+                  return (
+                    <button
+                      key={img.id}
+                      onClick={() => { setActiveImage(img); setImageError(false); }}
+                      className={`aspect-square rounded-xl overflow-hidden border-2 bg-slate-800 transition-all ${active ? 'border-primary-400' : 'border-slate-700 hover:border-slate-500'}`}
+                    >
+                      <img src={thumbSrc(img.url) ?? ''} alt={img.alt_text || product.name} className="w-full h-full object-cover" />
+                    </button>
+                  );
+                })}
+              </div>
+            )}
           </div>
 
           {/* Info */}
@@ -277,8 +382,8 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
                 <span className="px-4 py-2 bg-gradient-to-r from-primary-400/20 to-secondary-400/20 border border-primary-400/30 text-primary-400 rounded-full text-sm font-semibold">{product.category}</span>
                 {product.brand && <span className="text-slate-400 text-sm">by <span className="text-white font-semibold">{product.brand}</span></span>}
               </div>
-              {/* Delete button — admin only */}
-              {role === 'admin' && (
+              {/* Archive button — admin only */}
+              {role === 'admin' && !product.deleted_at && (
                 <button
                   onClick={() => setShowDeleteModal(true)}
                   className="inline-flex items-center gap-2 px-3 py-1.5 bg-red-500/10 hover:bg-red-500/20 border border-red-500/30 hover:border-red-500/60 text-red-400 hover:text-red-300 rounded-lg text-sm transition-all"
@@ -304,28 +409,59 @@ export default function ProductDetailPage({ params }: { params: Promise<{ id: st
               </span>
             </div>
             <div className="border-t border-b border-slate-700 py-4 md:py-6">
-              <p className="text-3xl md:text-4xl font-bold bg-gradient-to-r from-primary-400 to-secondary-400 bg-clip-text text-transparent">{formatPrice(product.price)}</p>
-              <p className="text-slate-400 text-sm mt-1">Inclusive of all taxes</p>
+              <p className="text-3xl md:text-4xl font-bold bg-gradient-to-r from-primary-400 to-secondary-400 bg-clip-text text-transparent">{formatPrice(price)}</p>
+              <p className="text-slate-400 text-sm mt-1">
+                Inclusive of all taxes{variant && <span className="ml-2 text-slate-500">SKU {variant.sku}</span>}
+              </p>
             </div>
+            {/* Variant picker */}
+            {hasVariants && product.options?.map((option) => (
+              <div key={option.name} className="space-y-2">
+                <label className="text-white font-semibold">{option.name}</label>
+                <div className="flex flex-wrap gap-2">
+                  {option.values.map((value) => {
+                    const active = selectedOptions[option.name] === value;
+                    // A value is available when some variant with it (and the other choices) exists
+                    const available = product.variants!.some((v) =>
+                      v.options.every((o) => o.name === option.name ? o.value === value : selectedOptions[o.name] === o.value)
+                    );
+                    return (
+                      <button
+                        key={value}
+                        onClick={() => { setSelectedOptions({ ...selectedOptions, [option.name]: value }); setQuantity(1); setActiveImage(null); setImageError(false); }}
+                        className={`px-4 py-2 rounded-lg border text-sm font-medium transition-all ${active
+                          ? 'border-primary-400 bg-primary-400/10 text-primary-400'
+                          : available
+                            ? 'border-slate-700 bg-slate-800 text-slate-300 hover:border-slate-500'
+                            : 'border-slate-800 bg-slate-900 text-slate-600 line-through'
+                          }`}
+                      >
+                        {value}
+                      </button>
+                    );
+                  })}
+                </div>
+              </div>
+            ))}
             {/* Quantity + Add to Cart — customer only */}
-            {role === 'customer' && (
+            {role === 'customer' && !product.deleted_at && (
               <>
                 <div className="space-y-3">
                   <label className="text-white font-semibold">Quantity</label>
                   <div className="flex items-center gap-3">
-                    <button onClick={() => setQuantity(Math.max(1, quantity - 1))} disabled={product.stock === 0} className="w-12 h-12 flex items-center justify-center bg-slate-800 hover:bg-slate-700 disabled:bg-slate-900 disabled:cursor-not-allowed border border-slate-700 rounded-lg text-white transition-all">
+                    <button onClick={() => setQuantity(Math.max(1, quantity - 1))} disabled={stock === 0} className="w-12 h-12 flex items-center justify-center bg-slate-800 hover:bg-slate-700 disabled:bg-slate-900 disabled:cursor-not-allowed border border-slate-700 rounded-lg text-white transition-all">
                       <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M20 12H4" /></svg>
                     </button>
                     <span className="w-16 text-center text-white font-bold text-xl">{quantity}</span>
-                    <button onClick={() => setQuantity(Math.min(product.stock, quantity + 1))} disabled={product.stock === 0} className="w-12 h-12 flex items-center justify-center bg-slate-800 hover:bg-slate-700 disabled:bg-slate-900 disabled:cursor-not-allowed border border-slate-700 rounded-lg text-white transition-all">
+                    <button onClick={() => setQuantity(Math.min(stock, quantity + 1))} disabled={stock === 0} className="w-12 h-12 flex items-center justify-center bg-slate-800 hover:bg-slate-700 disabled:bg-slate-900 disabled:cursor-not-allowed border border-slate-700 rounded-lg text-white transition-all">
                       <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 4v16m8-8H4" /></svg>
                     </button>
-                    <span className="text-slate-400 text-sm ml-2">({product.stock} available)</span>
+                    <span className="text-slate-400 text-sm ml-2">({stock} available)</span>
                   </div>
                 </div>
                 <div className="flex gap-3">
-                  <button onClick={handleAddToCart} disabled={product.stock === 0} className="flex-1 py-4 bg-gradient-to-r from-primary-400 to-secondary-400 hover:from-primary-500 hover:to-secondary-500 disabled:from-slate-600 disabled:to-slate-700 text-white font-bold rounded-lg transition-all duration-300 disabled:cursor-not-allowed">
-                    {product.stock === 0 ? 'Out of Stock' : 'Add to Cart'}
+                  <button onClick={handleAddToCart} disabled={stock === 0} className="flex-1 py-4 bg-gradient-to-r from-primary-400 to-secondary-400 hover:from-primary-500 hover:to-secondary-500 disabled:from-slate-600 disabled:to-slate-700 text-white font-bold rounded-lg transition-all duration-300 disabled:cursor-not-allowed">
+                    {hasVariants && !variant ? 'Unavailable' : stock === 0 ? 'Out of Stock' : 'Add to Cart'}
                   </button>
                 </div>
               </>
@@ -478,10 +614,10 @@ function DeleteConfirmModal({ productName, productId, onClose, onDeleted }: Dele
     try {
       const res = await authFetch(`${BACKEND}/api/products/${productId}`, { method: 'DELETE' });
       const data = await res.json();
-      if (!res.ok || !data.success) throw new Error(data.error || 'Gagal menghapus produk');
+      if (!res.ok || !data.success) throw new Error(data.error || 'Gagal mengarsipkan produk');
       onDeleted();
     } catch (err: unknown) {
-      setError(err instanceof Error ? err.message : 'Gagal menghapus produk');
+      setError(err instanceof Error ? err.message : 'Gagal mengarsipkan produk');
       setLoading(false);
     }
   };
@@ -500,15 +636,15 @@ function DeleteConfirmModal({ productName, productId, onClose, onDeleted }: Dele
         </div>
 
         {/* Text */}
-        <h2 className="text-xl font-bold text-white text-center mb-2">Hapus Produk</h2>
+        <h2 className="text-xl font-bold text-white text-center mb-2">Arsipkan Produk</h2>
         <p className="text-slate-400 text-center text-sm mb-1">
-          Apakah kamu yakin ingin menghapus produk ini?
+          Apakah kamu yakin ingin mengarsipkan produk ini?
         </p>
         <p className="text-white font-semibold text-center text-sm mb-6 px-4 py-2 bg-slate-800 rounded-lg border border-slate-700">
           &quot;{productName}&quot;
         </p>
         <p className="text-red-400/80 text-xs text-center mb-5">
-          Tindakan ini tidak dapat diurungkan. Semua data produk termasuk spesifikasi akan dihapus permanen.
+          Produk akan disembunyikan dari katalog, pencarian, dan keranjang, tetapi tetap tercatat di riwayat pesanan dan ulasan. Produk bisa dipulihkan kapan saja.
         </p>
 
         {error && (
@@ -533,7 +669,7 @@ function DeleteConfirmModal({ productName, productId, onClose, onDeleted }: Dele
             disabled={loading}
             className="flex-1 py-3 bg-red-500 hover:bg-red-600 disabled:bg-red-800 disabled:cursor-not-allowed text-white rounded-lg font-semibold transition-colors"
           >
-            {loading ? 'Menghapus...' : 'Ya, Hapus'}
+            {loading ? 'Mengarsipkan...' : 'Ya, Arsipkan'}
           </button>
         </div>
       </div>
@@ -579,10 +715,10 @@ function EditProductModal({ product, onClose, onSaved }: EditProductModalProps)
     rating: String(product.rating ?? 0),
   });
 
-  // Parse RAM: e.g. "8 GB DDR5" or "8 GB"
+  // Parse RAM: e.g. "8 GB" (older laptops stored "8 GB DDR5" in one row)
   const ramRaw = sv('RAM');
   const ramGB = parseNum(ramRaw);
-  const ramDdr = ramRaw.includes('DDR4') ? 'DDR4' : 'DDR5';
+  const ramDdr = (sv('RAM Type') || ramRaw).includes('DDR4') ? 'DDR4' : 'DDR5';
 
   // Parse ROM: e.g. "256 GB" or "1 TB SSD"
   const romRaw = sv('ROM');
@@ -601,11 +737,11 @@ function EditProductModal({ product, onClose, onSaved }: EditProductModalProps)
   const batteryRaw = sv('Battery');
   const batteryMah = parseNum(batteryRaw);
 
-  // Parse Laptop storage: e.g. "512 GB SSD" or "1 TB HDD"
+  // Parse Laptop storage: e.g. "512 GB" + Storage Type, or older "1 TB HDD"
   const storageRaw = sv('ROM');
   const storageValue = parseNum(storageRaw);
   const storageUnit = storageRaw.includes('TB') ? 'TB' : 'GB';
-  const storageType = storageRaw.includes('HDD') ? 'HDD' : 'SSD';
+  const storageType = (sv('Storage Type') || storageRaw).includes('HDD') ? 'HDD' : 'SSD';
 
   // Parse Laptop battery: e.g. "56 Wh"
   const batteryWhRaw = sv('Battery');
@@ -706,6 +842,10 @@ function EditProductModal({ product, onClose, onSaved }: EditProductModalProps)
           } else if (uploadData.url) {
             finalImageUrl = uploadData.url;
           }
+        } else {
+          // The server rejects files that are not real JPEG/PNG/WebP images or are too small/large
+          const uploadErr = await uploadRes.json().catch(() => null);
+          throw new Error(uploadErr?.error || 'Gagal mengunggah gambar');
         }
       }
 
@@ -870,9 +1010,9 @@ function EditProductModal({ product, onClose, onSaved }: EditProductModalProps)
                           <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 16l4.586-4.586a2 2 0 012.828 0L16 16m-2-2l1.586-1.586a2 2 0 012.828 0L20 14m-6-6h.01M6 20h12a2 2 0 002-2V6a2 2 0 00-2-2H6a2 2 0 00-2 2v12a2 2 0 002 2z" />
                         </svg>
                         <p className="text-slate-400 text-sm">{imageFile ? imageFile.name : displayImage ? 'Klik untuk ganti gambar' : 'Klik untuk upload gambar'}</p>
-                        <p className="text-slate-500 text-xs mt-1">JPG, PNG (Max 5MB)</p>
+                        <p className="text-slate-500 text-xs mt-1">JPG, PNG, WEBP (min. 100×100 px, max 10MB)</p>
                       </div>
-                      <input type="file" accept="image/*" onChange={handleImageChange} className="hidden" />
+                      <input type="file" accept="image/jpeg,image/png,image/webp" onChange={handleImageChange} className="hidden" />
                     </label>
                   </div>
                 </div>
@@ -7,44 +7,146 @@ import { authFetch, BACKEND, publicFetch, getImageUrl } from "../../utils/api";
 interface Product {
   id: string;
   name: string;
+  slug?: string;
   price: number;
   category: string;
   rating: number;
   total_reviews: number;
   stock: number;
+  available_stock: number; // stock minus units held by checkouts in progress
   image?: string;
 }
 
+interface Suggestion {
+  text: string;
+  type: "product" | "brand" | "category";
+  product_id?: number;
+}
+
+interface SpecFacet {
+  key: string;
+  type: "text" | "number" | "boolean" | "enum";
+  unit?: string;
+  values: { value: string; count: number }[];
+}
+
+const PAGE_SIZE = 24;
+
 export default function ProductsPage() {
   const [products, setProducts] = useState<Product[]>([]);
+  const [page, setPage] = useState(1);
+  const [totalPages, setTotalPages] = useState(1);
+  const [totalProducts, setTotalProducts] = useState(0);
   const [loading, setLoading] = useState(true);
   const [imageErrors, setImageErrors] = useState<Record<string, boolean>>({});
   const [searchQuery, setSearchQuery] = useState("");
+  const [suggestions, setSuggestions] = useState<Suggestion[]>([]);
+  const [showSuggestions, setShowSuggestions] = useState(false);
   const [selectedCategory, setSelectedCategory] = useState("All");
   const [sortOrder, setSortOrder] = useState<"asc" | "desc">("asc");
   const [showPriceFilter, setShowPriceFilter] = useState(false);
   const [minPrice, setMinPrice] = useState("");
   const [maxPrice, setMaxPrice] = useState("");
+  const [specFacets, setSpecFacets] = useState<SpecFacet[]>([]);
+  const [specFilters, setSpecFilters] = useState<Record<string, string[]>>({});
   const [showAddModal, setShowAddModal] = useState(false);
   const [role, setRole] = useState<string>("customer");
 
-  const categories = ["All", "Smartphones", "Laptops", "Audio"];
+  const [categories, setCategories] = useState<string[]>(["All", "Smartphones", "Laptops", "Audio"]);
+
+  // Top-level categories from the category tree; subcategories are included when filtering by a parent
+  useEffect(() => {
+    publicFetch(`${BACKEND}/api/categories`)
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => {
+        if (data?.success && Array.isArray(data.data) && data.data.length > 0) {
+          setCategories(["All", ...data.data.map((c: { name: string }) => c.name)]);
+        }
+      })
+      .catch(() => { /* keep the defaults */ });
+  }, []);
 
   useEffect(() => {
     try {
       const raw = localStorage.getItem("user");
       if (raw) setRole((JSON.parse(raw) as { role?: string }).role ?? "customer");
     } catch { /* ignore */ }
-    fetchProducts();
   }, []);
 
-  const fetchProducts = async () => {
+  // Spec values differ per category, so a new category starts without spec filters
+  useEffect(() => {
+    setSpecFilters((prev) => (Object.keys(prev).length > 0 ? {} : prev));
+  }, [selectedCategory]);
+
+  // Category, price range, spec filters and sort are applied by the backend; reload from page 1 when they change
+  useEffect(() => {
+    fetchProducts(1);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [selectedCategory, minPrice, maxPrice, sortOrder, specFilters]);
+
+  const toggleSpecValue = (key: string, value: string) => {
+    setSpecFilters((prev) => {
+      const current = prev[key] ?? [];
+      const next = current.includes(value) ? current.filter((v) => v !== value) : [...current, value];
+      const updated = { ...prev, [key]: next };
+      if (next.length === 0) delete updated[key];
+      return updated;
+    });
+  };
+
+  // Autocomplete: ask the backend for completions once typing pauses
+  useEffect(() => {
+    const q = searchQuery.trim();
+    if (!q) {
+      setSuggestions([]);
+      return;
+    }
+    const timer = setTimeout(async () => {
+      try {
+        const response = await publicFetch(`${BACKEND}/api/products/suggest?q=${encodeURIComponent(q)}`);
+        if (response.ok) {
+          const data = await response.json();
+          setSuggestions(data.success && Array.isArray(data.data) ? data.data : []);
+        }
+      } catch {
+        setSuggestions([]);
+      }
+    }, 150);
+    return () => clearTimeout(timer);
+  }, [searchQuery]);
+
+  const pickSuggestion = (s: Suggestion) => {
+    setShowSuggestions(false);
+    if (s.type === "category" && categories.includes(s.text)) {
+      setSelectedCategory(s.text);
+      setSearchQuery("");
+    } else {
+      setSearchQuery(s.text);
+    }
+  };
+
+  const fetchProducts = async (pageToLoad = 1) => {
+    const params = new URLSearchParams({
+      page: String(pageToLoad),
+      limit: String(PAGE_SIZE),
+      sort: sortOrder === "asc" ? "price_asc" : "price_desc",
+    });
+    if (selectedCategory !== "All") params.set("category", selectedCategory);
+    if (minPrice && Number(minPrice) > 0) params.set("min_price", String(Math.floor(Number(minPrice))));
+    if (maxPrice && Number(maxPrice) > 0) params.set("max_price", String(Math.floor(Number(maxPrice))));
+    Object.entries(specFilters).forEach(([key, values]) => params.set(`spec[${key}]`, values.join(",")));
+
     try {
-      const response = await publicFetch(`${BACKEND}/api/products`);
+      const response = await publicFetch(`${BACKEND}/api/products?${params}`);
       if (response.ok) {
         const data = await response.json();
         if (data.success && data.data) {
-          setProducts(data.data);
+          const list: Product[] = data.data.products ?? [];
+          setProducts((prev) => (pageToLoad === 1 ? list : [...prev, ...list]));
+          setPage(pageToLoad);
+          setTotalPages(data.data.pagination?.total_pages ?? 1);
+          setTotalProducts(data.data.pagination?.total ?? list.length);
+          setSpecFacets(data.data.facets?.specs ?? []);
         }
       }
     } catch (error) {
@@ -62,18 +164,10 @@ export default function ProductsPage() {
     }).format(price);
   };
 
-  const filteredProducts = products
-    .filter((product) => {
-      const matchesSearch = product.name.toLowerCase().includes(searchQuery.toLowerCase());
-      const matchesCategory = selectedCategory === "All" || product.category === selectedCategory;
-      const minPriceNum = minPrice ? parseFloat(minPrice) : 0;
-      const maxPriceNum = maxPrice ? parseFloat(maxPrice) : Infinity;
-      const matchesPrice = product.price >= minPriceNum && product.price <= maxPriceNum;
-      return matchesSearch && matchesCategory && matchesPrice;
-    })
-    .sort((a, b) => {
-      return sortOrder === "asc" ? a.price - b.price : b.price - a.price;
-    });
+  // The search box narrows the pages loaded so far
+  const filteredProducts = products.filter((product) =>
+    product.name.toLowerCase().includes(searchQuery.toLowerCase())
+  );
 
   if (loading) {
     return (
@@ -190,9 +284,41 @@ export default function ProductsPage() {
               type="text"
               placeholder="Search products..."
               value={searchQuery}
-              onChange={(e) => setSearchQuery(e.target.value)}
+              onChange={(e) => {
+                setSearchQuery(e.target.value);
+                setShowSuggestions(true);
+              }}
+              onFocus={() => setShowSuggestions(true)}
+              onBlur={() => setTimeout(() => setShowSuggestions(false), 150)}
               className="w-full bg-slate-800/50 border border-slate-700 rounded-lg pl-12 pr-4 py-3 text-white placeholder-slate-400 focus:outline-none focus:ring-2 focus:ring-primary-400/50 transition-all"
             />
+            {showSuggestions && suggestions.length > 0 && (
+              <ul className="absolute left-0 right-0 top-full mt-2 bg-slate-900 border border-slate-700 rounded-lg shadow-2xl z-30 overflow-hidden">
+                {suggestions.map((s) => (
+                  <li key={`${s.type}-${s.product_id ?? s.text}`}>
+                    {s.type === "product" && s.product_id ? (
+                      <Link
+                        href={`/products/${s.product_id}`}
+                        className="flex items-center justify-between px-4 py-2 text-sm text-white hover:bg-slate-800 transition-colors"
+                      >
+                        <span>{s.text}</span>
+                        <span className="text-xs text-slate-500">Product</span>
+                      </Link>
+                    ) : (
+                      <button
+                        type="button"
+                        onMouseDown={(e) => e.preventDefault()}
+                        onClick={() => pickSuggestion(s)}
+                        className="w-full flex items-center justify-between px-4 py-2 text-sm text-white hover:bg-slate-800 transition-colors text-left"
+                      >
+                        <span>{s.text}</span>
+                        <span className="text-xs text-slate-500">{s.type === "brand" ? "Brand" : "Category"}</span>
+                      </button>
+                    )}
+                  </li>
+                ))}
+              </ul>
+            )}
           </div>
 
           {/* Filter Controls */}
@@ -259,20 +385,48 @@ export default function ProductsPage() {
               )}
             </button>
           </div>
+
+          {/* Spec facets of the selected category */}
+          {selectedCategory !== "All" && specFacets.some((f) => f.values.length > 1) && (
+            <div className="space-y-2 pt-2 border-t border-slate-800">
+              {specFacets.filter((f) => f.values.length > 1).map((facet) => (
+                <div key={facet.key} className="flex items-center gap-2 flex-wrap">
+                  <span className="text-slate-400 text-xs md:text-sm font-medium min-w-[110px]">{facet.key}:</span>
+                  {facet.values.map((v) => {
+                    const active = specFilters[facet.key]?.includes(v.value) ?? false;
+                    return (
+                      <button
+                        key={v.value}
+                        onClick={() => toggleSpecValue(facet.key, v.value)}
+                        className={`px-3 py-1 rounded-lg text-xs font-medium transition-all ${active
+                          ? "bg-gradient-to-r from-primary-400/20 to-secondary-400/20 text-primary-400 border border-primary-400/30"
+                          : "bg-slate-800 text-slate-400 border border-slate-700 hover:text-white hover:border-slate-600"
+                          }`}
+                      >
+                        {v.value} <span className="text-slate-500">({v.count})</span>
+                      </button>
+                    );
+                  })}
+                </div>
+              ))}
+            </div>
+          )}
         </div>
 
         {/* Results Count */}
         <div className="flex items-center justify-between">
           <p className="text-slate-400 text-sm">
-            Showing <span className="text-white font-semibold">{filteredProducts.length}</span> {filteredProducts.length === 1 ? 'product' : 'products'}
+            Showing <span className="text-white font-semibold">{filteredProducts.length}</span> of{" "}
+            <span className="text-white font-semibold">{totalProducts}</span> {totalProducts === 1 ? 'product' : 'products'}
           </p>
-          {(searchQuery || selectedCategory !== "All" || minPrice || maxPrice) && (
+          {(searchQuery || selectedCategory !== "All" || minPrice || maxPrice || Object.keys(specFilters).length > 0) && (
             <button
               onClick={() => {
                 setSearchQuery("");
                 setSelectedCategory("All");
                 setMinPrice("");
                 setMaxPrice("");
+                setSpecFilters({});
               }}
               className="text-sm text-primary-400 hover:text-primary-300 transition-colors font-medium"
             >
@@ -286,7 +440,7 @@ export default function ProductsPage() {
           {filteredProducts.map((product) => (
             <Link
               key={product.id}
-              href={`/products/${product.id}`}
+              href={`/products/${product.slug || product.id}`}
               className="group bg-slate-900 rounded-xl overflow-hidden border border-slate-800 hover:border-primary-400/30 transition-all duration-300 hover:shadow-xl hover:shadow-primary-400/10"
             >
               {/* Image Container */}
@@ -312,9 +466,9 @@ export default function ProductsPage() {
                 </div>
 
                 {/* Stock Badge */}
-                {product.stock > 0 && product.stock <= 5 && (
+                {product.available_stock > 0 && product.available_stock <= 5 && (
                   <div className="absolute top-3 right-3 px-2 py-1 bg-amber-500/90 backdrop-blur-sm rounded-md text-white text-xs font-semibold">
-                    Only {product.stock} left
+                    Only {product.available_stock} left
                   </div>
                 )}
               </div>
@@ -350,7 +504,7 @@ export default function ProductsPage() {
                   <p className="text-primary-400 font-bold text-lg">
                     {formatPrice(product.price)}
                   </p>
-                  {product.stock === 0 ? (
+                  {product.available_stock === 0 ? (
                     <span className="text-red-400 text-xs font-medium">Out of Stock</span>
                   ) : (
                     <span className="text-green-400 text-xs font-medium">In Stock</span>
@@ -361,6 +515,18 @@ export default function ProductsPage() {
           ))}
         </div>
 
+        {/* Load More */}
+        {page < totalPages && (
+          <div className="flex justify-center">
+            <button
+              onClick={() => fetchProducts(page + 1)}
+              className="px-6 py-3 bg-slate-800 hover:bg-slate-700 text-white border border-slate-700 rounded-lg font-medium transition-colors"
+            >
+              Load more
+            </button>
+          </div>
+        )}
+
         {/* Empty State */}
         {filteredProducts.length === 0 && (
           <div className="text-center py-20">
@@ -391,7 +557,7 @@ export default function ProductsPage() {
         <AddProductModal
           onClose={() => setShowAddModal(false)}
           onProductAdded={() => {
-            fetchProducts();
+            fetchProducts(1);
             setShowAddModal(false);
           }}
         />
@@ -584,6 +750,10 @@ function AddProductModal({ onClose, onProductAdded }: AddProductModalProps) {
           } else if (uploadData.url) {
             imageUrl = uploadData.url;
           }
+        } else {
+          // The server rejects files that are not real JPEG/PNG/WebP images or are too small/large
+          const uploadErr = await uploadResponse.json().catch(() => null);
+          throw new Error(uploadErr?.error || 'Failed to upload image');
         }
       }
 
@@ -801,11 +971,11 @@ function AddProductModal({ onClose, onProductAdded }: AddProductModalProps) {
                           <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 16l4.586-4.586a2 2 0 012.828 0L16 16m-2-2l1.586-1.586a2 2 0 012.828 0L20 14m-6-6h.01M6 20h12a2 2 0 002-2V6a2 2 0 00-2-2H6a2 2 0 00-2 2v12a2 2 0 002 2z" />
                         </svg>
                         <p className="text-slate-400 text-sm">{imagePreview ? 'Klik untuk ganti gambar' : 'Klik untuk upload gambar'}</p>
-                        <p className="text-slate-500 text-xs mt-1">JPG, PNG (Max 5MB)</p>
+                        <p className="text-slate-500 text-xs mt-1">JPG, PNG, WEBP (min. 100×100 px, max 10MB)</p>
                       </div>
                       <input
                         type="file"
-                        accept="image/*"
+                        accept="image/jpeg,image/png,image/webp"
                         onChange={handleImageChange}
                         className="hidden"
                       />
@@ -220,7 +220,7 @@ export default function ProfilePage() {
                   <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 13a3 3 0 11-6 0 3 3 0 016 0z" />
                 </svg>
               </button>
-              <input ref={fileInputRef} type="file" accept="image/*" className="hidden" onChange={handleAvatarChange} />
+              <input ref={fileInputRef} type="file" accept="image/jpeg,image/png,image/webp" className="hidden" onChange={handleAvatarChange} />
             </div>
             <div className="text-center sm:text-left">
               <button
@@ -237,7 +237,7 @@ export default function ProfilePage() {
                   Remove
                 </button>
               )}
-              <p className="text-slate-500 text-xs mt-2">JPG, PNG, WEBP — max 5MB</p>
+              <p className="text-slate-500 text-xs mt-2">JPG, PNG, WEBP — min. 100×100 px, max 10MB</p>
             </div>
           </div>
         </div>
@@ -52,7 +52,7 @@ export default function LoginModal({ isOpen, onClose, onRegisterClick }: LoginMo
       console.log("Response data:", data);
 
       if (!response.ok) {
-        throw new Error(data.message || "Login gagal");
+        throw new Error(data.error || data.message || "Login gagal");
       }
 
       // Success - save user data and JWT token to localStorage
@@ -62,6 +62,9 @@ export default function LoginModal({ isOpen, onClose, onRegisterClick }: LoginMo
       if (data.data && data.data.token) {
         localStorage.setItem("token", data.data.token);
       }
+      if (data.data && data.data.refresh_token) {
+        localStorage.setItem("refreshToken", data.data.refresh_token);
+      }
 
       toast.success("Login berhasil!");
 
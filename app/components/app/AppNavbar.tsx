@@ -90,8 +90,18 @@ export default function AppNavbar({ onToggleSidebar }: AppNavbarProps) {
   };
 
   const handleLogout = () => {
+    // Revoke the session server-side; local state is cleared regardless of the result
+    fetch(`${BACKEND}/api/auth/logout`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        Authorization: `Bearer ${localStorage.getItem("token") ?? ""}`,
+      },
+      body: JSON.stringify({ refresh_token: localStorage.getItem("refreshToken") ?? "" }),
+    }).catch(() => {});
     localStorage.removeItem("user");
     localStorage.removeItem("token");
+    localStorage.removeItem("refreshToken");
     localStorage.removeItem("rememberMe");
     router.push("/");
   };
@@ -268,9 +268,9 @@ export default function FeaturedCategories({
       try {
         let BACKEND = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
         if (BACKEND.endsWith("/api")) BACKEND = BACKEND.slice(0, -4);
-        const res = await fetch(`${BACKEND}/api/products`);
+        const res = await fetch(`${BACKEND}/api/products?limit=100&sort=best_selling`);
         const json = await res.json();
-        const all: Product[] = json.data ?? [];
+        const all: Product[] = json.data?.products ?? [];
 
         // Group by category
         setPhones(
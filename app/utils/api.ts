@@ -25,13 +25,45 @@ export function getImageUrl(path: string) {
   return `${BACKEND}/${path}`;
 }
 
+/**
+ * refreshSession — exchanges the stored refresh token for a new token pair.
+ * Concurrent callers share one in-flight request so the rotating refresh token
+ * is only used once. Resolves to false when the session cannot be renewed.
+ */
+let refreshing: Promise<boolean> | null = null;
+function refreshSession(): Promise<boolean> {
+  const refreshToken = typeof window !== "undefined" ? localStorage.getItem("refreshToken") : null;
+  if (!refreshToken) return Promise.resolve(false);
+  if (!refreshing) {
+    refreshing = fetch(`${BACKEND}/api/auth/refresh`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    })
+      .then(async (res) => {
+        if (!res.ok) return false;
+        const data = await res.json();
+        localStorage.setItem("token", data.data.token);
+        localStorage.setItem("refreshToken", data.data.refresh_token);
+        return true;
+      })
+      .catch(() => false)
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+}
+
 /**
  * authFetch — fetch wrapper that automatically attaches the JWT Bearer token
  * stored in localStorage. Use for any API call to a protected endpoint.
+ * Access tokens are short-lived: on a 401 the session is refreshed once and
+ * the request retried before the user is sent back to /login.
  *
  * In DEMO_MODE all requests are intercepted by mockFetch — no real HTTP calls are made.
  */
-export function authFetch(url: string, options: RequestInit = {}): Promise<Response> {
+export function authFetch(url: string, options: RequestInit = {}, retried = false): Promise<Response> {
   // ── Demo mode: return mock response without touching the network ──────────
   if (DEMO_MODE) {
     return mockFetch(url, options);
@@ -52,13 +84,15 @@ export function authFetch(url: string, options: RequestInit = {}): Promise<Respo
     headers["Authorization"] = `Bearer ${token}`;
   }
 
-  return fetch(url, { ...options, headers }).then((res) => {
-    if (res.status === 401) {
-      if (typeof window !== "undefined") {
-        localStorage.removeItem("token");
-        localStorage.removeItem("user");
-        window.location.href = "/login";
+  return fetch(url, { ...options, headers }).then(async (res) => {
+    if (res.status === 401 && typeof window !== "undefined") {
+      if (!retried && (await refreshSession())) {
+        return authFetch(url, options, true);
       }
+      localStorage.removeItem("token");
+      localStorage.removeItem("refreshToken");
+      localStorage.removeItem("user");
+      window.location.href = "/login";
     }
     return res;
   });
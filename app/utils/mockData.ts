@@ -268,8 +268,8 @@ export const MOCK_ORDER_DETAILS: Record<string, object> = {
 
 // ─── Cart ─────────────────────────────────────────────────────────────────────
 export const MOCK_CART = [
-  { product_id: "19", quantity: 1 },
-  { product_id: "40", quantity: 2 },
+  { product_id: "19", variant_id: 0, quantity: 1 },
+  { product_id: "40", variant_id: 0, quantity: 2 },
 ];
 
 // ─── Vouchers ─────────────────────────────────────────────────────────────────
@@ -56,10 +56,22 @@ export function mockFetch(url: string, options: RequestInit = {}): Promise<Respo
 
   // ── Products ──────────────────────────────────────────────────────────────
   if (path === "/api/products" && method === "GET") {
-    return ok(MOCK_PRODUCTS);
+    return ok({
+      products: MOCK_PRODUCTS,
+      pagination: { page: 1, limit: MOCK_PRODUCTS.length, total: MOCK_PRODUCTS.length, total_pages: 1 },
+    });
   }
   if (path === "/api/products/search") {
-    return ok(MOCK_PRODUCTS);
+    return ok({
+      products: MOCK_PRODUCTS,
+      pagination: { page: 1, limit: MOCK_PRODUCTS.length, total: MOCK_PRODUCTS.length, total_pages: 1 },
+      facets: { category: [], brand: [], price: [] },
+    });
+  }
+  if (path === "/api/products/suggest") {
+    const q = (new URL(url, "http://localhost").searchParams.get("q") ?? "").toLowerCase();
+    const names = MOCK_PRODUCTS.filter(p => q && p.name.toLowerCase().includes(q)).slice(0, 8);
+    return ok(names.map(p => ({ text: p.name, type: "product", product_id: Number(p.id) })));
   }
   const productMatch = path.match(/^\/api\/products\/([^/]+)$/);
   if (productMatch && method === "GET") {
@@ -79,6 +91,16 @@ export function mockFetch(url: string, options: RequestInit = {}): Promise<Respo
     return ok({ message: "Product created (demo mode — not persisted)" }, "Created");
   }
 
+  const variantsMatch = path.match(/^\/api\/products\/([^/]+)\/variants$/);
+  if (variantsMatch && method === "GET") {
+    return ok({ options: [], variants: [] });
+  }
+
+  const imagesMatch = path.match(/^\/api\/products\/([^/]+)\/images$/);
+  if (imagesMatch && method === "GET") {
+    return ok([]);
+  }
+
   // Product reviews
   const reviewMatch = path.match(/^\/api\/products\/([^/]+)\/reviews$/);
   if (reviewMatch) {
@@ -88,6 +110,18 @@ export function mockFetch(url: string, options: RequestInit = {}): Promise<Respo
     ]);
   }
 
+  // ── Categories ────────────────────────────────────────────────────────────
+  if (path === "/api/categories" && method === "GET") {
+    return ok(["Smartphones", "Laptops", "Audio"].map((name, i) => ({
+      id: i + 1, parent_id: null, name, slug: name.toLowerCase(), sort_order: i + 1,
+      product_count: MOCK_PRODUCTS.filter(p => p.category === name).length,
+    })));
+  }
+  const specTemplateMatch = path.match(/^\/api\/categories\/(\d+)\/spec-template$/);
+  if (specTemplateMatch && method === "GET") {
+    return ok({ category_id: Number(specTemplateMatch[1]), fields: [] });
+  }
+
   // ── Vouchers ──────────────────────────────────────────────────────────────
   if (path === "/api/vouchers" && method === "GET") {
     return ok(MOCK_VOUCHERS);
@@ -180,25 +214,27 @@ export function mockFetch(url: string, options: RequestInit = {}): Promise<Respo
   if (cartListMatch && method === "POST") {
     try {
       const body = JSON.parse(options.body as string);
-      const existing = mockCart.find(c => c.product_id === body.product_id);
+      const variantId = body.variant_id ?? 0;
+      const existing = mockCart.find(c => c.product_id === body.product_id && c.variant_id === variantId);
       if (existing) {
         existing.quantity += body.quantity ?? 1;
       } else {
-        mockCart.push({ product_id: body.product_id, quantity: body.quantity ?? 1 });
+        mockCart.push({ product_id: body.product_id, variant_id: variantId, quantity: body.quantity ?? 1 });
       }
     } catch { /* ignore */ }
     return ok(mockCart, "Added to cart");
   }
 
   const cartItemMatch = path.match(/^\/api\/users\/(\d+)\/cart\/([^/]+)$/);
+  const cartVariantId = Number(new URL(url, "http://localhost").searchParams.get("variant_id") ?? 0);
   if (cartItemMatch && method === "DELETE") {
-    mockCart = mockCart.filter(c => c.product_id !== cartItemMatch[2]);
+    mockCart = mockCart.filter(c => !(c.product_id === cartItemMatch[2] && c.variant_id === cartVariantId));
     return ok(mockCart, "Removed from cart");
   }
   if (cartItemMatch && method === "PUT") {
     try {
       const body = JSON.parse(options.body as string);
-      const item = mockCart.find(c => c.product_id === cartItemMatch[2]);
+      const item = mockCart.find(c => c.product_id === cartItemMatch[2] && c.variant_id === cartVariantId);
       if (item) item.quantity = body.quantity;
     } catch { /* ignore */ }
     return ok(mockCart, "Cart updated");
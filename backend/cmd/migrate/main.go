@@ -0,0 +1,94 @@
+// cmd/migrate/main.go
+// Usage (from backend/):
+//
+//	go run ./cmd/migrate up              apply every pending migration
+//	go run ./cmd/migrate down [steps]    roll back the last N migrations (default 1)
+//	go run ./cmd/migrate status          list migrations and when they were applied
+//	go run ./cmd/migrate create <name>   add an empty up/down pair to migrations/sql
+//
+// Connection settings come from the same DB_* variables (and .env) as the server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/migrations"
+	"github.com/joho/godotenv"
+)
+
+func usage() {
+	fmt.Println("Usage: go run ./cmd/migrate <up|down [steps]|status|create <name>>")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	if os.Args[1] == "create" {
+		if len(os.Args) < 3 {
+			usage()
+		}
+		up, down, err := migrations.Create(migrations.Dir, os.Args[2])
+		if err != nil {
+			log.Fatal("❌ create:", err)
+		}
+		fmt.Printf("✅ Created\n   %s\n   %s\n", up, down)
+		return
+	}
+
+	godotenv.Load()
+	if err := config.OpenDatabase(); err != nil {
+		log.Fatal("❌ Database connection failed:", err)
+	}
+	defer config.CloseDatabase()
+
+	m, err := migrations.New(config.DB)
+	if err != nil {
+		log.Fatal("❌ load migrations:", err)
+	}
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Printf("✅ %d migration(s) applied\n", n)
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil || steps < 1 {
+				usage()
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Printf("✅ %d migration(s) rolled back\n", n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Println("─────────────────────────────────────────────────────────────")
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, state)
+		}
+		fmt.Println("─────────────────────────────────────────────────────────────")
+	default:
+		usage()
+	}
+}
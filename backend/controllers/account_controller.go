@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/mailer"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+// forgotPasswordMessage is returned whether or not the email is registered,
+// so the endpoint cannot be used to find accounts
+const forgotPasswordMessage = "Jika email terdaftar, link reset password telah dikirim"
+
+// TokenRequest - Request body for verify-email
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPasswordRequest - Request body for forgot-password
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest - Request body for reset-password
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// VerifyEmail - POST /api/auth/verify-email
+// Marks the email address as verified using the token from the verification email.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "token required")
+		return
+	}
+
+	token, ok := consumeActionToken(w, r, models.TokenVerifyEmail, req.Token)
+	if !ok {
+		return
+	}
+	// The token only vouches for the address it was mailed to
+	err := store.Users.SetEmailVerified(r.Context(), token.UserID, token.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Token tidak berlaku untuk email akun saat ini")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Verify email error: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	utils.SuccessResponse(w, "Email berhasil diverifikasi", nil)
+}
+
+// ResendVerification - POST /api/auth/verify-email/resend
+// Mails a new verification link to the logged-in user; older links stop working.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+	user, err := store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if user.EmailVerified {
+		utils.ErrorResponse(w, http.StatusConflict, "Email sudah terverifikasi")
+		return
+	}
+	if err := sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("❌ Verification email error: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	utils.SuccessResponse(w, "Link verifikasi telah dikirim ke email Anda", nil)
+}
+
+// ForgotPassword - POST /api/auth/forgot-password
+// Mails a password reset link if the email belongs to an account.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Email harus diisi")
+		return
+	}
+
+	user, err := store.Users.GetByEmail(r.Context(), req.Email)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("❌ Forgot password lookup error: %v", err)
+		}
+		utils.SuccessResponse(w, forgotPasswordMessage, nil)
+		return
+	}
+
+	token, err := issueActionToken(r.Context(), user, models.TokenResetPassword, resetPasswordTTL)
+	if err != nil {
+		log.Printf("❌ Reset token error: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create reset link")
+		return
+	}
+	deliver(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset password Go-Commerce",
+		Body: fmt.Sprintf("Halo %s,\n\nKami menerima permintaan reset password untuk akun Anda. "+
+			"Buka link berikut dalam 1 jam untuk membuat password baru:\n\n%s\n\n"+
+			"Abaikan email ini jika Anda tidak meminta reset password.\n",
+			user.FullName, frontendLink("/reset-password", token)),
+	})
+
+	utils.SuccessResponse(w, forgotPasswordMessage, nil)
+}
+
+// ResetPassword - POST /api/auth/reset-password
+// Sets a new password using the token from the reset email and signs out every session.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.Password == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Token dan password baru harus diisi")
+		return
+	}
+
+	token, ok := consumeActionToken(w, r, models.TokenResetPassword, req.Token)
+	if !ok {
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+	if err := store.Users.UpdatePassword(r.Context(), token.UserID, string(hash)); err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	// Old sessions may belong to whoever knew the old password
+	if err := store.Tokens.RevokeUser(r.Context(), token.UserID); err != nil {
+		log.Printf("⚠️  Failed to revoke sessions after password reset for user %d: %v", token.UserID, err)
+	}
+	// Following the emailed link proves the user owns the address, as long
+	// as it is still the account's email
+	store.Users.SetEmailVerified(r.Context(), token.UserID, token.Email)
+	// and lifts any lockout caused by guessing the old password
+	if user, err := store.Users.GetByID(r.Context(), token.UserID); err == nil {
+		store.LoginThrottles.Reset(r.Context(), accountThrottleKey(user.Email))
+	}
+
+	utils.SuccessResponse(w, "Password berhasil direset. Silakan login", nil)
+}
+
+// sendVerificationEmail issues a verify_email token for user and mails the link
+func sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := issueActionToken(ctx, user, models.TokenVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	deliver(mailer.Message{
+		To:      user.Email,
+		Subject: "Verifikasi email Go-Commerce",
+		Body: fmt.Sprintf("Halo %s,\n\nTerima kasih telah mendaftar di Go-Commerce. "+
+			"Buka link berikut untuk memverifikasi email Anda (berlaku 48 jam):\n\n%s\n",
+			user.FullName, frontendLink("/verify-email", token)),
+	})
+	return nil
+}
+
+// issueActionToken signs a new single-use token for user's current email and
+// stores its hash
+func issueActionToken(ctx context.Context, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	token, expiresAt, err := utils.NewActionToken(purpose, user.ID, ttl)
+	if err != nil {
+		return "", err
+	}
+	err = store.Tokens.CreateAction(ctx, &models.ActionToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	return token, err
+}
+
+// consumeActionToken checks the token's signature and marks it used, writing
+// the error response itself when the token is not accepted
+func consumeActionToken(w http.ResponseWriter, r *http.Request, purpose, raw string) (*models.ActionToken, bool) {
+	userID, err := utils.VerifyActionToken(raw, purpose)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Token tidak valid atau sudah kedaluwarsa")
+		return nil, false
+	}
+	token, err := store.Tokens.ConsumeAction(r.Context(), purpose, utils.HashToken(raw))
+	switch {
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrTokenUsed):
+		utils.ErrorResponse(w, http.StatusBadRequest, "Token tidak valid atau sudah digunakan")
+		return nil, false
+	case err != nil:
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to check token")
+		return nil, false
+	case token.UserID != userID:
+		utils.ErrorResponse(w, http.StatusBadRequest, "Token tidak valid")
+		return nil, false
+	}
+	return token, true
+}
+
+// deliver sends msg in the background so a slow mail server neither delays
+// the response nor reveals through timing whether an account exists
+func deliver(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mail.Send(ctx, msg); err != nil {
+			log.Printf("❌ Failed to send \"%s\" to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// frontendLink builds a link to a frontend page (APP_URL, default http://localhost:3000) carrying token
+func frontendLink(path, token string) string {
+	base := os.Getenv("APP_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,210 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/controllers"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/mailer"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+// inbox is a mailer that hands every message to the test
+type inbox chan mailer.Message
+
+func (in inbox) Send(ctx context.Context, msg mailer.Message) error {
+	in <- msg
+	return nil
+}
+
+// newInbox routes account emails to the returned inbox for the rest of the test
+func newInbox(t *testing.T) inbox {
+	in := make(inbox, 10)
+	controllers.SetMailer(in)
+	t.Cleanup(func() { controllers.SetMailer(mailer.Log{}) })
+	return in
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// token waits for the next message to "to" and returns the token in its link
+func (in inbox) token(t *testing.T, to string) string {
+	t.Helper()
+	select {
+	case msg := <-in:
+		if msg.To != to {
+			t.Fatalf("mail sent to %s, want %s", msg.To, to)
+		}
+		m := linkToken.FindStringSubmatch(msg.Body)
+		if m == nil {
+			t.Fatalf("no link in %q", msg.Body)
+		}
+		token, err := url.QueryUnescape(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no mail sent to %s", to)
+		return ""
+	}
+}
+
+// emailVerified reads the user's email_verified flag through the API
+func (a *testAPI) emailVerified(id int, token string) bool {
+	a.t.Helper()
+	res := a.call("GET", fmt.Sprintf("/api/users/%d", id), token, nil)
+	a.expect(res, http.StatusOK)
+	var user models.User
+	res.decode(a.t, &user)
+	return user.EmailVerified
+}
+
+func TestVerifyEmailTokenIsSingleUse(t *testing.T) {
+	a := newTestAPI(t)
+	in := newInbox(t)
+	a.register("budi@example.com", "rahasia123")
+	token := in.token(t, "budi@example.com")
+	login := a.login("budi@example.com", "rahasia123")
+
+	if a.emailVerified(login.User.ID, login.Token) {
+		t.Fatal("email verified before the link was opened")
+	}
+	// A verify_email token is not a reset_password token
+	a.expect(a.call("POST", "/api/auth/reset-password", "", map[string]string{"token": token, "password": "baru12345"}), http.StatusBadRequest)
+
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": token}), http.StatusOK)
+	if !a.emailVerified(login.User.ID, login.Token) {
+		t.Fatal("email not verified")
+	}
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": token}), http.StatusBadRequest)
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": "garbage"}), http.StatusBadRequest)
+	a.expect(a.call("POST", "/api/auth/verify-email/resend", login.Token, nil), http.StatusConflict)
+}
+
+func TestVerifyEmailTokenExpires(t *testing.T) {
+	a := newTestAPI(t)
+	id, _ := a.user("budi@example.com", "customer")
+
+	token, expiresAt, err := utils.NewActionToken(models.TokenVerifyEmail, id, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.Tokens.CreateAction(context.Background(), &models.ActionToken{
+		UserID: id, Purpose: models.TokenVerifyEmail, Email: "budi@example.com",
+		TokenHash: utils.HashToken(token), ExpiresAt: expiresAt,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": token}), http.StatusBadRequest)
+}
+
+func TestVerifyEmailTokenBoundToAddress(t *testing.T) {
+	a := newTestAPI(t)
+	in := newInbox(t)
+	a.register("budi@example.com", "rahasia123")
+	oldToken := in.token(t, "budi@example.com")
+	login := a.login("budi@example.com", "rahasia123")
+
+	// Moving to an address the user may not own drops the link sent to the old one
+	a.expect(a.call("PUT", fmt.Sprintf("/api/users/%d/profile", login.User.ID), login.Token, map[string]string{
+		"full_name": "Budi", "phone": "0812", "email": "orang.lain@example.com",
+	}), http.StatusOK)
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": oldToken}), http.StatusBadRequest)
+	if a.emailVerified(login.User.ID, login.Token) {
+		t.Fatal("new address verified with a link mailed to the old one")
+	}
+
+	// A link issued for an address that is no longer current is refused too
+	token, expiresAt, err := utils.NewActionToken(models.TokenVerifyEmail, login.User.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.Tokens.CreateAction(context.Background(), &models.ActionToken{
+		UserID: login.User.ID, Purpose: models.TokenVerifyEmail, Email: "budi@example.com",
+		TokenHash: utils.HashToken(token), ExpiresAt: expiresAt,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": token}), http.StatusBadRequest)
+
+	// A fresh link goes to the new address and verifies it
+	a.expect(a.call("POST", "/api/auth/verify-email/resend", login.Token, nil), http.StatusOK)
+	newToken := in.token(t, "orang.lain@example.com")
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": newToken}), http.StatusOK)
+	if !a.emailVerified(login.User.ID, login.Token) {
+		t.Fatal("new address not verified")
+	}
+}
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	a := newTestAPI(t)
+	in := newInbox(t)
+	a.register("budi@example.com", "rahasia123")
+	in.token(t, "budi@example.com")
+
+	known := a.call("POST", "/api/auth/forgot-password", "", map[string]string{"email": "budi@example.com"})
+	unknown := a.call("POST", "/api/auth/forgot-password", "", map[string]string{"email": "siapa@example.com"})
+	a.expect(known, http.StatusOK)
+	a.expect(unknown, http.StatusOK)
+	if known.Message != unknown.Message {
+		t.Fatalf("responses differ: %q vs %q", known.Message, unknown.Message)
+	}
+	in.token(t, "budi@example.com")
+	select {
+	case msg := <-in:
+		t.Fatalf("unexpected mail to %s", msg.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+	a.expect(a.call("POST", "/api/auth/forgot-password", "", `{}`), http.StatusBadRequest)
+}
+
+func TestResetPassword(t *testing.T) {
+	a := newTestAPI(t)
+	in := newInbox(t)
+	a.register("budi@example.com", "rahasia123")
+	verifyToken := in.token(t, "budi@example.com")
+	login := a.login("budi@example.com", "rahasia123")
+
+	a.expect(a.call("POST", "/api/auth/forgot-password", "", map[string]string{"email": "budi@example.com"}), http.StatusOK)
+	token := in.token(t, "budi@example.com")
+
+	// A reset_password token is not a verify_email token
+	a.expect(a.call("POST", "/api/auth/verify-email", "", map[string]string{"token": token}), http.StatusBadRequest)
+	// and a verify_email token cannot reset the password
+	a.expect(a.call("POST", "/api/auth/reset-password", "", map[string]string{"token": verifyToken, "password": "baru12345"}), http.StatusBadRequest)
+
+	a.expect(a.call("POST", "/api/auth/reset-password", "", map[string]string{"token": token, "password": "baru12345"}), http.StatusOK)
+	a.expect(a.call("POST", "/api/auth/reset-password", "", map[string]string{"token": token, "password": "lagi12345"}), http.StatusBadRequest)
+
+	a.expect(a.call("POST", "/api/auth/login", "", map[string]string{"email": "budi@example.com", "password": "rahasia123"}), http.StatusUnauthorized)
+	again := a.login("budi@example.com", "baru12345")
+	// Every earlier session is signed out and the mailbox counts as verified
+	a.expect(a.refresh(login.RefreshToken), http.StatusUnauthorized)
+	if !a.emailVerified(again.User.ID, again.Token) {
+		t.Fatal("reset did not verify the email")
+	}
+}
+
+func TestResetPasswordTokenExpires(t *testing.T) {
+	a := newTestAPI(t)
+	id, _ := a.user("budi@example.com", "customer")
+
+	token, expiresAt, err := utils.NewActionToken(models.TokenResetPassword, id, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.Tokens.CreateAction(context.Background(), &models.ActionToken{
+		UserID: id, Purpose: models.TokenResetPassword, Email: "budi@example.com",
+		TokenHash: utils.HashToken(token), ExpiresAt: expiresAt,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	a.expect(a.call("POST", "/api/auth/reset-password", "", map[string]string{"token": token, "password": "baru12345"}), http.StatusBadRequest)
+}
@@ -1,22 +1,37 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // LoginResponse - Response for successful login
 type loginResponse struct {
-	User  models.User `json:"user"`
-	Token string      `json:"token"`
+	User models.User `json:"user"`
+	tokenPair
+}
+
+// tokenPair is the access/refresh token pair returned by login and refresh
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// RefreshRequest - Request body for refresh and logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RegisterRequest - Request body for registration
@@ -49,10 +64,8 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if email already exists
-	var existingID int
-	checkQuery := `SELECT id FROM users WHERE email = ?`
-	err = config.DB.QueryRow(checkQuery, req.Email).Scan(&existingID)
-	if err == nil {
+	exists, err := store.Users.EmailExists(r.Context(), req.Email)
+	if err == nil && exists {
 		utils.ErrorResponse(w, http.StatusConflict, "Email sudah terdaftar")
 		return
 	}
@@ -64,28 +77,24 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Prepare query and values in DB column order (only columns that exist)
-	query := `INSERT INTO users (full_name, email, password, phone) VALUES (?, ?, ?, ?)`
-	values := []interface{}{req.FullName, req.Email, string(hashedPassword), req.Phone}
-	log.Printf("[DEBUG] SQL Query: %s", query)
-	log.Printf("[DEBUG] Values: full_name='%s', email='%s', password='%s', phone='%s'", req.FullName, req.Email, string(hashedPassword), req.Phone)
-
-	result, err := config.DB.Exec(query, values...)
-	if err != nil {
+	user := models.User{
+		FullName: req.FullName,
+		Email:    req.Email,
+		Phone:    req.Phone,
+		Password: string(hashedPassword),
+	}
+	if err := store.Users.Create(r.Context(), &user); err != nil {
 		log.Printf("❌ Registration error: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Gagal mendaftarkan akun: %v", err))
 		return
 	}
+	user.Password = ""
 
-	id, _ := result.LastInsertId()
-	user := models.User{
-		ID:       int(id),
-		FullName: req.FullName,
-		Email:    req.Email,
-		Phone:    req.Phone,
+	if err := sendVerificationEmail(r.Context(), &user); err != nil {
+		log.Printf("⚠️  Verification email for user %d not sent: %v", user.ID, err)
 	}
 
-	utils.CreatedResponse(w, "Registrasi berhasil! Silakan login", user)
+	utils.CreatedResponse(w, "Registrasi berhasil! Silakan cek email untuk verifikasi lalu login", user)
 }
 
 // Login - POST /api/auth/login
@@ -103,24 +112,24 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Every attempt counts as a failure until the password checks out; this
+	// refuses it while the account or client is backing off
+	accountKey, ipKey := loginThrottleKeys(r, req.Email)
+	if !reserveLoginAttempt(w, r, accountKey, ipKey) {
+		return
+	}
+
 	// Get user from database
-	var user models.User
-	var hashedPassword string
-	var avatarURL sql.NullString
-	var emailVerified bool
-	query := `SELECT id, full_name, email, password, phone, created_at, avatar_url, email_verified, total_spent, role FROM users WHERE email = ?`
-	err = config.DB.QueryRow(query, req.Email).Scan(
-		&user.ID, &user.FullName, &user.Email, &hashedPassword, &user.Phone, &user.CreatedAt, &avatarURL, &emailVerified, &user.TotalSpent, &user.Role,
-	)
+	user, err := store.Users.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		log.Printf("❌ Login query error: %v", err)
+		// Unknown emails keep their reserved failure too, so responses do not
+		// reveal which accounts exist
 		utils.ErrorResponse(w, http.StatusUnauthorized, "Email atau password salah")
 		return
 	}
-
-	if avatarURL.Valid {
-		user.AvatarURL = avatarURL.String
-	}
+	hashedPassword := user.Password
+	user.Password = ""
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password))
@@ -129,9 +138,25 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusUnauthorized, "Email atau password salah")
 		return
 	}
+	loginSucceeded(r.Context(), accountKey, ipKey)
 
-	// Generate JWT
-	token, err := utils.GenerateToken(user.ID, user.Role)
+	// REQUIRE_EMAIL_VERIFICATION=true blocks logins until the email link was opened
+	if !user.EmailVerified && os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true" {
+		utils.ErrorResponse(w, http.StatusForbidden, "Email belum diverifikasi. Silakan cek email Anda")
+		return
+	}
+
+	// Generate access token and start a new refresh token family
+	family, err := utils.RandomToken(16)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	tokens, refresh, err := newTokenPair(user)
+	if err == nil {
+		refresh.FamilyID = family
+		err = store.Tokens.CreateRefresh(r.Context(), refresh)
+	}
 	if err != nil {
 		log.Printf("❌ Token generation error: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
@@ -140,9 +165,128 @@ func Login(w http.ResponseWriter, r *http.Request) {
 
 	// Successful login
 	response := loginResponse{
-		User:  user,
-		Token: token,
+		User:      *user,
+		tokenPair: tokens,
 	}
 
 	utils.SuccessResponse(w, "Login berhasil", response)
 }
+
+// newTokenPair signs an access token for user and prepares (but does not store)
+// the matching refresh token record; the caller sets FamilyID and saves it
+func newTokenPair(user *models.User) (tokenPair, *models.RefreshToken, error) {
+	access, err := utils.GenerateToken(user.ID, user.Role)
+	if err != nil {
+		return tokenPair{}, nil, err
+	}
+	refresh, err := utils.RandomToken(32)
+	if err != nil {
+		return tokenPair{}, nil, err
+	}
+	record := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(refresh),
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+	}
+	return tokenPair{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+	}, record, nil
+}
+
+// RefreshToken - POST /api/auth/refresh
+// Exchanges a refresh token for a new access token and a new refresh token.
+// The presented token is revoked; presenting it again revokes the whole chain.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "refresh_token required")
+		return
+	}
+
+	old, err := store.Tokens.GetRefresh(r.Context(), utils.HashToken(req.RefreshToken))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if old.RevokedAt != nil {
+		// A rotated token came back: assume it was stolen and end the session
+		log.Printf("⚠️  Refresh token reuse detected for user %d, revoking session", old.UserID)
+		store.Tokens.RevokeFamily(r.Context(), old.FamilyID)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Refresh token has been revoked")
+		return
+	}
+	if !old.Active(time.Now()) {
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Refresh token expired")
+		return
+	}
+
+	// Read the user again so role changes apply on the next refresh
+	user, err := store.Users.GetByID(r.Context(), old.UserID)
+	if err != nil {
+		store.Tokens.RevokeFamily(r.Context(), old.FamilyID)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	tokens, next, err := newTokenPair(user)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	next.FamilyID = old.FamilyID
+	err = store.Tokens.RotateRefresh(r.Context(), old.ID, next)
+	if errors.Is(err, repository.ErrTokenReused) {
+		store.Tokens.RevokeFamily(r.Context(), old.FamilyID)
+		utils.ErrorResponse(w, http.StatusUnauthorized, "Refresh token has been revoked")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+
+	utils.SuccessResponse(w, "Token refreshed", tokens)
+}
+
+// Logout - POST /api/auth/logout
+// Revokes the current access token and, if given, the session's refresh tokens.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+	jti, _ := r.Context().Value(middlewares.TokenIDKey).(string)
+	expiry, _ := r.Context().Value(middlewares.TokenExpiryKey).(time.Time)
+
+	var req RefreshRequest
+	json.NewDecoder(r.Body).Decode(&req) // body is optional
+
+	if req.RefreshToken != "" {
+		t, err := store.Tokens.GetRefresh(r.Context(), utils.HashToken(req.RefreshToken))
+		if err == nil && t.UserID == userID {
+			if err := store.Tokens.RevokeFamily(r.Context(), t.FamilyID); err != nil {
+				utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to logout")
+				return
+			}
+		}
+	}
+	if err := store.Tokens.DenyAccessToken(r.Context(), jti, expiry); err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to logout")
+		return
+	}
+
+	utils.SuccessResponse(w, "Logout berhasil", nil)
+}
+
+// GetJWKS - GET /.well-known/jwks.json
+// Publishes the RS256/EdDSA public keys in the standard JWK Set format so
+// other services can verify access tokens. HS256 secrets are never listed.
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := utils.JWKS()
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Signing keys are not configured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": set})
+}
@@ -0,0 +1,212 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+// session is the token pair returned by login and refresh
+type session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	User         struct {
+		ID int `json:"id"`
+	} `json:"user"`
+}
+
+// register signs a customer up through the API
+func (a *testAPI) register(email, password string) {
+	a.t.Helper()
+	a.expect(a.call("POST", "/api/auth/register", "", map[string]string{
+		"full_name": email, "phone": "0812", "email": email, "password": password,
+	}), http.StatusCreated)
+}
+
+// login logs in and returns the session
+func (a *testAPI) login(email, password string) session {
+	a.t.Helper()
+	res := a.call("POST", "/api/auth/login", "", map[string]string{"email": email, "password": password})
+	a.expect(res, http.StatusOK)
+	var s session
+	res.decode(a.t, &s)
+	return s
+}
+
+// refresh exchanges refreshToken and returns the response
+func (a *testAPI) refresh(refreshToken string) apiResponse {
+	a.t.Helper()
+	return a.call("POST", "/api/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+	login := a.login("budi@example.com", "rahasia123")
+
+	res := a.refresh(login.RefreshToken)
+	a.expect(res, http.StatusOK)
+	var rotated session
+	res.decode(t, &rotated)
+	if rotated.Token == "" || rotated.RefreshToken == "" || rotated.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh did not issue a new token pair: %s", res.Data)
+	}
+	a.expect(a.call("GET", fmt.Sprintf("/api/users/%d", login.User.ID), rotated.Token, nil), http.StatusOK)
+
+	// The new refresh token keeps working, one exchange at a time
+	res = a.refresh(rotated.RefreshToken)
+	a.expect(res, http.StatusOK)
+	a.expect(a.refresh("not-a-refresh-token"), http.StatusUnauthorized)
+	a.expect(a.call("POST", "/api/auth/refresh", "", `{}`), http.StatusBadRequest)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+	login := a.login("budi@example.com", "rahasia123")
+	other := a.login("budi@example.com", "rahasia123")
+
+	res := a.refresh(login.RefreshToken)
+	a.expect(res, http.StatusOK)
+	var rotated session
+	res.decode(t, &rotated)
+
+	// Presenting the rotated token again ends the whole session ...
+	a.expect(a.refresh(login.RefreshToken), http.StatusUnauthorized)
+	a.expect(a.refresh(rotated.RefreshToken), http.StatusUnauthorized)
+	// ... but not the user's other logins
+	a.expect(a.refresh(other.RefreshToken), http.StatusOK)
+}
+
+func TestLogoutDeniesAccessToken(t *testing.T) {
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+	login := a.login("budi@example.com", "rahasia123")
+	userPath := fmt.Sprintf("/api/users/%d", login.User.ID)
+
+	a.expect(a.call("GET", userPath, login.Token, nil), http.StatusOK)
+	a.expect(a.call("POST", "/api/auth/logout", login.Token, map[string]string{"refresh_token": login.RefreshToken}), http.StatusOK)
+
+	// The access token's jti is on the denylist and the session's refresh token is revoked
+	claims, err := utils.ValidateToken(login.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denied, err := a.store.Tokens.IsAccessTokenDenied(context.Background(), claims.ID); err != nil || !denied {
+		t.Fatalf("jti %s denied = %v, %v", claims.ID, denied, err)
+	}
+	a.expect(a.call("GET", userPath, login.Token, nil), http.StatusUnauthorized)
+	a.expect(a.call("POST", "/api/auth/logout", login.Token, nil), http.StatusUnauthorized)
+	a.expect(a.refresh(login.RefreshToken), http.StatusUnauthorized)
+
+	// A fresh login is unaffected
+	again := a.login("budi@example.com", "rahasia123")
+	a.expect(a.call("GET", userPath, again.Token, nil), http.StatusOK)
+	a.expect(a.call("POST", "/api/auth/logout", "", nil), http.StatusUnauthorized)
+}
+
+// badLogin tries a wrong password for email and returns the response
+func (a *testAPI) badLogin(email string) apiResponse {
+	a.t.Helper()
+	return a.call("POST", "/api/auth/login", "", map[string]string{"email": email, "password": "salah"})
+}
+
+// retryAfter returns the Retry-After seconds of a 429 response
+func (a *testAPI) retryAfter(res apiResponse) int {
+	a.t.Helper()
+	a.expect(res, http.StatusTooManyRequests)
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil {
+		a.t.Fatalf("Retry-After %q: %v", res.Header.Get("Retry-After"), err)
+	}
+	return seconds
+}
+
+func TestLoginBacksOffAfterFreeAttempts(t *testing.T) {
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+
+	// Three free failures, then the fourth starts a one second back-off
+	for i := 0; i < 4; i++ {
+		a.expect(a.badLogin("budi@example.com"), http.StatusUnauthorized)
+	}
+	if s := a.retryAfter(a.badLogin("budi@example.com")); s != 1 {
+		t.Fatalf("Retry-After = %d, want 1", s)
+	}
+	// Even the right password waits, and the throttle is per account
+	a.retryAfter(a.call("POST", "/api/auth/login", "", map[string]string{"email": "BUDI@example.com ", "password": "rahasia123"}))
+	a.expect(a.badLogin("siti@example.com"), http.StatusUnauthorized)
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "2")
+	t.Setenv("LOGIN_LOCKOUT", "10m")
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+
+	a.expect(a.badLogin("budi@example.com"), http.StatusUnauthorized)
+	a.expect(a.badLogin("budi@example.com"), http.StatusUnauthorized)
+	res := a.call("POST", "/api/auth/login", "", map[string]string{"email": "budi@example.com", "password": "rahasia123"})
+	if s := a.retryAfter(res); s < 599 || s > 600 {
+		t.Fatalf("Retry-After = %d, want the 10 minute lockout", s)
+	}
+	// Attempts during the lockout are refused without counting
+	throttle, err := a.store.LoginThrottles.Get(context.Background(), "email:budi@example.com")
+	if err != nil || throttle.Failures != 2 {
+		t.Fatalf("throttle = %+v, %v", throttle, err)
+	}
+}
+
+func TestParallelLoginAttemptsAreThrottled(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "2")
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+
+	// Attempts are counted before the password is checked, so a burst
+	// cannot get more guesses in than the lockout allows
+	const attempts = 10
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- a.badLogin("budi@example.com").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	count := map[int]int{}
+	for code := range codes {
+		count[code]++
+	}
+	if count[http.StatusUnauthorized] != 2 || count[http.StatusTooManyRequests] != attempts-2 {
+		t.Fatalf("responses %v, want 2 password checks and the rest throttled", count)
+	}
+}
+
+func TestSuccessfulLoginClearsFailures(t *testing.T) {
+	a := newTestAPI(t)
+	a.register("budi@example.com", "rahasia123")
+
+	for i := 0; i < 3; i++ {
+		a.expect(a.badLogin("budi@example.com"), http.StatusUnauthorized)
+	}
+	a.login("budi@example.com", "rahasia123")
+	if _, err := a.store.LoginThrottles.Get(context.Background(), "email:budi@example.com"); err == nil {
+		t.Fatal("account failures kept after a successful login")
+	}
+	// The client keeps its failures but not the attempt that succeeded
+	ip, err := a.store.LoginThrottles.Get(context.Background(), "ip:192.0.2.1")
+	if err != nil || ip.Failures != 3 {
+		t.Fatalf("ip throttle = %+v, %v", ip, err)
+	}
+	for i := 0; i < 3; i++ {
+		a.expect(a.badLogin("budi@example.com"), http.StatusUnauthorized)
+	}
+}
@@ -2,10 +2,12 @@ package controllers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/gorilla/mux"
 )
@@ -14,6 +16,7 @@ type CartItemResponse struct {
 	ID        int    `json:"id"`
 	UserID    int    `json:"user_id"`
 	ProductID string `json:"product_id"`
+	VariantID int    `json:"variant_id"`
 	Quantity  int    `json:"quantity"`
 }
 
@@ -26,31 +29,29 @@ func GetCartItems(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := config.DB.Query("SELECT id, user_id, product_id, quantity FROM cart_items WHERE user_id = ? ORDER BY created_at DESC", userID)
+	lines, err := store.Carts.List(r.Context(), userID)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch cart items")
 		return
 	}
-	defer rows.Close()
 
-	var items []CartItemResponse
-	for rows.Next() {
-		var item CartItemResponse
-		if err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.Quantity); err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to scan cart item")
-			return
-		}
-		items = append(items, item)
-	}
-
-	if items == nil {
-		items = []CartItemResponse{}
+	items := []CartItemResponse{}
+	for _, line := range lines {
+		items = append(items, CartItemResponse{
+			ID:        line.ID,
+			UserID:    line.UserID,
+			ProductID: strconv.Itoa(line.ProductID),
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+		})
 	}
 
 	utils.SuccessResponse(w, "Cart items fetched", items)
 }
 
 // AddToCart - POST /api/users/{id}/cart
+// Body: {"product_id", "variant_id", "quantity"}; variant_id is required for
+// products with variants and must be left out for the others.
 func AddToCart(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
@@ -65,17 +66,25 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var productID string
+	var productID int
 	switch v := rawReq["product_id"].(type) {
 	case string:
-		productID = v
+		productID, _ = strconv.Atoi(v)
 	case float64:
-		productID = strconv.Itoa(int(v))
+		productID = int(v)
 	default:
 		utils.ErrorResponse(w, http.StatusBadRequest, "product_id is required")
 		return
 	}
 
+	var variantID int
+	switch v := rawReq["variant_id"].(type) {
+	case string:
+		variantID, _ = strconv.Atoi(v)
+	case float64:
+		variantID = int(v)
+	}
+
 	var quantity int
 	switch v := rawReq["quantity"].(type) {
 	case float64:
@@ -84,25 +93,72 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 		quantity = 1
 	}
 
-	if productID == "" || quantity <= 0 {
+	if productID <= 0 || quantity <= 0 {
 		utils.ErrorResponse(w, http.StatusBadRequest, "product_id and quantity > 0 are required")
 		return
 	}
 
-	// Upsert: if item already exists, add to quantity
-	_, err = config.DB.Exec(
-		"INSERT INTO cart_items (user_id, product_id, quantity) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)",
-		userID, productID, quantity,
-	)
+	product, err := store.Products.GetByID(r.Context(), productID)
+	if err == repository.ErrNotFound || err == nil && product.DeletedAt != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to add to cart: "+err.Error())
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch product")
+		return
+	}
+	if msg := checkVariant(product, variantID); msg != "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// Upsert: if item already exists, add to quantity
+	line := models.CartLine{ProductID: productID, VariantID: variantID}
+	if err := store.Carts.Add(r.Context(), userID, line, quantity); err != nil {
+		log.Printf("❌ Adding product %d to the cart of user %d failed: %v", productID, userID, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to add to cart")
 		return
 	}
 
 	utils.CreatedResponse(w, "Item added to cart", nil)
 }
 
-// UpdateCartItem - PUT /api/users/{id}/cart/{productId}
+// checkVariant reports why variantID cannot go into the cart with product,
+// or "" when it can
+func checkVariant(product *models.Product, variantID int) string {
+	if len(product.Variants) == 0 {
+		if variantID != 0 {
+			return "Product has no variants"
+		}
+		return ""
+	}
+	if variantID == 0 {
+		return "variant_id is required for this product"
+	}
+	for _, v := range product.Variants {
+		if v.ID == variantID {
+			return ""
+		}
+	}
+	return "Variant not found"
+}
+
+// cartLine reads the line addressed by .../cart/{productId}?variant_id=N
+func cartLine(r *http.Request) (models.CartLine, error) {
+	productID, err := strconv.Atoi(mux.Vars(r)["productId"])
+	if err != nil {
+		return models.CartLine{}, err
+	}
+	line := models.CartLine{ProductID: productID}
+	if v := r.URL.Query().Get("variant_id"); v != "" {
+		if line.VariantID, err = strconv.Atoi(v); err != nil {
+			return models.CartLine{}, err
+		}
+	}
+	return line, nil
+}
+
+// UpdateCartItem - PUT /api/users/{id}/cart/{productId}?variant_id=N
 func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
@@ -110,7 +166,11 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
-	productID := vars["productId"]
+	line, err := cartLine(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
 
 	var req struct {
 		Quantity int `json:"quantity"`
@@ -124,21 +184,20 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := config.DB.Exec("UPDATE cart_items SET quantity = ? WHERE user_id = ? AND product_id = ?", req.Quantity, userID, productID)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update cart item")
+	err = store.Carts.UpdateQuantity(r.Context(), userID, line, req.Quantity)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Cart item not found")
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		utils.ErrorResponse(w, http.StatusNotFound, "Cart item not found")
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update cart item")
 		return
 	}
 
 	utils.SuccessResponse(w, "Cart item updated", nil)
 }
 
-// RemoveFromCart - DELETE /api/users/{id}/cart/{productId}
+// RemoveFromCart - DELETE /api/users/{id}/cart/{productId}?variant_id=N
 func RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.Atoi(vars["id"])
@@ -146,18 +205,21 @@ func RemoveFromCart(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
-	productID := vars["productId"]
-
-	result, err := config.DB.Exec("DELETE FROM cart_items WHERE user_id = ? AND product_id = ?", userID, productID)
+	line, err := cartLine(r)
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to remove cart item")
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+
+	err = store.Carts.Remove(r.Context(), userID, line)
+	if err == repository.ErrNotFound {
 		utils.ErrorResponse(w, http.StatusNotFound, "Cart item not found")
 		return
 	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to remove cart item")
+		return
+	}
 
 	utils.SuccessResponse(w, "Item removed from cart", nil)
 }
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetCategoryTree - GET /api/categories
+// Returns the whole category tree, siblings ordered by sort_order then name.
+func GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	list, err := store.Categories.List(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+	utils.SuccessResponse(w, "Categories fetched successfully", models.CategoryTree(list))
+}
+
+// GetCategory - GET /api/categories/{id}
+// {id} may also be the category slug; the response includes its subtree.
+func GetCategory(w http.ResponseWriter, r *http.Request) {
+	list, err := store.Categories.List(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+
+	ref := mux.Vars(r)["id"]
+	id, _ := strconv.Atoi(ref)
+	var find func(nodes []models.Category) *models.Category
+	find = func(nodes []models.Category) *models.Category {
+		for i := range nodes {
+			if nodes[i].ID == id || strings.EqualFold(nodes[i].Slug, ref) {
+				return &nodes[i]
+			}
+			if c := find(nodes[i].Children); c != nil {
+				return c
+			}
+		}
+		return nil
+	}
+	node := find(models.CategoryTree(list))
+	if node == nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	utils.SuccessResponse(w, "Category fetched successfully", node)
+}
+
+// CreateCategory - POST /api/categories
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	category, msg := categoryFromRequest(r.Context(), 0, req)
+	if msg != "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	err := store.Categories.Create(r.Context(), &category)
+	if err == repository.ErrDuplicate {
+		utils.ErrorResponse(w, http.StatusConflict, "Category name or slug already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Create category failed: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	utils.CreatedResponse(w, "Category created successfully", map[string]interface{}{"id": category.ID, "slug": category.Slug})
+}
+
+// UpdateCategory - PUT /api/categories/{id}
+// Replaces every editable field; moving a category under one of its own
+// descendants is rejected.
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	existing, err := store.Categories.GetByID(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch category")
+		return
+	}
+
+	var req models.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	category, msg := categoryFromRequest(r.Context(), id, req)
+	if msg != "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	err = store.Categories.Update(r.Context(), &category)
+	if err == repository.ErrDuplicate {
+		utils.ErrorResponse(w, http.StatusConflict, "Category name or slug already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Update category %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+
+	// The search index stores category names; pick up a rename
+	if existing.Name != category.Name {
+		if err := BuildSearchIndex(r.Context()); err != nil {
+			log.Printf("⚠️  Failed to rebuild search index: %v", err)
+		}
+	}
+
+	utils.SuccessResponse(w, "Category updated successfully", nil)
+}
+
+// DeleteCategory - DELETE /api/categories/{id}
+// Only empty categories (no subcategories, no products) can be deleted.
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	err = store.Categories.Delete(r.Context(), id)
+	switch {
+	case err == repository.ErrNotFound:
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+	case err == repository.ErrCategoryInUse:
+		utils.ErrorResponse(w, http.StatusConflict, "Category still has subcategories or products; move them first")
+	case err != nil:
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete category")
+	default:
+		utils.SuccessResponse(w, "Category deleted successfully", nil)
+	}
+}
+
+// categoryFromRequest validates req for the category id (0 when creating).
+// The returned message is non-empty when the request is invalid.
+func categoryFromRequest(ctx context.Context, id int, req models.CategoryRequest) (models.Category, string) {
+	c := models.Category{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Slug:        utils.Slugify(req.Slug),
+		Description: strings.TrimSpace(req.Description),
+		Image:       strings.TrimSpace(req.Image),
+		SortOrder:   req.SortOrder,
+	}
+	if c.Name == "" {
+		return c, "Category name is required"
+	}
+	if len(c.Name) > 100 {
+		return c, "Category name must be at most 100 characters"
+	}
+	if c.Slug == "" {
+		c.Slug = utils.Slugify(c.Name)
+	}
+	if c.Slug == "" || len(c.Slug) > 120 {
+		return c, "Category slug must be 1-120 letters, digits or dashes"
+	}
+	if _, err := strconv.Atoi(c.Slug); err == nil {
+		// GET /api/categories/{id} accepts a slug too, so it must not look like an ID
+		return c, "Category slug cannot be a number"
+	}
+
+	if req.ParentID != nil {
+		list, err := store.Categories.List(ctx)
+		if err != nil {
+			return c, "Failed to check parent category"
+		}
+		parentExists := false
+		for _, other := range list {
+			if other.ID == *req.ParentID {
+				parentExists = true
+			}
+		}
+		if !parentExists {
+			return c, "Parent category not found"
+		}
+		if id != 0 {
+			for _, d := range models.CategoryDescendants(list, id) {
+				if d == *req.ParentID {
+					return c, "A category cannot be moved under itself or its subcategories"
+				}
+			}
+		}
+		parent := *req.ParentID
+		c.ParentID = &parent
+	}
+	return c, ""
+}
+
+// resolveCategory maps the category a product request names (by slug or name)
+// to the stored category name
+func resolveCategory(ctx context.Context, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", errors.New("Category is required")
+	}
+	c, err := store.Categories.Find(ctx, ref)
+	if err == repository.ErrNotFound {
+		return "", errors.New("Unknown category: " + ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.Name, nil
+}
+
+// expandCategories replaces each category filter value (slug or name) with
+// the names of that category and all its subcategories. Unknown values are
+// kept so they simply match nothing.
+func expandCategories(ctx context.Context, refs []string) []string {
+	if len(refs) == 0 {
+		return refs
+	}
+	list, err := store.Categories.List(ctx)
+	if err != nil {
+		return refs
+	}
+	names := map[int]string{}
+	for _, c := range list {
+		names[c.ID] = c.Name
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			out = append(out, name)
+		}
+	}
+	for _, ref := range refs {
+		c, ok := models.FindCategory(list, ref)
+		if !ok {
+			add(ref)
+			continue
+		}
+		for _, id := range models.CategoryDescendants(list, c.ID) {
+			add(names[id])
+		}
+	}
+	return out
+}
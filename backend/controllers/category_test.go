@@ -0,0 +1,177 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// category creates a category through the admin API and returns its ID
+func (a *testAPI) category(admin string, body map[string]interface{}) int {
+	a.t.Helper()
+	res := a.call("POST", "/api/categories", admin, body)
+	a.expect(res, http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	res.decode(a.t, &created)
+	return created.ID
+}
+
+// getCategory fetches one category with its subtree by ID or slug
+func (a *testAPI) getCategory(ref string) models.Category {
+	a.t.Helper()
+	res := a.call("GET", "/api/categories/"+ref, "", nil)
+	a.expect(res, http.StatusOK)
+	var c models.Category
+	res.decode(a.t, &c)
+	return c
+}
+
+func TestCategoryCRUD(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+
+	a.expect(a.call("POST", "/api/categories", customer, map[string]interface{}{"name": "Aksesoris"}), http.StatusForbidden)
+	parent := a.category(admin, map[string]interface{}{"name": "Aksesoris HP"})
+	child := a.category(admin, map[string]interface{}{"name": "Casing", "slug": "Casing & Cover", "parent_id": parent})
+
+	got := a.getCategory("aksesoris-hp")
+	if got.ID != parent || len(got.Children) != 1 || got.Children[0].ID != child || got.Children[0].Slug != "casing-cover" {
+		t.Fatalf("subtree = %+v", got)
+	}
+	if c := a.getCategory(fmt.Sprint(child)); c.Name != "Casing" || c.ParentID == nil || *c.ParentID != parent {
+		t.Fatalf("child = %+v", c)
+	}
+	a.expect(a.call("GET", "/api/categories/tidak-ada", "", nil), http.StatusNotFound)
+
+	for _, body := range []map[string]interface{}{
+		{"name": ""},
+		{"name": "Angka", "slug": "2024"},
+		{"name": "Yatim", "parent_id": 999},
+	} {
+		a.expect(a.call("POST", "/api/categories", admin, body), http.StatusBadRequest)
+	}
+	a.expect(a.call("POST", "/api/categories", admin, map[string]interface{}{"name": "casing"}), http.StatusConflict)
+	a.expect(a.call("POST", "/api/categories", admin, map[string]interface{}{"name": "Lain", "slug": "aksesoris-hp"}), http.StatusConflict)
+
+	a.expect(a.call("PUT", fmt.Sprintf("/api/categories/%d", child), admin, map[string]interface{}{
+		"name": "Case", "slug": "case", "parent_id": parent, "sort_order": 2,
+	}), http.StatusOK)
+	if c := a.getCategory("case"); c.ID != child || c.Name != "Case" || c.SortOrder != 2 {
+		t.Fatalf("after update = %+v", c)
+	}
+	a.expect(a.call("PUT", "/api/categories/999", admin, map[string]interface{}{"name": "X"}), http.StatusNotFound)
+
+	// Only empty categories can go
+	a.expect(a.call("DELETE", fmt.Sprintf("/api/categories/%d", parent), admin, nil), http.StatusConflict)
+	a.expect(a.call("DELETE", fmt.Sprintf("/api/categories/%d", child), admin, nil), http.StatusOK)
+	a.expect(a.call("DELETE", fmt.Sprintf("/api/categories/%d", parent), admin, nil), http.StatusOK)
+	a.expect(a.call("DELETE", fmt.Sprintf("/api/categories/%d", parent), admin, nil), http.StatusNotFound)
+}
+
+func TestCategoryParentCycle(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	top := a.category(admin, map[string]interface{}{"name": "Elektronik"})
+	mid := a.category(admin, map[string]interface{}{"name": "Gadget", "parent_id": top})
+	leaf := a.category(admin, map[string]interface{}{"name": "Wearable", "parent_id": mid})
+
+	move := func(id, parent int) apiResponse {
+		body := map[string]interface{}{"name": a.getCategory(fmt.Sprint(id)).Name, "parent_id": parent}
+		return a.call("PUT", fmt.Sprintf("/api/categories/%d", id), admin, body)
+	}
+	a.expect(move(top, top), http.StatusBadRequest)
+	a.expect(move(top, mid), http.StatusBadRequest)
+	a.expect(move(top, leaf), http.StatusBadRequest)
+	a.expect(move(mid, leaf), http.StatusBadRequest)
+
+	// Moving a subtree sideways or up is fine
+	a.expect(move(leaf, top), http.StatusOK)
+	a.expect(move(mid, leaf), http.StatusOK)
+	if c := a.getCategory(fmt.Sprint(top)); len(c.Children) != 1 || c.Children[0].ID != leaf || c.Children[0].Children[0].ID != mid {
+		t.Fatalf("tree = %+v", c)
+	}
+}
+
+func TestProductsNeedAKnownCategory(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	phones, err := a.store.Categories.Find(context.Background(), "smartphones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	foldables := a.category(admin, map[string]interface{}{"name": "Foldables", "parent_id": phones.ID})
+
+	for _, category := range []string{"", "   ", "Televisi"} {
+		a.expect(a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": "Produk", "price": 1000, "category": category,
+		}), http.StatusBadRequest)
+	}
+
+	// A slug resolves to the stored category name
+	res := a.call("POST", "/api/products", admin, map[string]interface{}{"name": "Galaxy Z Fold", "price": 1000, "category": "foldables"})
+	a.expect(res, http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	res.decode(t, &created)
+	if p := a.getProduct(created.ID); p.Category != "Foldables" {
+		t.Fatalf("category = %q, want Foldables", p.Category)
+	}
+	flat := a.product(admin, "Galaxy S24", 1000, 1)
+
+	// Filtering by a category includes its subcategories
+	if got := a.list("/api/products?category=smartphones&sort=price_asc").ids(); len(got) != 2 {
+		t.Fatalf("category=smartphones: got %v, want %d and %d", got, created.ID, flat)
+	}
+	if got := a.list("/api/products?category=Foldables").ids(); len(got) != 1 || got[0] != created.ID {
+		t.Fatalf("category=Foldables: got %v", got)
+	}
+
+	// Renames carry over to products; categories in use cannot be deleted
+	a.expect(a.call("PUT", fmt.Sprintf("/api/categories/%d", foldables), admin, map[string]interface{}{
+		"name": "Lipat", "parent_id": phones.ID,
+	}), http.StatusOK)
+	if p := a.getProduct(created.ID); p.Category != "Lipat" {
+		t.Fatalf("category after rename = %q", p.Category)
+	}
+	a.expect(a.call("DELETE", fmt.Sprintf("/api/categories/%d", foldables), admin, nil), http.StatusConflict)
+}
+
+func TestCategoryRefPrefersSlug(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	// "tab" is one category's slug and another one's name
+	tablets := a.category(admin, map[string]interface{}{"name": "Tablets", "slug": "tab"})
+	a.category(admin, map[string]interface{}{"name": "Tab", "slug": "tab-accessories"})
+
+	c, err := a.store.Categories.Find(context.Background(), "TAB")
+	if err != nil || c.ID != tablets {
+		t.Fatalf("Find(TAB) = %+v, %v; want the category with that slug", c, err)
+	}
+	if c, err := a.store.Categories.Find(context.Background(), "tab"); err != nil || c.ID != tablets {
+		t.Fatalf("Find(tab) = %+v, %v", c, err)
+	}
+	if c, err := a.store.Categories.Find(context.Background(), "Tab Accessories"); err == nil {
+		t.Fatalf("Find matched %+v by a name that does not exist", c)
+	}
+
+	pad := a.call("POST", "/api/products", admin, map[string]interface{}{"name": "Galaxy Tab S9", "price": 1000, "category": "tab"})
+	a.expect(pad, http.StatusCreated)
+	a.expect(a.call("POST", "/api/products", admin, map[string]interface{}{"name": "Sarung Tab", "price": 1000, "category": "tab-accessories"}), http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	pad.decode(t, &created)
+	if p := a.getProduct(created.ID); p.Category != "Tablets" {
+		t.Fatalf("category = %q, want Tablets", p.Category)
+	}
+	if got := a.list("/api/products?category=tab").ids(); len(got) != 1 || got[0] != created.ID {
+		t.Fatalf("category=tab: got %v", got)
+	}
+}
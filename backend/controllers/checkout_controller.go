@@ -1,209 +1,118 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/gorilla/mux"
 )
 
 // POST /api/users/{id}/checkout
+// Body: {"items": [{"product_id", "variant_id"}], "voucher_id"}; the older
+// {"product_ids": [...]} form checks out every line of those products.
+// Stock held by POST .../checkout/start counts as available to its owner.
 func Checkout(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+	checkout, ok := decodeCheckoutRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var req struct {
-		ProductIDs []string `json:"product_ids"`
-		VoucherID  string   `json:"voucher_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ProductIDs) == 0 {
-		utils.ErrorResponse(w, http.StatusBadRequest, "product_ids required")
-		return
-	}
-
-	tx, err := config.DB.Begin()
+	result, err := store.Orders.Checkout(r.Context(), checkout)
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to start transaction")
+		writeCheckoutError(w, err)
 		return
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
 
-	// 1. Fetch user balance
-	var balance int
-	err = tx.QueryRow("SELECT balance FROM users WHERE id = ?", userID).Scan(&balance)
+	utils.CreatedResponse(w, "Checkout successful", result)
+}
+
+// decodeCheckoutRequest reads the checkout body shared by Checkout and
+// StartCheckout and resolves product_ids to the user's cart lines
+func decodeCheckoutRequest(w http.ResponseWriter, r *http.Request) (models.CheckoutRequest, bool) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
-		return
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return models.CheckoutRequest{}, false
 	}
 
-	// 2. Fetch cart items for selected product IDs
-	type lineItem struct {
-		productID string
-		name      string
-		image     string
-		price     int
-		quantity  int
-	}
-	var items []lineItem
-	for _, pid := range req.ProductIDs {
-		var item lineItem
-		item.productID = pid
-		// Get cart quantity
-		err = tx.QueryRow("SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ?", userID, pid).Scan(&item.quantity)
-		if err == sql.ErrNoRows {
-			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Product %s not in cart", pid))
-			return
-		} else if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "DB error")
-			return
-		}
-		// Get product price, name, stock
-		// price is DECIMAL in DB so scan via float64 first, same as product_controller
-		var stock int
-		var priceFloat float64
-		err = tx.QueryRow("SELECT name, price, stock, COALESCE(image_url,'') FROM products WHERE id = ?", pid).Scan(&item.name, &priceFloat, &stock, &item.image)
-		if err != nil {
-			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Product %s not found", pid))
-			return
-		}
-		item.price = int(priceFloat)
-		if stock < item.quantity {
-			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Insufficient stock for %s", item.name))
-			return
-		}
-		items = append(items, item)
+	var req struct {
+		Items      []models.CartLine `json:"items"`
+		ProductIDs []string          `json:"product_ids"`
+		VoucherID  string            `json:"voucher_id"`
 	}
-
-	// 3. Compute subtotal
-	subtotal := 0
-	for _, item := range items {
-		subtotal += item.price * item.quantity
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Items)+len(req.ProductIDs) == 0 {
+		utils.ErrorResponse(w, http.StatusBadRequest, "items or product_ids required")
+		return models.CheckoutRequest{}, false
 	}
 
-	// 4. Apply voucher if provided
-	discount := 0
-	if req.VoucherID != "" {
-		var discountType string
-		var discountValueF, maxDiscountF, minPurchaseF float64 // all DECIMAL(15,2) in DB — must scan as float64
-		var usageLimit, usedCount int
-		var isActive bool
-		var validUntil time.Time
-		row := tx.QueryRow(`SELECT type, discount_value, COALESCE(max_discount,0), min_purchase, usage_limit, used_count, is_active, valid_until FROM vouchers WHERE id = ?`, req.VoucherID)
-		err = row.Scan(&discountType, &discountValueF, &maxDiscountF, &minPurchaseF, &usageLimit, &usedCount, &isActive, &validUntil)
+	checkout := models.CheckoutRequest{UserID: userID, Lines: req.Items}
+	if len(req.ProductIDs) > 0 {
+		// product_ids selects every cart line of those products, whatever the variant
+		cart, err := store.Carts.List(r.Context(), userID)
 		if err != nil {
-			// Voucher not found — ignore silently
-			err = nil
-		} else if isActive && validUntil.After(time.Now()) && (usageLimit == 0 || usedCount < usageLimit) && subtotal >= int(minPurchaseF) {
-			discountValue := int(discountValueF)
-			maxDiscount := int(maxDiscountF)
-			switch discountType {
-			case "percentage":
-				discount = subtotal * discountValue / 100
-				if maxDiscount > 0 && discount > maxDiscount {
-					discount = maxDiscount
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch cart items")
+			return models.CheckoutRequest{}, false
+		}
+		for _, pid := range req.ProductIDs {
+			id, err := strconv.Atoi(pid)
+			if err != nil {
+				utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Product %s not in cart", pid))
+				return models.CheckoutRequest{}, false
+			}
+			found := false
+			for _, item := range cart {
+				if item.ProductID == id {
+					checkout.Lines = append(checkout.Lines, models.CartLine{ProductID: id, VariantID: item.VariantID})
+					found = true
 				}
-			case "fixed_amount":
-				discount = discountValue
 			}
-			// Increment used_count
-			_, err = tx.Exec("UPDATE vouchers SET used_count = used_count + 1 WHERE id = ?", req.VoucherID)
-			if err != nil {
-				utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update voucher")
-				return
+			if !found {
+				// Let the repository report the missing line
+				checkout.Lines = append(checkout.Lines, models.CartLine{ProductID: id})
 			}
 		}
 	}
-
-	total := subtotal - discount
-	if total < 0 {
-		total = 0
-	}
-
-	// 5. Check balance
-	if balance < total {
-		err = fmt.Errorf("insufficient balance")
-		utils.ErrorResponse(w, http.StatusPaymentRequired, "Insufficient balance")
-		return
-	}
-
-	// 6. Deduct balance
-	_, err = tx.Exec("UPDATE users SET balance = balance - ? WHERE id = ?", total, userID)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to deduct balance: "+err.Error())
-		return
-	}
-
-	// 7. Increment total_spent
-	_, err = tx.Exec("UPDATE users SET total_spent = total_spent + ? WHERE id = ?", total, userID)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update total_spent: "+err.Error())
-		return
-	}
-
-	// 8. Create order — orders.id is AUTO_INCREMENT INT, order_number is the user-visible string
-	orderNumber := fmt.Sprintf("ORD-%d-%d", userID, time.Now().UnixMilli())
-	result, err := tx.Exec(
-		"INSERT INTO orders (order_number, user_id, address_id, subtotal, discount_amount, total_amount, status) VALUES (?, ?, NULL, ?, ?, ?, 'pending')",
-		orderNumber, userID, subtotal, discount, total,
-	)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create order: "+err.Error())
-		return
-	}
-	insertedOrderID, err := result.LastInsertId()
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get order ID: "+err.Error())
-		return
+	if req.VoucherID != "" {
+		checkout.VoucherID, _ = strconv.Atoi(req.VoucherID)
 	}
+	return checkout, true
+}
 
-	// 9. Insert order items, reduce stock, delete cart items
-	for _, item := range items {
-		subtotalItem := item.price * item.quantity
-		_, err = tx.Exec(
-			"INSERT INTO order_items (order_id, product_id, product_name, product_image, quantity, price, subtotal) VALUES (?, ?, ?, ?, ?, ?, ?)",
-			insertedOrderID, item.productID, item.name, item.image, item.quantity, item.price, subtotalItem,
-		)
-		if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to insert order item: "+err.Error())
-			return
-		}
-		_, err = tx.Exec("UPDATE products SET stock = stock - ? WHERE id = ?", item.quantity, item.productID)
-		if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update stock")
-			return
+// writeCheckoutError maps repository checkout errors to HTTP responses
+func writeCheckoutError(w http.ResponseWriter, err error) {
+	var itemErr *repository.ItemError
+	if errors.As(err, &itemErr) {
+		switch itemErr.Err {
+		case repository.ErrNotInCart:
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Product %s not in cart", itemErr.Item))
+		case repository.ErrProductNotFound:
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Product %s not found", itemErr.Item))
+		case repository.ErrInsufficientStock:
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Insufficient stock for %s", itemErr.Item))
+		default:
+			utils.ErrorResponse(w, http.StatusBadRequest, itemErr.Error())
 		}
-		_, err = tx.Exec("DELETE FROM cart_items WHERE user_id = ? AND product_id = ?", userID, item.productID)
-		if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to clear cart")
-			return
-		}
-	}
-
-	// 10. Commit
-	if err = tx.Commit(); err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
-
-	utils.CreatedResponse(w, "Checkout successful", map[string]interface{}{
-		"order_id": orderNumber,
-		"total":    total,
-		"discount": discount,
-	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		utils.ErrorResponse(w, http.StatusPaymentRequired, "Insufficient balance")
+	default:
+		log.Printf("❌ Checkout failed: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Checkout failed")
+	}
 }
 
 // GET /api/users/{id}/stats
@@ -215,98 +124,22 @@ func GetUserStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Total, pending, completed, this-year orders
-	var totalOrders, pendingOrders, completedOrders, thisYearOrders int
-	config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", userID).Scan(&totalOrders)
-	config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND status = 'pending'", userID).Scan(&pendingOrders)
-	config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND status = 'completed'", userID).Scan(&completedOrders)
-	thisYear := time.Now().Year()
-	config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND YEAR(created_at) = ?", userID, thisYear).Scan(&thisYearOrders)
-
-	// Monthly spending — last 6 months
-	type MonthData struct {
-		Month      string  `json:"month"`
-		Amount     int     `json:"amount"`
-		Percentage float64 `json:"percentage"`
-	}
-	var monthly []MonthData
-	rows, err := config.DB.Query(`
-		SELECT DATE_FORMAT(created_at, '%b') as month, SUM(total_amount) as amount
-		FROM orders
-		WHERE user_id = ? AND status = 'delivered' AND created_at >= DATE_SUB(NOW(), INTERVAL 6 MONTH)
-		GROUP BY YEAR(created_at), MONTH(created_at), DATE_FORMAT(created_at, '%b')
-		ORDER BY YEAR(created_at), MONTH(created_at)
-	`, userID)
-	maxAmount := 0
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var m MonthData
-			var amountF float64
-			rows.Scan(&m.Month, &amountF)
-			m.Amount = int(amountF)
-			if m.Amount > maxAmount {
-				maxAmount = m.Amount
-			}
-			monthly = append(monthly, m)
-		}
-	}
-	for i := range monthly {
-		if maxAmount > 0 {
-			monthly[i].Percentage = float64(monthly[i].Amount) / float64(maxAmount) * 100
-		}
-	}
-	if monthly == nil {
-		monthly = []MonthData{}
-	}
-
-	// Category breakdown (spending by product category)
-	type CategoryData struct {
-		Name       string  `json:"name"`
-		Value      int     `json:"value"`
-		Percentage float64 `json:"percentage"`
-	}
-	var categories []CategoryData
-	catRows, err := config.DB.Query(`
-		SELECT COALESCE(c.name, 'Other') as cat, SUM(oi.price * oi.quantity) as total
-		FROM order_items oi
-		JOIN orders o ON o.id = oi.order_id
-		JOIN products p ON p.id = oi.product_id
-		LEFT JOIN categories c ON c.id = p.category_id
-		WHERE o.user_id = ? AND o.status = 'delivered'
-		GROUP BY cat
-		ORDER BY total DESC
-		LIMIT 5
-	`, userID)
-	totalCatSpend := 0
-	if err == nil {
-		defer catRows.Close()
-		for catRows.Next() {
-			var c CategoryData
-			var valueF float64
-			catRows.Scan(&c.Name, &valueF)
-			c.Value = int(valueF)
-			totalCatSpend += c.Value
-			categories = append(categories, c)
-		}
-	}
-	for i := range categories {
-		if totalCatSpend > 0 {
-			categories[i].Percentage = float64(categories[i].Value) / float64(totalCatSpend) * 100
-		}
-	}
-	if categories == nil {
-		categories = []CategoryData{}
+	stats, err := store.Orders.Stats(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch stats")
+		return
 	}
 
-	utils.SuccessResponse(w, "Stats fetched", map[string]interface{}{
-		"total_orders":       totalOrders,
-		"pending_orders":     pendingOrders,
-		"completed_orders":   completedOrders,
-		"this_year_orders":   thisYearOrders,
-		"monthly_spending":   monthly,
-		"category_breakdown": categories,
-	})
+	utils.SuccessResponse(w, "Stats fetched", stats)
+}
+
+// orderListLimit reads ?limit=N (default 100)
+func orderListLimit(r *http.Request) int {
+	limit := 100
+	if l, e := strconv.Atoi(r.URL.Query().Get("limit")); e == nil && l > 0 {
+		limit = l
+	}
+	return limit
 }
 
 // GET /api/users/{id}/orders?limit=5
@@ -318,29 +151,11 @@ func GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100
-	if l, e := strconv.Atoi(limitStr); e == nil && l > 0 {
-		limit = l
-	}
-
-	rows, err := config.DB.Query(`
-		SELECT o.order_number, GROUP_CONCAT(p.name ORDER BY oi.id SEPARATOR ', ') as products,
-			SUM(oi.quantity) as total_qty, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at,
-			(SELECT COUNT(*) FROM reviews r WHERE r.order_id = o.id AND r.user_id = ?) > 0 as has_reviewed
-		FROM orders o
-		JOIN order_items oi ON oi.order_id = o.id
-		JOIN products p ON p.id = oi.product_id
-		WHERE o.user_id = ?
-		GROUP BY o.id, o.order_number, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at
-		ORDER BY o.created_at DESC
-		LIMIT ?
-	`, userID, userID, limit)
+	list, err := store.Orders.ListByUser(r.Context(), userID, orderListLimit(r))
 	if err != nil {
 		utils.SuccessResponse(w, "Orders fetched", []interface{}{})
 		return
 	}
-	defer rows.Close()
 
 	type OrderRow struct {
 		ID          string `json:"id"`
@@ -353,52 +168,30 @@ func GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		CreatedAt   string `json:"created_at"`
 		HasReviewed bool   `json:"has_reviewed"`
 	}
-	var orders []OrderRow
-	for rows.Next() {
-		var o OrderRow
-		var createdAt time.Time
-		var subtotalF, discountF, totalF float64
-		var hasReviewed int
-		rows.Scan(&o.ID, &o.Products, &o.TotalQty, &subtotalF, &discountF, &totalF, &o.Status, &createdAt, &hasReviewed)
-		o.Subtotal = int(subtotalF)
-		o.Discount = int(discountF)
-		o.Total = int(totalF)
-		o.CreatedAt = createdAt.Format("Jan 02, 2006")
-		o.HasReviewed = hasReviewed == 1
-		orders = append(orders, o)
-	}
-	if orders == nil {
-		orders = []OrderRow{}
+	orders := []OrderRow{}
+	for _, o := range list {
+		orders = append(orders, OrderRow{
+			ID:          o.OrderNumber,
+			Products:    o.Products,
+			TotalQty:    o.TotalQty,
+			Subtotal:    o.Subtotal,
+			Discount:    o.Discount,
+			Total:       o.Total,
+			Status:      o.Status,
+			CreatedAt:   o.CreatedAt.Format("Jan 02, 2006"),
+			HasReviewed: o.HasReviewed,
+		})
 	}
 	utils.SuccessResponse(w, "Orders fetched", orders)
 }
 
 // GET /api/orders (admin only - fetch all orders across all users)
 func GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	limit := 100
-	if l, e := strconv.Atoi(limitStr); e == nil && l > 0 {
-		limit = l
-	}
-
-	rows, err := config.DB.Query(`
-		SELECT o.order_number, o.user_id, u.full_name as user_name,
-			GROUP_CONCAT(p.name ORDER BY oi.id SEPARATOR ', ') as products,
-			SUM(oi.quantity) as total_qty, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at,
-			(SELECT COUNT(*) FROM reviews r WHERE r.order_id = o.id) > 0 as has_reviewed
-		FROM orders o
-		JOIN users u ON u.id = o.user_id
-		JOIN order_items oi ON oi.order_id = o.id
-		JOIN products p ON p.id = oi.product_id
-		GROUP BY o.id, o.order_number, o.user_id, u.full_name, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at
-		ORDER BY o.created_at DESC
-		LIMIT ?
-	`, limit)
+	list, err := store.Orders.ListByUser(r.Context(), 0, orderListLimit(r))
 	if err != nil {
 		utils.SuccessResponse(w, "Orders fetched", []interface{}{})
 		return
 	}
-	defer rows.Close()
 
 	type OrderRow struct {
 		ID          string `json:"id"`
@@ -413,36 +206,27 @@ func GetAllOrders(w http.ResponseWriter, r *http.Request) {
 		CreatedAt   string `json:"created_at"`
 		HasReviewed bool   `json:"has_reviewed"`
 	}
-	var orders []OrderRow
-	for rows.Next() {
-		var o OrderRow
-		var createdAt time.Time
-		var subtotalF, discountF, totalF float64
-		var hasReviewed int
-		rows.Scan(&o.ID, &o.UserID, &o.UserName, &o.Products, &o.TotalQty, &subtotalF, &discountF, &totalF, &o.Status, &createdAt, &hasReviewed)
-		o.Subtotal = int(subtotalF)
-		o.Discount = int(discountF)
-		o.Total = int(totalF)
-		o.CreatedAt = createdAt.Format("Jan 02, 2006")
-		o.HasReviewed = hasReviewed == 1
-		orders = append(orders, o)
-	}
-	if orders == nil {
-		orders = []OrderRow{}
+	orders := []OrderRow{}
+	for _, o := range list {
+		orders = append(orders, OrderRow{
+			ID:          o.OrderNumber,
+			UserID:      o.UserID,
+			UserName:    o.UserName,
+			Products:    o.Products,
+			TotalQty:    o.TotalQty,
+			Subtotal:    o.Subtotal,
+			Discount:    o.Discount,
+			Total:       o.Total,
+			Status:      o.Status,
+			CreatedAt:   o.CreatedAt.Format("Jan 02, 2006"),
+			HasReviewed: o.HasReviewed,
+		})
 	}
 	utils.SuccessResponse(w, "All orders fetched", orders)
 }
 
-// GET /api/users/{id}/orders/{orderNumber}
-func GetOrderDetail(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
-		return
-	}
-	orderNumber := vars["orderNumber"]
-
+// writeOrderDetail sends the order header and its items
+func writeOrderDetail(w http.ResponseWriter, order *models.Order) {
 	type OrderHeader struct {
 		OrderNumber string `json:"order_number"`
 		Status      string `json:"status"`
@@ -451,22 +235,6 @@ func GetOrderDetail(w http.ResponseWriter, r *http.Request) {
 		Total       int    `json:"total"`
 		CreatedAt   string `json:"created_at"`
 	}
-	var header OrderHeader
-	var createdAt time.Time
-	var subtotalF, discountF, totalF float64
-	err = config.DB.QueryRow(`
-		SELECT order_number, status, subtotal, discount_amount, total_amount, created_at
-		FROM orders WHERE order_number = ? AND user_id = ?
-	`, orderNumber, userID).Scan(&header.OrderNumber, &header.Status, &subtotalF, &discountF, &totalF, &createdAt)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
-		return
-	}
-	header.Subtotal = int(subtotalF)
-	header.Discount = int(discountF)
-	header.Total = int(totalF)
-	header.CreatedAt = createdAt.Format("Jan 02, 2006")
-
 	type ItemRow struct {
 		ProductID    int    `json:"product_id"`
 		ProductName  string `json:"product_name"`
@@ -474,35 +242,33 @@ func GetOrderDetail(w http.ResponseWriter, r *http.Request) {
 		Quantity     int    `json:"quantity"`
 		Price        int    `json:"price"`
 		Subtotal     int    `json:"subtotal"`
-	}
-	iRows, err := config.DB.Query(`
-		SELECT COALESCE(oi.product_id, 0),
-		       oi.product_name,
-		       COALESCE(NULLIF(oi.product_image,''), p.image_url, '') as img,
-		       oi.quantity, oi.price, oi.subtotal
-		FROM order_items oi
-		JOIN orders o ON o.id = oi.order_id
-		LEFT JOIN products p ON p.id = oi.product_id
-		WHERE o.order_number = ? AND o.user_id = ?
-		ORDER BY oi.id ASC
-	`, orderNumber, userID)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch order items: "+err.Error())
-		return
-	}
-	defer iRows.Close()
-
-	var orderItems []ItemRow
-	for iRows.Next() {
-		var item ItemRow
-		var pf, sf float64
-		iRows.Scan(&item.ProductID, &item.ProductName, &item.ProductImage, &item.Quantity, &pf, &sf)
-		item.Price = int(pf)
-		item.Subtotal = int(sf)
-		orderItems = append(orderItems, item)
-	}
-	if orderItems == nil {
-		orderItems = []ItemRow{}
+		// Set for products bought as a variant
+		VariantID int                    `json:"variant_id,omitempty"`
+		SKU       string                 `json:"sku,omitempty"`
+		Options   []models.VariantOption `json:"options,omitempty"`
+	}
+
+	header := OrderHeader{
+		OrderNumber: order.OrderNumber,
+		Status:      order.Status,
+		Subtotal:    order.Subtotal,
+		Discount:    order.Discount,
+		Total:       order.Total,
+		CreatedAt:   order.CreatedAt.Format("Jan 02, 2006"),
+	}
+	orderItems := []ItemRow{}
+	for _, item := range order.Items {
+		orderItems = append(orderItems, ItemRow{
+			ProductID:    item.ProductID,
+			ProductName:  item.ProductName,
+			ProductImage: item.ProductImage,
+			Quantity:     item.Quantity,
+			Price:        item.Price,
+			Subtotal:     item.Subtotal,
+			VariantID:    item.VariantID,
+			SKU:          item.SKU,
+			Options:      item.Options,
+		})
 	}
 	utils.SuccessResponse(w, "Order detail fetched", map[string]interface{}{
 		"order": header,
@@ -510,291 +276,192 @@ func GetOrderDetail(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GET /api/orders/{orderNumber} (admin only - view any order)
-func GetOrderDetailAdmin(w http.ResponseWriter, r *http.Request) {
+// GET /api/users/{id}/orders/{orderNumber}
+func GetOrderDetail(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	orderNumber := vars["orderNumber"]
-
-	type OrderHeader struct {
-		OrderNumber string `json:"order_number"`
-		Status      string `json:"status"`
-		Subtotal    int    `json:"subtotal"`
-		Discount    int    `json:"discount"`
-		Total       int    `json:"total"`
-		CreatedAt   string `json:"created_at"`
-	}
-	var header OrderHeader
-	var createdAt time.Time
-	var subtotalF, discountF, totalF float64
-	err := config.DB.QueryRow(`
-		SELECT order_number, status, subtotal, discount_amount, total_amount, created_at
-		FROM orders WHERE order_number = ?
-	`, orderNumber).Scan(&header.OrderNumber, &header.Status, &subtotalF, &discountF, &totalF, &createdAt)
+	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
-	header.Subtotal = int(subtotalF)
-	header.Discount = int(discountF)
-	header.Total = int(totalF)
-	header.CreatedAt = createdAt.Format("Jan 02, 2006")
 
-	type ItemRow struct {
-		ProductID    int    `json:"product_id"`
-		ProductName  string `json:"product_name"`
-		ProductImage string `json:"product_image"`
-		Quantity     int    `json:"quantity"`
-		Price        int    `json:"price"`
-		Subtotal     int    `json:"subtotal"`
-	}
-	iRows, err := config.DB.Query(`
-		SELECT COALESCE(oi.product_id, 0),
-		       oi.product_name,
-		       COALESCE(NULLIF(oi.product_image,''), p.image_url, '') as img,
-		       oi.quantity, oi.price, oi.subtotal
-		FROM order_items oi
-		JOIN orders o ON o.id = oi.order_id
-		LEFT JOIN products p ON p.id = oi.product_id
-		WHERE o.order_number = ?
-		ORDER BY oi.id ASC
-	`, orderNumber)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch order items: "+err.Error())
+	order, err := store.Orders.Get(r.Context(), vars["orderNumber"])
+	if err != nil || order.UserID != userID {
+		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
-	defer iRows.Close()
-
-	var orderItems []ItemRow
-	for iRows.Next() {
-		var item ItemRow
-		var pf, sf float64
-		iRows.Scan(&item.ProductID, &item.ProductName, &item.ProductImage, &item.Quantity, &pf, &sf)
-		item.Price = int(pf)
-		item.Subtotal = int(sf)
-		orderItems = append(orderItems, item)
-	}
-	if orderItems == nil {
-		orderItems = []ItemRow{}
-	}
-	utils.SuccessResponse(w, "Order detail fetched", map[string]interface{}{
-		"order": header,
-		"items": orderItems,
-	})
+	writeOrderDetail(w, order)
 }
 
-// PATCH /api/users/{id}/orders/{orderNumber}/status
-func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID, err := strconv.Atoi(vars["id"])
+// GET /api/orders/{orderNumber} (admin only - view any order)
+func GetOrderDetailAdmin(w http.ResponseWriter, r *http.Request) {
+	order, err := store.Orders.Get(r.Context(), mux.Vars(r)["orderNumber"])
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
-	orderNumber := vars["orderNumber"]
+	writeOrderDetail(w, order)
+}
 
-	var req struct {
-		Status string `json:"status"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
+// orderStatusRequest is the body of the PATCH .../status endpoints
+type orderStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
 
-	validStatuses := map[string]bool{
-		"pending": true, "processing": true, "shipped": true,
-		"delivered": true, "cancelled": true,
+// applyOrderStatus moves order to req.Status if the lifecycle allows the caller's
+// role to make that move; cancelling restores stock and refunds the balance
+func applyOrderStatus(w http.ResponseWriter, r *http.Request, order *models.Order, req orderStatusRequest) {
+	actorID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+	role, _ := r.Context().Value(middlewares.RoleKey).(string)
+
+	valid, permitted := models.OrderTransition(order.Status, req.Status, role)
+	if !valid {
+		utils.ErrorResponse(w, http.StatusConflict, fmt.Sprintf("Cannot change order status from %s to %s", order.Status, req.Status))
+		return
 	}
-	if !validStatuses[req.Status] {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid status")
+	if !permitted {
+		utils.ErrorResponse(w, http.StatusForbidden, fmt.Sprintf("You are not allowed to change order status from %s to %s", order.Status, req.Status))
 		return
 	}
 
-	// Fetch current order state
-	var orderID int
-	var currentStatus string
-	var totalAmountF float64
-	err = config.DB.QueryRow(
-		"SELECT id, status, total_amount FROM orders WHERE order_number = ? AND user_id = ?",
-		orderNumber, userID,
-	).Scan(&orderID, &currentStatus, &totalAmountF)
-	if err != nil {
+	err := store.Orders.Transition(r.Context(), models.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   req.Status,
+		ChangedBy:  actorID,
+		ActorRole:  role,
+		Note:       req.Note,
+	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
 		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
+	case errors.Is(err, repository.ErrStatusConflict):
+		utils.ErrorResponse(w, http.StatusConflict, "Order status was changed by another request, please reload")
+		return
+	case err != nil:
+		log.Printf("❌ Failed to change status of order %d to %s: %v", order.ID, req.Status, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update status")
+		return
 	}
-	totalAmount := int(totalAmountF)
-
-	// Cancelling: restore stock + refund balance inside a transaction
-	if req.Status == "cancelled" && currentStatus != "cancelled" {
-		// Collect items to restore BEFORE opening transaction (avoid interleaving Query+Exec on same conn)
-		type stockItem struct {
-			productID string
-			qty       int
-		}
-		var toRestore []stockItem
-		qRows, qErr := config.DB.Query("SELECT product_id, quantity FROM order_items WHERE order_id = ?", orderID)
-		if qErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch order items: "+qErr.Error())
-			return
-		}
-		for qRows.Next() {
-			var si stockItem
-			qRows.Scan(&si.productID, &si.qty)
-			toRestore = append(toRestore, si)
-		}
-		qRows.Close()
-
-		tx, txErr := config.DB.Begin()
-		if txErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to start transaction")
-			return
-		}
-
-		// Update order status
-		if _, txErr = tx.Exec("UPDATE orders SET status = 'cancelled' WHERE id = ?", orderID); txErr != nil {
-			tx.Rollback()
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update status: "+txErr.Error())
-			return
-		}
-
-		// Restore stock for each item
-		for _, si := range toRestore {
-			tx.Exec("UPDATE products SET stock = stock + ? WHERE id = ?", si.qty, si.productID)
-		}
-
-		// Refund balance and decrement total_spent
-		if _, txErr = tx.Exec(
-			"UPDATE users SET balance = balance + ?, total_spent = GREATEST(0, total_spent - ?) WHERE id = ?",
-			totalAmount, totalAmount, userID,
-		); txErr != nil {
-			tx.Rollback()
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to refund balance: "+txErr.Error())
-			return
-		}
 
-		if txErr = tx.Commit(); txErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to commit: "+txErr.Error())
-			return
-		}
+	if req.Status == models.OrderCancelled {
 		utils.SuccessResponse(w, "Order cancelled. Stock restored and balance refunded.", map[string]string{"status": req.Status})
 		return
 	}
+	utils.SuccessResponse(w, "Order status updated", map[string]string{"status": req.Status})
+}
 
-	// Non-cancel status update
-	result, err := config.DB.Exec(
-		"UPDATE orders SET status = ? WHERE order_number = ? AND user_id = ?",
-		req.Status, orderNumber, userID,
-	)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update status: "+err.Error())
-		return
+// decodeOrderStatus reads and validates the PATCH .../status body
+func decodeOrderStatus(w http.ResponseWriter, r *http.Request) (orderStatusRequest, bool) {
+	var req orderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return req, false
 	}
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
-		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
-		return
+	if !models.IsOrderStatus(req.Status) {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid status")
+		return req, false
 	}
-	utils.SuccessResponse(w, "Order status updated", map[string]string{"status": req.Status})
+	return req, true
 }
 
-// PATCH /api/orders/{orderNumber}/status (admin only - update any order)
-func UpdateOrderStatusAdmin(w http.ResponseWriter, r *http.Request) {
+// PATCH /api/users/{id}/orders/{orderNumber}/status
+// Customers may only cancel their own pending orders.
+func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	orderNumber := vars["orderNumber"]
-
-	var req struct {
-		Status string `json:"status"`
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+
+	req, ok := decodeOrderStatus(w, r)
+	if !ok {
 		return
 	}
 
-	validStatuses := map[string]bool{
-		"pending": true, "processing": true, "shipped": true,
-		"delivered": true, "cancelled": true,
+	order, err := store.Orders.Get(r.Context(), vars["orderNumber"])
+	if err != nil || order.UserID != userID {
+		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
+		return
 	}
-	if !validStatuses[req.Status] {
-		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid status")
+	applyOrderStatus(w, r, order, req)
+}
+
+// PATCH /api/orders/{orderNumber}/status (admin only - advance or cancel any order)
+func UpdateOrderStatusAdmin(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeOrderStatus(w, r)
+	if !ok {
 		return
 	}
 
-	// Fetch current order state (no user_id restriction for admin)
-	var orderID int
-	var currentStatus string
-	var totalAmountF float64
-	var userID int
-	err := config.DB.QueryRow(
-		"SELECT id, status, total_amount, user_id FROM orders WHERE order_number = ?",
-		orderNumber,
-	).Scan(&orderID, &currentStatus, &totalAmountF, &userID)
+	order, err := store.Orders.Get(r.Context(), mux.Vars(r)["orderNumber"])
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
-	totalAmount := int(totalAmountF)
+	applyOrderStatus(w, r, order, req)
+}
 
-	// Cancelling: restore stock + refund balance
-	if req.Status == "cancelled" && currentStatus != "cancelled" {
-		type stockItem struct {
-			productID string
-			qty       int
-		}
-		var toRestore []stockItem
-		qRows, qErr := config.DB.Query("SELECT product_id, quantity FROM order_items WHERE order_id = ?", orderID)
-		if qErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch order items: "+qErr.Error())
-			return
-		}
-		for qRows.Next() {
-			var si stockItem
-			qRows.Scan(&si.productID, &si.qty)
-			toRestore = append(toRestore, si)
-		}
-		qRows.Close()
+// writeOrderHistory sends the status timeline of order
+func writeOrderHistory(w http.ResponseWriter, r *http.Request, order *models.Order) {
+	history, err := store.Orders.History(r.Context(), order.ID)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch order history")
+		return
+	}
+
+	type TimelineRow struct {
+		FromStatus string `json:"from_status"`
+		ToStatus   string `json:"to_status"`
+		ChangedBy  int    `json:"changed_by"`
+		ActorRole  string `json:"actor_role"`
+		Note       string `json:"note"`
+		CreatedAt  string `json:"created_at"`
+	}
+	timeline := []TimelineRow{}
+	for _, c := range history {
+		timeline = append(timeline, TimelineRow{
+			FromStatus: c.FromStatus,
+			ToStatus:   c.ToStatus,
+			ChangedBy:  c.ChangedBy,
+			ActorRole:  c.ActorRole,
+			Note:       c.Note,
+			CreatedAt:  c.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	utils.SuccessResponse(w, "Order history fetched", map[string]interface{}{
+		"order_number": order.OrderNumber,
+		"status":       order.Status,
+		"timeline":     timeline,
+	})
+}
 
-		tx, txErr := config.DB.Begin()
-		if txErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to start transaction")
-			return
-		}
-		for _, si := range toRestore {
-			if _, e := tx.Exec("UPDATE products SET stock = stock + ? WHERE id = ?", si.qty, si.productID); e != nil {
-				tx.Rollback()
-				utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to restore stock")
-				return
-			}
-		}
-		if _, e := tx.Exec("UPDATE users SET balance = balance + ? WHERE id = ?", totalAmount, userID); e != nil {
-			tx.Rollback()
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to refund balance")
-			return
-		}
-		if _, e := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", req.Status, orderID); e != nil {
-			tx.Rollback()
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update status")
-			return
-		}
-		if cErr := tx.Commit(); cErr != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Transaction commit failed")
-			return
-		}
-		utils.SuccessResponse(w, "Order cancelled, stock restored, balance refunded", map[string]string{"status": req.Status})
+// GET /api/users/{id}/orders/{orderNumber}/history
+func GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
-	// Non-cancel status update (no user_id restriction)
-	result, err := config.DB.Exec(
-		"UPDATE orders SET status = ? WHERE order_number = ?",
-		req.Status, orderNumber,
-	)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update status: "+err.Error())
+	order, err := store.Orders.Get(r.Context(), vars["orderNumber"])
+	if err != nil || order.UserID != userID {
+		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
+	writeOrderHistory(w, r, order)
+}
+
+// GET /api/orders/{orderNumber}/history (admin only)
+func GetOrderHistoryAdmin(w http.ResponseWriter, r *http.Request) {
+	order, err := store.Orders.Get(r.Context(), mux.Vars(r)["orderNumber"])
+	if err != nil {
 		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
-	utils.SuccessResponse(w, "Order status updated", map[string]string{"status": req.Status})
+	writeOrderHistory(w, r, order)
 }
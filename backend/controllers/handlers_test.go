@@ -0,0 +1,791 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/controllers"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/memory"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/routes"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "controllers-test-secret")
+	os.Exit(m.Run())
+}
+
+// testAPI is the full router over a fresh memory store
+type testAPI struct {
+	t      *testing.T
+	store  *repository.Store
+	router *mux.Router
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	s := memory.New()
+	router := routes.SetupRoutes(s)
+	// The search indexes are package globals; start each test from this store
+	if err := controllers.BuildSearchIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return &testAPI{t: t, store: s, router: router}
+}
+
+// apiResponse is the envelope written by utils.JSONResponse
+type apiResponse struct {
+	Code    int
+	Header  http.Header
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// call sends body (marshalled unless it is a string) with token and decodes the response
+func (a *testAPI) call(method, path, token string, body interface{}, headers ...string) apiResponse {
+	a.t.Helper()
+	var raw string
+	switch b := body.(type) {
+	case nil:
+	case string:
+		raw = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		raw = string(encoded)
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+
+	res := apiResponse{Code: rec.Code, Header: rec.Header()}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		a.t.Fatalf("%s %s: invalid JSON response %q", method, path, rec.Body.String())
+	}
+	return res
+}
+
+// decode unmarshals the response data into v
+func (r apiResponse) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("decode %s: %v", r.Data, err)
+	}
+}
+
+func (a *testAPI) expect(res apiResponse, code int) {
+	a.t.Helper()
+	if res.Code != code {
+		a.t.Fatalf("got %d (%s%s), want %d", res.Code, res.Message, res.Error, code)
+	}
+}
+
+// user creates a user with role and returns its ID and an access token
+func (a *testAPI) user(email, role string) (int, string) {
+	a.t.Helper()
+	u := &models.User{Email: email, FullName: email, Role: role}
+	if err := a.store.Users.Create(context.Background(), u); err != nil {
+		a.t.Fatal(err)
+	}
+	token, err := utils.GenerateToken(u.ID, role)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return u.ID, token
+}
+
+// product creates a product through the admin API and returns its ID
+func (a *testAPI) product(adminToken string, name string, price, stock int) int {
+	a.t.Helper()
+	res := a.call("POST", "/api/products", adminToken, map[string]interface{}{
+		"name": name, "price": price, "stock": stock, "category": "Smartphones",
+	})
+	a.expect(res, http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	res.decode(a.t, &created)
+	return created.ID
+}
+
+func (a *testAPI) balance(userID int, token string) int {
+	a.t.Helper()
+	res := a.call("GET", fmt.Sprintf("/api/users/%d/balance", userID), token, nil)
+	a.expect(res, http.StatusOK)
+	var b struct {
+		Balance int `json:"balance"`
+	}
+	res.decode(a.t, &b)
+	return b.Balance
+}
+
+func (a *testAPI) stock(productID int) int {
+	a.t.Helper()
+	res := a.call("GET", fmt.Sprintf("/api/products/%d", productID), "", nil)
+	a.expect(res, http.StatusOK)
+	var p struct {
+		Stock int `json:"stock"`
+	}
+	res.decode(a.t, &p)
+	return p.Stock
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	a := newTestAPI(t)
+	register := map[string]string{"full_name": "Budi", "phone": "0812", "email": "budi@example.com", "password": "rahasia123"}
+
+	a.expect(a.call("POST", "/api/auth/register", "", register), http.StatusCreated)
+	a.expect(a.call("POST", "/api/auth/register", "", register), http.StatusConflict)
+	a.expect(a.call("POST", "/api/auth/register", "", map[string]string{"email": "x@example.com"}), http.StatusBadRequest)
+
+	a.expect(a.call("POST", "/api/auth/login", "", map[string]string{"email": "budi@example.com", "password": "salah"}), http.StatusUnauthorized)
+	res := a.call("POST", "/api/auth/login", "", map[string]string{"email": "budi@example.com", "password": "rahasia123"})
+	a.expect(res, http.StatusOK)
+	var login struct {
+		Token string      `json:"token"`
+		User  models.User `json:"user"`
+	}
+	res.decode(t, &login)
+	if login.Token == "" || login.User.Email != "budi@example.com" || login.User.Password != "" {
+		t.Fatalf("unexpected login response %s", res.Data)
+	}
+
+	// The issued token opens the user's own routes
+	a.expect(a.call("GET", fmt.Sprintf("/api/users/%d", login.User.ID), login.Token, nil), http.StatusOK)
+}
+
+func TestProductCatalog(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+
+	a.expect(a.call("POST", "/api/products", customer, map[string]interface{}{"name": "Phone", "price": 100}), http.StatusForbidden)
+	a.expect(a.call("POST", "/api/products", admin, map[string]interface{}{"name": "", "price": 100}), http.StatusBadRequest)
+	id := a.product(admin, "Galaxy S24", 12000000, 5)
+
+	res := a.call("GET", "/api/products", "", nil)
+	a.expect(res, http.StatusOK)
+	var list struct {
+		Products []models.Product `json:"products"`
+	}
+	res.decode(t, &list)
+	if len(list.Products) != 1 || list.Products[0].ID != id || list.Products[0].Slug != "galaxy-s24" {
+		t.Fatalf("unexpected product list %s", res.Data)
+	}
+
+	if got := a.stock(id); got != 5 {
+		t.Fatalf("stock = %d, want 5", got)
+	}
+	a.expect(a.call("GET", "/api/products/999", "", nil), http.StatusNotFound)
+}
+
+func TestCartAndCheckout(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1000, 5)
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 10000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 3}), http.StatusCreated)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": 999}), http.StatusNotFound)
+
+	res := a.call("GET", base+"/cart", token, nil)
+	a.expect(res, http.StatusOK)
+	var cart []struct {
+		ProductID string `json:"product_id"`
+		Quantity  int    `json:"quantity"`
+	}
+	res.decode(t, &cart)
+	if len(cart) != 1 || cart[0].ProductID != fmt.Sprint(productID) || cart[0].Quantity != 3 {
+		t.Fatalf("unexpected cart %s", res.Data)
+	}
+
+	checkout := map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}}
+	res = a.call("POST", base+"/checkout", token, checkout)
+	a.expect(res, http.StatusCreated)
+	var result models.CheckoutResult
+	res.decode(t, &result)
+	if result.Total != 3000 {
+		t.Fatalf("total = %d, want 3000", result.Total)
+	}
+	if got := a.balance(userID, token); got != 7000 {
+		t.Fatalf("balance = %d, want 7000", got)
+	}
+	if got := a.stock(productID); got != 2 {
+		t.Fatalf("stock = %d, want 2", got)
+	}
+
+	// The line left the cart with the order
+	res = a.call("POST", base+"/checkout", token, checkout)
+	a.expect(res, http.StatusBadRequest)
+	if !strings.Contains(res.Error, "not in cart") {
+		t.Fatalf("error = %q", res.Error)
+	}
+
+	res = a.call("GET", base+"/orders", token, nil)
+	a.expect(res, http.StatusOK)
+	var orders []struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	res.decode(t, &orders)
+	if len(orders) != 1 || orders[0].ID != result.OrderNumber || orders[0].Status != models.OrderPending {
+		t.Fatalf("unexpected orders %s", res.Data)
+	}
+}
+
+func TestCheckoutRejectsShortStockAndBalance(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1000, 2)
+	base := fmt.Sprintf("/api/users/%d", userID)
+	checkout := map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}}
+
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 3}), http.StatusCreated)
+	res := a.call("POST", base+"/checkout", token, checkout)
+	a.expect(res, http.StatusBadRequest)
+	if !strings.Contains(res.Error, "Insufficient stock") {
+		t.Fatalf("error = %q", res.Error)
+	}
+
+	a.expect(a.call("PUT", fmt.Sprintf("%s/cart/%d", base, productID), token, map[string]int{"quantity": 1}), http.StatusOK)
+	a.expect(a.call("POST", base+"/checkout", token, checkout), http.StatusPaymentRequired)
+	if got := a.stock(productID); got != 2 {
+		t.Fatalf("failed checkouts changed stock to %d", got)
+	}
+}
+
+func TestCancelOrderRestoresStockAndBalance(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1000, 5)
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 5000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 2}), http.StatusCreated)
+	res := a.call("POST", base+"/checkout", token, map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}})
+	a.expect(res, http.StatusCreated)
+	var result models.CheckoutResult
+	res.decode(t, &result)
+
+	orderPath := base + "/orders/" + result.OrderNumber
+	// Customers may cancel but not advance their own orders
+	a.expect(a.call("PATCH", orderPath+"/status", token, map[string]string{"status": models.OrderProcessing}), http.StatusForbidden)
+	a.expect(a.call("PATCH", orderPath+"/status", token, map[string]string{"status": models.OrderCancelled}), http.StatusOK)
+	a.expect(a.call("PATCH", orderPath+"/status", token, map[string]string{"status": models.OrderCancelled}), http.StatusConflict)
+
+	if got := a.stock(productID); got != 5 {
+		t.Fatalf("stock = %d, want 5", got)
+	}
+	if got := a.balance(userID, token); got != 5000 {
+		t.Fatalf("balance = %d, want 5000", got)
+	}
+
+	res = a.call("GET", orderPath+"/history", token, nil)
+	a.expect(res, http.StatusOK)
+	var history struct {
+		Status   string `json:"status"`
+		Timeline []struct {
+			ToStatus string `json:"to_status"`
+		} `json:"timeline"`
+	}
+	res.decode(t, &history)
+	if history.Status != models.OrderCancelled || len(history.Timeline) != 2 {
+		t.Fatalf("unexpected history %s", res.Data)
+	}
+}
+
+func TestTopUpIdempotencyKey(t *testing.T) {
+	a := newTestAPI(t)
+	userID, token := a.user("customer@example.com", "customer")
+	path := fmt.Sprintf("/api/users/%d/topup", userID)
+
+	first := a.call("POST", path, token, `{"amount":2500}`, "Idempotency-Key", "topup-1")
+	a.expect(first, http.StatusOK)
+	replay := a.call("POST", path, token, `{"amount":2500}`, "Idempotency-Key", "topup-1")
+	a.expect(replay, http.StatusOK)
+	if string(replay.Data) != string(first.Data) {
+		t.Fatalf("replay returned %s, want %s", replay.Data, first.Data)
+	}
+	a.expect(a.call("POST", path, token, `{"amount":9999}`, "Idempotency-Key", "topup-1"), http.StatusConflict)
+
+	if got := a.balance(userID, token); got != 2500 {
+		t.Fatalf("balance = %d, want 2500", got)
+	}
+}
+
+func TestOrderTransitionMatrix(t *testing.T) {
+	// path is the status reached through admin moves before the tested move
+	paths := map[string][]string{
+		models.OrderPending:    nil,
+		models.OrderProcessing: {models.OrderProcessing},
+		models.OrderShipped:    {models.OrderProcessing, models.OrderShipped},
+		models.OrderDelivered:  {models.OrderProcessing, models.OrderShipped, models.OrderDelivered},
+		models.OrderCancelled:  {models.OrderCancelled},
+	}
+	tests := []struct {
+		from, to, role string
+		want           int
+	}{
+		{models.OrderPending, models.OrderCancelled, "customer", http.StatusOK},
+		{models.OrderPending, models.OrderProcessing, "customer", http.StatusForbidden},
+		{models.OrderProcessing, models.OrderCancelled, "customer", http.StatusForbidden},
+		{models.OrderProcessing, models.OrderShipped, "customer", http.StatusForbidden},
+		{models.OrderShipped, models.OrderDelivered, "customer", http.StatusForbidden},
+		{models.OrderDelivered, models.OrderCancelled, "customer", http.StatusConflict},
+		{models.OrderPending, models.OrderProcessing, "admin", http.StatusOK},
+		{models.OrderProcessing, models.OrderCancelled, "admin", http.StatusOK},
+		{models.OrderShipped, models.OrderDelivered, "admin", http.StatusOK},
+		{models.OrderPending, models.OrderShipped, "admin", http.StatusConflict},
+		{models.OrderShipped, models.OrderCancelled, "admin", http.StatusConflict},
+		{models.OrderShipped, models.OrderProcessing, "admin", http.StatusConflict},
+		{models.OrderDelivered, models.OrderCancelled, "admin", http.StatusConflict},
+		{models.OrderCancelled, models.OrderPending, "admin", http.StatusConflict},
+		{models.OrderPending, "refunded", "admin", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to+" as "+tt.role, func(t *testing.T) {
+			a := newTestAPI(t)
+			_, admin := a.user("admin@example.com", "admin")
+			userID, customer := a.user("customer@example.com", "customer")
+			productID := a.product(admin, "Phone", 1000, 5)
+			base := fmt.Sprintf("/api/users/%d", userID)
+			a.expect(a.call("POST", base+"/topup", customer, map[string]int{"amount": 1000}), http.StatusOK)
+			a.expect(a.call("POST", base+"/cart", customer, map[string]int{"product_id": productID, "quantity": 1}), http.StatusCreated)
+			res := a.call("POST", base+"/checkout", customer, map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}})
+			a.expect(res, http.StatusCreated)
+			var result models.CheckoutResult
+			res.decode(t, &result)
+
+			adminPath := "/api/orders/" + result.OrderNumber
+			for _, status := range paths[tt.from] {
+				a.expect(a.call("PATCH", adminPath+"/status", admin, map[string]string{"status": status}), http.StatusOK)
+			}
+			path, token := adminPath, admin
+			if tt.role == "customer" {
+				path, token = base+"/orders/"+result.OrderNumber, customer
+			}
+			a.expect(a.call("PATCH", path+"/status", token, map[string]string{"status": tt.to}), tt.want)
+
+			// The history records every applied move after the checkout entry
+			res = a.call("GET", path+"/history", token, nil)
+			a.expect(res, http.StatusOK)
+			var history struct {
+				Status   string `json:"status"`
+				Timeline []struct {
+					FromStatus string `json:"from_status"`
+					ToStatus   string `json:"to_status"`
+				} `json:"timeline"`
+			}
+			res.decode(t, &history)
+			moves := len(paths[tt.from])
+			want := tt.from
+			if tt.want == http.StatusOK {
+				moves++
+				want = tt.to
+			}
+			if history.Status != want || len(history.Timeline) != moves+1 {
+				t.Fatalf("history %s, want status %s after %d moves", res.Data, want, moves)
+			}
+			if last := history.Timeline[len(history.Timeline)-1]; moves > 0 && last.ToStatus != want {
+				t.Fatalf("last timeline entry %+v, want to_status %s", last, want)
+			}
+		})
+	}
+}
+
+// failingOrders is an order store whose writes fail with a driver error
+type failingOrders struct {
+	repository.OrderRepository
+}
+
+func (failingOrders) Checkout(ctx context.Context, req models.CheckoutRequest) (*models.CheckoutResult, error) {
+	return nil, fmt.Errorf("Error 1205: Lock wait timeout exceeded")
+}
+
+func (failingOrders) Transition(ctx context.Context, change models.OrderStatusChange) error {
+	return fmt.Errorf("Error 1205: Lock wait timeout exceeded")
+}
+
+func TestOrderStoreErrorsAreNotLeaked(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1000, 5)
+	base := fmt.Sprintf("/api/users/%d", userID)
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 1000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 1}), http.StatusCreated)
+	checkout := map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}}
+	res := a.call("POST", base+"/checkout", token, checkout)
+	a.expect(res, http.StatusCreated)
+	var result models.CheckoutResult
+	res.decode(t, &result)
+
+	a.store.Orders = failingOrders{a.store.Orders}
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 1}), http.StatusCreated)
+	for _, res := range []apiResponse{
+		a.call("POST", base+"/checkout", token, checkout),
+		a.call("PATCH", "/api/orders/"+result.OrderNumber+"/status", admin, map[string]string{"status": models.OrderProcessing}),
+	} {
+		a.expect(res, http.StatusInternalServerError)
+		if strings.Contains(res.Error, "1205") {
+			t.Errorf("driver error leaked: %q", res.Error)
+		}
+	}
+}
+
+func TestAdminSetsBalanceThroughLedger(t *testing.T) {
+	a := newTestAPI(t)
+	_, adminToken := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	path := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("PUT", path, adminToken, `{"full_name":"Customer","balance":5000}`), http.StatusOK)
+	a.expect(a.call("PUT", path, adminToken, `{"full_name":"Customer","balance":5000}`), http.StatusOK)
+	a.expect(a.call("PUT", path, adminToken, `{"full_name":"Renamed","balance":-1}`), http.StatusBadRequest)
+	if got := a.balance(userID, token); got != 5000 {
+		t.Fatalf("balance = %d, want 5000", got)
+	}
+	if u, err := a.store.Users.GetByID(context.Background(), userID); err != nil || u.FullName != "Customer" {
+		t.Fatalf("rejected update saved the profile: %+v, %v", u, err)
+	}
+	if _, n, _ := a.store.Wallet.Statement(context.Background(), userID, 0, 0); n != 1 {
+		t.Fatalf("ledger has %d entries, want 1 adjustment", n)
+	}
+
+	res := a.call("POST", path+"/wallet/reconcile", adminToken, nil)
+	a.expect(res, http.StatusOK)
+	var reconciled struct {
+		Balance int `json:"balance"`
+		Drift   int `json:"drift"`
+	}
+	res.decode(t, &reconciled)
+	if reconciled.Balance != 5000 || reconciled.Drift != 0 {
+		t.Fatalf("reconcile = %+v, want balance 5000 without drift", reconciled)
+	}
+}
+
+func TestWalletLedgerBalancesToZero(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1500, 5)
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 5000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 2}), http.StatusCreated)
+	res := a.call("POST", base+"/checkout", token, map[string]interface{}{"items": []models.CartLine{{ProductID: productID}}})
+	a.expect(res, http.StatusCreated)
+	var result models.CheckoutResult
+	res.decode(t, &result)
+	a.expect(a.call("PATCH", base+"/orders/"+result.OrderNumber+"/status", token, map[string]string{"status": models.OrderCancelled}), http.StatusOK)
+	a.expect(a.call("POST", base+"/wallet/adjustments", admin, map[string]interface{}{"amount": -700, "description": "Correction"}), http.StatusCreated)
+
+	res = a.call("GET", "/api/admin/wallet/trial-balance", admin, nil)
+	a.expect(res, http.StatusOK)
+	var trial struct {
+		Accounts []models.AccountBalance `json:"accounts"`
+		Total    int                     `json:"total"`
+	}
+	res.decode(t, &trial)
+	want := map[string]int{
+		models.AccountWallets:     4300,
+		models.AccountTopUps:      -5000,
+		models.AccountSales:       0,
+		models.AccountAdjustments: 700,
+	}
+	if trial.Total != 0 || len(trial.Accounts) != len(want) {
+		t.Fatalf("trial balance %s", res.Data)
+	}
+	for _, b := range trial.Accounts {
+		if want[b.Account] != b.Balance {
+			t.Errorf("%s = %d, want %d", b.Account, b.Balance, want[b.Account])
+		}
+	}
+	if got := a.balance(userID, token); got != 4300 {
+		t.Fatalf("balance = %d, want 4300", got)
+	}
+	a.expect(a.call("GET", "/api/admin/wallet/trial-balance", token, nil), http.StatusForbidden)
+}
+
+func TestSearchStockAndRatingFilters(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	create := func(name string, stock int, rating float64) int {
+		res := a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": name, "price": 1000, "stock": stock, "rating": rating, "category": "Smartphones",
+		})
+		a.expect(res, http.StatusCreated)
+		var created struct {
+			ID int `json:"id"`
+		}
+		res.decode(t, &created)
+		return created.ID
+	}
+	soldOut := create("Zephyr One", 0, 4.8)
+	lowRated := create("Zephyr Two", 5, 3.1)
+	good := create("Zephyr Three", 5, 4.6)
+
+	for query, want := range map[string][]int{
+		"q=zephyr":                            {soldOut, lowRated, good},
+		"q=zephyr&in_stock=true":              {lowRated, good},
+		"q=zephyr&min_rating=4.5":             {soldOut, good},
+		"q=zephyr&in_stock=true&min_rating=4": {good},
+	} {
+		res := a.call("GET", "/api/products/search?"+query, "", nil)
+		a.expect(res, http.StatusOK)
+		var found struct {
+			Products []models.Product `json:"products"`
+		}
+		res.decode(t, &found)
+		got := map[int]bool{}
+		for _, p := range found.Products {
+			got[p.ID] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %s", query, res.Data)
+			continue
+		}
+		for _, id := range want {
+			if !got[id] {
+				t.Errorf("%s: product %d missing from %s", query, id, res.Data)
+			}
+		}
+	}
+}
+
+func TestOrderSnapshotKeepsImageReferenced(t *testing.T) {
+	a := newTestAPI(t)
+	ctx := context.Background()
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	const image = "/assets/uploads/product_1_large.jpg"
+	res := a.call("POST", "/api/products", admin, map[string]interface{}{
+		"name": "Phone", "price": 1000, "stock": 5, "category": "Smartphones", "image": image,
+	})
+	a.expect(res, http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	res.decode(t, &created)
+
+	base := fmt.Sprintf("/api/users/%d", userID)
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 1000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": created.ID, "quantity": 1}), http.StatusCreated)
+	a.expect(a.call("POST", base+"/checkout", token, map[string]interface{}{"items": []models.CartLine{{ProductID: created.ID}}}), http.StatusCreated)
+
+	// The product moves on to a new picture; the order still shows the old one
+	p, err := a.store.Products.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Image = "/assets/uploads/product_2_large.jpg"
+	if err := a.store.Products.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	referenced, err := a.store.Images.ReferencedURLs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !referenced[image] {
+		t.Fatalf("%s is still on an order but not referenced: %v", image, referenced)
+	}
+}
+
+func TestArchivedProductOnlyVisibleToAdmins(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Old Phone", 1000, 5)
+	path := fmt.Sprintf("/api/products/%d", productID)
+
+	a.expect(a.call("POST", path+"/archive", admin, nil), http.StatusOK)
+
+	a.expect(a.call("GET", path, "", nil), http.StatusNotFound)
+	a.expect(a.call("GET", path, customer, nil), http.StatusNotFound)
+	a.expect(a.call("GET", path, "not-a-token", nil), http.StatusNotFound)
+	res := a.call("GET", path, admin, nil)
+	a.expect(res, http.StatusOK)
+	var p models.Product
+	res.decode(t, &p)
+	if p.DeletedAt == nil {
+		t.Fatalf("admin got %s without deleted_at", res.Data)
+	}
+
+	a.expect(a.call("POST", path+"/restore", admin, nil), http.StatusOK)
+	a.expect(a.call("GET", path, "", nil), http.StatusOK)
+}
+
+// failingImport is a product store whose imports fail
+type failingImport struct {
+	repository.ProductRepository
+}
+
+func (failingImport) Import(ctx context.Context, items []models.ProductImportItem) error {
+	return fmt.Errorf("disk full")
+}
+
+func TestImportSavesAllOrNothing(t *testing.T) {
+	a := newTestAPI(t)
+	ctx := context.Background()
+	_, admin := a.user("admin@example.com", "admin")
+	rows := []models.ProductImportRow{
+		{Name: "Alpha Phone", Category: "Smartphones", Price: 1000, Stock: 1},
+		{Name: "Beta Phone", Category: "Smartphones", Price: 2000, Stock: 2},
+	}
+
+	products := a.store.Products
+	a.store.Products = failingImport{products}
+	a.expect(a.call("POST", "/api/admin/products/import?format=json", admin, rows), http.StatusInternalServerError)
+	a.store.Products = products
+	if list, _ := products.List(ctx, 0); len(list) != 0 {
+		t.Fatalf("failed import saved %d products", len(list))
+	}
+
+	// A failing item leaves the items before it unsaved too
+	err := products.Import(ctx, []models.ProductImportItem{
+		{Product: models.Product{Name: "Alpha Phone", Slug: "alpha-phone", Category: "Smartphones", Price: 1000}},
+		{Product: models.Product{Name: "Beta Phone", Slug: "beta-phone", Category: "Nope", Price: 2000}},
+	})
+	if err != repository.ErrUnknownCategory {
+		t.Fatalf("got %v, want ErrUnknownCategory", err)
+	}
+	if list, _ := products.List(ctx, 0); len(list) != 0 {
+		t.Fatalf("failed import saved %d products", len(list))
+	}
+
+	res := a.call("POST", "/api/admin/products/import?format=json", admin, rows)
+	a.expect(res, http.StatusOK)
+	var report models.ProductImportReport
+	res.decode(t, &report)
+	if report.Created != 2 || report.Rows[0].ProductID == 0 || report.Rows[1].ProductID == 0 {
+		t.Fatalf("got report %s", res.Data)
+	}
+}
+
+func TestExportEscapesFormulas(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	const name = "=HYPERLINK(\"http://evil.example\",\"Phone\")"
+	productID := a.product(admin, name, 1000, 5)
+
+	req := httptest.NewRequest("GET", "/api/admin/products/export?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: got %d: %s", rec.Code, rec.Body.String())
+	}
+	exported := rec.Body.String()
+	if !strings.Contains(exported, `"'=HYPERLINK(`) {
+		t.Fatalf("name not escaped in export:\n%s", exported)
+	}
+
+	// Importing the export again strips the escape
+	res := a.call("POST", "/api/admin/products/import", admin, exported, "Content-Type", "text/csv")
+	a.expect(res, http.StatusOK)
+	p, err := a.store.Products.GetByID(context.Background(), productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != name {
+		t.Fatalf("name after round trip: %q, want %q", p.Name, name)
+	}
+}
+
+func TestArchivedProductSlugOnlyFoundByAdmins(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Old Phone", 1000, 5)
+	a.expect(a.call("PUT", fmt.Sprintf("/api/products/%d", productID), admin, map[string]interface{}{
+		"name": "New Phone", "price": 1000, "stock": 5, "category": "Smartphones",
+	}), http.StatusOK)
+	a.expect(a.call("POST", fmt.Sprintf("/api/products/%d/archive", productID), admin, nil), http.StatusOK)
+
+	for _, slug := range []string{"new-phone", "old-phone"} {
+		path := "/api/products/by-slug/" + slug
+		a.expect(a.call("GET", path, "", nil), http.StatusNotFound)
+		a.expect(a.call("GET", path, customer, nil), http.StatusNotFound)
+	}
+	a.expect(a.call("GET", "/api/products/by-slug/new-phone", admin, nil), http.StatusOK)
+	a.expect(a.call("GET", "/api/products/by-slug/old-phone", admin, nil), http.StatusMovedPermanently)
+}
+
+func TestCheckoutReleasesOnlyItsOwnHolds(t *testing.T) {
+	a := newTestAPI(t)
+	ctx := context.Background()
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	bought := a.product(admin, "Phone", 1000, 5)
+	kept := a.product(admin, "Tablet", 2000, 5)
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 10000}), http.StatusOK)
+	for _, id := range []int{bought, kept} {
+		a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": id, "quantity": 1}), http.StatusCreated)
+	}
+	a.expect(a.call("POST", base+"/checkout/start", token, map[string]interface{}{
+		"items": []models.CartLine{{ProductID: bought}, {ProductID: kept}},
+	}), http.StatusOK)
+	a.expect(a.call("POST", base+"/checkout", token, map[string]interface{}{
+		"items": []models.CartLine{{ProductID: bought}},
+	}), http.StatusCreated)
+
+	holds, err := a.store.Reservations.List(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].ProductID != kept {
+		t.Fatalf("holds after checkout = %+v, want only product %d", holds, kept)
+	}
+}
+
+func TestCheckoutIgnoresExpiredHolds(t *testing.T) {
+	a := newTestAPI(t)
+	ctx := context.Background()
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Phone", 1000, 2)
+	base := fmt.Sprintf("/api/users/%d", userID)
+	line := models.CartLine{ProductID: productID}
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 10000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]int{"product_id": productID, "quantity": 1}), http.StatusCreated)
+	if _, err := a.store.Reservations.Reserve(ctx, userID, []models.CartLine{line}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired hold still counts as reserved until it is swept, but no
+	// longer makes its unit available to the buyer
+	a.expect(a.call("PUT", fmt.Sprintf("%s/cart/%d", base, productID), token, map[string]int{"quantity": 2}), http.StatusOK)
+	res := a.call("POST", base+"/checkout", token, map[string]interface{}{"items": []models.CartLine{line}})
+	a.expect(res, http.StatusBadRequest)
+	if !strings.Contains(res.Error, "Insufficient stock") {
+		t.Fatalf("error = %q", res.Error)
+	}
+}
@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// imageIDs parses the {id} and {imageId} path variables
+func imageIDs(r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	productID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, false
+	}
+	imageID, err := strconv.Atoi(vars["imageId"])
+	if err != nil {
+		return 0, 0, false
+	}
+	return productID, imageID, true
+}
+
+// validateImageURL returns a message when url is not a usable image URL. An
+// uploaded file must still exist.
+func validateImageURL(ctx context.Context, url string) string {
+	switch {
+	case url == "":
+		return "url is required"
+	case len(url) > 500:
+		return "url must be at most 500 characters"
+	case strings.HasPrefix(url, uploadURLPrefix):
+		key := uploadKey(url)
+		if key == "" {
+			return "Invalid upload URL"
+		}
+		if _, err := uploads.Stat(ctx, key); err != nil {
+			return "Uploaded file not found: " + url
+		}
+	case !strings.HasPrefix(url, "/assets/") && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://"):
+		return "url must start with /assets/ or http(s)://"
+	}
+	return ""
+}
+
+// syncPrimaryImage keeps the gallery in line with the image field of the
+// product form: a known URL becomes primary, a new one is added as primary.
+// An empty field restores the current primary image on the product.
+func syncPrimaryImage(ctx context.Context, productID int, url string) error {
+	images, err := store.Images.List(ctx, productID)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if img.URL == url || url == "" && img.IsPrimary {
+			img.IsPrimary = true
+			return store.Images.Update(ctx, &img)
+		}
+	}
+	if url == "" {
+		return nil
+	}
+	return store.Images.Add(ctx, &models.ProductImage{ProductID: productID, URL: url, IsPrimary: true})
+}
+
+// GetProductImages - GET /api/products/{id}/images
+func GetProductImages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	images, err := store.Images.List(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch images")
+		return
+	}
+	utils.SuccessResponse(w, "Images fetched successfully", images)
+}
+
+// AddProductImage - POST /api/products/{id}/images
+// Accepts either JSON {"url", "alt_text", "is_primary"} pointing at an
+// existing image, or a multipart form with an "image" file plus optional
+// "alt_text" and "is_primary" fields; the file is processed like /api/upload
+// and its large rendition is attached. The first image becomes primary.
+func AddProductImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	img := models.ProductImage{ProductID: id}
+	uploaded := false
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, err := imageFormFile(w, r)
+		if err != nil {
+			imageUploadError(w, err)
+			return
+		}
+		defer file.Close()
+		img.AltText = strings.TrimSpace(r.FormValue("alt_text"))
+		img.IsPrimary = r.FormValue("is_primary") == "true"
+		if len(img.AltText) > 255 {
+			utils.ErrorResponse(w, http.StatusBadRequest, "alt_text must be at most 255 characters")
+			return
+		}
+		renditions, err := saveImage(r.Context(), file)
+		if err != nil {
+			imageUploadError(w, err)
+			return
+		}
+		img.URL = renditions["large"].URL
+		uploaded = true
+	} else {
+		var req models.ProductImageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		img.URL = strings.TrimSpace(req.URL)
+		if msg := validateImageURL(r.Context(), img.URL); msg != "" {
+			utils.ErrorResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if req.AltText != nil {
+			img.AltText = strings.TrimSpace(*req.AltText)
+		}
+		if len(img.AltText) > 255 {
+			utils.ErrorResponse(w, http.StatusBadRequest, "alt_text must be at most 255 characters")
+			return
+		}
+		img.IsPrimary = req.IsPrimary
+	}
+
+	err = store.Images.Add(r.Context(), &img)
+	if err != nil && uploaded {
+		removeIfOrphan(r.Context(), img.URL)
+	}
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Adding an image to product %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to add image")
+		return
+	}
+	if img.IsPrimary {
+		reindexProduct(r.Context(), id)
+	}
+	utils.CreatedResponse(w, "Image added successfully", img)
+}
+
+// UpdateProductImage - PATCH /api/products/{id}/images/{imageId}
+// Body: {"alt_text", "is_primary"}. Setting is_primary moves the primary flag
+// to this image; to change the primary image, promote another one.
+func UpdateProductImage(w http.ResponseWriter, r *http.Request) {
+	productID, imageID, ok := imageIDs(r)
+	if !ok {
+		utils.ErrorResponse(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	var req models.ProductImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	images, err := store.Images.List(r.Context(), productID)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch images")
+		return
+	}
+	var img *models.ProductImage
+	for i := range images {
+		if images[i].ID == imageID {
+			img = &images[i]
+		}
+	}
+	if img == nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	if req.AltText != nil {
+		img.AltText = strings.TrimSpace(*req.AltText)
+	}
+	if len(img.AltText) > 255 {
+		utils.ErrorResponse(w, http.StatusBadRequest, "alt_text must be at most 255 characters")
+		return
+	}
+	wasPrimary := img.IsPrimary
+	img.IsPrimary = req.IsPrimary
+
+	err = store.Images.Update(r.Context(), img)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Updating image %d of product %d failed: %v", imageID, productID, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update image")
+		return
+	}
+	if req.IsPrimary && !wasPrimary {
+		reindexProduct(r.Context(), productID)
+	}
+	utils.SuccessResponse(w, "Image updated successfully", img)
+}
+
+// ReorderProductImages - PUT /api/products/{id}/images/order
+// Body: {"image_ids": [...]} listing every image of the product once.
+func ReorderProductImages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	var req models.ImageOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err = store.Images.Reorder(r.Context(), id, req.ImageIDs)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err == repository.ErrImageOrder {
+		utils.ErrorResponse(w, http.StatusBadRequest, "image_ids must list each image of the product exactly once")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Reordering images of product %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to reorder images")
+		return
+	}
+
+	images, err := store.Images.List(r.Context(), id)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch images")
+		return
+	}
+	utils.SuccessResponse(w, "Images reordered successfully", images)
+}
+
+// DeleteProductImage - DELETE /api/products/{id}/images/{imageId}
+// The next image in order takes over when the primary image is deleted. An
+// uploaded file is removed once nothing else points at it.
+func DeleteProductImage(w http.ResponseWriter, r *http.Request) {
+	productID, imageID, ok := imageIDs(r)
+	if !ok {
+		utils.ErrorResponse(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	removed, err := store.Images.Delete(r.Context(), productID, imageID)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Deleting image %d of product %d failed: %v", imageID, productID, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete image")
+		return
+	}
+	removeIfOrphan(r.Context(), removed.URL)
+	if removed.IsPrimary {
+		reindexProduct(r.Context(), productID)
+	}
+	utils.SuccessResponse(w, "Image deleted successfully", nil)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+// accountLoginPolicy throttles guesses against one email address. Lockout
+// threshold and duration can be tuned with LOGIN_MAX_FAILURES and LOGIN_LOCKOUT.
+func accountLoginPolicy() models.LoginPolicy {
+	p := models.LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && n > 0 {
+		p.LockoutAfter = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT")); err == nil && d > 0 {
+		p.LockoutDuration = d
+	}
+	return p
+}
+
+// ipLoginPolicy throttles one client spraying passwords across many accounts;
+// it is looser than the per-account policy because of shared NAT addresses
+var ipLoginPolicy = models.LoginPolicy{
+	FreeAttempts:    20,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    100,
+	LockoutDuration: time.Hour,
+	Window:          time.Hour,
+}
+
+// loginThrottleKeys returns the per-account and per-IP keys for a login attempt
+func loginThrottleKeys(r *http.Request, email string) (account, ip string) {
+	return accountThrottleKey(email), "ip:" + utils.ClientIP(r)
+}
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// reserveLoginAttempt counts the attempt against the account and the client
+// IP before the password is checked, so parallel guesses cannot all get in
+// before the first failure is recorded. If either key is still blocked it
+// answers 429 with Retry-After and returns false. Store errors let the attempt
+// through rather than lock everyone out.
+func reserveLoginAttempt(w http.ResponseWriter, r *http.Request, account, ip string) bool {
+	now := time.Now()
+	policy := accountLoginPolicy()
+	t, err := store.LoginThrottles.Reserve(r.Context(), account, policy, now)
+	switch {
+	case errors.Is(err, repository.ErrLoginThrottled):
+		writeLoginBlocked(w, t.RetryAfter(now))
+		return false
+	case err != nil:
+		log.Printf("⚠️  Failed to reserve login attempt for %s: %v", account, err)
+	case t.Failures == policy.LockoutAfter:
+		log.Printf("⚠️  Account %s locked after %d failed logins", account, t.Failures)
+	}
+
+	t, err = store.LoginThrottles.Reserve(r.Context(), ip, ipLoginPolicy, now)
+	switch {
+	case errors.Is(err, repository.ErrLoginThrottled):
+		// The password is never checked, so the account keeps its attempt
+		if err := store.LoginThrottles.Release(r.Context(), account, policy); err != nil {
+			log.Printf("⚠️  Failed to release login attempt for %s: %v", account, err)
+		}
+		writeLoginBlocked(w, t.RetryAfter(now))
+		return false
+	case err != nil:
+		log.Printf("⚠️  Failed to reserve login attempt for %s: %v", ip, err)
+	}
+	return true
+}
+
+// loginSucceeded clears the account's failures and gives the client IP back
+// the attempt reserved for this login
+func loginSucceeded(ctx context.Context, account, ip string) {
+	if err := store.LoginThrottles.Reset(ctx, account); err != nil {
+		log.Printf("⚠️  Failed to reset login throttle for %s: %v", account, err)
+	}
+	if err := store.LoginThrottles.Release(ctx, ip, ipLoginPolicy); err != nil {
+		log.Printf("⚠️  Failed to release login attempt for %s: %v", ip, err)
+	}
+}
+
+// writeLoginBlocked answers 429 with Retry-After rounded up to whole seconds
+func writeLoginBlocked(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.ErrorResponse(w, http.StatusTooManyRequests,
+		fmt.Sprintf("Terlalu banyak percobaan login. Coba lagi dalam %d detik", seconds))
+}
+
+// SweepLoginThrottles deletes counters that saw no attempt for a day every
+// interval until ctx is done. Run it in its own goroutine after SetupRoutes.
+func SweepLoginThrottles(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := store.LoginThrottles.DeleteExpired(ctx, now); err != nil {
+				log.Printf("❌ Deleting stale login throttles failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d stale login throttle(s)", n)
+			}
+		}
+	}
+}
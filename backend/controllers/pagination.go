@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxPage bounds ?page= so (page-1)*limit stays a small, positive offset
+const maxPage = 10000
+
+// pageParams reads ?page= and ?limit= (1-based page, limit capped at maxLimit).
+// A page past maxPage is an error the caller answers with 400.
+func pageParams(r *http.Request, defaultLimit, maxLimit int) (page, limit int, err error) {
+	page, limit = 1, defaultLimit
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		if p > maxPage {
+			return 0, 0, fmt.Errorf("page must be at most %d", maxPage)
+		}
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return page, limit, nil
+}
+
+// paginationMeta is the "pagination" object returned next to a page of results
+func paginationMeta(page, limit, total int) map[string]int {
+	return map[string]int{
+		"page":        page,
+		"limit":       limit,
+		"total":       total,
+		"total_pages": (total + limit - 1) / limit,
+	}
+}
@@ -1,67 +1,185 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/search"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/gorilla/mux"
 )
 
-// GetAllProducts - GET /api/products
+// GetAllProducts - GET /api/products?page=1&limit=20
+// Filters: category, brand (comma-separated), min_price, max_price, in_stock=true, min_rating,
+// and spec filters such as spec[RAM]>=12 or spec[GPU]=RTX 4060,RTX 4070.
+// A category filter also matches its subcategories.
+// Sort: price_asc, price_desc, rating, newest, best_selling (default: id order).
+// facets.specs lists the spec values found under the non-spec filters.
+// Archived products are left out.
 func GetAllProducts(w http.ResponseWriter, r *http.Request) {
-	query := `SELECT p.id, p.name, p.price, p.stock, c.name, p.rating, COALESCE(p.total_reviews,0), p.description, p.image_url, p.brand, p.created_at 
-			  FROM products p 
-			  LEFT JOIN categories c ON p.category_id = c.id 
-			  ORDER BY p.id ASC`
+	listProducts(w, r, false)
+}
+
+// GetArchivedProducts - GET /api/admin/products/archived
+// Takes the same filters, sort and paging as GET /api/products.
+func GetArchivedProducts(w http.ResponseWriter, r *http.Request) {
+	listProducts(w, r, true)
+}
 
-	rows, err := config.DB.Query(query)
+// listProducts serves one page of the catalog, or of the archived products
+func listProducts(w http.ResponseWriter, r *http.Request, archived bool) {
+	q, err := productQueryFromRequest(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.Archived = archived
+	page, limit, err := pageParams(r, 20, 100)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.Limit, q.Offset = limit, (page-1)*limit
+
+	products, total, err := store.Products.Query(r.Context(), q)
+	if err != nil {
+		log.Printf("❌ Product query failed: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch products")
+		return
+	}
+	if products == nil {
+		products = []models.Product{}
+	}
+	specFacets, err := store.Products.SpecFacets(r.Context(), q)
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Query error: "+err.Error())
+		log.Printf("❌ Product spec facets failed: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch products")
 		return
 	}
-	defer rows.Close()
 
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		var imageURL, description, brand sql.NullString
-		var category sql.NullString
-		var ratingFloat float64
+	message := "Products fetched successfully"
+	if total == 0 {
+		message = "No products found"
+	}
+	utils.SuccessResponse(w, message, map[string]interface{}{
+		"products":   products,
+		"pagination": paginationMeta(page, limit, total),
+		"facets":     map[string]interface{}{"specs": specFacets},
+	})
+}
 
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock,
-			&category, &ratingFloat, &product.TotalReviews, &description, &imageURL, &brand, &product.CreatedAt)
+// productQueryFromRequest parses the listing filters and sort order
+func productQueryFromRequest(r *http.Request) (models.ProductQuery, error) {
+	params := r.URL.Query()
+	q := models.ProductQuery{
+		Categories: expandCategories(r.Context(), splitList(params.Get("category"))),
+		Brands:     splitList(params.Get("brand")),
+		Sort:       params.Get("sort"),
+	}
+	if q.Sort != "" && !models.IsProductSort(q.Sort) {
+		return q, fmt.Errorf("Invalid sort %q (use price_asc, price_desc, rating, newest or best_selling)", q.Sort)
+	}
+
+	for name, dst := range map[string]*int{"min_price": &q.MinPrice, "max_price": &q.MaxPrice} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("Invalid %s", name)
+			}
+			*dst = n
+		}
+	}
+	if v := params.Get("min_rating"); v != "" {
+		rating, err := strconv.ParseFloat(v, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			return q, fmt.Errorf("Invalid min_rating")
+		}
+		q.MinRating = rating
+	}
+	if v := params.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
 		if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Scan error: "+err.Error())
-			return
+			return q, fmt.Errorf("Invalid in_stock")
+		}
+		q.InStock = inStock
+	}
+
+	specs, err := specFilters(r.URL.RawQuery)
+	if err != nil {
+		return q, err
+	}
+	q.Specs = specs
+	return q, nil
+}
+
+// specFilterPattern matches one decoded spec[Key]<op><value> query parameter
+var specFilterPattern = regexp.MustCompile(`^spec\[([^\]]+)\](>=|<=|>|<|=)(.*)$`)
+
+// specFilters parses the spec filters of a raw query string. They are read
+// from the raw string because url.Values splits "spec[RAM]>=12" at the "=".
+func specFilters(rawQuery string) ([]models.SpecFilter, error) {
+	var filters []models.SpecFilter
+	for _, part := range strings.Split(rawQuery, "&") {
+		decoded, err := url.QueryUnescape(part)
+		if err != nil || !strings.HasPrefix(decoded, "spec[") {
+			continue
+		}
+		m := specFilterPattern.FindStringSubmatch(decoded)
+		if m == nil {
+			return nil, fmt.Errorf("Invalid spec filter %q (use spec[Key]=value, >, >=, < or <=)", decoded)
 		}
-		product.Rating = models.Decimal(ratingFloat)
-		product.Description = description.String
-		product.Image = imageURL.String
-		product.Brand = brand.String
-		if category.Valid {
-			product.Category = category.String
+		f := models.SpecFilter{Key: strings.TrimSpace(m[1]), Op: m[2]}
+		value := strings.TrimSpace(m[3])
+		if f.Op == models.SpecEq {
+			for _, v := range splitList(value) {
+				// Boolean specs are stored as Yes/No
+				switch strings.ToLower(v) {
+				case "true":
+					v = "Yes"
+				case "false":
+					v = "No"
+				}
+				f.Values = append(f.Values, v)
+			}
+			if len(f.Values) == 0 {
+				return nil, fmt.Errorf("Invalid spec filter %q: value is required", decoded)
+			}
 		} else {
-			product.Category = "Uncategorized"
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+				return nil, fmt.Errorf("Invalid spec filter %q: %s needs a number", decoded, f.Op)
+			}
+			f.Number = n
 		}
-		products = append(products, product)
+		filters = append(filters, f)
 	}
+	return filters, nil
+}
 
-	if len(products) == 0 {
-		utils.SuccessResponse(w, "No products found", []models.Product{})
-		return
+// splitList splits a comma-separated query value, dropping empty entries
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
 	}
-
-	utils.SuccessResponse(w, "Products fetched successfully", products)
+	return out
 }
 
 // GetProductByID - GET /api/products/{id}
+// Archived products are 404 except for admins, who get them with deleted_at
+// set. Orders keep their own snapshot of name and image.
 func GetProductByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -72,75 +190,75 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT p.id, p.name, p.price, p.stock, c.name, p.rating, COALESCE(p.total_reviews,0), p.description, p.image_url, p.brand, p.created_at 
-			  FROM products p 
-			  LEFT JOIN categories c ON p.category_id = c.id 
-			  WHERE p.id = ?`
-
-	var product models.Product
-	var imageURL, description, brand sql.NullString
-	var category sql.NullString
-	var ratingFloat float64
-
-	err = config.DB.QueryRow(query, productID).Scan(
-		&product.ID, &product.Name, &product.Price, &product.Stock,
-		&category, &ratingFloat, &product.TotalReviews, &description, &imageURL, &brand, &product.CreatedAt,
-	)
-	if err != nil {
+	product, err := store.Products.GetByID(r.Context(), productID)
+	if err != nil || product.DeletedAt != nil && !middlewares.IsAdmin(r) {
 		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
 		return
 	}
 
-	product.Rating = models.Decimal(ratingFloat)
-	product.Description = description.String
-	product.Image = imageURL.String
-	product.Brand = brand.String
-	if category.Valid {
-		product.Category = category.String
-	} else {
-		product.Category = "Uncategorized"
-	}
-
-	// Fetch key-value specifications
-	product.Specifications = fetchSpecifications(product.ID)
-
 	utils.SuccessResponse(w, "Product fetched successfully", product)
 }
 
-// fetchSpecifications returns all spec rows for a product as key-value pairs
-func fetchSpecifications(productID int) []models.ProductSpec {
-	rows, err := config.DB.Query(
-		`SELECT spec_key, spec_value FROM product_specifications 
-		 WHERE product_id = ? ORDER BY display_order ASC`, productID)
+// GetProductBySlug - GET /api/products/by-slug/{slug}
+// Returns the product like GET /api/products/{id}. A slug the product had
+// before it was renamed answers 301 with the current URL in Location.
+// Archived products, and slugs redirecting to them, are only found by admins.
+func GetProductBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	found, err := store.Products.FindBySlug(r.Context(), slug, middlewares.IsAdmin(r))
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
-		return nil
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch product")
+		return
+	}
+	if found.Slug != slug {
+		w.Header().Set("Location", "/api/products/by-slug/"+url.PathEscape(found.Slug))
+		utils.JSONResponse(w, http.StatusMovedPermanently, utils.Response{
+			Success: true,
+			Message: "Product moved",
+			Data:    map[string]interface{}{"id": found.ID, "slug": found.Slug},
+		})
+		return
 	}
-	defer rows.Close()
 
-	var specs []models.ProductSpec
-	for rows.Next() {
-		var s models.ProductSpec
-		if err := rows.Scan(&s.Key, &s.Value); err != nil {
-			continue
-		}
-		specs = append(specs, s)
+	product, err := store.Products.GetByID(r.Context(), found.ID)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
 	}
-	return specs
+	utils.SuccessResponse(w, "Product fetched successfully", product)
 }
 
-// insertSpec inserts a single key-value spec row (skips empty values)
-func insertSpec(productID int64, key, value string, order int) error {
-	if value == "" {
-		return nil
+// maxProductSlug leaves room in products.slug for a -N suffix
+const maxProductSlug = 180
+
+// productSlug returns the slug for a product: the requested one, or one
+// derived from the name. Slugs are never plain numbers so the frontend can
+// tell them from ids.
+func productSlug(requested, name string) (string, error) {
+	if strings.TrimSpace(requested) != "" {
+		slug := utils.TruncateSlug(utils.Slugify(requested), maxProductSlug)
+		if slug == "" {
+			return "", fmt.Errorf("Slug must contain letters or digits")
+		}
+		if _, err := strconv.Atoi(slug); err == nil {
+			return "", fmt.Errorf("Product slug cannot be a number")
+		}
+		return slug, nil
+	}
+	slug := utils.TruncateSlug(utils.Slugify(name), maxProductSlug)
+	if _, err := strconv.Atoi(slug); err == nil || slug == "" {
+		slug = strings.TrimSuffix("product-"+slug, "-")
 	}
-	_, err := config.DB.Exec(
-		`INSERT INTO product_specifications (product_id, spec_key, spec_value, display_order) VALUES (?, ?, ?, ?)`,
-		productID, key, value, order,
-	)
-	return err
+	return slug, nil
 }
 
 // CreateProduct - POST /api/products
+// The slug comes from "slug" or the name; -2, -3, ... is appended when
+// another product has (or had) it.
 func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var req models.ProductCreateRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -153,119 +271,95 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Name and price are required")
 		return
 	}
-
-	// Get category_id from category name
-	var categoryID int
-	err = config.DB.QueryRow("SELECT id FROM categories WHERE name = ?", req.Category).Scan(&categoryID)
+	slug, err := productSlug(req.Slug, req.Name)
 	if err != nil {
-		categoryID = 1
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
-
-	slug := strings.ToLower(strings.ReplaceAll(req.Name, " ", "-"))
-
-	query := `INSERT INTO products (name, slug, price, stock, category_id, rating, description, image_url, brand) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	result, err := config.DB.Exec(query, req.Name, slug, req.Price, req.Stock, categoryID, req.Rating, req.Description, req.Image, req.Brand)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create product: "+err.Error())
+	if req.Category, err = resolveCategory(r.Context(), req.Category); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	productID, err := result.LastInsertId()
+	specs, err := productSpecs(r.Context(), req)
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to get product ID")
+		productSpecsError(w, err)
 		return
 	}
 
-	// Insert specifications as key-value rows
-	order := 1
-
-	var specRows []struct{ key, value string }
-
-	switch req.Category {
-	case "Audio":
-		specRows = []struct{ key, value string }{
-			{"Respon Frekuensi", req.FrequencyResponse},
-			{"Impedansi", formatUnit(req.Impedance, "Ω")},
-			{"Sensitivitas", formatUnit(req.Sensitivity, "dB")},
-			{"Ukuran Driver", formatUnit(req.DriverSize, "mm")},
-		}
-	case "Laptops":
-		specRows = []struct{ key, value string }{
-			{"Chipset", req.Chipset},
-			{"RAM", formatRAM(req.RamGB, req.RamDdr)},
-			{"ROM", formatROM(req.RomValue, req.RomUnit, req.StorageType)},
-			{"Display", req.Display},
-			{"GPU", req.GPU},
-			{"Battery", req.Battery},
-			{"Operating System", formatOS(req.OsName, req.OsVersion)},
-			{"Connectivity", formatConnectivity(req.Connectivity5G, req.ConnectivityWifi, req.ConnectivityNfc)},
-		}
-	default:
-		specRows = []struct{ key, value string }{
-			{"Chipset", req.Chipset},
-			{"RAM", formatRAM(req.RamGB, req.RamDdr)},
-			{"ROM", formatROM(req.RomValue, req.RomUnit, req.StorageType)},
-			{"Display", formatDisplay(req.DisplayInch)},
-			{"Refresh Rate", formatRefreshRate(req.RefreshRateHz)},
-			{"Battery", req.Battery},
-			{"Charging", req.Charging},
-			{"Camera", req.Camera},
-			{"Operating System", formatOS(req.OsName, req.OsVersion)},
-			{"Connectivity", formatConnectivity(req.Connectivity5G, req.ConnectivityWifi, req.ConnectivityNfc)},
-		}
-	}
-
-	for _, s := range specRows {
-		if err := insertSpec(productID, s.key, s.value, order); err != nil {
-			// Log but don't fail; product was already created
-			fmt.Printf("Warning: failed to insert spec %s: %v\n", s.key, err)
+	product := models.Product{
+		Name:        req.Name,
+		Slug:        slug,
+		Price:       req.Price,
+		Stock:       req.Stock,
+		Category:    req.Category,
+		Rating:      models.Decimal(req.Rating),
+		Description: req.Description,
+		Image:       req.Image,
+		Brand:       req.Brand,
+	}
+	if err := store.Products.Create(r.Context(), &product); err != nil {
+		if err == repository.ErrUnknownCategory {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Unknown category: "+req.Category)
+			return
 		}
-		order++
+		log.Printf("❌ Creating product %q failed: %v", product.Name, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create product")
+		return
 	}
 
-	utils.CreatedResponse(w, "Product created successfully", map[string]interface{}{"id": productID})
-}
-
-// --- Spec value formatters ---
-
-func formatRAM(ramGB int, ddr string) string {
-	if ramGB <= 0 {
-		return ""
+	// Insert specifications as key-value rows
+	if err := store.Products.ReplaceSpecifications(r.Context(), product.ID, specs); err != nil {
+		// Log but don't fail; product was already created
+		log.Printf("⚠️  Failed to insert specs of product %d: %v", product.ID, err)
 	}
-	if ddr != "" {
-		return fmt.Sprintf("%d GB %s", ramGB, ddr)
+	if err := syncPrimaryImage(r.Context(), product.ID, product.Image); err != nil {
+		log.Printf("⚠️  Failed to add the image of product %d to its gallery: %v", product.ID, err)
 	}
-	return fmt.Sprintf("%d GB", ramGB)
+	reindexProduct(r.Context(), product.ID)
+
+	utils.CreatedResponse(w, "Product created successfully", map[string]interface{}{"id": product.ID, "slug": product.Slug})
 }
 
-func formatROM(value int, unit string, storageType string) string {
-	if value <= 0 {
-		return ""
+// legacySpecValues maps the flat spec fields of older clients onto the keys
+// of the default spec templates
+func legacySpecValues(req models.ProductCreateRequest) map[string]interface{} {
+	values := map[string]interface{}{
+		"chipset":            req.Chipset,
+		"ram_type":           req.RamDdr,
+		"storage_type":       req.StorageType,
+		"display":            req.Display,
+		"battery":            req.Battery,
+		"charging":           req.Charging,
+		"camera":             req.Camera,
+		"os":                 formatOS(req.OsName, req.OsVersion),
+		"connectivity":       formatConnectivity(req.Connectivity5G, req.ConnectivityWifi, req.ConnectivityNfc),
+		"gpu":                req.GPU,
+		"frequency_response": req.FrequencyResponse,
+		"impedance":          req.Impedance,
+		"sensitivity":        req.Sensitivity,
+		"driver_size":        req.DriverSize,
+	}
+	if req.RamGB > 0 {
+		values["ram"] = req.RamGB
+	}
+	if req.RomValue > 0 {
+		// Storage is recorded in GB
+		if strings.EqualFold(req.RomUnit, "TB") {
+			values["rom"] = req.RomValue * 1024
+		} else {
+			values["rom"] = req.RomValue
+		}
 	}
-	if unit == "" {
-		unit = "GB"
+	if req.Display == "" && req.DisplayInch > 0 {
+		values["display"] = req.DisplayInch
 	}
-	if storageType != "" {
-		return fmt.Sprintf("%d %s %s", value, unit, storageType)
+	if req.RefreshRateHz > 0 {
+		values["refresh_rate"] = req.RefreshRateHz
 	}
-	return fmt.Sprintf("%d %s", value, unit)
+	return values
 }
 
-func formatDisplay(inch float64) string {
-	if inch <= 0 {
-		return ""
-	}
-	return fmt.Sprintf("%.1f inch", inch)
-}
-
-func formatRefreshRate(hz int) string {
-	if hz <= 0 {
-		return ""
-	}
-	return fmt.Sprintf("%d Hz", hz)
-}
+// --- Spec value formatters ---
 
 func formatOS(name, version string) string {
 	if name == "" {
@@ -277,13 +371,6 @@ func formatOS(name, version string) string {
 	return name
 }
 
-func formatUnit(value, unit string) string {
-	if value == "" {
-		return ""
-	}
-	return value + " " + unit
-}
-
 func formatConnectivity(g5, wifi, nfc bool) string {
 	var parts []string
 	if g5 {
@@ -299,160 +386,233 @@ func formatConnectivity(g5, wifi, nfc bool) string {
 }
 
 // UpdateProduct - PUT /api/products/{id}
+// Renaming a product gives it a new slug unless "slug" is sent; the old slug
+// keeps redirecting to it.
 func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id := vars["id"]
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
 
 	var req models.ProductCreateRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
-
-	// Verify product exists first
-	var productIDInt int
-	err = config.DB.QueryRow("SELECT id FROM products WHERE id = ?", id).Scan(&productIDInt)
-	if err != nil || productIDInt == 0 {
+	if req.Category, err = resolveCategory(r.Context(), req.Category); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	specs, err := productSpecs(r.Context(), req)
+	if err != nil {
+		productSpecsError(w, err)
+		return
+	}
+	existing, err := store.Products.GetByID(r.Context(), id)
+	if err == repository.ErrNotFound {
 		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
 		return
 	}
-
-	// Resolve category_id from category name
-	var categoryID int
-	if req.Category != "" {
-		_ = config.DB.QueryRow("SELECT id FROM categories WHERE name = ?", req.Category).Scan(&categoryID)
-		if categoryID == 0 {
-			categoryID = 1
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch product")
+		return
+	}
+	// An empty slug keeps the current one
+	slug := ""
+	if req.Slug != "" || req.Name != "" && req.Name != existing.Name {
+		if slug, err = productSlug(req.Slug, req.Name); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
 		}
 	}
 
 	// Update basic product fields (rating is intentionally excluded — not editable via product form)
-	query := `UPDATE products SET name = ?, price = ?, stock = ?, category_id = ?, description = ?, image_url = ?, brand = ? WHERE id = ?`
-	_, err = config.DB.Exec(query, req.Name, req.Price, req.Stock, categoryID, req.Description, req.Image, req.Brand, id)
+	product := models.Product{
+		ID:          id,
+		Name:        req.Name,
+		Slug:        slug,
+		Price:       req.Price,
+		Stock:       req.Stock,
+		Category:    req.Category,
+		Description: req.Description,
+		Image:       req.Image,
+		Brand:       req.Brand,
+	}
+	// Price and stock of a product with variants follow its variants
+	if len(existing.Variants) > 0 {
+		product.Price, product.Stock = models.VariantTotals(existing.Variants)
+	}
+	err = store.Products.Update(r.Context(), &product)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err == repository.ErrUnknownCategory {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Unknown category: "+req.Category)
+		return
+	}
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update product: "+err.Error())
+		log.Printf("❌ Updating product %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update product")
 		return
 	}
 
-	// Re-sync specifications: delete old, insert new (productIDInt already set above)
-	if productIDInt > 0 {
-		_, _ = config.DB.Exec("DELETE FROM product_specifications WHERE product_id = ?", productIDInt)
+	// Re-sync specifications: delete old, insert new
+	if err := store.Products.ReplaceSpecifications(r.Context(), id, specs); err != nil {
+		log.Printf("⚠️  Failed to sync specs of product %d: %v", id, err)
+	}
+	if err := syncPrimaryImage(r.Context(), id, product.Image); err != nil {
+		log.Printf("⚠️  Failed to sync gallery of product %d: %v", id, err)
+	}
+	reindexProduct(r.Context(), id)
 
-		order := 1
-		var specRows []struct{ key, value string }
+	utils.SuccessResponse(w, "Product updated successfully", map[string]interface{}{"slug": product.Slug})
+}
 
-		switch req.Category {
-		case "Audio":
-			specRows = []struct{ key, value string }{
-				{"Respon Frekuensi", req.FrequencyResponse},
-				{"Impedansi", formatUnit(req.Impedance, "Ω")},
-				{"Sensitivitas", formatUnit(req.Sensitivity, "dB")},
-				{"Ukuran Driver", formatUnit(req.DriverSize, "mm")},
-			}
-		case "Laptops":
-			specRows = []struct{ key, value string }{
-				{"Chipset", req.Chipset},
-				{"RAM", formatRAM(req.RamGB, req.RamDdr)},
-				{"ROM", formatROM(req.RomValue, req.RomUnit, req.StorageType)},
-				{"Display", req.Display},
-				{"GPU", req.GPU},
-				{"Battery", req.Battery},
-				{"Operating System", formatOS(req.OsName, req.OsVersion)},
-				{"Connectivity", formatConnectivity(req.Connectivity5G, req.ConnectivityWifi, req.ConnectivityNfc)},
-			}
-		default:
-			specRows = []struct{ key, value string }{
-				{"Chipset", req.Chipset},
-				{"RAM", formatRAM(req.RamGB, req.RamDdr)},
-				{"ROM", formatROM(req.RomValue, req.RomUnit, req.StorageType)},
-				{"Display", formatDisplay(req.DisplayInch)},
-				{"Refresh Rate", formatRefreshRate(req.RefreshRateHz)},
-				{"Battery", req.Battery},
-				{"Charging", req.Charging},
-				{"Camera", req.Camera},
-				{"Operating System", formatOS(req.OsName, req.OsVersion)},
-				{"Connectivity", formatConnectivity(req.Connectivity5G, req.ConnectivityWifi, req.ConnectivityNfc)},
-			}
-		}
+// ArchiveProduct - POST /api/products/{id}/archive (also DELETE /api/products/{id})
+// Soft-deletes the product: it leaves the catalog, search and carts but stays
+// resolvable for orders and reviews. Its images are kept for a restore.
+func ArchiveProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
 
-		for _, s := range specRows {
-			if err2 := insertSpec(int64(productIDInt), s.key, s.value, order); err2 != nil {
-				fmt.Printf("Warning: failed to insert spec %s: %v\n", s.key, err2)
-			}
-			order++
-		}
+	err = store.Products.Archive(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to archive product")
+		return
 	}
+	unindexProduct(id)
 
-	utils.SuccessResponse(w, "Product updated successfully", nil)
+	utils.SuccessResponse(w, "Product archived successfully", nil)
 }
 
-// DeleteProduct - DELETE /api/products/{id}
-func DeleteProduct(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	query := `DELETE FROM products WHERE id = ?`
-	result, err := config.DB.Exec(query, id)
+// RestoreProduct - POST /api/products/{id}/restore
+// Puts an archived product back in the catalog; cart lines hidden while it
+// was archived show up again.
+func RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete product")
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err = store.Products.Restore(r.Context(), id)
+	if err == repository.ErrNotFound {
 		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
 		return
 	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to restore product")
+		return
+	}
+	reindexProduct(r.Context(), id)
 
-	utils.SuccessResponse(w, "Product deleted successfully", nil)
+	utils.SuccessResponse(w, "Product restored successfully", nil)
 }
 
-// SearchProducts - GET /api/products/search?q=keyword
+// SearchProducts - GET /api/products/search?q=keyword&page=1&limit=20
+// Ranks products by relevance over name, brand, category, specifications and
+// description, tolerating typos. Accepts the listing's category, brand,
+// min_price, max_price, in_stock, min_rating and spec filters and returns
+// facet counts.
 func SearchProducts(w http.ResponseWriter, r *http.Request) {
 	keyword := r.URL.Query().Get("q")
 	if keyword == "" {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Search keyword is required")
 		return
 	}
+	filters, err := productQueryFromRequest(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, limit, err := pageParams(r, 20, 100)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	query := `SELECT p.id, p.name, p.price, p.stock, c.name, p.rating, p.description, p.image_url, p.brand, p.created_at
-              FROM products p
-              LEFT JOIN categories c ON p.category_id = c.id
-              WHERE p.name LIKE ? OR p.description LIKE ?
-              ORDER BY p.rating DESC`
+	// The index knows nothing about specifications, stock or ratings; narrow
+	// it to the products passing those filters
+	var only map[int]bool
+	if len(filters.Specs) > 0 || filters.InStock || filters.MinRating > 0 {
+		matching, _, err := store.Products.Query(r.Context(), models.ProductQuery{
+			Specs:     filters.Specs,
+			InStock:   filters.InStock,
+			MinRating: filters.MinRating,
+		})
+		if err != nil {
+			log.Printf("❌ Search filter query failed: %v", err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to search products")
+			return
+		}
+		only = make(map[int]bool, len(matching))
+		for _, p := range matching {
+			only[p.ID] = true
+		}
+	}
+
+	result := catalog.Search(search.Query{
+		Text:       keyword,
+		Categories: filters.Categories,
+		Brands:     filters.Brands,
+		MinPrice:   filters.MinPrice,
+		MaxPrice:   filters.MaxPrice,
+		Only:       only,
+		Limit:      limit,
+		Offset:     (page - 1) * limit,
+	})
 
-	searchPattern := "%" + keyword + "%"
-	rows, err := config.DB.Query(query, searchPattern, searchPattern)
+	// The index only ranks; prices, stock and ratings come fresh from the store
+	found, err := store.Products.ListByIDs(r.Context(), result.IDs)
 	if err != nil {
+		log.Printf("❌ Loading search results failed: %v", err)
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to search products")
 		return
 	}
-	defer rows.Close()
-
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		var imageURL, description, brand sql.NullString
-		var category sql.NullString
-		var ratingFloat float64
-
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock,
-			&category, &ratingFloat, &description, &imageURL, &brand, &product.CreatedAt)
-		if err != nil {
-			continue
+	byID := make(map[int]models.Product, len(found))
+	for _, p := range found {
+		if p.DeletedAt == nil {
+			byID[p.ID] = p
 		}
-		product.Rating = models.Decimal(ratingFloat)
-		product.Description = description.String
-		product.Image = imageURL.String
-		product.Brand = brand.String
-		if category.Valid {
-			product.Category = category.String
-		} else {
-			product.Category = "Uncategorized"
+	}
+	products := make([]models.Product, 0, len(result.IDs))
+	for _, id := range result.IDs {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
 		}
-		products = append(products, product)
 	}
 
-	utils.SuccessResponse(w, "Search completed", products)
+	utils.SuccessResponse(w, "Search completed", map[string]interface{}{
+		"products":   products,
+		"pagination": paginationMeta(page, limit, result.Total),
+		"facets":     result.Facets,
+	})
+}
+
+// SuggestProducts - GET /api/products/suggest?q=gal&limit=8
+// Autocomplete for the search box: product names, brands and categories
+// that start with q (or have a word starting with it).
+func SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		utils.SuccessResponse(w, "Suggestions", []search.Suggestion{})
+		return
+	}
+	limit := 8
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, 20)
+	}
+
+	utils.SuccessResponse(w, "Suggestions", suggestions.Suggest(q, limit))
 }
@@ -0,0 +1,606 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+const (
+	// maxImportSize caps the body of an import request
+	maxImportSize = 10 << 20
+	// maxImportRows caps the number of products in one import
+	maxImportRows = 5000
+	// specColumnPrefix marks the CSV columns holding spec values, e.g. "spec.ram"
+	specColumnPrefix = "spec."
+)
+
+// importColumns are the CSV columns besides the spec.<key> ones, in export order
+var importColumns = []string{"slug", "name", "category", "brand", "price", "stock", "description", "image", "skus"}
+
+// importRow is a parsed import row with the problems found while parsing it
+type importRow struct {
+	models.ProductImportRow
+	line     int
+	problems []string
+}
+
+// importPlan is what the import will do with one row
+type importPlan struct {
+	result       models.ProductImportResult
+	product      models.Product
+	specs        []models.ProductSpec
+	replaceSpecs bool
+}
+
+// ImportProducts - POST /api/admin/products/import?format=csv|json&dry_run=true
+// Accepts a CSV file (header row, spec values in spec.<key> columns) or a
+// JSON array of rows, as the raw body or as the "file" of a multipart form.
+// Rows update the product with the same slug or variant SKU and create the
+// others; every field is replaced as with PUT /api/products/{id}. Nothing is
+// saved unless every row is valid, and the rows are saved in one transaction;
+// ?dry_run=true only reports what would happen.
+func ImportProducts(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	rows, err := readImport(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import file must be at most %d MB", maxImportSize>>20))
+			return
+		}
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		utils.ErrorResponse(w, http.StatusBadRequest, "No products to import")
+		return
+	}
+	if len(rows) > maxImportRows {
+		utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("At most %d products can be imported at once", maxImportRows))
+		return
+	}
+
+	plans := make([]*importPlan, 0, len(rows))
+	slugs, products := map[string]int{}, map[int]int{}
+	for _, row := range rows {
+		plan, err := planImportRow(r.Context(), row, slugs, products)
+		if err != nil {
+			log.Printf("❌ Checking import row %d failed: %v", row.line, err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to check import")
+			return
+		}
+		plans = append(plans, plan)
+	}
+
+	report := importReport(plans, dryRun)
+	if dryRun {
+		utils.SuccessResponse(w, "Import checked; nothing was saved", report)
+		return
+	}
+	if report.Failed > 0 {
+		utils.JSONResponse(w, http.StatusUnprocessableEntity, utils.Response{
+			Success: false,
+			Error:   fmt.Sprintf("%d of %d rows are invalid; nothing was imported", report.Failed, report.Total),
+			Data:    report,
+		})
+		return
+	}
+
+	items := make([]models.ProductImportItem, len(plans))
+	for i, plan := range plans {
+		items[i] = models.ProductImportItem{Product: plan.product, Specs: plan.specs, ReplaceSpecs: plan.replaceSpecs}
+	}
+	if err := store.Products.Import(r.Context(), items); err != nil {
+		log.Printf("❌ Product import failed: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to import products; nothing was saved")
+		return
+	}
+	for i, plan := range plans {
+		p := items[i].Product
+		plan.result.ProductID, plan.result.Slug = p.ID, p.Slug
+		if err := syncPrimaryImage(r.Context(), p.ID, p.Image); err != nil {
+			log.Printf("⚠️  Failed to sync gallery of product %d: %v", p.ID, err)
+		}
+		reindexProduct(r.Context(), p.ID)
+	}
+	utils.SuccessResponse(w, "Products imported successfully", importReport(plans, false))
+}
+
+// importReport counts the outcome of plans
+func importReport(plans []*importPlan, dryRun bool) models.ProductImportReport {
+	report := models.ProductImportReport{DryRun: dryRun, Total: len(plans), Rows: make([]models.ProductImportResult, len(plans))}
+	for i, plan := range plans {
+		report.Rows[i] = plan.result
+		switch {
+		case len(plan.result.Errors) > 0:
+			report.Failed++
+		case plan.result.Action == models.ImportCreate:
+			report.Created++
+		default:
+			report.Updated++
+		}
+	}
+	return report
+}
+
+// planImportRow validates row and finds the product it updates. slugs and
+// products remember the row numbers of the slugs and products seen so far so
+// a file cannot name the same product twice. The error is only set when the
+// store fails; row problems go into the plan's result.
+func planImportRow(ctx context.Context, row importRow, slugs map[string]int, products map[int]int) (*importPlan, error) {
+	plan := &importPlan{result: models.ProductImportResult{Row: row.line}}
+	problems := row.problems
+
+	name := strings.TrimSpace(row.Name)
+	if name == "" {
+		problems = append(problems, "Name is required")
+	}
+	slug := ""
+	if name != "" || strings.TrimSpace(row.Slug) != "" {
+		var err error
+		if slug, err = productSlug(row.Slug, name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	category, err := resolveCategory(ctx, row.Category)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// Match by slug first, then by the variant SKUs
+	var existing *models.Product
+	if slug != "" {
+		existing, err = store.Products.FindBySlug(ctx, slug, true)
+		if err != nil && err != repository.ErrNotFound {
+			return nil, err
+		}
+	}
+	for _, sku := range row.SKUs {
+		sku = strings.TrimSpace(sku)
+		if sku == "" {
+			continue
+		}
+		owner, err := store.Products.FindBySKU(ctx, sku)
+		switch {
+		case err == repository.ErrNotFound:
+			problems = append(problems, "Unknown SKU: "+sku+" (variants are managed with PUT /api/products/{id}/variants)")
+		case err != nil:
+			return nil, err
+		case existing == nil:
+			existing = owner
+		case owner.ID != existing.ID:
+			problems = append(problems, fmt.Sprintf("SKU %s belongs to another product (%s)", sku, owner.Slug))
+		}
+	}
+	if existing != nil {
+		slug = existing.Slug
+	}
+	plan.result.Slug = slug
+	if first, ok := slugs[slug]; ok && slug != "" {
+		problems = append(problems, fmt.Sprintf("Slug %s is also used on row %d", slug, first))
+	} else if slug != "" {
+		slugs[slug] = row.line
+	}
+
+	var variants []models.ProductVariant
+	if existing != nil {
+		plan.result.Action, plan.result.ProductID = models.ImportUpdate, existing.ID
+		if first, ok := products[existing.ID]; ok {
+			problems = append(problems, fmt.Sprintf("Row %d already updates this product", first))
+		} else {
+			products[existing.ID] = row.line
+		}
+		if _, variants, err = store.Products.Variants(ctx, existing.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		plan.result.Action = models.ImportCreate
+	}
+
+	// Price and stock of a product with variants follow its variants
+	if len(variants) == 0 && row.Price <= 0 {
+		problems = append(problems, "Price must be > 0")
+	}
+	if len(variants) == 0 && row.Stock < 0 {
+		problems = append(problems, "Stock must be >= 0")
+	}
+
+	// A row without specs keeps an existing product's specifications; a new
+	// product is still checked for required ones
+	if category != "" && (row.Specs != nil || existing == nil) {
+		values := row.Specs
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		specs, err := productSpecs(ctx, models.ProductCreateRequest{Category: category, Specs: values})
+		var invalid *specError
+		switch {
+		case errors.As(err, &invalid):
+			problems = append(problems, invalid.problems...)
+		case err != nil:
+			return nil, err
+		}
+		plan.specs, plan.replaceSpecs = specs, true
+	}
+
+	plan.product = models.Product{
+		Name:        name,
+		Slug:        slug,
+		Price:       row.Price,
+		Stock:       row.Stock,
+		Category:    category,
+		Description: strings.TrimSpace(row.Description),
+		Image:       strings.TrimSpace(row.Image),
+		Brand:       strings.TrimSpace(row.Brand),
+	}
+	if existing != nil {
+		plan.product.ID = existing.ID
+	}
+	if len(variants) > 0 {
+		plan.product.Price, plan.product.Stock = models.VariantTotals(variants)
+	}
+	plan.result.Errors = problems
+	return plan, nil
+}
+
+// readImport parses the import body as CSV or JSON. The format comes from
+// ?format=, else from the file extension or the Content-Type.
+func readImport(w http.ResponseWriter, r *http.Request) ([]importRow, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	format := strings.ToLower(r.URL.Query().Get("format"))
+
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, errors.New("Invalid multipart form")
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("Import file is required")
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(path.Ext(header.Filename)), ".")
+		}
+	} else if format == "" {
+		switch {
+		case strings.Contains(mediaType, "csv"):
+			format = "csv"
+		case strings.Contains(mediaType, "json"):
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "csv":
+		return readImportCSV(body)
+	case "json":
+		var in []models.ProductImportRow
+		if err := json.NewDecoder(body).Decode(&in); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, errors.New("Invalid JSON: expected an array of products")
+		}
+		rows := make([]importRow, len(in))
+		for i, row := range in {
+			rows[i] = importRow{ProductImportRow: row, line: i + 1}
+		}
+		return rows, nil
+	}
+	return nil, errors.New("Unknown import format (use ?format=csv or ?format=json)")
+}
+
+// readImportCSV parses a CSV import. The header names the columns in any
+// order; only name is required. Empty spec cells mean no value.
+func readImportCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, h := range header {
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff") // BOM written by Excel
+		}
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !strings.HasPrefix(h, specColumnPrefix) && !containsFold(importColumns, h) {
+			return nil, fmt.Errorf("Unknown column %q (use %s or %s<key>)", h, strings.Join(importColumns, ", "), specColumnPrefix)
+		}
+		if seen[h] {
+			return nil, fmt.Errorf("Duplicate column %q", h)
+		}
+		seen[h] = true
+		columns[i] = h
+	}
+	if !seen["name"] {
+		return nil, errors.New("The name column is required")
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := importRow{line: line}
+		for i, cell := range record {
+			cell = strings.TrimSpace(csvUnescape(cell))
+			switch col := columns[i]; col {
+			case "slug":
+				row.Slug = cell
+			case "name":
+				row.Name = cell
+			case "category":
+				row.Category = cell
+			case "brand":
+				row.Brand = cell
+			case "description":
+				row.Description = cell
+			case "image":
+				row.Image = cell
+			case "skus":
+				row.SKUs = splitList(cell)
+			case "price", "stock":
+				n, err := strconv.Atoi(cell)
+				if cell != "" && err != nil {
+					row.problems = append(row.problems, strings.ToUpper(col[:1])+col[1:]+" must be a whole number")
+				}
+				if col == "price" {
+					row.Price = n
+				} else {
+					row.Stock = n
+				}
+			default:
+				if row.Specs == nil {
+					row.Specs = map[string]interface{}{}
+				}
+				if cell != "" {
+					row.Specs[strings.TrimPrefix(col, specColumnPrefix)] = cell
+				}
+			}
+		}
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return rows, nil
+		}
+	}
+}
+
+// csvError words a csv.Reader error for the client
+func csvError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("Invalid CSV on line %d: %v", parseErr.Line, parseErr.Err)
+	}
+	return fmt.Errorf("Invalid CSV: %v", err)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportProducts - GET /api/admin/products/export?format=csv|json
+// Streams the catalog (archived products left out) in the import format, so
+// an edited export can be imported again. Spec values are keyed by the
+// template keys of each product's category; specs no longer in the template
+// are left out.
+func ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Unknown export format (use csv or json)")
+		return
+	}
+
+	products, err := store.Products.ListWithSpecifications(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch products")
+		return
+	}
+	templates := map[string]*models.SpecTemplate{}
+	var specKeys []string
+	seenKeys := map[string]bool{}
+	for _, p := range products {
+		if _, ok := templates[p.Category]; ok {
+			continue
+		}
+		tmpl, err := categoryTemplate(r.Context(), p.Category)
+		if err != nil {
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch spec templates")
+			return
+		}
+		templates[p.Category] = tmpl
+		for _, f := range tmpl.Fields {
+			if !seenKeys[f.Key] {
+				seenKeys[f.Key] = true
+				specKeys = append(specKeys, f.Key)
+			}
+		}
+	}
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	flush := http.NewResponseController(w).Flush
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[\n")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	csvOut := csv.NewWriter(w)
+	if format == "csv" {
+		header := append([]string(nil), importColumns...)
+		for _, key := range specKeys {
+			header = append(header, specColumnPrefix+key)
+		}
+		csvOut.Write(header)
+	}
+
+	// Headers are out; from here on failures can only be logged
+	for i, p := range products {
+		row, err := exportRow(r.Context(), p, templates[p.Category])
+		if err != nil {
+			log.Printf("❌ Product export stopped at product %d: %v", p.ID, err)
+			return
+		}
+		if format == "json" {
+			data, _ := json.Marshal(row)
+			if i > 0 {
+				io.WriteString(w, ",\n")
+			}
+			w.Write(data)
+		} else {
+			record := []string{row.Slug, row.Name, row.Category, row.Brand, strconv.Itoa(row.Price),
+				strconv.Itoa(row.Stock), row.Description, row.Image, strings.Join(row.SKUs, ", ")}
+			for _, key := range specKeys {
+				record = append(record, csvCell(row.Specs[key]))
+			}
+			for i, cell := range record {
+				record[i] = csvSafe(cell)
+			}
+			csvOut.Write(record)
+		}
+		if i%100 == 99 {
+			csvOut.Flush()
+			flush()
+		}
+	}
+	if format == "json" {
+		io.WriteString(w, "\n]\n")
+	}
+	csvOut.Flush()
+	if err := csvOut.Error(); err != nil {
+		log.Printf("❌ Product export failed: %v", err)
+	}
+}
+
+// categoryTemplate returns the spec template in effect for the named category
+func categoryTemplate(ctx context.Context, name string) (*models.SpecTemplate, error) {
+	c, err := store.Categories.Find(ctx, name)
+	if err == repository.ErrNotFound {
+		return &models.SpecTemplate{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return specTemplate(ctx, c.ID)
+}
+
+// exportRow converts p to an import row, mapping its spec rows back to
+// template keys by label
+func exportRow(ctx context.Context, p models.Product, tmpl *models.SpecTemplate) (models.ProductImportRow, error) {
+	row := models.ProductImportRow{
+		Slug:        p.Slug,
+		Name:        p.Name,
+		Category:    p.Category,
+		Brand:       p.Brand,
+		Price:       p.Price,
+		Stock:       p.Stock,
+		Description: p.Description,
+		Image:       p.Image,
+		Specs:       map[string]interface{}{},
+	}
+	_, variants, err := store.Products.Variants(ctx, p.ID)
+	if err != nil {
+		return row, err
+	}
+	for _, v := range variants {
+		row.SKUs = append(row.SKUs, v.SKU)
+	}
+
+	for _, s := range p.Specifications {
+		for _, f := range tmpl.Fields {
+			if !strings.EqualFold(f.Label, s.Key) {
+				continue
+			}
+			switch {
+			case f.Type == models.SpecNumber && s.Number != nil:
+				row.Specs[f.Key] = *s.Number
+			case f.Type == models.SpecBoolean && s.Number != nil:
+				row.Specs[f.Key] = *s.Number == 1
+			default:
+				row.Specs[f.Key] = s.Value
+			}
+		}
+	}
+	return row, nil
+}
+
+// csvFormulaPrefixes start cells that spreadsheets evaluate as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafe prefixes a cell spreadsheets would run as a formula with ' so it
+// is shown as text; readImportCSV strips the ' again
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// csvUnescape undoes csvSafe
+func csvUnescape(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// csvCell formats an exported spec value for a CSV cell
+func csvCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
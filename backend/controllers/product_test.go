@@ -0,0 +1,338 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/search"
+)
+
+// productPage is the data of a product listing response
+type productPage struct {
+	Products   []models.Product `json:"products"`
+	Pagination map[string]int   `json:"pagination"`
+}
+
+// list fetches a product listing and returns the page
+func (a *testAPI) list(path string) productPage {
+	a.t.Helper()
+	res := a.call("GET", path, "", nil)
+	a.expect(res, http.StatusOK)
+	var page productPage
+	res.decode(a.t, &page)
+	return page
+}
+
+// getProduct fetches one product by ID
+func (a *testAPI) getProduct(id int) models.Product {
+	a.t.Helper()
+	res := a.call("GET", fmt.Sprintf("/api/products/%d", id), "", nil)
+	a.expect(res, http.StatusOK)
+	var p models.Product
+	res.decode(a.t, &p)
+	return p
+}
+
+// ids returns the product IDs of the page in order
+func (p productPage) ids() []int {
+	ids := []int{}
+	for _, product := range p.Products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
+
+// catalog creates the products the listing tests query
+func (a *testAPI) catalog(admin string) map[string]int {
+	a.t.Helper()
+	ids := map[string]int{}
+	for _, p := range []struct {
+		name, category, brand string
+		price, stock          int
+		rating                float64
+	}{
+		{"Galaxy S24", "Smartphones", "Samsung", 12000000, 5, 4.7},
+		{"Galaxy A15", "Smartphones", "Samsung", 2500000, 0, 4.2},
+		{"Pixel 8", "Smartphones", "Google", 9000000, 3, 4.5},
+		{"ThinkPad X1", "Laptops", "Lenovo", 25000000, 2, 4.8},
+		{"Buds Pro", "Audio", "Samsung", 1500000, 10, 3.9},
+	} {
+		res := a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": p.name, "category": p.category, "brand": p.brand,
+			"price": p.price, "stock": p.stock, "rating": p.rating,
+		})
+		a.expect(res, http.StatusCreated)
+		var created struct {
+			ID int `json:"id"`
+		}
+		res.decode(a.t, &created)
+		ids[p.name] = created.ID
+	}
+	return ids
+}
+
+func TestProductListingPages(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	ids := a.catalog(admin)
+
+	var seen []int
+	for page := 1; page <= 3; page++ {
+		got := a.list(fmt.Sprintf("/api/products?sort=price_asc&limit=2&page=%d", page))
+		want := map[string]int{"page": page, "limit": 2, "total": 5, "total_pages": 3}
+		if !reflect.DeepEqual(got.Pagination, want) {
+			t.Fatalf("page %d: pagination %v, want %v", page, got.Pagination, want)
+		}
+		seen = append(seen, got.ids()...)
+	}
+	want := []int{ids["Buds Pro"], ids["Galaxy A15"], ids["Pixel 8"], ids["Galaxy S24"], ids["ThinkPad X1"]}
+	if !reflect.DeepEqual(seen, want) {
+		t.Fatalf("pages returned %v, want %v", seen, want)
+	}
+
+	if got := a.list("/api/products?page=4&limit=2"); len(got.Products) != 0 || got.Pagination["total"] != 5 {
+		t.Fatalf("page past the end: %+v", got)
+	}
+	if got := a.list("/api/products?limit=1000"); got.Pagination["limit"] != 100 || len(got.Products) != 5 {
+		t.Fatalf("limit not capped: %v", got.Pagination)
+	}
+	if got := a.list("/api/products?page=abc&limit=0"); got.Pagination["page"] != 1 || got.Pagination["limit"] != 20 {
+		t.Fatalf("defaults not applied: %v", got.Pagination)
+	}
+	if got := a.list("/api/products?page=10000"); len(got.Products) != 0 {
+		t.Fatalf("last allowed page returned %v", got.ids())
+	}
+}
+
+func TestPageOutOfRangeIsRejected(t *testing.T) {
+	a := newTestAPI(t)
+	id, token := a.user("budi@example.com", "customer")
+
+	// (page-1)*limit must not overflow into a negative offset
+	for _, path := range []string{
+		"/api/products?page=10001",
+		"/api/products?page=9223372036854775807&limit=100",
+		"/api/products/search?q=galaxy&page=92233720368547759",
+		fmt.Sprintf("/api/users/%d/wallet/transactions?page=10001", id),
+	} {
+		a.expect(a.call("GET", path, token, nil), http.StatusBadRequest)
+	}
+}
+
+func TestProductListingFilters(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	ids := a.catalog(admin)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"category=Laptops", []string{"ThinkPad X1"}},
+		{"category=smartphones,audio&sort=price_desc", []string{"Galaxy S24", "Pixel 8", "Galaxy A15", "Buds Pro"}},
+		{"brand=Samsung&sort=price_asc", []string{"Buds Pro", "Galaxy A15", "Galaxy S24"}},
+		{"brand=Samsung,Google&category=Smartphones&sort=rating", []string{"Galaxy S24", "Pixel 8", "Galaxy A15"}},
+		{"min_price=2500000&max_price=12000000&sort=price_asc", []string{"Galaxy A15", "Pixel 8", "Galaxy S24"}},
+		{"in_stock=true&category=Smartphones&sort=price_asc", []string{"Pixel 8", "Galaxy S24"}},
+		{"min_rating=4.5&sort=rating", []string{"ThinkPad X1", "Galaxy S24", "Pixel 8"}},
+		{"brand=Nokia", []string{}},
+	}
+	for _, tt := range tests {
+		want := []int{}
+		for _, name := range tt.want {
+			want = append(want, ids[name])
+		}
+		if got := a.list("/api/products?" + tt.query).ids(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, want)
+		}
+	}
+
+	for _, query := range []string{"sort=cheapest", "min_price=-1", "max_price=abc", "min_rating=6", "in_stock=maybe"} {
+		a.expect(a.call("GET", "/api/products?"+query, "", nil), http.StatusBadRequest)
+	}
+}
+
+func TestSuggestProducts(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	ids := a.catalog(admin)
+
+	suggest := func(query string) []search.Suggestion {
+		t.Helper()
+		res := a.call("GET", "/api/products/suggest?"+query, "", nil)
+		a.expect(res, http.StatusOK)
+		var got []search.Suggestion
+		res.decode(t, &got)
+		return got
+	}
+
+	want := []search.Suggestion{
+		{Text: "Galaxy S24", Type: search.SuggestProduct, ProductID: ids["Galaxy S24"]},
+		{Text: "Galaxy A15", Type: search.SuggestProduct, ProductID: ids["Galaxy A15"]},
+	}
+	if got := suggest("q=gal"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("q=gal: got %+v, want %+v", got, want)
+	}
+	if got := suggest("q=gal&limit=1"); !reflect.DeepEqual(got, want[:1]) {
+		t.Fatalf("limit=1: got %+v", got)
+	}
+	if got := suggest("q=s"); len(got) == 0 || got[0] != (search.Suggestion{Text: "Smartphones", Type: search.SuggestCategory}) {
+		t.Fatalf("q=s: got %+v, want the category first", got)
+	}
+	if got := suggest("q=%20"); len(got) != 0 {
+		t.Fatalf("blank query: got %+v", got)
+	}
+
+	// Archived products drop out and new ones appear without a rebuild
+	a.expect(a.call("POST", fmt.Sprintf("/api/products/%d/archive", ids["Galaxy S24"]), admin, nil), http.StatusOK)
+	a.product(admin, "Galaxy Tab S9", 11000000, 1)
+	got := suggest("q=galaxy")
+	if len(got) != 2 || got[0].Text != "Galaxy A15" || got[1].Text != "Galaxy Tab S9" {
+		t.Fatalf("after archive and create: got %+v", got)
+	}
+}
+
+// specCatalog creates phones and laptops whose templates share some labels
+func (a *testAPI) specCatalog(admin string) map[string]int {
+	a.t.Helper()
+	ids := map[string]int{}
+	for _, p := range []struct {
+		name, category string
+		specs          map[string]interface{}
+	}{
+		{"Galaxy S24", "Smartphones", map[string]interface{}{"ram": 12, "display": 6.2, "battery": "4000 mAh", "os": "Android"}},
+		{"Galaxy A15", "Smartphones", map[string]interface{}{"ram": 8, "display": 6.5, "battery": 5000, "os": "Android"}},
+		{"iPhone 15", "Smartphones", map[string]interface{}{"ram": 6, "display": 6.1, "battery": 3349, "os": "iOS"}},
+		{"ThinkPad X1", "Laptops", map[string]interface{}{"ram": 16, "display": "14 inch OLED", "battery": 57}},
+	} {
+		res := a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": p.name, "category": p.category, "price": 1000, "specs": p.specs,
+		})
+		a.expect(res, http.StatusCreated)
+		var created struct {
+			ID int `json:"id"`
+		}
+		res.decode(a.t, &created)
+		ids[p.name] = created.ID
+	}
+	return ids
+}
+
+func TestProductSpecFilters(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	a.specCatalog(admin)
+	a.gadgetTemplate(admin)
+	for _, waterproof := range []bool{true, false} {
+		a.expect(a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": fmt.Sprintf("Gadget %v", waterproof), "category": "Gadget", "price": 1000,
+			"specs": map[string]interface{}{"weight": 30, "waterproof": waterproof},
+		}), http.StatusCreated)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"spec[RAM]=8", []string{"Galaxy A15"}},
+		{"spec[ram]=12,6", []string{"Galaxy S24", "iPhone 15"}},
+		{"spec[Operating System]=android", []string{"Galaxy A15", "Galaxy S24"}},
+		{"spec[RAM]>8", []string{"Galaxy S24", "ThinkPad X1"}},
+		{"spec[RAM]>=8&category=Smartphones", []string{"Galaxy A15", "Galaxy S24"}},
+		{"spec[RAM]<8", []string{"iPhone 15"}},
+		{"spec[RAM]<=8", []string{"Galaxy A15", "iPhone 15"}},
+		{"spec[RAM]>=8&spec[Battery]>4000", []string{"Galaxy A15"}},
+		// Comparisons skip the laptops' text Display
+		{"spec[Display]>6", []string{"Galaxy A15", "Galaxy S24", "iPhone 15"}},
+		{"spec[Waterproof]=true", []string{"Gadget true"}},
+		{"spec[Waterproof]=false", []string{"Gadget false"}},
+		{"spec[RAM]=32", []string{}},
+	}
+	for _, tt := range tests {
+		page := a.list("/api/products?" + url.PathEscape(tt.query))
+		got := []string{}
+		for _, p := range page.Products {
+			got = append(got, p.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"spec[RAM]!=8", "spec[RAM]>", "spec[RAM]>big", "spec[RAM]>=NaN", "spec[RAM]<Inf", "spec[RAM]=", "spec[RAM]=,"} {
+		if res := a.call("GET", "/api/products?"+url.PathEscape(query), "", nil); res.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, res.Code)
+		}
+	}
+}
+
+func TestProductSpecFacets(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	a.specCatalog(admin)
+
+	facets := func(query string) []models.SpecFacet {
+		t.Helper()
+		res := a.call("GET", "/api/products?"+url.PathEscape(query), "", nil)
+		a.expect(res, http.StatusOK)
+		var data struct {
+			Facets struct {
+				Specs []models.SpecFacet `json:"specs"`
+			} `json:"facets"`
+		}
+		res.decode(t, &data)
+		return data.Facets.Specs
+	}
+	find := func(list []models.SpecFacet, category, key string) *models.SpecFacet {
+		for i := range list {
+			if list[i].Category == category && list[i].Key == key {
+				return &list[i]
+			}
+		}
+		return nil
+	}
+	num := func(f float64) *float64 { return &f }
+
+	all := facets("")
+	// Shared labels stay apart per category, with their own type and unit
+	phone, laptop := find(all, "Smartphones", "Display"), find(all, "Laptops", "Display")
+	if phone == nil || laptop == nil || phone.Type != models.SpecNumber || laptop.Type != models.SpecText {
+		t.Fatalf("display facets = %+v, %+v", phone, laptop)
+	}
+	if !reflect.DeepEqual(phone.Min, num(6.1)) || !reflect.DeepEqual(phone.Max, num(6.5)) || phone.Unit != "inch" {
+		t.Fatalf("phone display range = %v..%v %q", phone.Min, phone.Max, phone.Unit)
+	}
+	if b := find(all, "Laptops", "Battery"); b == nil || b.Unit != "Wh" || len(b.Values) != 1 || b.Values[0].Count != 1 {
+		t.Fatalf("laptop battery facet = %+v", b)
+	}
+	ram := find(all, "Smartphones", "RAM")
+	if ram == nil || len(ram.Values) != 3 || *ram.Values[0].Number != 6 || *ram.Values[2].Number != 12 {
+		t.Fatalf("phone RAM facet = %+v", ram)
+	}
+	os := find(all, "Smartphones", "Operating System")
+	want := []models.SpecFacetValue{{Value: "Android", Count: 2}, {Value: "iOS", Count: 1}}
+	if os == nil || !reflect.DeepEqual(os.Values, want) {
+		t.Fatalf("OS facet = %+v, want values %+v", os, want)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Category > all[i].Category {
+			t.Fatalf("facets not grouped by category: %q before %q", all[i-1].Category, all[i].Category)
+		}
+	}
+
+	// The category filter applies, spec filters do not
+	for _, f := range facets("category=Smartphones&spec[RAM]=8") {
+		if f.Category != "Smartphones" {
+			t.Fatalf("category=Smartphones returned a %s facet", f.Category)
+		}
+		if f.Key == "RAM" && len(f.Values) != 3 {
+			t.Fatalf("spec filter narrowed the RAM facet: %+v", f.Values)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// reservationTTL is how long checkout start holds stock, 15 minutes unless
+// RESERVATION_TTL is set (e.g. "10m")
+func reservationTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("RESERVATION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
+
+// writeReservation sends the user's holds and when they run out
+func writeReservation(w http.ResponseWriter, message string, holds []models.StockReservation) {
+	if holds == nil {
+		holds = []models.StockReservation{}
+	}
+	var expiresAt *time.Time
+	for i := range holds {
+		if expiresAt == nil || holds[i].ExpiresAt.Before(*expiresAt) {
+			expiresAt = &holds[i].ExpiresAt
+		}
+	}
+	utils.SuccessResponse(w, message, map[string]interface{}{
+		"expires_at": expiresAt,
+		"items":      holds,
+	})
+}
+
+// POST /api/users/{id}/checkout/start
+// Same body as checkout. Holds the cart quantities of the selected lines for
+// RESERVATION_TTL so they cannot sell out before payment; calling it again
+// replaces the previous holds and restarts the timer.
+func StartCheckout(w http.ResponseWriter, r *http.Request) {
+	checkout, ok := decodeCheckoutRequest(w, r)
+	if !ok {
+		return
+	}
+
+	holds, err := store.Reservations.Reserve(r.Context(), checkout.UserID, checkout.Lines, time.Now().Add(reservationTTL()))
+	if err != nil {
+		writeCheckoutError(w, err)
+		return
+	}
+	writeReservation(w, "Stock reserved", holds)
+}
+
+// GET /api/users/{id}/checkout/reservation
+func GetCheckoutReservation(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	holds, err := store.Reservations.List(r.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch reservation")
+		return
+	}
+	// Expired holds waiting for the sweeper no longer count
+	active := holds[:0]
+	for _, h := range holds {
+		if h.ExpiresAt.After(time.Now()) {
+			active = append(active, h)
+		}
+	}
+	writeReservation(w, "Reservation fetched", active)
+}
+
+// DELETE /api/users/{id}/checkout/reservation
+// Gives the held stock back, e.g. when the customer leaves the checkout page.
+func ReleaseCheckoutReservation(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := store.Reservations.Release(r.Context(), userID); err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to release reservation")
+		return
+	}
+	utils.SuccessResponse(w, "Reservation released", nil)
+}
+
+// SweepReservations releases expired stock holds every interval until ctx
+// is done. Run it in its own goroutine after SetupRoutes.
+func SweepReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := store.Reservations.ReleaseExpired(ctx, now)
+			if err != nil {
+				log.Printf("❌ Releasing expired stock reservations failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Released %d expired stock reservation(s)", n)
+			}
+		}
+	}
+}
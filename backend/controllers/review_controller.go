@@ -4,9 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"time"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/gorilla/mux"
 )
@@ -23,12 +22,8 @@ func SubmitReviews(w http.ResponseWriter, r *http.Request) {
 	orderNumber := vars["orderNumber"]
 
 	// Resolve numeric order ID
-	var orderID int
-	err = config.DB.QueryRow(
-		"SELECT id FROM orders WHERE order_number = ? AND user_id = ?",
-		orderNumber, userID,
-	).Scan(&orderID)
-	if err != nil {
+	order, err := store.Orders.Get(r.Context(), orderNumber)
+	if err != nil || order.UserID != userID {
 		utils.ErrorResponse(w, http.StatusNotFound, "Order not found")
 		return
 	}
@@ -49,19 +44,13 @@ func SubmitReviews(w http.ResponseWriter, r *http.Request) {
 		if item.ProductID <= 0 || item.Rating < 1 || item.Rating > 5 {
 			continue
 		}
-		_, _ = config.DB.Exec(`
-			INSERT INTO reviews (product_id, user_id, order_id, rating, review_text, is_verified, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, 1, NOW(), NOW())
-			ON DUPLICATE KEY UPDATE rating = VALUES(rating), review_text = VALUES(review_text), updated_at = NOW()
-		`, item.ProductID, userID, orderID, item.Rating, item.ReviewText)
-
-		// Recalculate product average rating and review count
-		_, _ = config.DB.Exec(`
-			UPDATE products SET
-				rating        = (SELECT AVG(rating)   FROM reviews WHERE product_id = ?),
-				total_reviews = (SELECT COUNT(*)       FROM reviews WHERE product_id = ?)
-			WHERE id = ?
-		`, item.ProductID, item.ProductID, item.ProductID)
+		_ = store.Reviews.Upsert(r.Context(), models.Review{
+			ProductID:  item.ProductID,
+			UserID:     userID,
+			OrderID:    order.ID,
+			Rating:     item.Rating,
+			ReviewText: item.ReviewText,
+		})
 	}
 
 	utils.SuccessResponse(w, "Reviews submitted successfully", nil)
@@ -85,38 +74,22 @@ func GetProductReviews(w http.ResponseWriter, r *http.Request) {
 		CreatedAt  string `json:"created_at"`
 	}
 
-	rows, err := config.DB.Query(`
-		SELECT r.id,
-		       COALESCE(NULLIF(u.full_name,''), u.email, 'Anonymous'),
-		       r.rating,
-		       COALESCE(r.review_text, ''),
-		       r.is_verified,
-		       r.created_at
-		FROM reviews r
-		JOIN users u ON u.id = r.user_id
-		WHERE r.product_id = ?
-		ORDER BY r.created_at DESC
-	`, productID)
+	list, err := store.Reviews.ListByProduct(r.Context(), productID)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch reviews")
 		return
 	}
-	defer rows.Close()
 
-	var reviews []ReviewRow
-	for rows.Next() {
-		var rev ReviewRow
-		var createdAt time.Time
-		var isVerified int
-		if err := rows.Scan(&rev.ID, &rev.UserName, &rev.Rating, &rev.ReviewText, &isVerified, &createdAt); err != nil {
-			continue
-		}
-		rev.IsVerified = isVerified == 1
-		rev.CreatedAt = createdAt.Format("Jan 02, 2006")
-		reviews = append(reviews, rev)
-	}
-	if reviews == nil {
-		reviews = []ReviewRow{}
+	reviews := []ReviewRow{}
+	for _, rev := range list {
+		reviews = append(reviews, ReviewRow{
+			ID:         rev.ID,
+			UserName:   rev.UserName,
+			Rating:     rev.Rating,
+			ReviewText: rev.ReviewText,
+			IsVerified: rev.IsVerified,
+			CreatedAt:  rev.CreatedAt.Format("Jan 02, 2006"),
+		})
 	}
 
 	utils.SuccessResponse(w, "Reviews fetched", reviews)
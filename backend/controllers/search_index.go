@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"log"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/search"
+)
+
+// catalog is the in-process full-text index used by SearchProducts and
+// suggestions the prefix index used by SuggestProducts. Both are built from
+// the store at startup and kept in sync by the product handlers.
+var (
+	catalog     = search.New()
+	suggestions = search.NewSuggester()
+)
+
+// BuildSearchIndex (re)builds the product search and suggestion indexes from the store
+func BuildSearchIndex(ctx context.Context) error {
+	products, err := store.Products.ListWithSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	catalog.Build(products)
+	suggestions.Build(products)
+	log.Printf("✅ Search index built (%d products)", catalog.Len())
+	return nil
+}
+
+// reindexProduct refreshes one product after it was created or updated;
+// archived products are kept out of the indexes
+func reindexProduct(ctx context.Context, id int) {
+	p, err := store.Products.GetByID(ctx, id)
+	if err != nil {
+		log.Printf("⚠️  Failed to reindex product %d: %v", id, err)
+		return
+	}
+	if p.DeletedAt != nil {
+		unindexProduct(id)
+		return
+	}
+	catalog.Upsert(*p)
+	suggestions.Upsert(*p)
+}
+
+// unindexProduct drops an archived product from the indexes
+func unindexProduct(id int) {
+	catalog.Remove(id)
+	suggestions.Remove(id)
+}
@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// specKeyPattern is the shape of a template key: it is used as a JSON field
+// name in product requests, so keep it to lower-case identifiers
+var specKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// GetSpecTemplate - GET /api/categories/{id}/spec-template
+// Returns the fields products of the category carry. A category without
+// fields of its own uses the nearest ancestor's template.
+func GetSpecTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	tmpl, err := specTemplate(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch spec template")
+		return
+	}
+	utils.SuccessResponse(w, "Spec template fetched successfully", tmpl)
+}
+
+// ReplaceSpecTemplate - PUT /api/categories/{id}/spec-template
+// Body: {"fields": [{"key", "label", "unit", "type", "options", "required", "display_order"}]}.
+// An empty list makes the category inherit its parent's template again.
+// Existing products keep their specifications until they are next saved.
+func ReplaceSpecTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if _, err := store.Categories.GetByID(r.Context(), id); err != nil {
+		if err == repository.ErrNotFound {
+			utils.ErrorResponse(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch category")
+		return
+	}
+
+	var req struct {
+		Fields []models.SpecField `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	fields, msg := validateSpecFields(req.Fields)
+	if msg != "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := store.Categories.ReplaceSpecFields(r.Context(), id, fields); err != nil {
+		if err == repository.ErrDuplicate {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Spec keys must be unique")
+			return
+		}
+		log.Printf("❌ Save spec template for category %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to save spec template")
+		return
+	}
+	utils.SuccessResponse(w, "Spec template saved successfully", fields)
+}
+
+// validateSpecFields normalises the fields of a template. The returned
+// message is non-empty when they are invalid.
+func validateSpecFields(in []models.SpecField) ([]models.SpecField, string) {
+	fields := make([]models.SpecField, 0, len(in))
+	keys, labels := map[string]bool{}, map[string]bool{}
+	for i, f := range in {
+		f.Key = strings.TrimSpace(f.Key)
+		f.Label = strings.TrimSpace(f.Label)
+		f.Unit = strings.TrimSpace(f.Unit)
+		if f.Type == "" {
+			f.Type = models.SpecText
+		}
+		switch {
+		case !specKeyPattern.MatchString(f.Key):
+			return nil, fmt.Sprintf("Field %d: key must be lower-case letters, digits or underscores (max 50)", i+1)
+		case f.Label == "" || len(f.Label) > 100:
+			return nil, fmt.Sprintf("Field %s: label is required (max 100 characters)", f.Key)
+		case len(f.Unit) > 20:
+			return nil, fmt.Sprintf("Field %s: unit must be at most 20 characters", f.Key)
+		case !models.IsSpecType(f.Type):
+			return nil, fmt.Sprintf("Field %s: type must be text, number, boolean or enum", f.Key)
+		case keys[f.Key]:
+			return nil, "Duplicate spec key: " + f.Key
+		case labels[strings.ToLower(f.Label)]:
+			return nil, "Duplicate spec label: " + f.Label
+		}
+		keys[f.Key], labels[strings.ToLower(f.Label)] = true, true
+
+		if f.Type != models.SpecEnum {
+			f.Options = nil
+		} else {
+			options, seen := []string{}, map[string]bool{}
+			for _, o := range f.Options {
+				o = strings.TrimSpace(o)
+				if o == "" || seen[strings.ToLower(o)] {
+					continue
+				}
+				seen[strings.ToLower(o)] = true
+				options = append(options, o)
+			}
+			if len(options) == 0 {
+				return nil, fmt.Sprintf("Field %s: an enum needs at least one option", f.Key)
+			}
+			f.Options = options
+		}
+		if f.DisplayOrder == 0 {
+			f.DisplayOrder = i + 1
+		}
+		fields = append(fields, f)
+	}
+	return fields, ""
+}
+
+// specTemplate returns the template in effect for the category: its own
+// fields, or those of the nearest ancestor that has any
+func specTemplate(ctx context.Context, categoryID int) (*models.SpecTemplate, error) {
+	list, err := store.Categories.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parents := map[int]*int{}
+	for _, c := range list {
+		parents[c.ID] = c.ParentID
+	}
+	if _, ok := parents[categoryID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	tmpl := &models.SpecTemplate{CategoryID: categoryID, Fields: []models.SpecField{}}
+	seen := map[int]bool{}
+	for id := categoryID; !seen[id]; {
+		seen[id] = true
+		fields, err := store.Categories.SpecFields(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			if id != categoryID {
+				from := id
+				tmpl.InheritedFrom = &from
+			}
+			tmpl.Fields = fields
+			break
+		}
+		parent := parents[id]
+		if parent == nil {
+			break
+		}
+		id = *parent
+	}
+	return tmpl, nil
+}
+
+// productSpecs validates the spec values of a product request against its
+// category's template and returns the rows to store, in display order.
+// Requests without "specs" fall back to the legacy flat fields.
+func productSpecs(ctx context.Context, req models.ProductCreateRequest) ([]models.ProductSpec, error) {
+	category, err := store.Categories.Find(ctx, req.Category)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := specTemplate(ctx, category.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	values, legacy := req.Specs, false
+	if values == nil {
+		values, legacy = legacySpecValues(req), true
+	}
+
+	known := map[string]bool{}
+	var specs []models.ProductSpec
+	var problems []string
+	for _, f := range tmpl.Fields {
+		known[f.Key] = true
+		spec, err := specValue(f, values[f.Key])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if spec.Value == "" {
+			if f.Required {
+				problems = append(problems, f.Label+" is required")
+			}
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	if !legacy {
+		var unknown []string
+		for key := range values {
+			if !known[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		for _, key := range unknown {
+			problems = append(problems, fmt.Sprintf("Unknown spec %q for %s", key, category.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &specError{problems}
+	}
+	return specs, nil
+}
+
+// productSpecsError reports a productSpecs failure: 400 for invalid values, 500 otherwise
+func productSpecsError(w http.ResponseWriter, err error) {
+	var invalid *specError
+	if errors.As(err, &invalid) {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Printf("❌ Checking specifications failed: %v", err)
+	utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to check specifications")
+}
+
+// specError lists every spec problem of a request
+type specError struct{ problems []string }
+
+func (e *specError) Error() string {
+	return "Invalid specifications: " + strings.Join(e.problems, "; ")
+}
+
+// specValue converts a request value for field f to the spec row stored in
+// product_specifications; an empty Value means no value
+func specValue(f models.SpecField, raw interface{}) (models.ProductSpec, error) {
+	spec := models.ProductSpec{Key: f.Label, Type: f.Type, Unit: f.Unit}
+	if raw == nil {
+		return spec, nil
+	}
+	s := strings.TrimSpace(fmt.Sprint(raw))
+	if s == "" {
+		return spec, nil
+	}
+
+	switch f.Type {
+	case models.SpecNumber:
+		var n float64
+		switch v := raw.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		default:
+			// Accept "16" and "16 GB" for a field measured in GB
+			num := strings.TrimSpace(strings.TrimSuffix(s, f.Unit))
+			parsed, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return spec, fmt.Errorf("%s must be a number", f.Label)
+			}
+			n = parsed
+		}
+		// ParseFloat accepts "NaN" and "Inf", which no spec can measure
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return spec, fmt.Errorf("%s must be a number", f.Label)
+		}
+		spec.Number = &n
+		spec.Value = strconv.FormatFloat(n, 'f', -1, 64)
+		if f.Unit != "" {
+			spec.Value += " " + f.Unit
+		}
+	case models.SpecBoolean:
+		b, ok := raw.(bool)
+		if !ok {
+			parsed, err := strconv.ParseBool(s)
+			if err != nil {
+				return spec, fmt.Errorf("%s must be true or false", f.Label)
+			}
+			b = parsed
+		}
+		n := 0.0
+		if b {
+			n = 1
+		}
+		spec.Number = &n
+		spec.Value = yesNo(b)
+	case models.SpecEnum:
+		for _, o := range f.Options {
+			if strings.EqualFold(o, s) {
+				spec.Value = o
+				return spec, nil
+			}
+		}
+		return spec, fmt.Errorf("%s must be one of: %s", f.Label, strings.Join(f.Options, ", "))
+	default:
+		if f.Unit != "" && !strings.HasSuffix(s, f.Unit) {
+			s += " " + f.Unit
+		}
+		spec.Value = s
+	}
+	return spec, nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -0,0 +1,163 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// specTemplate fetches the template in effect for a category
+func (a *testAPI) specTemplate(categoryID int) models.SpecTemplate {
+	a.t.Helper()
+	res := a.call("GET", fmt.Sprintf("/api/categories/%d/spec-template", categoryID), "", nil)
+	a.expect(res, http.StatusOK)
+	var tmpl models.SpecTemplate
+	res.decode(a.t, &tmpl)
+	return tmpl
+}
+
+// gadgetTemplate gives a new "Gadget" category one field of every type and returns its ID
+func (a *testAPI) gadgetTemplate(admin string) int {
+	a.t.Helper()
+	id := a.category(admin, map[string]interface{}{"name": "Gadget"})
+	a.expect(a.call("PUT", fmt.Sprintf("/api/categories/%d/spec-template", id), admin, map[string]interface{}{
+		"fields": []map[string]interface{}{
+			{"key": "weight", "label": "Weight", "unit": "g", "type": "number", "required": true},
+			{"key": "waterproof", "label": "Waterproof", "type": "boolean"},
+			{"key": "panel", "label": "Panel", "type": "enum", "options": []string{"OLED", "LCD"}},
+			{"key": "color", "label": "Color"},
+		},
+	}), http.StatusOK)
+	return id
+}
+
+func TestSpecTemplateInheritance(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+	parent := a.gadgetTemplate(admin)
+	child := a.category(admin, map[string]interface{}{"name": "Smartwatch", "parent_id": parent})
+
+	tmpl := a.specTemplate(child)
+	if tmpl.InheritedFrom == nil || *tmpl.InheritedFrom != parent || len(tmpl.Fields) != 4 {
+		t.Fatalf("child template = %+v, want the parent's fields", tmpl)
+	}
+
+	path := fmt.Sprintf("/api/categories/%d/spec-template", child)
+	own := map[string]interface{}{"fields": []map[string]interface{}{
+		{"key": "strap", "label": " Strap ", "type": "enum", "options": []string{"Silicone", " silicone", "", "Metal"}},
+		{"key": "battery", "label": "Battery", "unit": "days", "type": "number", "options": []string{"ignored"}, "display_order": 9},
+	}}
+	a.expect(a.call("PUT", path, customer, own), http.StatusForbidden)
+	res := a.call("PUT", path, admin, own)
+	a.expect(res, http.StatusOK)
+	var saved []models.SpecField
+	res.decode(t, &saved)
+	want := []models.SpecField{
+		{Key: "strap", Label: "Strap", Type: models.SpecEnum, Options: []string{"Silicone", "Metal"}, DisplayOrder: 1},
+		{Key: "battery", Label: "Battery", Unit: "days", Type: models.SpecNumber, DisplayOrder: 9},
+	}
+	if !reflect.DeepEqual(saved, want) {
+		t.Fatalf("saved %+v, want %+v", saved, want)
+	}
+	if tmpl := a.specTemplate(child); tmpl.InheritedFrom != nil || !reflect.DeepEqual(tmpl.Fields, want) {
+		t.Fatalf("own template = %+v", tmpl)
+	}
+
+	// An empty list falls back to the parent again
+	a.expect(a.call("PUT", path, admin, map[string]interface{}{"fields": []interface{}{}}), http.StatusOK)
+	if tmpl := a.specTemplate(child); tmpl.InheritedFrom == nil || *tmpl.InheritedFrom != parent {
+		t.Fatalf("after clearing = %+v", tmpl)
+	}
+	a.expect(a.call("GET", "/api/categories/999/spec-template", "", nil), http.StatusNotFound)
+	a.expect(a.call("PUT", "/api/categories/999/spec-template", admin, own), http.StatusNotFound)
+}
+
+func TestSpecTemplateValidation(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	id := a.category(admin, map[string]interface{}{"name": "Gadget"})
+	path := fmt.Sprintf("/api/categories/%d/spec-template", id)
+
+	tests := []struct {
+		name   string
+		fields []map[string]interface{}
+		msg    string
+	}{
+		{"upper-case key", []map[string]interface{}{{"key": "Weight", "label": "Weight"}}, "key must be"},
+		{"key with spaces", []map[string]interface{}{{"key": "screen size", "label": "Screen"}}, "key must be"},
+		{"missing label", []map[string]interface{}{{"key": "weight", "label": " "}}, "label is required"},
+		{"long unit", []map[string]interface{}{{"key": "weight", "label": "Weight", "unit": strings.Repeat("g", 21)}}, "unit must be"},
+		{"unknown type", []map[string]interface{}{{"key": "weight", "label": "Weight", "type": "float"}}, "type must be"},
+		{"duplicate key", []map[string]interface{}{{"key": "weight", "label": "Weight"}, {"key": "weight", "label": "Mass"}}, "Duplicate spec key"},
+		{"duplicate label", []map[string]interface{}{{"key": "weight", "label": "Weight"}, {"key": "mass", "label": "weight"}}, "Duplicate spec label"},
+		{"enum without options", []map[string]interface{}{{"key": "panel", "label": "Panel", "type": "enum", "options": []string{" "}}}, "at least one option"},
+	}
+	for _, tt := range tests {
+		res := a.call("PUT", path, admin, map[string]interface{}{"fields": tt.fields})
+		if res.Code != http.StatusBadRequest || !strings.Contains(res.Message+res.Error, tt.msg) {
+			t.Errorf("%s: got %d %q, want 400 mentioning %q", tt.name, res.Code, res.Message+res.Error, tt.msg)
+		}
+	}
+	if tmpl := a.specTemplate(id); len(tmpl.Fields) != 0 {
+		t.Fatalf("rejected templates were saved: %+v", tmpl.Fields)
+	}
+}
+
+func TestProductSpecValues(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	a.gadgetTemplate(admin)
+
+	create := func(specs map[string]interface{}) apiResponse {
+		return a.call("POST", "/api/products", admin, map[string]interface{}{
+			"name": "Gadget", "price": 1000, "category": "Gadget", "specs": specs,
+		})
+	}
+	num := func(f float64) *float64 { return &f }
+
+	res := create(map[string]interface{}{"weight": "32.5 g", "waterproof": "true", "panel": "oled", "color": "Hitam"})
+	a.expect(res, http.StatusCreated)
+	var created struct {
+		ID int `json:"id"`
+	}
+	res.decode(t, &created)
+	want := []models.ProductSpec{
+		{Key: "Weight", Value: "32.5 g", Type: models.SpecNumber, Number: num(32.5), Unit: "g"},
+		{Key: "Waterproof", Value: "Yes", Type: models.SpecBoolean, Number: num(1)},
+		{Key: "Panel", Value: "OLED", Type: models.SpecEnum},
+		{Key: "Color", Value: "Hitam", Type: models.SpecText},
+	}
+	if got := a.getProduct(created.ID).Specifications; !reflect.DeepEqual(got, want) {
+		t.Fatalf("specifications = %+v, want %+v", got, want)
+	}
+
+	// JSON numbers and booleans are accepted as they are; optional fields may be left out
+	a.expect(create(map[string]interface{}{"weight": 40, "waterproof": false}), http.StatusCreated)
+
+	tests := []struct {
+		name  string
+		specs map[string]interface{}
+		msg   string
+	}{
+		{"not a number", map[string]interface{}{"weight": "berat"}, "Weight must be a number"},
+		{"NaN", map[string]interface{}{"weight": "NaN"}, "Weight must be a number"},
+		{"infinity", map[string]interface{}{"weight": "Inf"}, "Weight must be a number"},
+		{"negative infinity", map[string]interface{}{"weight": "-Infinity g"}, "Weight must be a number"},
+		{"out of range", map[string]interface{}{"weight": "1e400"}, "Weight must be a number"},
+		{"not a boolean", map[string]interface{}{"weight": 1, "waterproof": "maybe"}, "Waterproof must be true or false"},
+		{"not an option", map[string]interface{}{"weight": 1, "panel": "AMOLED"}, "Panel must be one of: OLED, LCD"},
+		{"required missing", map[string]interface{}{"color": "Putih"}, "Weight is required"},
+		{"unknown key", map[string]interface{}{"weight": 1, "ram": 8}, `Unknown spec "ram" for Gadget`},
+	}
+	for _, tt := range tests {
+		res := create(tt.specs)
+		if res.Code != http.StatusBadRequest || !strings.Contains(res.Message+res.Error, tt.msg) {
+			t.Errorf("%s: got %d %q, want 400 mentioning %q", tt.name, res.Code, res.Message+res.Error, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"github.com/HHHAAAANNNNN/go-commerce-backend/mailer"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/storage"
+)
+
+// store is the storage layer every handler reads from; it is injected once at startup
+var store *repository.Store
+
+// mail delivers verification and password reset emails; defaults to the server log
+var mail mailer.Mailer = mailer.Log{}
+
+// uploads keeps uploaded files; defaults to ./uploads on local disk
+var uploads storage.Storage = &storage.Local{Dir: "./uploads", BaseURL: uploadURLPrefix}
+
+// SetStore injects the repositories used by the handlers
+func SetStore(s *repository.Store) {
+	store = s
+}
+
+// SetMailer injects the mailer used for account emails
+func SetMailer(m mailer.Mailer) {
+	mail = m
+}
+
+// SetUploadStorage injects the storage uploaded files are kept in
+func SetUploadStorage(s storage.Storage) {
+	uploads = s
+}
@@ -1,54 +1,225 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/HHHAAAANNNNN/go-commerce-backend/imaging"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 )
 
-func UploadProductImage(w http.ResponseWriter, r *http.Request) {
-	r.ParseMultipartForm(10 << 20) // 10MB max
+// uploadURLPrefix is the public path uploaded files are served under
+const uploadURLPrefix = "/assets/uploads/"
+
+// errNoImage is returned when a multipart upload carries no "image" file
+var errNoImage = errors.New("image file is required")
+
+// orphanGracePeriod keeps fresh uploads that have not been attached to anything yet
+const orphanGracePeriod = 24 * time.Hour
+
+// RenditionURL is one stored size of an uploaded image
+type RenditionURL struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// saveImage validates an uploaded image, puts its renditions in the upload
+// storage and returns their URLs by size name. Errors from the imaging
+// package mean the upload itself is unacceptable.
+func saveImage(ctx context.Context, file io.Reader) (map[string]RenditionURL, error) {
+	renditions, err := imaging.Process(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate unique filename; the extension follows the encoded format, not the client's name
+	base := fmt.Sprintf("product_%d", time.Now().UnixNano())
+	urls := make(map[string]RenditionURL, len(renditions))
+	for _, rendition := range renditions {
+		name := imaging.FileName(base, rendition)
+		err := uploads.Put(ctx, name, bytes.NewReader(rendition.Data), int64(len(rendition.Data)), mime.TypeByExtension(rendition.Ext))
+		if err != nil {
+			removeGroup(ctx, base)
+			return nil, err
+		}
+		urls[rendition.Name] = RenditionURL{URL: uploadURLPrefix + name, Width: rendition.Width, Height: rendition.Height}
+	}
+	return urls, nil
+}
+
+// imageUploadError answers a failed imageFormFile or saveImage
+func imageUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoImage):
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, imaging.ErrUnsupported):
+		utils.ErrorResponse(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and WebP images are accepted")
+	case errors.Is(err, imaging.ErrTooLarge):
+		utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image must be at most %d MB", imaging.MaxFileSize>>20))
+	case errors.Is(err, imaging.ErrDimensions), errors.Is(err, imaging.ErrCorrupt):
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	default:
+		log.Printf("❌ Failed to save image: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to save file")
+	}
+}
 
-	file, handler, err := r.FormFile("image")
+// imageFormFile returns the "image" file of a multipart upload, capping the
+// request body a little above the image size limit
+func imageFormFile(w http.ResponseWriter, r *http.Request) (multipart.File, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, imaging.MaxFileSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10MB in memory, the rest on disk
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, imaging.ErrTooLarge
+		}
+		return nil, errNoImage
+	}
+	file, _, err := r.FormFile("image")
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return nil, errNoImage
+	}
+	return file, nil
+}
+
+// removeGroup deletes every rendition of the upload stored under group
+func removeGroup(ctx context.Context, group string) {
+	objects, err := uploads.List(ctx, group)
+	if err != nil {
+		log.Printf("⚠️  Failed to list renditions of %s: %v", group, err)
 		return
 	}
-	defer file.Close()
+	for _, obj := range objects {
+		if imaging.Group(obj.Key) != group {
+			continue
+		}
+		if err := uploads.Delete(ctx, obj.Key); err != nil {
+			log.Printf("⚠️  Failed to remove %s: %v", obj.Key, err)
+		}
+	}
+}
 
-	// Create uploads directory jika belum ada
-	uploadsDir := "/app/uploads"
-	if os.Getenv("RAILWAY_ENVIRONMENT_NAME") == "" {
-		// Local development
-		uploadsDir = "./uploads"
+// referencedGroups maps referenced URLs onto the upload groups they keep alive
+func referencedGroups(urls map[string]bool) map[string]bool {
+	groups := map[string]bool{}
+	for url := range urls {
+		if key := uploadKey(url); key != "" {
+			groups[imaging.Group(key)] = true
+		}
 	}
+	return groups
+}
 
-	os.MkdirAll(uploadsDir, 0755)
+// uploadKey maps an /assets/uploads/ URL onto its storage key, or "" for any other URL
+func uploadKey(url string) string {
+	key := strings.TrimPrefix(url, uploadURLPrefix)
+	if key == url || key == "" || key != path.Base(key) {
+		return ""
+	}
+	return key
+}
 
-	// Generate unique filename
-	ext := filepath.Ext(handler.Filename)
-	filename := fmt.Sprintf("product_%d%s", time.Now().UnixNano(), ext)
-	filepath := filepath.Join(uploadsDir, filename)
+// removeIfOrphan deletes the uploaded file behind url, along with its other
+// renditions, once nothing references any of them
+func removeIfOrphan(ctx context.Context, url string) {
+	key := uploadKey(url)
+	if key == "" {
+		return
+	}
+	referenced, err := store.Images.ReferencedURLs(ctx)
+	if err != nil {
+		log.Printf("⚠️  Failed to check references of %s: %v", url, err)
+		return
+	}
+	group := imaging.Group(key)
+	if referencedGroups(referenced)[group] {
+		return
+	}
+	removeGroup(ctx, group)
+}
 
-	// Save file
-	dst, err := os.Create(filepath)
+// UploadProductImage - POST /api/upload
+// Accepts a JPEG, PNG or WebP "image" file and stores it as thumb, medium
+// and large renditions with metadata stripped. "url" is the large rendition.
+func UploadProductImage(w http.ResponseWriter, r *http.Request) {
+	file, err := imageFormFile(w, r)
 	if err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		imageUploadError(w, err)
 		return
 	}
-	defer dst.Close()
+	defer file.Close()
 
-	io.Copy(dst, file)
+	renditions, err := saveImage(r.Context(), file)
+	if err != nil {
+		imageUploadError(w, err)
+		return
+	}
 
-	// Return accessible URL
-	imageURL := fmt.Sprintf("/assets/uploads/%s", filename)
+	utils.SuccessResponse(w, "Image uploaded successfully", map[string]interface{}{
+		"url":        renditions["large"].URL,
+		"renditions": renditions,
+	})
+}
+
+// CleanupUploads - POST /api/admin/uploads/cleanup
+// Removes uploaded files no product, gallery, variant, category, avatar or
+// order item snapshot points at; all renditions of an upload stay while one
+// of them is used.
+// Files younger than a day are kept since an upload is attached in a second
+// request. ?dry_run=true only lists what would be removed.
+func CleanupUploads(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	referenced, err := store.Images.ReferencedURLs(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to collect image references")
+		return
+	}
+	groups := referencedGroups(referenced)
+	objects, err := uploads.List(r.Context(), "")
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to list uploads")
+		return
+	}
+
+	removed, kept := []string{}, 0
+	var freed int64
+	for _, obj := range objects {
+		if groups[imaging.Group(obj.Key)] || time.Since(obj.ModTime) < orphanGracePeriod {
+			kept++
+			continue
+		}
+		if !dryRun {
+			if err := uploads.Delete(r.Context(), obj.Key); err != nil {
+				log.Printf("⚠️  Failed to remove orphaned upload %s: %v", obj.Key, err)
+				kept++
+				continue
+			}
+		}
+		removed = append(removed, uploadURLPrefix+obj.Key)
+		freed += obj.Size
+	}
+	sort.Strings(removed)
+	if !dryRun && len(removed) > 0 {
+		log.Printf("✅ Removed %d orphaned uploads (%d bytes)", len(removed), freed)
+	}
 
-	utils.SuccessResponse(w, "Image uploaded successfully", map[string]string{
-		"url": imageURL,
+	utils.SuccessResponse(w, "Upload cleanup finished", map[string]interface{}{
+		"dry_run":     dryRun,
+		"removed":     removed,
+		"kept":        kept,
+		"freed_bytes": freed,
 	})
 }
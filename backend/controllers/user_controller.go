@@ -1,13 +1,16 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
@@ -15,29 +18,11 @@ import (
 
 // GetAllUsers - GET /api/users
 func GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	query := `SELECT id, full_name, email, phone, balance, is_member, total_spent, avatar_url, created_at FROM users ORDER BY id`
-
-	rows, err := config.DB.Query(query)
+	users, err := store.Users.List(r.Context())
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch users")
 		return
 	}
-	defer rows.Close()
-
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		var avatarURL sql.NullString
-		err := rows.Scan(&user.ID, &user.FullName, &user.Email, &user.Phone, &user.Balance, &user.IsMember, &user.TotalSpent, &avatarURL, &user.CreatedAt)
-		if err != nil {
-			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to scan user")
-			return
-		}
-		if avatarURL.Valid {
-			user.AvatarURL = avatarURL.String
-		}
-		users = append(users, user)
-	}
 
 	utils.SuccessResponse(w, "Users fetched successfully", users)
 }
@@ -51,20 +36,11 @@ func GetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, full_name, email, phone, balance, is_member, total_spent, avatar_url, created_at FROM users WHERE id = ?`
-
-	var user models.User
-	var avatarURL sql.NullString
-	err = config.DB.QueryRow(query, id).Scan(
-		&user.ID, &user.FullName, &user.Email, &user.Phone, &user.Balance, &user.IsMember, &user.TotalSpent, &avatarURL, &user.CreatedAt,
-	)
+	user, err := store.Users.GetByID(r.Context(), id)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
-	if avatarURL.Valid {
-		user.AvatarURL = avatarURL.String
-	}
 
 	utils.SuccessResponse(w, "User fetched successfully", user)
 }
@@ -83,19 +59,14 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `INSERT INTO users (full_name, email) VALUES (?, ?)`
-	result, err := config.DB.Exec(query, req.FullName, req.Email)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
-		return
-	}
-
-	id, _ := result.LastInsertId()
 	user := models.User{
-		ID:       int(id),
 		FullName: req.FullName,
 		Email:    req.Email,
 	}
+	if err := store.Users.Create(r.Context(), &user); err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
 
 	utils.CreatedResponse(w, "User created successfully", user)
 }
@@ -115,18 +86,40 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	// Checked before anything is saved so a rejected balance changes nothing
+	if req.Balance != nil && *req.Balance < 0 {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Balance must not be negative")
+		return
+	}
 
-	query := `UPDATE users SET full_name = ?, balance = ?, is_member = ? WHERE id = ?`
-	result, err := config.DB.Exec(query, req.FullName, req.Balance, req.IsMember, id)
+	err = store.Users.Update(r.Context(), id, req)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
-		return
+	// Setting a balance is recorded as an admin adjustment for the difference
+	if req.Balance != nil {
+		adminID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+		err := store.Wallet.SetBalance(r.Context(), &models.WalletTransaction{
+			UserID:      id,
+			Type:        models.WalletAdjustment,
+			Reference:   fmt.Sprintf("ADJ-%d-%d", id, time.Now().UnixMilli()),
+			Description: "Balance set by admin",
+			CreatedBy:   adminID,
+		}, *req.Balance)
+		if err == repository.ErrInsufficientBalance {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Balance must not be negative")
+			return
+		}
+		if err != nil {
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update balance")
+			return
+		}
 	}
 
 	utils.SuccessResponse(w, "User updated successfully", nil)
@@ -163,8 +156,7 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 			utils.ErrorResponse(w, http.StatusBadRequest, "Current password is required to set a new password")
 			return
 		}
-		var hashedPassword string
-		err := config.DB.QueryRow("SELECT password FROM users WHERE id = ?", id).Scan(&hashedPassword)
+		hashedPassword, err := store.Users.GetPasswordHash(r.Context(), id)
 		if err != nil {
 			utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 			return
@@ -179,27 +171,23 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to hash password")
 			return
 		}
-		config.DB.Exec("UPDATE users SET password = ? WHERE id = ?", string(newHash), id)
+		store.Users.UpdatePassword(r.Context(), id, string(newHash))
 	}
 
 	// Update profile fields
-	result, err := config.DB.Exec(
-		"UPDATE users SET full_name = ?, phone = ?, email = ?, avatar_url = ? WHERE id = ?",
-		req.FullName, req.Phone, req.Email, req.AvatarURL, id,
-	)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update profile: "+err.Error())
+	err = store.Users.UpdateProfile(r.Context(), id, req.FullName, req.Phone, req.Email, req.AvatarURL)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+	if err != nil {
+		log.Printf("❌ Updating profile of user %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to update profile")
 		return
 	}
 
-	// Return only the fields that were changed — avoids re-querying with
-	// balance/is_member columns that may fail due to type scan issues.
-	// The frontend merges these partial fields on top of existing localStorage data.
+	// Return only the fields that were changed — the frontend merges these
+	// partial fields on top of existing localStorage data.
 	type ProfileResponse struct {
 		ID        int    `json:"id"`
 		FullName  string `json:"full_name"`
@@ -225,16 +213,13 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `DELETE FROM users WHERE id = ?`
-	result, err := config.DB.Exec(query, id)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete user")
+	err = store.Users.Delete(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
 
@@ -250,12 +235,17 @@ func GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var balance int
-	err = config.DB.QueryRow("SELECT balance FROM users WHERE id = ?", id).Scan(&balance)
-	if err != nil {
+	// The ledger is authoritative; repairing users.balance is left to
+	// POST /api/users/{id}/wallet/reconcile
+	balance, err := store.Wallet.Balance(r.Context(), id)
+	if err == repository.ErrNotFound {
 		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch balance")
+		return
+	}
 
 	utils.SuccessResponse(w, "Balance fetched", map[string]int{"balance": balance})
 }
@@ -281,27 +271,35 @@ func TopUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Atomically increment balance
-	result, err := config.DB.Exec("UPDATE users SET balance = balance + ? WHERE id = ?", req.Amount, id)
-	if err != nil {
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to top up balance")
-		return
+	// Credit the wallet through the ledger
+	actorID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+	entry := models.WalletTransaction{
+		UserID:      id,
+		Type:        models.WalletTopUp,
+		Amount:      req.Amount,
+		Reference:   fmt.Sprintf("TOPUP-%d-%d", id, time.Now().UnixMilli()),
+		Description: "Wallet top-up",
+		CreatedBy:   actorID,
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err = store.Wallet.Record(r.Context(), &entry)
+	if err == repository.ErrNotFound {
 		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to top up balance")
+		return
+	}
 
-	// Return updated balance
-	var newBalance int
-	config.DB.QueryRow("SELECT balance FROM users WHERE id = ?", id).Scan(&newBalance)
-
-	utils.SuccessResponse(w, "Top-up successful", map[string]int{"balance": newBalance})
+	utils.SuccessResponse(w, "Top-up successful", map[string]interface{}{
+		"balance":        entry.BalanceAfter,
+		"transaction_id": entry.ID,
+		"reference":      entry.Reference,
+	})
 }
 
 // GetDashboard - GET /api/users/{id}/dashboard
-// Returns all dashboard data in a single DB round-trip to prevent connection overload.
+// Returns all dashboard data in a single request to prevent connection overload.
 func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -309,139 +307,34 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
+	ctx := r.Context()
 
 	// 1. User info (balance, total_spent, role)
-	var balance, totalSpent int
-	var role string
-	err = config.DB.QueryRow("SELECT balance, total_spent, role FROM users WHERE id = ?", id).Scan(&balance, &totalSpent, &role)
+	user, err := store.Users.GetByID(ctx, id)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
+	balance, totalSpent := user.Balance, user.TotalSpent
 	var totalUsers int
-	isAdmin := role == "admin"
-	if isAdmin {
-		var gTotal sql.NullInt64
-		config.DB.QueryRow("SELECT SUM(total_amount) FROM orders WHERE status='delivered' OR status='completed'").Scan(&gTotal)
-		if gTotal.Valid {
-			totalSpent = int(gTotal.Int64)
-		}
-		config.DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'customer'").Scan(&totalUsers)
-	}
-
-	// 2. Order stats
-	var totalOrders, pendingOrders, completedOrders, thisYearOrders int
+	isAdmin := user.Role == "admin"
+	scope := id
 	if isAdmin {
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders").Scan(&totalOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE status = 'pending'").Scan(&pendingOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE status = 'delivered' OR status = 'completed'").Scan(&completedOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE YEAR(created_at) = YEAR(NOW())").Scan(&thisYearOrders)
-	} else {
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", id).Scan(&totalOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND status = 'pending'", id).Scan(&pendingOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND status = 'delivered' OR status = 'completed'", id).Scan(&completedOrders)
-		config.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ? AND YEAR(created_at) = YEAR(NOW())", id).Scan(&thisYearOrders)
-	}
-
-	// 3. Monthly spending
-	type MonthData struct {
-		Month      string  `json:"month"`
-		Amount     int     `json:"amount"`
-		Percentage float64 `json:"percentage"`
-	}
-	var monthly []MonthData
-	var monthlyQuery string
-	var mArgs []interface{}
-	if isAdmin {
-		monthlyQuery = `
-			SELECT DATE_FORMAT(created_at,'%b') as month, SUM(total_amount) as amount
-			FROM orders WHERE status='delivered' AND created_at >= DATE_SUB(NOW(), INTERVAL 6 MONTH)
-			GROUP BY YEAR(created_at), MONTH(created_at), DATE_FORMAT(created_at,'%b')
-			ORDER BY YEAR(created_at), MONTH(created_at)`
-	} else {
-		monthlyQuery = `
-			SELECT DATE_FORMAT(created_at,'%b') as month, SUM(total_amount) as amount
-			FROM orders WHERE user_id = ? AND status='delivered' AND created_at >= DATE_SUB(NOW(), INTERVAL 6 MONTH)
-			GROUP BY YEAR(created_at), MONTH(created_at), DATE_FORMAT(created_at,'%b')
-			ORDER BY YEAR(created_at), MONTH(created_at)`
-		mArgs = append(mArgs, id)
-	}
-	mRows, _ := config.DB.Query(monthlyQuery, mArgs...)
-	if mRows != nil {
-		defer mRows.Close()
-		maxAmt := 0
-		for mRows.Next() {
-			var m MonthData
-			var f float64
-			mRows.Scan(&m.Month, &f)
-			m.Amount = int(f)
-			if m.Amount > maxAmt {
-				maxAmt = m.Amount
-			}
-			monthly = append(monthly, m)
-		}
-		for i := range monthly {
-			if maxAmt > 0 {
-				monthly[i].Percentage = float64(monthly[i].Amount) / float64(maxAmt) * 100
-			}
+		scope = 0
+		if revenue, err := store.Orders.Revenue(ctx); err == nil && revenue > 0 {
+			totalSpent = revenue
 		}
-	}
-	if monthly == nil {
-		monthly = []MonthData{}
+		totalUsers, _ = store.Users.CountByRole(ctx, "customer")
 	}
 
-	// 4. Category breakdown
-	type CategoryData struct {
-		Name       string  `json:"name"`
-		Value      int     `json:"value"`
-		Percentage float64 `json:"percentage"`
-	}
-	var categories []CategoryData
-	var catQuery string
-	var cArgs []interface{}
-	if isAdmin {
-		catQuery = `
-			SELECT COALESCE(c.name,'Other') as cat, SUM(oi.price*oi.quantity) as total
-			FROM order_items oi
-			JOIN orders o ON o.id=oi.order_id
-			JOIN products p ON p.id=oi.product_id
-			LEFT JOIN categories c ON c.id=p.category_id
-			WHERE o.status='delivered'
-			GROUP BY cat ORDER BY total DESC LIMIT 5`
-	} else {
-		catQuery = `
-			SELECT COALESCE(c.name,'Other') as cat, SUM(oi.price*oi.quantity) as total
-			FROM order_items oi
-			JOIN orders o ON o.id=oi.order_id
-			JOIN products p ON p.id=oi.product_id
-			LEFT JOIN categories c ON c.id=p.category_id
-			WHERE o.user_id=? AND o.status='delivered'
-			GROUP BY cat ORDER BY total DESC LIMIT 5`
-		cArgs = append(cArgs, id)
-	}
-	cRows, _ := config.DB.Query(catQuery, cArgs...)
-	totalCat := 0
-	if cRows != nil {
-		defer cRows.Close()
-		for cRows.Next() {
-			var c CategoryData
-			var f float64
-			cRows.Scan(&c.Name, &f)
-			c.Value = int(f)
-			totalCat += c.Value
-			categories = append(categories, c)
-		}
-		for i := range categories {
-			if totalCat > 0 {
-				categories[i].Percentage = float64(categories[i].Value) / float64(totalCat) * 100
-			}
-		}
-	}
-	if categories == nil {
-		categories = []CategoryData{}
+	// 2. Order stats (monthly spending + category breakdown included)
+	stats, err := store.Orders.Stats(ctx, scope)
+	if err != nil {
+		stats = &models.OrderStats{}
+		repository.FillPercentages(stats)
 	}
 
-	// 5. Recent orders (last 5)
+	// 3. Recent orders (last 5)
 	type OrderRow struct {
 		ID        string `json:"id"`
 		Products  string `json:"products"`
@@ -450,83 +343,48 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 		Status    string `json:"status"`
 		CreatedAt string `json:"created_at"`
 	}
-	var orders []OrderRow
-	var oQuery string
-	var oArgs []interface{}
-	if isAdmin {
-		oQuery = `
-			SELECT o.order_number, GROUP_CONCAT(p.name ORDER BY oi.id SEPARATOR ', '),
-				SUM(oi.quantity), o.total_amount, o.status, o.created_at
-			FROM orders o
-			JOIN order_items oi ON oi.order_id=o.id
-			JOIN products p ON p.id=oi.product_id
-			GROUP BY o.id, o.order_number, o.total_amount, o.status, o.created_at
-			ORDER BY o.created_at DESC LIMIT 5`
-	} else {
-		oQuery = `
-			SELECT o.order_number, GROUP_CONCAT(p.name ORDER BY oi.id SEPARATOR ', '),
-				SUM(oi.quantity), o.total_amount, o.status, o.created_at
-			FROM orders o
-			JOIN order_items oi ON oi.order_id=o.id
-			JOIN products p ON p.id=oi.product_id
-			WHERE o.user_id=?
-			GROUP BY o.id, o.order_number, o.total_amount, o.status, o.created_at
-			ORDER BY o.created_at DESC LIMIT 5`
-		oArgs = append(oArgs, id)
-	}
-	oRows, _ := config.DB.Query(oQuery, oArgs...)
-	if oRows != nil {
-		defer oRows.Close()
-		for oRows.Next() {
-			var o OrderRow
-			var createdAt sql.NullTime
-			var f float64
-			oRows.Scan(&o.ID, &o.Products, &o.TotalQty, &f, &o.Status, &createdAt)
-			o.Total = int(f)
-			if createdAt.Valid {
-				o.CreatedAt = createdAt.Time.Format("Jan 02, 2006")
-			}
-			orders = append(orders, o)
-		}
-	}
-	if orders == nil {
-		orders = []OrderRow{}
+	orders := []OrderRow{}
+	recent, _ := store.Orders.ListByUser(ctx, scope, 5)
+	for _, o := range recent {
+		orders = append(orders, OrderRow{
+			ID:        o.OrderNumber,
+			Products:  o.Products,
+			TotalQty:  o.TotalQty,
+			Total:     o.Total,
+			Status:    o.Status,
+			CreatedAt: o.CreatedAt.Format("Jan 02, 2006"),
+		})
 	}
 
-	// 6. Products (for recommendations — fetched once)
+	// 4. Products (for recommendations — fetched once)
 	type Product struct {
-		ID       int     `json:"id"`
-		Name     string  `json:"name"`
-		Price    int     `json:"price"`
-		Category string  `json:"category"`
-		Rating   float64 `json:"rating"`
-		Stock    int     `json:"stock"`
-		Image    string  `json:"image"`
-		Brand    string  `json:"brand"`
-	}
-	var products []Product
-	pRows, _ := config.DB.Query(`
-		SELECT p.id, p.name, p.price, COALESCE(c.name,''), COALESCE(p.rating,0),
-		       COALESCE(p.stock,0), COALESCE(p.image_url,''), COALESCE(p.brand,'')
-		FROM products p
-		LEFT JOIN categories c ON c.id = p.category_id
-		LIMIT 20`)
-	if pRows != nil {
-		defer pRows.Close()
-		for pRows.Next() {
-			var p Product
-			var priceF, rf float64
-			pRows.Scan(&p.ID, &p.Name, &priceF, &p.Category, &rf, &p.Stock, &p.Image, &p.Brand)
-			p.Price = int(priceF)
-			p.Rating = rf
-			products = append(products, p)
-		}
-	}
-	if products == nil {
-		products = []Product{}
-	}
-
-	// 7. Active vouchers
+		ID             int     `json:"id"`
+		Name           string  `json:"name"`
+		Price          int     `json:"price"`
+		Category       string  `json:"category"`
+		Rating         float64 `json:"rating"`
+		Stock          int     `json:"stock"`
+		AvailableStock int     `json:"available_stock"`
+		Image          string  `json:"image"`
+		Brand          string  `json:"brand"`
+	}
+	products := []Product{}
+	catalog, _ := store.Products.List(ctx, 20)
+	for _, p := range catalog {
+		products = append(products, Product{
+			ID:             p.ID,
+			Name:           p.Name,
+			Price:          p.Price,
+			Category:       p.Category,
+			Rating:         float64(p.Rating),
+			Stock:          p.Stock,
+			AvailableStock: models.AvailableStock(p.Stock, p.Reserved),
+			Image:          p.Image,
+			Brand:          p.Brand,
+		})
+	}
+
+	// 5. Active vouchers
 	type VoucherRow struct {
 		ID            int     `json:"id"`
 		Code          string  `json:"code"`
@@ -542,45 +400,36 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 		ValidUntil    string  `json:"valid_until"`
 		IsActive      bool    `json:"is_active"`
 	}
-	var vouchers []VoucherRow
-	vRows, _ := config.DB.Query(`SELECT id, code, name, COALESCE(description,''), type, discount_value, min_purchase, max_discount, usage_limit, used_count, valid_from, valid_until, is_active FROM vouchers WHERE valid_until > NOW()`)
-	if vRows != nil {
-		defer vRows.Close()
-		for vRows.Next() {
-			var v VoucherRow
-			var vf, maxD, minPurchaseF float64
-			var vFrom, vUntil sql.NullTime
-			var isActive bool
-			vRows.Scan(&v.ID, &v.Code, &v.Name, &v.Description, &v.Type, &vf, &minPurchaseF, &maxD, &v.UsageLimit, &v.UsedCount, &vFrom, &vUntil, &isActive)
-			v.DiscountValue = vf
-			v.MaxDiscount = maxD
-			v.MinPurchase = int(minPurchaseF)
-			v.IsActive = isActive
-			if vFrom.Valid {
-				v.ValidFrom = vFrom.Time.Format("2006-01-02T15:04:05Z")
-			}
-			if vUntil.Valid {
-				v.ValidUntil = vUntil.Time.Format("2006-01-02T15:04:05Z")
-			}
-			vouchers = append(vouchers, v)
+	vouchers := []VoucherRow{}
+	active, _ := store.Vouchers.ListUnexpired(ctx)
+	for _, v := range active {
+		row := VoucherRow{
+			ID:            v.ID,
+			Code:          v.Code,
+			Name:          v.Name,
+			Description:   v.Description,
+			Type:          v.Type,
+			DiscountValue: v.DiscountValue,
+			MinPurchase:   int(v.MinPurchase),
+			MaxDiscount:   v.MaxDiscount,
+			UsageLimit:    v.UsageLimit,
+			UsedCount:     v.UsedCount,
+			IsActive:      v.IsActive,
 		}
-	}
-	if vouchers == nil {
-		vouchers = []VoucherRow{}
+		if !v.ValidFrom.IsZero() {
+			row.ValidFrom = v.ValidFrom.Format("2006-01-02T15:04:05Z")
+		}
+		if !v.ValidUntil.IsZero() {
+			row.ValidUntil = v.ValidUntil.Format("2006-01-02T15:04:05Z")
+		}
+		vouchers = append(vouchers, row)
 	}
 
 	utils.SuccessResponse(w, "Dashboard fetched", map[string]interface{}{
-		"balance":     balance,
-		"total_spent": totalSpent,
-		"total_users": totalUsers,
-		"stats": map[string]interface{}{
-			"total_orders":       totalOrders,
-			"pending_orders":     pendingOrders,
-			"completed_orders":   completedOrders,
-			"this_year_orders":   thisYearOrders,
-			"monthly_spending":   monthly,
-			"category_breakdown": categories,
-		},
+		"balance":       balance,
+		"total_spent":   totalSpent,
+		"total_users":   totalUsers,
+		"stats":         stats,
 		"recent_orders": orders,
 		"vouchers":      vouchers,
 		"products":      products,
@@ -596,8 +445,7 @@ func GetTotalSpent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var totalSpent int
-	err = config.DB.QueryRow("SELECT total_spent FROM users WHERE id = ?", id).Scan(&totalSpent)
+	totalSpent, err := store.Users.GetTotalSpent(r.Context(), id)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
 		return
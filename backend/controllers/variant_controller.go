@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetProductVariants - GET /api/products/{id}/variants
+// Returns the product's option types and its variants in display order.
+func GetProductVariants(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	options, variants, err := store.Products.Variants(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch variants")
+		return
+	}
+	if options == nil {
+		options = []models.ProductOption{}
+	}
+	if variants == nil {
+		variants = []models.ProductVariant{}
+	}
+	utils.SuccessResponse(w, "Variants fetched successfully", map[string]interface{}{
+		"options":  options,
+		"variants": variants,
+	})
+}
+
+// ReplaceProductVariants - PUT /api/products/{id}/variants
+// Body: {"options": [{"name", "values"}], "variants": [{"sku", "options", "price", "stock", "image"}]}.
+// Variants keep their id as long as their SKU stays; cart lines of removed
+// variants are dropped. The product's price and stock become the lowest
+// variant price and the total variant stock. Empty lists remove the variants.
+func ReplaceProductVariants(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	var req models.ProductVariantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	options, variants, msg := validateVariants(req)
+	if msg != "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	err = store.Products.ReplaceVariants(r.Context(), id, options, variants)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err == repository.ErrDuplicate {
+		utils.ErrorResponse(w, http.StatusConflict, "SKU is already used by another product")
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Saving variants of product %d failed: %v", id, err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to save variants")
+		return
+	}
+	reindexProduct(r.Context(), id)
+
+	if variants == nil {
+		variants = []models.ProductVariant{}
+	}
+	utils.SuccessResponse(w, "Variants saved successfully", map[string]interface{}{
+		"options":  options,
+		"variants": variants,
+	})
+}
+
+// validateVariants normalises a variants request. Every variant names exactly
+// one of the declared values for each option, and no two variants share a
+// combination. The returned message is non-empty when the request is invalid.
+func validateVariants(req models.ProductVariantsRequest) ([]models.ProductOption, []models.ProductVariant, string) {
+	options := make([]models.ProductOption, 0, len(req.Options))
+	names := map[string]bool{}
+	for i, o := range req.Options {
+		o.Name = strings.TrimSpace(o.Name)
+		switch {
+		case o.Name == "" || len(o.Name) > 50:
+			return nil, nil, fmt.Sprintf("Option %d: name is required (max 50 characters)", i+1)
+		case names[strings.ToLower(o.Name)]:
+			return nil, nil, "Duplicate option: " + o.Name
+		}
+		names[strings.ToLower(o.Name)] = true
+
+		values, seen := []string{}, map[string]bool{}
+		for _, v := range o.Values {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return nil, nil, fmt.Sprintf("Option %s: values must not be empty", o.Name)
+			}
+			if seen[strings.ToLower(v)] {
+				return nil, nil, fmt.Sprintf("Option %s: duplicate value %s", o.Name, v)
+			}
+			seen[strings.ToLower(v)] = true
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			return nil, nil, fmt.Sprintf("Option %s needs at least one value", o.Name)
+		}
+		o.Values = values
+		options = append(options, o)
+	}
+	if len(req.Variants) > 0 && len(options) == 0 {
+		return nil, nil, "Variants need at least one option"
+	}
+
+	var variants []models.ProductVariant
+	skus, combos := map[string]bool{}, map[string]bool{}
+	for i, in := range req.Variants {
+		v := models.ProductVariant{
+			SKU:   strings.TrimSpace(in.SKU),
+			Price: in.Price,
+			Stock: in.Stock,
+			Image: strings.TrimSpace(in.Image),
+		}
+		switch {
+		case v.SKU == "" || len(v.SKU) > 64:
+			return nil, nil, fmt.Sprintf("Variant %d: sku is required (max 64 characters)", i+1)
+		case skus[strings.ToLower(v.SKU)]:
+			return nil, nil, "Duplicate SKU: " + v.SKU
+		case v.Price <= 0:
+			return nil, nil, fmt.Sprintf("Variant %s: price must be > 0", v.SKU)
+		case v.Stock < 0:
+			return nil, nil, fmt.Sprintf("Variant %s: stock must be >= 0", v.SKU)
+		case len(in.Options) != len(options):
+			return nil, nil, fmt.Sprintf("Variant %s must choose a value for each of: %s", v.SKU, optionNames(options))
+		}
+		skus[strings.ToLower(v.SKU)] = true
+
+		// Match names and values case-insensitively, keep the declared spelling
+		for _, o := range options {
+			chosen, ok := "", false
+			for name, value := range in.Options {
+				if strings.EqualFold(strings.TrimSpace(name), o.Name) {
+					chosen, ok = strings.TrimSpace(value), true
+				}
+			}
+			if !ok {
+				return nil, nil, fmt.Sprintf("Variant %s: missing option %s", v.SKU, o.Name)
+			}
+			matched := ""
+			for _, value := range o.Values {
+				if strings.EqualFold(value, chosen) {
+					matched = value
+				}
+			}
+			if matched == "" {
+				return nil, nil, fmt.Sprintf("Variant %s: %s must be one of: %s", v.SKU, o.Name, strings.Join(o.Values, ", "))
+			}
+			v.Options = append(v.Options, models.VariantOption{Name: o.Name, Value: matched})
+		}
+		combo := strings.ToLower(v.Label())
+		if combos[combo] {
+			return nil, nil, "Duplicate variant: " + v.Label()
+		}
+		combos[combo] = true
+		variants = append(variants, v)
+	}
+	return options, variants, ""
+}
+
+func optionNames(options []models.ProductOption) string {
+	names := make([]string, len(options))
+	for i, o := range options {
+		names[i] = o.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,238 @@
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+// phoneVariants gives a product two colors in one storage size and returns
+// the saved variants keyed by SKU
+func (a *testAPI) phoneVariants(admin string, productID int) map[string]models.ProductVariant {
+	a.t.Helper()
+	res := a.call("PUT", fmt.Sprintf("/api/products/%d/variants", productID), admin, map[string]interface{}{
+		"options": []map[string]interface{}{
+			{"name": "Color", "values": []string{"Black", "White"}},
+			{"name": "Storage", "values": []string{"256 GB"}},
+		},
+		"variants": []map[string]interface{}{
+			{"sku": "S24-BLK-256", "options": map[string]string{"Color": "Black", "Storage": "256 GB"}, "price": 12000, "stock": 3},
+			{"sku": "S24-WHT-256", "options": map[string]string{"color": "white", "storage": "256 gb"}, "price": 12500, "stock": 1},
+		},
+	})
+	a.expect(res, http.StatusOK)
+	var saved struct {
+		Variants []models.ProductVariant `json:"variants"`
+	}
+	res.decode(a.t, &saved)
+	variants := map[string]models.ProductVariant{}
+	for _, v := range saved.Variants {
+		variants[v.SKU] = v
+	}
+	return variants
+}
+
+func TestProductVariants(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	_, customer := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Galaxy S24", 1000, 1)
+	path := fmt.Sprintf("/api/products/%d/variants", productID)
+
+	a.expect(a.call("PUT", path, customer, map[string]interface{}{}), http.StatusForbidden)
+	variants := a.phoneVariants(admin, productID)
+	white := variants["S24-WHT-256"]
+	want := []models.VariantOption{{Name: "Color", Value: "White"}, {Name: "Storage", Value: "256 GB"}}
+	if !reflect.DeepEqual(white.Options, want) {
+		t.Fatalf("options = %+v, want the declared spelling %+v", white.Options, want)
+	}
+
+	// The product lists its variants and takes the lowest price and total stock
+	p := a.getProduct(productID)
+	if len(p.Variants) != 2 || p.Price != 12000 || p.Stock != 4 {
+		t.Fatalf("product = price %d, stock %d, variants %+v", p.Price, p.Stock, p.Variants)
+	}
+	a.expect(a.call("GET", path, "", nil), http.StatusOK)
+	a.expect(a.call("GET", "/api/products/999/variants", "", nil), http.StatusNotFound)
+	a.expect(a.call("PUT", "/api/products/999/variants", admin, map[string]interface{}{}), http.StatusNotFound)
+}
+
+func TestProductVariantValidation(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	productID := a.product(admin, "Galaxy S24", 1000, 1)
+	other := a.product(admin, "Galaxy A15", 1000, 1)
+	a.phoneVariants(admin, other)
+	path := fmt.Sprintf("/api/products/%d/variants", productID)
+
+	color := []map[string]interface{}{{"name": "Color", "values": []string{"Black", "White"}}}
+	variant := func(sku string, options map[string]string) map[string]interface{} {
+		return map[string]interface{}{"sku": sku, "options": options, "price": 1000, "stock": 1}
+	}
+	black := map[string]string{"Color": "Black"}
+
+	tests := []struct {
+		name     string
+		options  []map[string]interface{}
+		variants []map[string]interface{}
+		code     int
+		msg      string
+	}{
+		{"duplicate SKU", color, []map[string]interface{}{variant("A-1", black), variant("a-1", map[string]string{"Color": "White"})}, http.StatusBadRequest, "Duplicate SKU: a-1"},
+		{"duplicate combination", color, []map[string]interface{}{variant("A-1", black), variant("A-2", map[string]string{"color": "BLACK"})}, http.StatusBadRequest, "Duplicate variant: Black"},
+		{"missing option", append(color, map[string]interface{}{"name": "Storage", "values": []string{"128 GB"}}),
+			[]map[string]interface{}{variant("A-1", map[string]string{"Color": "Black", "RAM": "8 GB"})}, http.StatusBadRequest, "missing option Storage"},
+		{"too few options", append(color, map[string]interface{}{"name": "Storage", "values": []string{"128 GB"}}),
+			[]map[string]interface{}{variant("A-1", black)}, http.StatusBadRequest, "must choose a value for each of: Color, Storage"},
+		{"unknown value", color, []map[string]interface{}{variant("A-1", map[string]string{"Color": "Red"})}, http.StatusBadRequest, "Color must be one of: Black, White"},
+		{"duplicate option value", []map[string]interface{}{{"name": "Color", "values": []string{"Black", "black"}}}, nil, http.StatusBadRequest, "duplicate value black"},
+		{"variants without options", nil, []map[string]interface{}{variant("A-1", nil)}, http.StatusBadRequest, "at least one option"},
+		{"SKU of another product", color, []map[string]interface{}{variant("S24-BLK-256", black)}, http.StatusConflict, "SKU is already used"},
+	}
+	for _, tt := range tests {
+		res := a.call("PUT", path, admin, map[string]interface{}{"options": tt.options, "variants": tt.variants})
+		if res.Code != tt.code || !strings.Contains(res.Message+res.Error, tt.msg) {
+			t.Errorf("%s: got %d %q, want %d mentioning %q", tt.name, res.Code, res.Message+res.Error, tt.code, tt.msg)
+		}
+	}
+	if p := a.getProduct(productID); len(p.Variants) != 0 {
+		t.Fatalf("rejected variants were saved: %+v", p.Variants)
+	}
+}
+
+func TestVariantCartLines(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Galaxy S24", 1000, 1)
+	plain := a.product(admin, "Charger", 100, 5)
+	variants := a.phoneVariants(admin, productID)
+	black, white := variants["S24-BLK-256"], variants["S24-WHT-256"]
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	add := func(body map[string]interface{}) apiResponse {
+		return a.call("POST", base+"/cart", token, body)
+	}
+	a.expect(add(map[string]interface{}{"product_id": productID, "quantity": 1}), http.StatusBadRequest)
+	a.expect(add(map[string]interface{}{"product_id": productID, "variant_id": 999}), http.StatusBadRequest)
+	a.expect(add(map[string]interface{}{"product_id": plain, "variant_id": black.ID}), http.StatusBadRequest)
+
+	// Each variant is its own line; adding again tops the line up
+	a.expect(add(map[string]interface{}{"product_id": productID, "variant_id": black.ID, "quantity": 1}), http.StatusCreated)
+	a.expect(add(map[string]interface{}{"product_id": productID, "variant_id": fmt.Sprint(black.ID), "quantity": 1}), http.StatusCreated)
+	a.expect(add(map[string]interface{}{"product_id": productID, "variant_id": white.ID, "quantity": 1}), http.StatusCreated)
+
+	cart := func() map[int]int {
+		t.Helper()
+		res := a.call("GET", base+"/cart", token, nil)
+		a.expect(res, http.StatusOK)
+		var lines []struct {
+			VariantID int `json:"variant_id"`
+			Quantity  int `json:"quantity"`
+		}
+		res.decode(t, &lines)
+		quantities := map[int]int{}
+		for _, l := range lines {
+			quantities[l.VariantID] = l.Quantity
+		}
+		return quantities
+	}
+	if got, want := cart(), map[int]int{black.ID: 2, white.ID: 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cart = %v, want %v", got, want)
+	}
+
+	// Updates and removals address one variant's line
+	line := fmt.Sprintf("%s/cart/%d?variant_id=%d", base, productID, black.ID)
+	a.expect(a.call("PUT", line, token, map[string]int{"quantity": 3}), http.StatusOK)
+	a.expect(a.call("DELETE", fmt.Sprintf("%s/cart/%d?variant_id=%d", base, productID, white.ID), token, nil), http.StatusOK)
+	if got, want := cart(), map[int]int{black.ID: 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cart after update = %v, want %v", got, want)
+	}
+	a.expect(a.call("PUT", fmt.Sprintf("%s/cart/%d?variant_id=abc", base, productID), token, map[string]int{"quantity": 1}), http.StatusBadRequest)
+}
+
+func TestVariantOrderSnapshot(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Galaxy S24", 1000, 1)
+	white := a.phoneVariants(admin, productID)["S24-WHT-256"]
+	base := fmt.Sprintf("/api/users/%d", userID)
+
+	a.expect(a.call("POST", base+"/topup", token, map[string]int{"amount": 100000}), http.StatusOK)
+	a.expect(a.call("POST", base+"/cart", token, map[string]interface{}{"product_id": productID, "variant_id": white.ID, "quantity": 2}), http.StatusCreated)
+	checkout := map[string]interface{}{"items": []models.CartLine{{ProductID: productID, VariantID: white.ID}}}
+	a.expect(a.call("POST", base+"/checkout", token, checkout), http.StatusBadRequest)
+	a.expect(a.call("PUT", fmt.Sprintf("%s/cart/%d?variant_id=%d", base, productID, white.ID), token, map[string]int{"quantity": 1}), http.StatusOK)
+	res := a.call("POST", base+"/checkout", token, checkout)
+	a.expect(res, http.StatusCreated)
+	var result models.CheckoutResult
+	res.decode(t, &result)
+	if result.Total != 12500 {
+		t.Fatalf("total = %d, want the variant price 12500", result.Total)
+	}
+
+	// Renaming the variant later does not rewrite the order
+	a.expect(a.call("PUT", fmt.Sprintf("/api/products/%d/variants", productID), admin, map[string]interface{}{
+		"options":  []map[string]interface{}{{"name": "Color", "values": []string{"Ivory"}}},
+		"variants": []map[string]interface{}{{"sku": "S24-IVR", "options": map[string]string{"Color": "Ivory"}, "price": 9000, "stock": 1}},
+	}), http.StatusOK)
+
+	res = a.call("GET", base+"/orders/"+result.OrderNumber, token, nil)
+	a.expect(res, http.StatusOK)
+	var detail struct {
+		Items []models.OrderItem `json:"items"`
+	}
+	res.decode(t, &detail)
+	want := models.OrderItem{
+		ProductID: productID, ProductName: "Galaxy S24", Quantity: 1, Price: 12500, Subtotal: 12500,
+		VariantID: white.ID, SKU: "S24-WHT-256",
+		Options: []models.VariantOption{{Name: "Color", Value: "White"}, {Name: "Storage", Value: "256 GB"}},
+	}
+	if len(detail.Items) != 1 || !reflect.DeepEqual(detail.Items[0], want) {
+		t.Fatalf("items = %+v, want %+v", detail.Items, want)
+	}
+}
+
+// failingVariants is a product store whose variant and cart writes fail with
+// a driver error
+type failingVariants struct {
+	repository.ProductRepository
+}
+
+func (failingVariants) ReplaceVariants(ctx context.Context, productID int, options []models.ProductOption, variants []models.ProductVariant) error {
+	return fmt.Errorf("Error 1213: Deadlock found when trying to get lock")
+}
+
+type failingCarts struct {
+	repository.CartRepository
+}
+
+func (failingCarts) Add(ctx context.Context, userID int, line models.CartLine, quantity int) error {
+	return fmt.Errorf("Error 1213: Deadlock found when trying to get lock")
+}
+
+func TestVariantStoreErrorsAreNotLeaked(t *testing.T) {
+	a := newTestAPI(t)
+	_, admin := a.user("admin@example.com", "admin")
+	userID, token := a.user("customer@example.com", "customer")
+	productID := a.product(admin, "Galaxy S24", 1000, 1)
+
+	a.store.Products = failingVariants{a.store.Products}
+	a.store.Carts = failingCarts{a.store.Carts}
+	for _, res := range []apiResponse{
+		a.call("PUT", fmt.Sprintf("/api/products/%d/variants", productID), admin, map[string]interface{}{}),
+		a.call("POST", fmt.Sprintf("/api/users/%d/cart", userID), token, map[string]interface{}{"product_id": productID, "quantity": 1}),
+	} {
+		a.expect(res, http.StatusInternalServerError)
+		if strings.Contains(res.Message+res.Error, "1213") {
+			t.Errorf("driver error leaked: %q", res.Message+res.Error)
+		}
+	}
+}
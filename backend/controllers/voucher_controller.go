@@ -6,7 +6,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 )
 
@@ -42,35 +43,30 @@ type VoucherCreateRequest struct {
 
 // ListVouchers - GET /api/vouchers
 func ListVouchers(w http.ResponseWriter, r *http.Request) {
-	rows, err := config.DB.Query(
-		`SELECT id, code, name, description, type, discount_value, min_purchase, max_discount,
-		        usage_limit, used_count, valid_from, valid_until, is_active, created_at
-		 FROM vouchers ORDER BY created_at DESC`)
+	list, err := store.Vouchers.List(r.Context())
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch vouchers")
 		return
 	}
-	defer rows.Close()
 
-	var vouchers []VoucherRow
-	for rows.Next() {
-		var v VoucherRow
-		var validFrom, validUntil, createdAt []byte
-		if err := rows.Scan(
-			&v.ID, &v.Code, &v.Name, &v.Description, &v.Type,
-			&v.DiscountValue, &v.MinPurchase, &v.MaxDiscount,
-			&v.UsageLimit, &v.UsedCount, &validFrom, &validUntil,
-			&v.IsActive, &createdAt,
-		); err != nil {
-			continue
-		}
-		v.ValidFrom = string(validFrom)
-		v.ValidUntil = string(validUntil)
-		v.CreatedAt = string(createdAt)
-		vouchers = append(vouchers, v)
-	}
-	if vouchers == nil {
-		vouchers = []VoucherRow{}
+	vouchers := []VoucherRow{}
+	for _, v := range list {
+		vouchers = append(vouchers, VoucherRow{
+			ID:            v.ID,
+			Code:          v.Code,
+			Name:          v.Name,
+			Description:   v.Description,
+			Type:          v.Type,
+			DiscountValue: v.DiscountValue,
+			MinPurchase:   v.MinPurchase,
+			MaxDiscount:   v.MaxDiscount,
+			UsageLimit:    v.UsageLimit,
+			UsedCount:     v.UsedCount,
+			ValidFrom:     v.ValidFrom.Format(time.RFC3339Nano),
+			ValidUntil:    v.ValidUntil.Format(time.RFC3339Nano),
+			IsActive:      v.IsActive,
+			CreatedAt:     v.CreatedAt.Format(time.RFC3339Nano),
+		})
 	}
 	utils.SuccessResponse(w, "Vouchers fetched successfully", vouchers)
 }
@@ -105,23 +101,28 @@ func CreateVoucher(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
 	validUntil := now.AddDate(0, 0, req.DurationDays)
 
-	result, err := config.DB.Exec(
-		`INSERT INTO vouchers
-		 (code, name, description, type, discount_value, min_purchase, max_discount, usage_limit, valid_from, valid_until, is_active)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
-		req.Code, req.Name, req.Description, req.Type,
-		req.DiscountValue, req.MinPurchase, req.MaxDiscount,
-		req.UsageLimit, now, validUntil,
-	)
+	voucher := models.Voucher{
+		Code:          req.Code,
+		Name:          req.Name,
+		Description:   req.Description,
+		Type:          req.Type,
+		DiscountValue: req.DiscountValue,
+		MinPurchase:   req.MinPurchase,
+		MaxDiscount:   req.MaxDiscount,
+		UsageLimit:    req.UsageLimit,
+		ValidFrom:     now,
+		ValidUntil:    validUntil,
+		IsActive:      true,
+	}
+	err := store.Vouchers.Create(r.Context(), &voucher)
+	if err == repository.ErrDuplicate {
+		utils.ErrorResponse(w, http.StatusConflict, "Voucher code already exists")
+		return
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "Duplicate entry") {
-			utils.ErrorResponse(w, http.StatusConflict, "Voucher code already exists")
-			return
-		}
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to create voucher: "+err.Error())
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	utils.CreatedResponse(w, "Voucher created successfully", map[string]interface{}{"id": id})
+	utils.CreatedResponse(w, "Voucher created successfully", map[string]interface{}{"id": voucher.ID})
 }
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetWalletTransactions - GET /api/users/{id}/wallet/transactions?page=1&limit=20
+// Returns the wallet statement, newest entry first.
+func GetWalletTransactions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, limit, err := pageParams(r, 20, 100)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, total, err := store.Wallet.Statement(r.Context(), id, limit, (page-1)*limit)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to fetch wallet transactions")
+		return
+	}
+	if entries == nil {
+		entries = []models.WalletTransaction{}
+	}
+
+	utils.SuccessResponse(w, "Wallet transactions fetched", map[string]interface{}{
+		"transactions": entries,
+		"pagination":   paginationMeta(page, limit, total),
+	})
+}
+
+// AdjustWallet - POST /api/users/{id}/wallet/adjustments (admin only)
+// Credits (positive amount) or debits (negative amount) the wallet with a reason.
+func AdjustWallet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Amount      int    `json:"amount"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Amount == 0 {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Adjustment amount must not be 0")
+		return
+	}
+	if req.Description == "" {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Description is required")
+		return
+	}
+
+	adminID, _ := r.Context().Value(middlewares.UserIDKey).(int)
+	entry := models.WalletTransaction{
+		UserID:      id,
+		Type:        models.WalletAdjustment,
+		Amount:      req.Amount,
+		Reference:   fmt.Sprintf("ADJ-%d-%d", id, time.Now().UnixMilli()),
+		Description: req.Description,
+		CreatedBy:   adminID,
+	}
+	err = store.Wallet.Record(r.Context(), &entry)
+	switch {
+	case err == repository.ErrNotFound:
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	case err == repository.ErrInsufficientBalance:
+		utils.ErrorResponse(w, http.StatusBadRequest, "Adjustment would make the balance negative")
+		return
+	case err != nil:
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to adjust balance")
+		return
+	}
+
+	utils.CreatedResponse(w, "Balance adjusted", entry)
+}
+
+// ReconcileWallet - POST /api/users/{id}/wallet/reconcile (admin only)
+// Recomputes the balance from the ledger and repairs users.balance if it drifted.
+func ReconcileWallet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	balance, drift, err := store.Wallet.Reconcile(r.Context(), id)
+	if err == repository.ErrNotFound {
+		utils.ErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to reconcile balance")
+		return
+	}
+	if drift != 0 {
+		log.Printf("⚠️  Balance of user %d drifted from the wallet ledger by %d, reconciled", id, drift)
+	}
+
+	utils.SuccessResponse(w, "Balance reconciled", map[string]int{"balance": balance, "drift": drift})
+}
+
+// GetTrialBalance - GET /api/admin/wallet/trial-balance (admin only)
+// Sums the ledger legs per account; a healthy ledger totals zero.
+func GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	balances, err := store.Wallet.TrialBalance(r.Context())
+	if err != nil {
+		log.Printf("❌ Failed to compute trial balance: %v", err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to compute trial balance")
+		return
+	}
+	total := 0
+	for _, b := range balances {
+		total += b.Balance
+	}
+	if total != 0 {
+		log.Printf("⚠️  Wallet ledger is out of balance by %d", total)
+	}
+	if balances == nil {
+		balances = []models.AccountBalance{}
+	}
+
+	utils.SuccessResponse(w, "Trial balance computed", map[string]interface{}{
+		"accounts": balances,
+		"total":    total,
+	})
+}
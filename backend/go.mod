@@ -7,11 +7,10 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
+	github.com/rs/cors v1.11.1
 	golang.org/x/crypto v0.48.0
+	golang.org/x/image v0.25.0
 	golang.org/x/text v0.34.0
 )
 
-require (
-	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/rs/cors v1.11.1 // indirect
-)
+require filippo.io/edwards25519 v1.1.0 // indirect
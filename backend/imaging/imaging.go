@@ -0,0 +1,187 @@
+// Package imaging validates uploaded images and renders the sizes the shop
+// serves. Uploads are sniffed and fully decoded (JPEG, PNG or WebP), checked
+// against dimension limits, turned upright according to their EXIF
+// orientation and re-encoded, which drops EXIF and any other metadata.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // registers the WebP decoder
+)
+
+// Upload limits
+const (
+	MaxFileSize = 10 << 20   // bytes
+	MinSide     = 100        // px, per side
+	MaxSide     = 8000       // px, per side
+	MaxPixels   = 40_000_000 // width × height
+)
+
+// jpegQuality is used for every JPEG rendition
+const jpegQuality = 85
+
+var (
+	// ErrUnsupported is returned for content that is not a JPEG, PNG or WebP image
+	ErrUnsupported = errors.New("unsupported image type")
+	// ErrTooLarge is returned when the file exceeds MaxFileSize
+	ErrTooLarge = errors.New("image file too large")
+	// ErrDimensions is returned when the image is smaller or larger than the limits
+	ErrDimensions = errors.New("image dimensions out of range")
+	// ErrCorrupt is returned when the image cannot be decoded
+	ErrCorrupt = errors.New("image could not be decoded")
+)
+
+// Size is a rendition the longest side of the image is scaled down to
+type Size struct {
+	Name string
+	Max  int
+}
+
+// Sizes lists the renditions from largest to smallest. Images are never
+// scaled up, so a small upload yields renditions of its own size.
+var Sizes = []Size{
+	{Name: "large", Max: 1200},
+	{Name: "medium", Max: 600},
+	{Name: "thumb", Max: 200},
+}
+
+// Rendition is one encoded size of an upload
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+	Ext    string // ".jpg" or ".png"
+	Data   []byte
+}
+
+// sniffed maps the content types we accept onto image package format names
+var sniffed = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+// Process reads an upload and returns its renditions in the order of Sizes.
+// Opaque images are encoded as JPEG, images with transparency as PNG.
+func Process(r io.Reader) ([]Rendition, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFileSize {
+		return nil, ErrTooLarge
+	}
+
+	// Trust the bytes, not the file name or the client's Content-Type
+	format, ok := sniffed[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return nil, ErrCorrupt
+	}
+	if err := checkDimensions(cfg.Width, cfg.Height); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+	opaque := true
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		opaque = o.Opaque()
+	}
+
+	renditions := make([]Rendition, 0, len(Sizes))
+	src := img
+	for _, size := range Sizes {
+		// Each size is scaled from the previous one, which is much cheaper
+		// than going back to a large original every time
+		src = fit(src, size.Max)
+		out, err := encode(src, opaque)
+		if err != nil {
+			return nil, err
+		}
+		out.Name = size.Name
+		renditions = append(renditions, out)
+	}
+	return renditions, nil
+}
+
+func checkDimensions(width, height int) error {
+	switch {
+	case width < MinSide || height < MinSide:
+		return fmt.Errorf("%w: %dx%d is smaller than %dx%d", ErrDimensions, width, height, MinSide, MinSide)
+	case width > MaxSide || height > MaxSide:
+		return fmt.Errorf("%w: %dx%d is larger than %dx%d", ErrDimensions, width, height, MaxSide, MaxSide)
+	case width*height > MaxPixels:
+		return fmt.Errorf("%w: %dx%d has more than %d pixels", ErrDimensions, width, height, MaxPixels)
+	}
+	return nil
+}
+
+// fit scales img down so its longest side is at most limit
+func fit(img image.Image, limit int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= limit && h <= limit {
+		return img
+	}
+	if w >= h {
+		w, h = limit, h*limit/w
+	} else {
+		w, h = w*limit/h, limit
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, opaque bool) (Rendition, error) {
+	var buf bytes.Buffer
+	out := Rendition{Width: img.Bounds().Dx(), Height: img.Bounds().Dy(), Ext: ".jpg"}
+	var err error
+	if opaque {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		out.Ext = ".png"
+		err = png.Encode(&buf, img)
+	}
+	out.Data = buf.Bytes()
+	return out, err
+}
+
+// FileName names a rendition of the upload stored under base, e.g.
+// product_123_thumb.jpg
+func FileName(base string, r Rendition) string {
+	return base + "_" + r.Name + r.Ext
+}
+
+// Group returns the base name shared by all renditions of an upload, so
+// product_123_thumb.jpg and product_123_large.jpg both map to product_123.
+// Files stored before renditions existed are their own group.
+func Group(fileName string) string {
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for _, size := range Sizes {
+		if trimmed := strings.TrimSuffix(name, "_"+size.Name); trimmed != name {
+			return trimmed
+		}
+	}
+	return name
+}
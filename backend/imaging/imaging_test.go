@@ -0,0 +1,280 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// halves draws a w×h image whose left half is red and right half blue
+func halves(w, h int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := red
+			if x >= w/2 {
+				c = blue
+			}
+			c.A = alpha
+			img.SetNRGBA(x, y, color.NRGBA(c))
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an Exif APP1 segment holding orientation (and a camera
+// model, to look for in the output) right after the JPEG's SOI marker
+func withExif(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 2) // entries
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	// Model, ASCII, stored at offset 38 right after the IFD
+	tiff = append(tiff, 0x01, 0x10, 0x00, 0x02, 0, 0, 0, 8, 0, 0, 0, 38)
+	tiff = append(tiff, 0, 0, 0, 0) // no next IFD
+	tiff = append(tiff, "SecretX\x00"...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+// pngHeader returns just the signature and IHDR chunk of a w×h PNG, enough
+// for DecodeConfig but not for decoding
+func pngHeader(w, h uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = binary.BigEndian.AppendUint32(out, 13)
+	out = append(out, ihdr...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(ihdr))
+}
+
+// at decodes a rendition and returns the color at x, y
+func at(t *testing.T, r Rendition, x, y int) color.RGBA {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(r.Data))
+	if err != nil {
+		t.Fatalf("%s: %v", r.Name, err)
+	}
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+// near reports whether c is within JPEG noise of want
+func near(c, want color.RGBA) bool {
+	within := func(a, b uint8) bool { return int(a)-int(b) <= 40 && int(b)-int(a) <= 40 }
+	return within(c.R, want.R) && within(c.G, want.G) && within(c.B, want.B)
+}
+
+func TestProcessSniffsContent(t *testing.T) {
+	jpg := encodeJPEG(t, halves(200, 100, 255))
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("<html><body>not an image</body></html>"), ErrUnsupported},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), ErrUnsupported},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="200"/>`), ErrUnsupported},
+		{"empty", nil, ErrUnsupported},
+		{"truncated jpeg", jpg[:len(jpg)/2], ErrCorrupt},
+		{"png signature only", []byte("\x89PNG\r\n\x1a\nrest of the file"), ErrCorrupt},
+	}
+	for _, tt := range tests {
+		if _, err := Process(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Process(bytes.NewReader(jpg)); err != nil {
+		t.Fatalf("jpeg: %v", err)
+	}
+}
+
+func TestProcessRejectsLargeFiles(t *testing.T) {
+	data := append(encodePNG(t, halves(100, 100, 255)), make([]byte, MaxFileSize)...)
+	if _, err := Process(bytes.NewReader(data)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestProcessChecksDimensions(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too narrow", encodePNG(t, halves(MinSide-1, 300, 255))},
+		{"too short", encodePNG(t, halves(300, MinSide-1, 255))},
+		{"too wide", pngHeader(MaxSide+1, 200)},
+		{"too tall", pngHeader(200, MaxSide+1)},
+		// Both sides within MaxSide, but too many pixels. Only the header is
+		// read, so the pixels are never allocated.
+		{"too many pixels", pngHeader(7000, 6000)},
+	}
+	for _, tt := range tests {
+		if _, err := Process(bytes.NewReader(tt.data)); !errors.Is(err, ErrDimensions) {
+			t.Errorf("%s: err = %v, want ErrDimensions", tt.name, err)
+		}
+	}
+
+	for _, size := range [][2]int{{MinSide, MinSide}, {MaxSide, MaxPixels / MaxSide}} {
+		if err := checkDimensions(size[0], size[1]); err != nil {
+			t.Errorf("%dx%d: %v", size[0], size[1], err)
+		}
+	}
+	if err := checkDimensions(MaxSide, MaxPixels/MaxSide+1); !errors.Is(err, ErrDimensions) {
+		t.Errorf("one pixel row over the limit: %v", err)
+	}
+}
+
+func TestProcessRenditions(t *testing.T) {
+	out, err := Process(bytes.NewReader(encodePNG(t, halves(1500, 750, 255))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rendition{
+		{Name: "large", Width: 1200, Height: 600, Ext: ".jpg"},
+		{Name: "medium", Width: 600, Height: 300, Ext: ".jpg"},
+		{Name: "thumb", Width: 200, Height: 100, Ext: ".jpg"},
+	}
+	if len(out) != len(want) {
+		t.Fatalf("got %d renditions, want %d", len(out), len(want))
+	}
+	for i, r := range out {
+		if r.Name != want[i].Name || r.Width != want[i].Width || r.Height != want[i].Height || r.Ext != want[i].Ext {
+			t.Errorf("rendition %d = %s %dx%d %s, want %+v", i, r.Name, r.Width, r.Height, r.Ext, want[i])
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(r.Data))
+		if err != nil || format != "jpeg" || cfg.Width != r.Width || cfg.Height != r.Height {
+			t.Errorf("%s encodes %s %dx%d (%v)", r.Name, format, cfg.Width, cfg.Height, err)
+		}
+	}
+
+	// Small uploads are not scaled up; transparency keeps them PNG
+	out, err = Process(bytes.NewReader(encodePNG(t, halves(150, 120, 128))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range out {
+		if r.Width != 150 || r.Height != 120 || r.Ext != ".png" {
+			t.Errorf("transparent %s = %dx%d %s, want 150x120 .png", r.Name, r.Width, r.Height, r.Ext)
+		}
+		if c := at(t, r, 10, 10); c.A == 255 {
+			t.Errorf("transparent %s lost its alpha: %+v", r.Name, c)
+		}
+	}
+}
+
+func TestProcessStripsExifAndOrients(t *testing.T) {
+	data := withExif(encodeJPEG(t, halves(400, 200, 255)), 6)
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("exifOrientation = %d, want 6", got)
+	}
+
+	out, err := Process(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := out[0]
+	// Orientation 6 turns the landscape upload 90° clockwise: the red left
+	// half ends up on top
+	if large.Width != 200 || large.Height != 400 {
+		t.Fatalf("large = %dx%d, want 200x400", large.Width, large.Height)
+	}
+	if c := at(t, large, 100, 20); !near(c, red) {
+		t.Errorf("top = %+v, want red", c)
+	}
+	if c := at(t, large, 100, 380); !near(c, blue) {
+		t.Errorf("bottom = %+v, want blue", c)
+	}
+	for _, r := range out {
+		if bytes.Contains(r.Data, []byte("Exif")) || bytes.Contains(r.Data, []byte("SecretX")) {
+			t.Errorf("%s still carries the Exif segment", r.Name)
+		}
+		if got := exifOrientation(r.Data); got != 1 {
+			t.Errorf("%s orientation = %d", r.Name, got)
+		}
+	}
+
+	// Other orientations; 1 and unknown values leave the image alone
+	for orientation, size := range map[uint16][2]int{1: {400, 200}, 3: {400, 200}, 8: {200, 400}, 9: {400, 200}} {
+		out, err := Process(bytes.NewReader(withExif(encodeJPEG(t, halves(400, 200, 255)), orientation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out[0].Width != size[0] || out[0].Height != size[1] {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", orientation, out[0].Width, out[0].Height, size[0], size[1])
+		}
+	}
+}
+
+func TestExifOrientationIgnoresMalformedSegments(t *testing.T) {
+	jpg := encodeJPEG(t, halves(120, 120, 255))
+	broken := withExif(jpg, 6)
+	// Claim a segment longer than the file
+	binary.BigEndian.PutUint16(broken[4:], 0xFFFF)
+	for name, data := range map[string][]byte{
+		"no exif":        jpg,
+		"not a jpeg":     encodePNG(t, halves(120, 120, 255)),
+		"too short":      {0xFF},
+		"segment length": broken,
+	} {
+		if got := exifOrientation(data); got != 1 {
+			t.Errorf("%s: orientation = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestFileNameAndGroup(t *testing.T) {
+	r := Rendition{Name: "thumb", Ext: ".jpg"}
+	if got := FileName("product_12", r); got != "product_12_thumb.jpg" {
+		t.Fatalf("FileName = %q", got)
+	}
+	for name, want := range map[string]string{
+		"product_12_thumb.jpg":  "product_12",
+		"product_12_large.png":  "product_12",
+		"product_12_medium.jpg": "product_12",
+		"product_12.jpg":        "product_12",
+		"thumbnail.jpg":         "thumbnail",
+	} {
+		if got := Group(name); got != want {
+			t.Errorf("Group(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
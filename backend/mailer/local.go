@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// Log prints every message to the server log instead of sending it
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// File writes every message to Dir as an .eml file that mail clients can open
+type File struct {
+	Dir  string
+	From string
+}
+
+var (
+	unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+	fileSeq         atomic.Int64
+)
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%03d-%s.eml", now.Format("20060102-150405"), fileSeq.Add(1)%1000,
+		unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(f.Dir, name), compose(f.From, msg, now), 0644)
+}
@@ -0,0 +1,61 @@
+// Package mailer delivers transactional emails. The implementation is picked
+// with MAILER: "log" (default) prints messages, "file" writes them to
+// MAIL_DIR as .eml files for local testing, and "smtp" sends them for real.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a message or returns why it could not
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv builds the mailer configured by MAILER, MAIL_FROM, MAIL_DIR and SMTP_*
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Go-Commerce <no-reply@go-commerce.web.id>"
+	}
+
+	switch driver := os.Getenv("MAILER"); driver {
+	case "", "log":
+		return Log{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./mail"
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return &File{Dir: dir, From: from}, nil
+	case "smtp":
+		m := &SMTP{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		if m.Host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAILER=smtp")
+		}
+		if m.Port == "" {
+			m.Port = "587"
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q (use log, file or smtp)", driver)
+	}
+}
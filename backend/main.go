@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/HHHAAAANNNNN/go-commerce-backend/config"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/controllers"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/mailer"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/memory"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/mysql"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/routes"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/storage"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
@@ -23,29 +33,62 @@ func main() {
 		log.Println("⚠️  No .env file found. Using default environment variables.")
 	}
 
-	// Connect to database
-	err := config.ConnectDatabase()
+	// Load JWT signing keys — outside development a missing or default secret is fatal
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatal("❌ JWT key configuration invalid: ", err)
+	}
+
+	// Mailer for verification and password reset emails (MAILER=log|file|smtp)
+	m, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal("❌ Mailer configuration invalid: ", err)
+	}
+	controllers.SetMailer(m)
+
+	// Storage for uploaded images (STORAGE_DRIVER=local|s3)
+	files, err := storage.FromEnv()
 	if err != nil {
-		log.Fatal("❌ Database connection failed:", err)
+		log.Fatal("❌ Storage configuration invalid: ", err)
+	}
+	controllers.SetUploadStorage(files)
+
+	// Connect to storage — STORE_DRIVER=memory runs the API without MySQL
+	var store *repository.Store
+	if os.Getenv("STORE_DRIVER") == "memory" {
+		log.Println("⚠️  Using in-memory store. Data is lost on restart.")
+		store = memory.New()
+	} else {
+		err := config.ConnectDatabase()
+		if err != nil {
+			log.Fatal("❌ Database connection failed:", err)
+		}
+		defer config.CloseDatabase()
+		store = mysql.New(config.DB)
 	}
-	defer config.CloseDatabase()
 
 	// Setup routes
-	router := routes.SetupRoutes()
+	router := routes.SetupRoutes(store)
+
+	// Load every product into the in-process search and autocomplete indexes
+	if err := controllers.BuildSearchIndex(context.Background()); err != nil {
+		log.Printf("⚠️  Search index not built: %v", err)
+	}
+
+	// Release checkout stock holds whose RESERVATION_TTL ran out
+	go controllers.SweepReservations(context.Background(), time.Minute)
+	// Forget Idempotency-Key responses once IdempotencyTTL has passed
+	go middlewares.SweepIdempotencyKeys(context.Background(), store.Idempotency, time.Hour)
+	// Forget login throttle counters nobody has touched for a day
+	go controllers.SweepLoginThrottles(context.Background(), time.Hour)
 
 	// Serve static files from the project root public/assets directory
 	// Binary runs from backend/ so use ../ to go up to project root
 	// URL: /assets/products/phones/file.jpg
 	// → strip "/assets/" → products/phones/file.jpg
 	// → serve from ../public/assets/ → public/assets/products/phones/file.jpg ✓
-	// Serve uploaded files
-	uploadsDir := "/app/uploads"
-	if os.Getenv("RAILWAY_ENVIRONMENT_NAME") == "" {
-		uploadsDir = "./uploads" // Local dev
-	}
-	os.MkdirAll(uploadsDir, 0755)
+	// Serve uploaded files from the upload storage (no directory listing)
 	router.PathPrefix("/assets/uploads/").Handler(
-		http.StripPrefix("/assets/uploads/", http.FileServer(http.Dir(uploadsDir))),
+		http.StripPrefix("/assets/uploads/", storage.Handler(files)),
 	)
 
 	publicAssetsDir := filepath.Join("..", "public", "assets")
@@ -87,8 +130,8 @@ func main() {
 			"http://localhost:8080",
 		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept"},
-		ExposedHeaders:   []string{"Content-Length"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Accept", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Content-Length", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	})
@@ -2,10 +2,14 @@ package middlewares
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
 )
 
 type contextKey string
@@ -13,8 +17,53 @@ type contextKey string
 const (
 	UserIDKey contextKey = "userID"
 	RoleKey   contextKey = "role"
+	// TokenIDKey and TokenExpiryKey carry the access token's jti and expiry (time.Time) for logout
+	TokenIDKey     contextKey = "tokenID"
+	TokenExpiryKey contextKey = "tokenExpiry"
 )
 
+// tokens is the jti denylist consulted by RequireAuth; nil disables the check
+var tokens repository.TokenRepository
+
+// SetTokenStore sets the repository RequireAuth checks for revoked access tokens
+func SetTokenStore(t repository.TokenRepository) {
+	tokens = t
+}
+
+// authenticate validates the request's Bearer JWT and returns a context
+// carrying userID, role and the token's jti and expiry. On failure it returns
+// the status and JSON body to answer with instead.
+func authenticate(r *http.Request) (context.Context, int, string) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, http.StatusUnauthorized, `{"success":false,"message":"Authorization token required"}`
+	}
+
+	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := utils.ValidateToken(tokenStr)
+	// Tokens issued before revocation support carry no jti and are refused
+	if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, http.StatusUnauthorized, `{"success":false,"message":"Invalid or expired token"}`
+	}
+
+	if tokens != nil {
+		denied, err := tokens.IsAccessTokenDenied(r.Context(), claims.ID)
+		if err != nil {
+			log.Printf("❌ Token denylist lookup failed: %v", err)
+			return nil, http.StatusInternalServerError, `{"success":false,"message":"Internal Server Error"}`
+		}
+		if denied {
+			return nil, http.StatusUnauthorized, `{"success":false,"message":"Token has been revoked"}`
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, RoleKey, claims.Role)
+	ctx = context.WithValue(ctx, TokenIDKey, claims.ID)
+	ctx = context.WithValue(ctx, TokenExpiryKey, claims.ExpiresAt.Time)
+	return ctx, 0, ""
+}
+
 // RequireAuth validates the Bearer JWT and injects userID + role into the request context.
 // Returns 401 if the token is missing or invalid.
 func RequireAuth(next http.Handler) http.Handler {
@@ -25,29 +74,35 @@ func RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		ctx, status, body := authenticate(r)
+		if ctx == nil {
 			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"success":false,"message":"Authorization token required"}`))
+			w.WriteHeader(status)
+			w.Write([]byte(body))
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := utils.ValidateToken(tokenStr)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"success":false,"message":"Invalid or expired token"}`))
-			return
+// OptionalAuth injects userID + role like RequireAuth when the request carries
+// a valid Bearer JWT, and otherwise serves it anonymously. For public routes
+// that show admins more, e.g. archived products.
+func OptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctx, _, _ := authenticate(r); ctx != nil {
+			r = r.WithContext(ctx)
 		}
-
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, RoleKey, claims.Role)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
 
+// IsAdmin reports whether the request was authenticated as an admin
+func IsAdmin(r *http.Request) bool {
+	role, _ := r.Context().Value(RoleKey).(string)
+	return role == "admin"
+}
+
 // RequireRole returns a middleware that allows only the specified roles.
 // Must be used after RequireAuth (role is read from context).
 func RequireRole(roles ...string) func(http.Handler) http.Handler {
@@ -79,3 +134,40 @@ func RequireRole(roles ...string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// RequireOwner returns a middleware that only lets the request through when the
+// `param` path variable matches the authenticated user's ID. Admins may access any ID.
+// Must be used after RequireAuth (user ID and role are read from context).
+func RequireOwner(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID, ok := r.Context().Value(UserIDKey).(int)
+			if !ok || userID == 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"success":false,"message":"Unauthorized"}`))
+				return
+			}
+
+			if role, _ := r.Context().Value(RoleKey).(string); role == "admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			pathID, err := strconv.Atoi(mux.Vars(r)[param])
+			if err != nil || pathID != userID {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"success":false,"message":"Forbidden: you can only access your own resources"}`))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+const (
+	// IdempotencyHeader is the request header clients use to make a POST safe to retry
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyTTL is how long a key and its cached response are kept
+	IdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLen = 255
+	maxIdempotentBody    = 1 << 20
+)
+
+// Idempotency makes the wrapped handler safe to retry when the client sends an
+// Idempotency-Key header. The first request runs normally and its response is
+// stored; a replay with the same key and body gets the stored response back,
+// while the same key with a different body is rejected with 409.
+// Must run after RequireAuth: keys are scoped per user.
+func Idempotency(keys repository.IdempotencyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyHeader)
+			if key == "" || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				writeJSONError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			userID, ok := r.Context().Value(UserIDKey).(int)
+			if !ok {
+				writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			// Read one byte past the limit so an oversized body is refused
+			// instead of being cut short, fingerprinted and forwarded
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			record := &models.IdempotencyKey{
+				UserID:      userID,
+				Key:         key,
+				Fingerprint: requestFingerprint(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(IdempotencyTTL),
+			}
+			existing, err := keys.Reserve(r.Context(), record)
+			if err != nil {
+				log.Printf("❌ Idempotency reserve failed: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+			if existing != nil {
+				replayIdempotent(w, existing, record.Fingerprint)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// Server errors (and panics) are not cached so the client can retry
+				if p := recover(); p != nil {
+					keys.Release(r.Context(), userID, key)
+					panic(p)
+				}
+				if rec.status >= http.StatusInternalServerError {
+					keys.Release(r.Context(), userID, key)
+					return
+				}
+				if err := keys.Complete(r.Context(), userID, key, rec.status, rec.body.Bytes()); err != nil {
+					log.Printf("⚠️  Failed to store idempotent response: %v", err)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// SweepIdempotencyKeys deletes expired keys and their cached responses every
+// interval until ctx is done. Run it in its own goroutine.
+func SweepIdempotencyKeys(ctx context.Context, keys repository.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n, err := keys.DeleteExpired(ctx, now); err != nil {
+				log.Printf("❌ Deleting expired idempotency keys failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Deleted %d expired idempotency key(s)", n)
+			}
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key was already used
+func replayIdempotent(w http.ResponseWriter, k *models.IdempotencyKey, fingerprint string) {
+	switch {
+	case k.Fingerprint != fingerprint:
+		writeJSONError(w, http.StatusConflict, "Idempotency-Key was already used with a different request")
+	case !k.Completed():
+		writeJSONError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(k.StatusCode)
+		w.Write(k.Body)
+	}
+}
+
+// requestFingerprint hashes method, path and body. JSON bodies are
+// re-encoded first so whitespace and key order do not matter.
+func requestFingerprint(r *http.Request, body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": message})
+}
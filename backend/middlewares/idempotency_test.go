@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/memory"
+)
+
+// idempotentRequest runs one request for user 1 through the middleware and
+// reports the response and whether the handler ran
+func idempotentRequest(t *testing.T, h func(http.Handler) http.Handler, key, body string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	ran := false
+	handler := h(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ran = true
+		w.WriteHeader(http.StatusCreated)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/users/1/topup", strings.NewReader(body))
+	req.Header.Set(IdempotencyHeader, key)
+	req = req.WithContext(context.WithValue(req.Context(), UserIDKey, 1))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, ran
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	store := memory.New()
+	h := Idempotency(store.Idempotency)
+
+	rec, ran := idempotentRequest(t, h, "big", strings.Repeat("x", maxIdempotentBody+1))
+	if rec.Code != http.StatusRequestEntityTooLarge || ran {
+		t.Fatalf("oversized body: got %d, handler ran %v", rec.Code, ran)
+	}
+
+	// The key was not used up, and a body at the limit still goes through
+	rec, ran = idempotentRequest(t, h, "big", strings.Repeat("x", maxIdempotentBody))
+	if rec.Code != http.StatusCreated || !ran {
+		t.Fatalf("body at limit: got %d, handler ran %v", rec.Code, ran)
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	store := memory.New()
+	ctx := context.Background()
+	now := time.Now()
+	for key, expiresAt := range map[string]time.Time{
+		"old": now.Add(-time.Minute),
+		"new": now.Add(time.Hour),
+	} {
+		k := &models.IdempotencyKey{UserID: 1, Key: key, CreatedAt: now.Add(-IdempotencyTTL), ExpiresAt: expiresAt}
+		if _, err := store.Idempotency.Reserve(ctx, k); err != nil {
+			t.Fatalf("reserve %s: %v", key, err)
+		}
+	}
+
+	n, err := store.Idempotency.DeleteExpired(ctx, now)
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v; want 1", n, err)
+	}
+	if err := store.Idempotency.Complete(ctx, 1, "old", http.StatusOK, nil); err == nil {
+		t.Error("expired key is still stored")
+	}
+	if err := store.Idempotency.Complete(ctx, 1, "new", http.StatusOK, nil); err != nil {
+		t.Errorf("live key was deleted: %v", err)
+	}
+}
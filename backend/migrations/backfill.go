@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+// backfills are Go steps that run right after the SQL of their migration, for
+// data SQL cannot derive the way the application does (e.g. slugs, which must
+// match utils.Slugify). They run on the migration's connection.
+var backfills = map[int]func(ctx context.Context, conn *sql.Conn) error{
+	8:  backfillCategorySlugs,
+	14: backfillProductSlugs,
+}
+
+// namedRow is an id and the name a slug is derived from
+type namedRow struct {
+	id   int
+	name string
+}
+
+// loadNamedRows runs query, which must select an id and a name
+func loadNamedRows(ctx context.Context, conn *sql.Conn, query string) ([]namedRow, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []namedRow
+	for rows.Next() {
+		var r namedRow
+		if err := rows.Scan(&r.id, &r.name); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// uniqueSlugs gives every row the slug of its name, the way the controllers
+// build one, made unique in id order by appending -2, -3, ... to later rows.
+// Names without letters or digits, or that slugify to a number, become
+// prefix-<slug> or prefix-<id>.
+func uniqueSlugs(rows []namedRow, prefix string, max int) map[int]string {
+	taken := map[string]bool{}
+	slugs := make(map[int]string, len(rows))
+	for _, r := range rows {
+		base := utils.TruncateSlug(utils.Slugify(r.name), max)
+		if base == "" {
+			base = fmt.Sprintf("%s-%d", prefix, r.id)
+		} else if _, err := strconv.Atoi(base); err == nil {
+			base = prefix + "-" + base
+		}
+		slug := base
+		for n := 2; taken[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[slug] = true
+		slugs[r.id] = slug
+	}
+	return slugs
+}
+
+// backfillCategorySlugs replaces the '#<id>' placeholders 0008 leaves in
+// categories.slug
+func backfillCategorySlugs(ctx context.Context, conn *sql.Conn) error {
+	rows, err := loadNamedRows(ctx, conn, "SELECT id, TRIM(name) FROM categories WHERE slug LIKE '#%' ORDER BY id")
+	if err != nil {
+		return err
+	}
+	for id, slug := range uniqueSlugs(rows, "category", 110) {
+		if _, err := conn.ExecContext(ctx, "UPDATE categories SET slug = ? WHERE id = ?", slug, id); err != nil {
+			return fmt.Errorf("category %d slug: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// backfillProductSlugs replaces the '#<id>' placeholders 0014 leaves in
+// products.slug; 180 bytes matches the controllers' maxProductSlug
+func backfillProductSlugs(ctx context.Context, conn *sql.Conn) error {
+	rows, err := loadNamedRows(ctx, conn, "SELECT id, name FROM products WHERE slug LIKE '#%' ORDER BY id")
+	if err != nil {
+		return err
+	}
+	for id, slug := range uniqueSlugs(rows, "product", 180) {
+		if _, err := conn.ExecContext(ctx, "UPDATE products SET slug = ? WHERE id = ?", slug, id); err != nil {
+			return fmt.Errorf("product %d slug: %w", id, err)
+		}
+	}
+	return nil
+}
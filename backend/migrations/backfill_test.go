@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+)
+
+func TestUniqueSlugs(t *testing.T) {
+	rows := []namedRow{
+		{1, "Smart Phones"},
+		{2, "smart  phones"},
+		{3, "Smart-Phones!"},
+		{4, "Éléctronique & Co"},
+		{5, "2024"},
+		{6, "???"},
+		{7, "Category 6"},
+	}
+	want := map[int]string{
+		1: "smart-phones",
+		2: "smart-phones-2",
+		3: "smart-phones-3",
+		4: "electronique-co",
+		5: "category-2024",
+		6: "category-6",
+		7: "category-6-2",
+	}
+	got := uniqueSlugs(rows, "category", 110)
+	for id, slug := range want {
+		if got[id] != slug {
+			t.Errorf("row %d: slug %q, want %q", id, got[id], slug)
+		}
+	}
+	// Every slug must be one the application could have produced itself
+	for id, slug := range got {
+		if utils.Slugify(slug) != slug {
+			t.Errorf("row %d: %q is not a Slugify result", id, slug)
+		}
+	}
+}
+
+// TestSlugPlaceholders checks the up files still leave the '#<id>'
+// placeholders the backfills replace
+func TestSlugPlaceholders(t *testing.T) {
+	migrator, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{
+		8:  "UPDATE categories SET slug = CONCAT('#', id), sort_order = id WHERE slug IS NULL",
+		14: "UPDATE products SET slug = CONCAT('#', id)",
+	}
+	for _, m := range migrator.migrations {
+		stmt, ok := want[m.Version]
+		if !ok {
+			continue
+		}
+		if backfills[m.Version] == nil {
+			t.Errorf("migration %04d has no backfill", m.Version)
+		}
+		found := false
+		for _, s := range SplitStatements(m.Up) {
+			found = found || s == stmt
+		}
+		if !found {
+			t.Errorf("migration %04d no longer runs %q", m.Version, stmt)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package migrations applies the numbered SQL files in sql/ to the database and
+// records each applied version in the schema_migrations table.
+//
+// Files are named NNNN_description.up.sql / NNNN_description.down.sql.
+// Statements are split on ";" so each file may contain several of them. A
+// migration may also have a Go backfill (see backfills) that runs after its
+// up file.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// Dir is the source directory of the embedded migrations, relative to backend/
+const Dir = "migrations/sql"
+
+// lockName is the MySQL advisory lock that keeps two instances from migrating at once
+const lockName = "go_commerce_schema_migrations"
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load reads every migration in fsys, sorted by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations embedded in the binary
+func New(db *sql.DB) (*Migrator, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 30)", lockName).Scan(&got); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if got.Int64 != 1 {
+		return fmt.Errorf("another instance is running migrations")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("schema_migrations table: %w", err)
+	}
+	return fn(conn)
+}
+
+// applied returns the applied versions and when they ran
+func applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		versions[v] = at
+	}
+	return versions, rows.Err()
+}
+
+// exec runs every statement of a migration file in order
+func exec(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range SplitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n  in: %s", err, firstLine(stmt))
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration in version order and returns how many ran
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := exec(ctx, conn, mig.Up); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if backfill := backfills[mig.Version]; backfill != nil {
+				if err := backfill(ctx, conn); err != nil {
+					return fmt.Errorf("migration %04d_%s backfill: %w", mig.Version, mig.Name, err)
+				}
+			}
+			if _, err := conn.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name) VALUES (?, ?)", mig.Version, mig.Name); err != nil {
+				return err
+			}
+			log.Printf("✅ migration %04d_%s applied", mig.Version, mig.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down rolls back the `steps` most recently applied migrations and returns how many ran
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("migration %04d_%s has no down file", mig.Version, mig.Name)
+			}
+			if err := exec(ctx, conn, mig.Down); err != nil {
+				return fmt.Errorf("rollback %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+				return err
+			}
+			log.Printf("↩️  migration %04d_%s rolled back", mig.Version, mig.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status lists every known migration with its applied time (nil when pending)
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := Status{Migration: mig}
+			if at, ok := done[mig.Version]; ok {
+				s.AppliedAt = &at
+			}
+			statuses = append(statuses, s)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Create writes an empty up/down pair for the next version into dir and returns the file paths
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name is required")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	next := 1
+	for _, e := range entries {
+		if m := fileName.FindStringSubmatch(e.Name()); m != nil {
+			if v, _ := strconv.Atoi(m[1]); v >= next {
+				next = v + 1
+			}
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", next, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- revert "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// SplitStatements splits a SQL script on ";" outside quotes and comments.
+// Comments follow MySQL: "#" and "-- " (two dashes and whitespace) run to the
+// end of the line and are dropped, as are /* ... */ comments; /*! ... */ and
+// /*+ ... */ are executed by MySQL and kept in the statement.
+func SplitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	var quote rune
+	inLineComment, inBlockComment, keepBlock := false, false, false
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case inLineComment:
+			if c == '\n' {
+				inLineComment = false
+				cur.WriteRune(c)
+			}
+			continue
+		case inBlockComment:
+			if c == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+				inBlockComment = false
+				i++
+				if keepBlock {
+					cur.WriteString("*/")
+				} else {
+					cur.WriteRune(' ')
+				}
+			} else if keepBlock {
+				cur.WriteRune(c)
+			}
+			continue
+		case quote != 0:
+			cur.WriteRune(c)
+			if c == '\\' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '#', c == '-' && i+1 < len(runes) && runes[i+1] == '-' && (i+2 == len(runes) || unicode.IsSpace(runes[i+2]) || unicode.IsControl(runes[i+2])):
+			inLineComment = true
+			continue
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			inBlockComment = true
+			keepBlock = i+2 < len(runes) && (runes[i+2] == '!' || runes[i+2] == '+')
+			i++
+			if keepBlock {
+				cur.WriteString("/*")
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';':
+			if s := strings.TrimSpace(cur.String()); s != "" {
+				stmts = append(stmts, s)
+			}
+			cur.Reset()
+			continue
+		}
+		cur.WriteRune(c)
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
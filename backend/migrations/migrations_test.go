@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"plain", "SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"no trailing semicolon", "SELECT 1;\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"single quotes", "INSERT INTO t VALUES ('a;b'); SELECT 1", []string{"INSERT INTO t VALUES ('a;b')", "SELECT 1"}},
+		{"double quotes", `SELECT "x;y"; SELECT 1`, []string{`SELECT "x;y"`, "SELECT 1"}},
+		{"backticks", "SELECT `a;b` FROM t; SELECT 1", []string{"SELECT `a;b` FROM t", "SELECT 1"}},
+		{"escaped quote", `SELECT 'it\'s;'; SELECT 1`, []string{`SELECT 'it\'s;'`, "SELECT 1"}},
+		{"doubled quote", "SELECT 'it''s;'; SELECT 1", []string{"SELECT 'it''s;'", "SELECT 1"}},
+		{"dash comment", "-- first; not a statement\nSELECT 1;", []string{"SELECT 1"}},
+		{"dash comment at end", "SELECT 1; --", []string{"SELECT 1"}},
+		{"dashes without space", "SELECT 5--1; SELECT 2", []string{"SELECT 5--1", "SELECT 2"}},
+		{"hash comment", "# setup; step one\nSELECT 1; # trailing; note\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"hash in quotes", "SELECT CONCAT('#', id) FROM t; SELECT 1", []string{"SELECT CONCAT('#', id) FROM t", "SELECT 1"}},
+		{"block comment", "SELECT /* a; b */ 1; /* only; a comment */", []string{"SELECT   1"}},
+		{"multi-line block comment", "/*\n drop; everything\n*/\nSELECT 1;", []string{"SELECT 1"}},
+		{"executable comment", "CREATE TABLE t (id INT) /*!50100 ENGINE=InnoDB */; SELECT 1", []string{"CREATE TABLE t (id INT) /*!50100 ENGINE=InnoDB */", "SELECT 1"}},
+		{"comment markers in quotes", "SELECT '/* -- #'; SELECT 1", []string{"SELECT '/* -- #'", "SELECT 1"}},
+		{"empty statements", ";;\n;", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEmbeddedMigrationsSplit checks every embedded file splits into
+// statements with no comment text left in them
+func TestEmbeddedMigrationsSplit(t *testing.T) {
+	migrator, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrator.migrations {
+		for dir, script := range map[string]string{"up": m.Up, "down": m.Down} {
+			if dir == "down" && strings.TrimSpace(script) == "" {
+				continue
+			}
+			stmts := SplitStatements(script)
+			if len(stmts) == 0 {
+				t.Errorf("%04d_%s.%s.sql has no statements", m.Version, m.Name, dir)
+			}
+			for _, stmt := range stmts {
+				if strings.HasPrefix(stmt, "--") || strings.HasPrefix(stmt, "#") || strings.HasPrefix(stmt, "/*") {
+					t.Errorf("%04d_%s.%s.sql: statement starts with a comment: %s", m.Version, m.Name, dir, firstLine(stmt))
+				}
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("SELECT 2")},
+		"0001_first.up.sql":    {Data: []byte("SELECT 1")},
+		"0001_first.down.sql":  {Data: []byte("SELECT -1")},
+		"README.md":            {Data: []byte("not a migration")},
+		"0003_broken.down.sql": {Data: []byte("SELECT -3")},
+	}
+	if _, err := Load(fsys); err == nil || !strings.Contains(err.Error(), "no up file") {
+		t.Fatalf("missing up file: got %v", err)
+	}
+	delete(fsys, "0003_broken.down.sql")
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "first" || migrations[0].Down != "SELECT -1" || migrations[1].Version != 2 {
+		t.Fatalf("got %+v", migrations)
+	}
+}
@@ -0,0 +1,10 @@
+-- Drops every baseline table. This destroys all data; only use it on a scratch database.
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS product_specifications;
+DROP TABLE IF EXISTS vouchers;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
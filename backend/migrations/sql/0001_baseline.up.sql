@@ -0,0 +1,124 @@
+-- Baseline schema: the tables previously created by config.runMigrations.
+-- Every statement is guarded (IF NOT EXISTS) so it is safe on databases
+-- that were set up before versioned migrations existed.
+
+CREATE TABLE IF NOT EXISTS users (
+	id             INT AUTO_INCREMENT PRIMARY KEY,
+	full_name      VARCHAR(100) NOT NULL,
+	email          VARCHAR(100) UNIQUE NOT NULL,
+	password       VARCHAR(255) NOT NULL,
+	phone          VARCHAR(20),
+	balance        INT DEFAULT 0,
+	total_spent    INT DEFAULT 0,
+	is_member      BOOLEAN DEFAULT FALSE,
+	avatar_url     VARCHAR(500),
+	role           ENUM('admin','customer') NOT NULL DEFAULT 'customer',
+	email_verified BOOLEAN DEFAULT FALSE,
+	created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+	id   INT AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	name          VARCHAR(200) NOT NULL,
+	slug          VARCHAR(200),
+	price         INT NOT NULL,
+	stock         INT DEFAULT 0,
+	category_id   INT,
+	rating        DECIMAL(3,2) DEFAULT 0.00,
+	total_reviews INT DEFAULT 0,
+	description   TEXT,
+	image_url     VARCHAR(500),
+	brand         VARCHAR(100),
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id              INT AUTO_INCREMENT PRIMARY KEY,
+	order_number    VARCHAR(100) NOT NULL UNIQUE,
+	user_id         INT NOT NULL,
+	address_id      INT DEFAULT NULL,
+	subtotal        INT NOT NULL DEFAULT 0,
+	discount_amount INT NOT NULL DEFAULT 0,
+	total_amount    INT NOT NULL DEFAULT 0,
+	status          VARCHAR(50) DEFAULT 'pending',
+	created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	order_id      INT NOT NULL,
+	product_id    INT NOT NULL,
+	product_name  VARCHAR(200) NOT NULL DEFAULT '',
+	product_image VARCHAR(500) NOT NULL DEFAULT '',
+	quantity      INT NOT NULL,
+	price         INT NOT NULL,
+	subtotal      INT NOT NULL DEFAULT 0,
+	FOREIGN KEY (order_id) REFERENCES orders(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+	id         INT AUTO_INCREMENT PRIMARY KEY,
+	user_id    INT NOT NULL,
+	product_id INT NOT NULL,
+	quantity   INT NOT NULL DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY unique_cart_item (user_id, product_id),
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (product_id) REFERENCES products(id)
+);
+
+CREATE TABLE IF NOT EXISTS product_specifications (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	product_id    INT NOT NULL,
+	spec_key      VARCHAR(100) NOT NULL,
+	spec_value    TEXT,
+	display_order INT DEFAULT 0,
+	INDEX idx_product_id (product_id)
+);
+
+CREATE TABLE IF NOT EXISTS vouchers (
+	id             INT AUTO_INCREMENT PRIMARY KEY,
+	code           VARCHAR(50) NOT NULL UNIQUE,
+	name           VARCHAR(100) NOT NULL DEFAULT '',
+	description    TEXT,
+	type           VARCHAR(50) NOT NULL DEFAULT 'percentage',
+	discount_value DECIMAL(10,2) DEFAULT 0,
+	min_purchase   INT DEFAULT 0,
+	max_discount   DECIMAL(10,2) DEFAULT 0,
+	usage_limit    INT DEFAULT 0,
+	used_count     INT DEFAULT 0,
+	valid_from     DATETIME DEFAULT CURRENT_TIMESTAMP,
+	valid_until    DATETIME,
+	is_active      BOOLEAN DEFAULT TRUE,
+	created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+	id          INT AUTO_INCREMENT PRIMARY KEY,
+	product_id  INT NOT NULL,
+	user_id     INT NOT NULL,
+	order_id    INT NOT NULL,
+	rating      INT NOT NULL,
+	review_text TEXT,
+	is_verified BOOLEAN DEFAULT TRUE,
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+	UNIQUE KEY unique_review (product_id, user_id, order_id),
+	INDEX idx_product_id (product_id),
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+-- Seed the default categories only on an empty table
+INSERT INTO categories (name)
+SELECT seed.name FROM (SELECT 'Smartphones' AS name UNION ALL SELECT 'Laptops' UNION ALL SELECT 'Audio') seed
+WHERE NOT EXISTS (SELECT 1 FROM categories);
@@ -0,0 +1,15 @@
+-- Idempotency-Key bookkeeping for POST /users/{id}/checkout and /topup.
+-- status_code stays 0 while the first request is still being processed.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id         INT NOT NULL,
+	idempotency_key VARCHAR(255) NOT NULL,
+	fingerprint     CHAR(64) NOT NULL,
+	status_code     INT NOT NULL DEFAULT 0,
+	response_body   MEDIUMBLOB,
+	created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at      DATETIME NOT NULL,
+	PRIMARY KEY (user_id, idempotency_key),
+	INDEX idx_idempotency_expires (expires_at),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
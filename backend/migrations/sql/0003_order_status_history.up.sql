@@ -0,0 +1,21 @@
+-- Timeline of order status changes. Existing orders get a single entry
+-- for their current status so every order has at least one row.
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id          INT AUTO_INCREMENT PRIMARY KEY,
+	order_id    INT NOT NULL,
+	from_status VARCHAR(50),
+	to_status   VARCHAR(50) NOT NULL,
+	changed_by  INT,
+	actor_role  VARCHAR(20),
+	note        VARCHAR(255),
+	created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_order_status_history_order (order_id),
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+	FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO order_status_history (order_id, from_status, to_status, created_at)
+SELECT o.id, NULL, COALESCE(o.status, 'pending'), o.created_at
+FROM orders o
+WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id);
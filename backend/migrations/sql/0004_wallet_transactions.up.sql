@@ -0,0 +1,29 @@
+-- Wallet ledger. users.balance stays as a cached running total that is
+-- updated in the same transaction as every ledger entry; the ledger is the
+-- source of truth (SUM(amount) per user). Existing balances are carried
+-- over as an opening adjustment so both agree from the start.
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+	id              INT AUTO_INCREMENT PRIMARY KEY,
+	user_id         INT NOT NULL,
+	type            ENUM('topup','purchase','refund','adjustment') NOT NULL,
+	amount          INT NOT NULL,
+	balance_after   INT NOT NULL,
+	counter_account VARCHAR(50) NOT NULL,
+	order_id        INT,
+	reference       VARCHAR(100) NOT NULL,
+	description     VARCHAR(255),
+	created_by      INT,
+	created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_wallet_transactions_user (user_id, id),
+	INDEX idx_wallet_transactions_order (order_id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL,
+	FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO wallet_transactions (user_id, type, amount, balance_after, counter_account, reference, description)
+SELECT u.id, 'adjustment', u.balance, u.balance, 'store:adjustments', 'OPENING', 'Opening balance'
+FROM users u
+WHERE COALESCE(u.balance, 0) <> 0
+  AND NOT EXISTS (SELECT 1 FROM wallet_transactions w WHERE w.user_id = u.id);
@@ -0,0 +1,21 @@
+-- Rotating refresh tokens (stored as SHA-256 hashes) and the denylist of
+-- revoked access-token IDs (jti) checked by RequireAuth until they expire.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id         INT AUTO_INCREMENT PRIMARY KEY,
+	user_id    INT NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	family_id  VARCHAR(64) NOT NULL,
+	expires_at DATETIME NOT NULL,
+	revoked_at DATETIME,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_refresh_tokens_family (family_id),
+	INDEX idx_refresh_tokens_user (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+	jti        VARCHAR(64) PRIMARY KEY,
+	expires_at DATETIME NOT NULL,
+	INDEX idx_revoked_access_tokens_expires (expires_at)
+);
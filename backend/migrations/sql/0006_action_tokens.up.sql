@@ -0,0 +1,14 @@
+-- Single-use tokens mailed to users for email verification and password
+-- reset. Only the SHA-256 of the token is stored.
+
+CREATE TABLE IF NOT EXISTS action_tokens (
+	id         INT AUTO_INCREMENT PRIMARY KEY,
+	user_id    INT NOT NULL,
+	purpose    VARCHAR(32) NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	expires_at DATETIME NOT NULL,
+	used_at    DATETIME,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_action_tokens_user (user_id, purpose),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Failed-login counters used for backoff and lockout, keyed by
+-- "email:<address>" (per account) or "ip:<address>" (per client).
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+	throttle_key    VARCHAR(320) PRIMARY KEY,
+	failures        INT NOT NULL DEFAULT 0,
+	locked_until    DATETIME,
+	last_failure_at DATETIME NOT NULL,
+	INDEX idx_login_throttles_last_failure (last_failure_at)
+);
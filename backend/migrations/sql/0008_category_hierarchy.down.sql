@@ -0,0 +1,13 @@
+ALTER TABLE categories DROP FOREIGN KEY fk_categories_parent;
+
+DROP INDEX idx_categories_parent ON categories;
+
+DROP INDEX idx_categories_slug ON categories;
+
+ALTER TABLE categories
+	DROP COLUMN parent_id,
+	DROP COLUMN slug,
+	DROP COLUMN description,
+	DROP COLUMN image_url,
+	DROP COLUMN sort_order,
+	DROP COLUMN created_at;
@@ -0,0 +1,23 @@
+-- Category management: parent/child nesting, URL slugs, images and an
+-- explicit display order. Existing categories become top-level. Their slug
+-- is set to a unique '#<id>' placeholder here and then replaced from Go with
+-- utils.Slugify of the name, de-duplicated (see backfills in backfill.go).
+
+ALTER TABLE categories
+	ADD COLUMN parent_id   INT NULL,
+	ADD COLUMN slug        VARCHAR(120) NULL,
+	ADD COLUMN description TEXT,
+	ADD COLUMN image_url   VARCHAR(500),
+	ADD COLUMN sort_order  INT NOT NULL DEFAULT 0,
+	ADD COLUMN created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE categories SET slug = CONCAT('#', id), sort_order = id WHERE slug IS NULL;
+
+ALTER TABLE categories MODIFY slug VARCHAR(120) NOT NULL;
+
+CREATE UNIQUE INDEX idx_categories_slug ON categories (slug);
+
+CREATE INDEX idx_categories_parent ON categories (parent_id, sort_order);
+
+ALTER TABLE categories
+	ADD CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE RESTRICT;
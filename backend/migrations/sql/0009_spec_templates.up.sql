@@ -0,0 +1,48 @@
+-- Specification templates: the spec fields each category's products have.
+-- Subcategories without fields of their own use their parent's template.
+-- The three default categories are seeded with the fields that used to be
+-- hard-coded in the product handlers.
+
+CREATE TABLE IF NOT EXISTS category_spec_fields (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	category_id   INT NOT NULL,
+	spec_key      VARCHAR(50) NOT NULL,
+	label         VARCHAR(100) NOT NULL,
+	unit          VARCHAR(20) NOT NULL DEFAULT '',
+	type          ENUM('text','number','boolean') NOT NULL DEFAULT 'text',
+	required      BOOLEAN NOT NULL DEFAULT FALSE,
+	display_order INT NOT NULL DEFAULT 0,
+	UNIQUE KEY uq_category_spec_fields_key (category_id, spec_key),
+	FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+INSERT INTO category_spec_fields (category_id, spec_key, label, unit, type, display_order)
+SELECT c.id, f.spec_key, f.label, f.unit, f.type, f.display_order
+FROM categories c
+JOIN (
+	SELECT 'Smartphones' AS category, 'chipset' AS spec_key, 'Chipset' AS label, '' AS unit, 'text' AS type, 1 AS display_order
+	UNION ALL SELECT 'Smartphones', 'ram', 'RAM', 'GB', 'number', 2
+	UNION ALL SELECT 'Smartphones', 'rom', 'ROM', 'GB', 'number', 3
+	UNION ALL SELECT 'Smartphones', 'display', 'Display', 'inch', 'number', 4
+	UNION ALL SELECT 'Smartphones', 'refresh_rate', 'Refresh Rate', 'Hz', 'number', 5
+	UNION ALL SELECT 'Smartphones', 'battery', 'Battery', 'mAh', 'number', 6
+	UNION ALL SELECT 'Smartphones', 'charging', 'Charging', '', 'text', 7
+	UNION ALL SELECT 'Smartphones', 'camera', 'Camera', '', 'text', 8
+	UNION ALL SELECT 'Smartphones', 'os', 'Operating System', '', 'text', 9
+	UNION ALL SELECT 'Smartphones', 'connectivity', 'Connectivity', '', 'text', 10
+	UNION ALL SELECT 'Laptops', 'chipset', 'Chipset', '', 'text', 1
+	UNION ALL SELECT 'Laptops', 'ram', 'RAM', 'GB', 'number', 2
+	UNION ALL SELECT 'Laptops', 'ram_type', 'RAM Type', '', 'text', 3
+	UNION ALL SELECT 'Laptops', 'rom', 'ROM', 'GB', 'number', 4
+	UNION ALL SELECT 'Laptops', 'storage_type', 'Storage Type', '', 'text', 5
+	UNION ALL SELECT 'Laptops', 'display', 'Display', '', 'text', 6
+	UNION ALL SELECT 'Laptops', 'gpu', 'GPU', '', 'text', 7
+	UNION ALL SELECT 'Laptops', 'battery', 'Battery', 'Wh', 'number', 8
+	UNION ALL SELECT 'Laptops', 'os', 'Operating System', '', 'text', 9
+	UNION ALL SELECT 'Laptops', 'connectivity', 'Connectivity', '', 'text', 10
+	UNION ALL SELECT 'Audio', 'frequency_response', 'Respon Frekuensi', '', 'text', 1
+	UNION ALL SELECT 'Audio', 'impedance', 'Impedansi', 'Ω', 'number', 2
+	UNION ALL SELECT 'Audio', 'sensitivity', 'Sensitivitas', 'dB', 'number', 3
+	UNION ALL SELECT 'Audio', 'driver_size', 'Ukuran Driver', 'mm', 'number', 4
+) f ON f.category = c.name
+WHERE NOT EXISTS (SELECT 1 FROM category_spec_fields);
@@ -0,0 +1,12 @@
+DROP INDEX idx_product_specs_number ON product_specifications;
+
+ALTER TABLE product_specifications
+	DROP COLUMN value_type,
+	DROP COLUMN value_number,
+	DROP COLUMN unit;
+
+UPDATE category_spec_fields SET type = 'text' WHERE type = 'enum';
+
+ALTER TABLE category_spec_fields
+	DROP COLUMN options,
+	MODIFY type ENUM('text','number','boolean') NOT NULL DEFAULT 'text';
@@ -0,0 +1,35 @@
+-- Typed specification values so products can be filtered by them.
+-- Templates gain an 'enum' type with a fixed list of options (a JSON array),
+-- and every spec row records its type, unit and, for numbers and booleans,
+-- the value as a number. Existing rows of the form "<number> <unit>" that
+-- belong to a number field of the product's own category (and Yes/No rows of
+-- boolean fields) are typed here; anything else is typed when the product is
+-- next saved.
+
+ALTER TABLE category_spec_fields
+	MODIFY type ENUM('text','number','boolean','enum') NOT NULL DEFAULT 'text',
+	ADD COLUMN options TEXT NULL;
+
+ALTER TABLE product_specifications
+	ADD COLUMN value_type   VARCHAR(10) NOT NULL DEFAULT 'text',
+	ADD COLUMN value_number DECIMAL(16,4) NULL,
+	ADD COLUMN unit         VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_product_specs_number ON product_specifications (spec_key, value_number);
+
+UPDATE product_specifications ps
+JOIN products p ON p.id = ps.product_id
+JOIN category_spec_fields f ON f.category_id = p.category_id AND f.label = ps.spec_key
+SET ps.value_type = 'number',
+	ps.unit = f.unit,
+	ps.value_number = CAST(SUBSTRING_INDEX(ps.spec_value, ' ', 1) AS DECIMAL(16,4))
+WHERE f.type = 'number'
+	AND SUBSTRING_INDEX(ps.spec_value, ' ', 1) REGEXP '^[0-9]+([.][0-9]+)?$'
+	AND ps.spec_value = TRIM(CONCAT(SUBSTRING_INDEX(ps.spec_value, ' ', 1), ' ', f.unit));
+
+UPDATE product_specifications ps
+JOIN products p ON p.id = ps.product_id
+JOIN category_spec_fields f ON f.category_id = p.category_id AND f.label = ps.spec_key
+SET ps.value_type = 'boolean',
+	ps.value_number = CASE ps.spec_value WHEN 'Yes' THEN 1 ELSE 0 END
+WHERE f.type = 'boolean' AND ps.spec_value IN ('Yes', 'No');
@@ -0,0 +1,16 @@
+ALTER TABLE order_items
+	DROP COLUMN variant_id,
+	DROP COLUMN sku,
+	DROP COLUMN variant_options;
+
+DELETE FROM cart_items WHERE variant_id <> 0;
+
+CREATE UNIQUE INDEX unique_cart_item ON cart_items (user_id, product_id);
+
+DROP INDEX unique_cart_line ON cart_items;
+
+ALTER TABLE cart_items DROP COLUMN variant_id;
+
+DROP TABLE IF EXISTS product_variants;
+
+DROP TABLE IF EXISTS product_options;
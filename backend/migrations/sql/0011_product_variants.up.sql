@@ -0,0 +1,46 @@
+-- Product variants. A product may declare option types (e.g. Color with
+-- values Black/White, Storage with 256 GB/512 GB); each variant picks one
+-- value per option and has its own SKU, price, stock and image. The option
+-- values and a variant's choices are stored as JSON arrays. For products
+-- with variants, products.price and products.stock hold the lowest variant
+-- price and the total variant stock so listings keep working unchanged.
+-- Cart lines and order items reference the variant (cart_items.variant_id
+-- is 0 for products without variants); order items also snapshot the SKU
+-- and the chosen options.
+
+CREATE TABLE IF NOT EXISTS product_options (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	product_id    INT NOT NULL,
+	name          VARCHAR(50) NOT NULL,
+	option_values TEXT NOT NULL,
+	display_order INT NOT NULL DEFAULT 0,
+	UNIQUE KEY uq_product_options_name (product_id, name),
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	product_id    INT NOT NULL,
+	sku           VARCHAR(64) NOT NULL,
+	options       TEXT NOT NULL,
+	price         INT NOT NULL,
+	stock         INT NOT NULL DEFAULT 0,
+	image_url     VARCHAR(500) NOT NULL DEFAULT '',
+	display_order INT NOT NULL DEFAULT 0,
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uq_product_variants_sku (sku),
+	INDEX idx_product_variants_product (product_id, display_order),
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+ALTER TABLE cart_items ADD COLUMN variant_id INT NOT NULL DEFAULT 0;
+
+-- The new key also serves the user_id foreign key, so add it before dropping the old one
+CREATE UNIQUE INDEX unique_cart_line ON cart_items (user_id, product_id, variant_id);
+
+DROP INDEX unique_cart_item ON cart_items;
+
+ALTER TABLE order_items
+	ADD COLUMN variant_id      INT NULL,
+	ADD COLUMN sku             VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN variant_options TEXT NULL;
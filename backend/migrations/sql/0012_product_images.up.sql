@@ -0,0 +1,18 @@
+-- Image gallery per product. Exactly one image of a product is primary; its
+-- URL is mirrored into products.image_url so listings keep showing a cover.
+-- Existing covers become the first, primary image of their product.
+
+CREATE TABLE IF NOT EXISTS product_images (
+	id            INT AUTO_INCREMENT PRIMARY KEY,
+	product_id    INT NOT NULL,
+	url           VARCHAR(500) NOT NULL,
+	alt_text      VARCHAR(255) NOT NULL DEFAULT '',
+	is_primary    BOOLEAN NOT NULL DEFAULT FALSE,
+	display_order INT NOT NULL DEFAULT 0,
+	created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_product_images_product (product_id, display_order),
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+INSERT INTO product_images (product_id, url, alt_text, is_primary, display_order)
+SELECT id, image_url, name, TRUE, 1 FROM products WHERE image_url IS NOT NULL AND image_url <> '';
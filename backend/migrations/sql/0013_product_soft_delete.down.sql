@@ -0,0 +1,7 @@
+-- Archived products become visible again once deleted_at is gone.
+
+ALTER TABLE product_specifications DROP FOREIGN KEY fk_product_specifications_product;
+
+DROP INDEX idx_products_deleted_at ON products;
+
+ALTER TABLE products DROP COLUMN deleted_at;
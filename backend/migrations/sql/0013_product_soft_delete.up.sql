@@ -0,0 +1,15 @@
+-- Soft delete for products. Deleting a product now only sets deleted_at, so
+-- order items, cart lines and reviews keep pointing at a real row; archived
+-- products are left out of the catalog, search and carts and can be restored.
+-- Spec rows left behind by earlier hard deletes are removed, and the foreign
+-- key makes sure future deletes take their specs along.
+
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+
+CREATE INDEX idx_products_deleted_at ON products (deleted_at);
+
+DELETE FROM product_specifications WHERE product_id NOT IN (SELECT id FROM products);
+
+ALTER TABLE product_specifications
+	ADD CONSTRAINT fk_product_specifications_product
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- Former slugs are forgotten; current slugs stay but are no longer unique.
+
+DROP TABLE IF EXISTS product_slug_history;
+
+DROP INDEX idx_products_slug ON products;
+
+ALTER TABLE products MODIFY slug VARCHAR(200) NULL;
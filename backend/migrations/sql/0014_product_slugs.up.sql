@@ -0,0 +1,21 @@
+-- Unique product slugs for SEO-friendly URLs. Existing slugs were a naive
+-- lower-case of the name and never read, so they are rebuilt: set to a
+-- unique '#<id>' placeholder here and then replaced from Go with
+-- utils.Slugify of the name, the rule the API uses (see backfills in
+-- backfill.go). Slugs that come out empty or numeric get a product- prefix
+-- and duplicates get -2, -3, ... Slugs a product had before a rename are
+-- kept in product_slug_history so old links can be redirected.
+
+UPDATE products SET slug = CONCAT('#', id);
+
+ALTER TABLE products MODIFY slug VARCHAR(200) NOT NULL;
+
+CREATE UNIQUE INDEX idx_products_slug ON products (slug);
+
+CREATE TABLE IF NOT EXISTS product_slug_history (
+	slug       VARCHAR(200) PRIMARY KEY,
+	product_id INT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	INDEX idx_product_slug_history_product (product_id),
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+-- Holds in progress are dropped; checkout goes back to checking stock alone.
+
+DROP TABLE IF EXISTS stock_reservations;
+
+ALTER TABLE product_variants DROP COLUMN reserved;
+
+ALTER TABLE products DROP COLUMN reserved;
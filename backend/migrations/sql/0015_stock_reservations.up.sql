@@ -0,0 +1,23 @@
+-- Stock reservations. Starting a checkout holds the cart quantities for a
+-- limited time: each hold is a stock_reservations row, and its quantity is
+-- added to products.reserved (and product_variants.reserved for variant
+-- lines) so other customers only see stock - reserved. Checkout consumes
+-- the buyer's own holds; a background sweeper releases expired ones.
+
+ALTER TABLE products ADD COLUMN reserved INT NOT NULL DEFAULT 0;
+
+ALTER TABLE product_variants ADD COLUMN reserved INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+	id         INT AUTO_INCREMENT PRIMARY KEY,
+	user_id    INT NOT NULL,
+	product_id INT NOT NULL,
+	variant_id INT NOT NULL DEFAULT 0,
+	quantity   INT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY uq_stock_reservations_line (user_id, product_id, variant_id),
+	INDEX idx_stock_reservations_expires (expires_at),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
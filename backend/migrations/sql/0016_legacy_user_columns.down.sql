@@ -0,0 +1,3 @@
+-- Nothing to undo: the columns are part of the baseline users table, and
+-- dropping them would break databases that were created with them.
+DO 0;
@@ -0,0 +1,41 @@
+-- Columns that config.runMigrations used to add to users tables created by
+-- older releases. The baseline only creates missing tables, so a database
+-- from before versioned migrations could still lack them. Each ALTER runs
+-- only when information_schema says the column is missing, which makes this
+-- a no-op on databases created from the baseline.
+
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+	'ALTER TABLE users ADD COLUMN total_spent INT DEFAULT 0',
+	'DO 0')
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'total_spent');
+PREPARE add_column FROM @ddl;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
+
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+	'ALTER TABLE users ADD COLUMN avatar_url VARCHAR(500)',
+	'DO 0')
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'avatar_url');
+PREPARE add_column FROM @ddl;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
+
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+	'ALTER TABLE users ADD COLUMN role ENUM(''admin'',''customer'') NOT NULL DEFAULT ''customer''',
+	'DO 0')
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'role');
+PREPARE add_column FROM @ddl;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
+
+SET @ddl = (SELECT IF(COUNT(*) = 0,
+	'ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT FALSE',
+	'DO 0')
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'email_verified');
+PREPARE add_column FROM @ddl;
+EXECUTE add_column;
+DEALLOCATE PREPARE add_column;
@@ -0,0 +1,18 @@
+-- Double-entry legs for the wallet ledger. Every wallet_transactions row is
+-- posted as two ledger_entries: Amount on the user's wallet account
+-- ('user:<id>') and -Amount on its counter account, so the ledger as a whole
+-- always sums to zero. Existing transactions get both legs here.
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	id             INT AUTO_INCREMENT PRIMARY KEY,
+	transaction_id INT NOT NULL,
+	account        VARCHAR(50) NOT NULL,
+	amount         INT NOT NULL,
+	INDEX idx_ledger_entries_account (account),
+	FOREIGN KEY (transaction_id) REFERENCES wallet_transactions(id) ON DELETE CASCADE
+);
+
+INSERT INTO ledger_entries (transaction_id, account, amount)
+SELECT id, CONCAT('user:', user_id), amount FROM wallet_transactions
+UNION ALL
+SELECT id, counter_account, -amount FROM wallet_transactions;
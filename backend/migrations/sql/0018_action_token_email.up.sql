@@ -0,0 +1,8 @@
+-- Action tokens remember the address they were mailed to. A verify_email
+-- token only verifies that address, and changing the email drops the tokens
+-- sent to the old one. Tokens issued before this migration have no address,
+-- so they are retired and users request a new link.
+
+ALTER TABLE action_tokens ADD COLUMN email VARCHAR(255) NOT NULL DEFAULT '' AFTER purpose;
+
+UPDATE action_tokens SET used_at = NOW() WHERE used_at IS NULL;
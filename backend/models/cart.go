@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type CartItem struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	ProductID int       `json:"product_id"`
+	VariantID int       `json:"variant_id"` // 0 for products without variants
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CartLine identifies one cart line of a user: a product and, for products
+// with variants, the chosen variant
+type CartLine struct {
+	ProductID int `json:"product_id"`
+	VariantID int `json:"variant_id"`
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Category is a node of the category tree. ParentID is nil for top-level
+// categories; Children is only filled in by CategoryTree.
+type Category struct {
+	ID           int        `json:"id"`
+	ParentID     *int       `json:"parent_id"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	Description  string     `json:"description,omitempty"`
+	Image        string     `json:"image,omitempty"`
+	SortOrder    int        `json:"sort_order"`
+	ProductCount int        `json:"product_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Children     []Category `json:"children,omitempty"`
+}
+
+// CategoryRequest is the body of the admin create/update category endpoints.
+// An empty slug is derived from the name.
+type CategoryRequest struct {
+	ParentID    *int   `json:"parent_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	SortOrder   int    `json:"sort_order"`
+}
+
+// sortCategories orders siblings by sort order, then name
+func sortCategories(list []Category) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].SortOrder != list[j].SortOrder {
+			return list[i].SortOrder < list[j].SortOrder
+		}
+		return list[i].Name < list[j].Name
+	})
+}
+
+// CategoryTree nests a flat category list under its parents. Categories whose
+// parent is missing from the list are treated as top-level.
+func CategoryTree(list []Category) []Category {
+	byParent := map[int][]Category{}
+	known := map[int]bool{}
+	for _, c := range list {
+		known[c.ID] = true
+	}
+	for _, c := range list {
+		parent := 0
+		if c.ParentID != nil && known[*c.ParentID] {
+			parent = *c.ParentID
+		}
+		byParent[parent] = append(byParent[parent], c)
+	}
+
+	var build func(parent int, seen map[int]bool) []Category
+	build = func(parent int, seen map[int]bool) []Category {
+		nodes := byParent[parent]
+		sortCategories(nodes)
+		for i := range nodes {
+			if seen[nodes[i].ID] {
+				continue
+			}
+			seen[nodes[i].ID] = true
+			nodes[i].Children = build(nodes[i].ID, seen)
+		}
+		return nodes
+	}
+	return build(0, map[int]bool{})
+}
+
+// FindCategory returns the category in list whose slug equals ref, ignoring
+// case, or failing that the one whose name does
+func FindCategory(list []Category, ref string) (Category, bool) {
+	byName := -1
+	for i, c := range list {
+		if strings.EqualFold(c.Slug, ref) {
+			return c, true
+		}
+		if byName < 0 && strings.EqualFold(c.Name, ref) {
+			byName = i
+		}
+	}
+	if byName < 0 {
+		return Category{}, false
+	}
+	return list[byName], true
+}
+
+// CategoryDescendants returns the IDs of id and every category below it
+func CategoryDescendants(list []Category, id int) []int {
+	children := map[int][]int{}
+	for _, c := range list {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+	ids := []int{id}
+	seen := map[int]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// Spec field types
+const (
+	SpecText    = "text"
+	SpecNumber  = "number"
+	SpecBoolean = "boolean"
+	SpecEnum    = "enum" // one of SpecField.Options
+)
+
+// IsSpecType reports whether t is one of the Spec* types
+func IsSpecType(t string) bool {
+	return t == SpecText || t == SpecNumber || t == SpecBoolean || t == SpecEnum
+}
+
+// SpecField is one field of a category's specification template. Key is the
+// name used in product requests; Label is what products display (and what
+// product_specifications.spec_key stores).
+type SpecField struct {
+	Key          string   `json:"key"`
+	Label        string   `json:"label"`
+	Unit         string   `json:"unit,omitempty"`
+	Type         string   `json:"type"`
+	Options      []string `json:"options,omitempty"`
+	Required     bool     `json:"required"`
+	DisplayOrder int      `json:"display_order"`
+}
+
+// SpecTemplate is the template in effect for a category. InheritedFrom is
+// set when the fields come from an ancestor category.
+type SpecTemplate struct {
+	CategoryID    int         `json:"category_id"`
+	InheritedFrom *int        `json:"inherited_from,omitempty"`
+	Fields        []SpecField `json:"fields"`
+}
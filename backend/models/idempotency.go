@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey records a request made with an Idempotency-Key header so a
+// retry can be answered with the original response instead of running again
+type IdempotencyKey struct {
+	UserID      int
+	Key         string
+	Fingerprint string // sha256 of method, path and canonical body
+	StatusCode  int    // 0 while the original request is still in flight
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the original response has been stored
+func (k *IdempotencyKey) Completed() bool { return k.StatusCode != 0 }
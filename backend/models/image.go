@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ProductImage is one image of a product's gallery. URL is either a file
+// under /assets/ or an absolute http(s) URL.
+type ProductImage struct {
+	ID           int       `json:"id"`
+	ProductID    int       `json:"product_id"`
+	URL          string    `json:"url"`
+	AltText      string    `json:"alt_text"`
+	IsPrimary    bool      `json:"is_primary"`
+	DisplayOrder int       `json:"display_order"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ProductImageRequest attaches or edits a gallery image. AltText is a pointer
+// so an edit can leave it unchanged.
+type ProductImageRequest struct {
+	URL       string  `json:"url"`
+	AltText   *string `json:"alt_text"`
+	IsPrimary bool    `json:"is_primary"`
+}
+
+// ImageOrderRequest lists every image id of a product in its new order
+type ImageOrderRequest struct {
+	ImageIDs []int `json:"image_ids"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// LoginThrottle counts failed logins for one key ("email:<addr>" or "ip:<addr>")
+type LoginThrottle struct {
+	Key         string
+	Failures    int
+	LockedUntil time.Time // zero when not blocked
+	LastFailure time.Time
+}
+
+// RetryAfter is how long logins for the key stay blocked; 0 means allowed
+func (t *LoginThrottle) RetryAfter(now time.Time) time.Duration {
+	if t == nil || !now.Before(t.LockedUntil) {
+		return 0
+	}
+	return t.LockedUntil.Sub(now)
+}
+
+// LoginPolicy decides how failed logins are throttled. The first FreeAttempts
+// failures cost nothing; each one after that blocks the key for BaseDelay,
+// doubling up to MaxDelay; reaching LockoutAfter failures locks it for
+// LockoutDuration. Counters restart once Window passes without a failure.
+type LoginPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// Fail returns t updated for one more failed attempt at now
+func (p LoginPolicy) Fail(t LoginThrottle, now time.Time) LoginThrottle {
+	if now.Sub(t.LastFailure) > p.Window {
+		t.Failures = 0
+	}
+	t.Failures++
+	t.LastFailure = now
+
+	switch {
+	case t.Failures >= p.LockoutAfter:
+		t.LockedUntil = now.Add(p.LockoutDuration)
+	case t.Failures > p.FreeAttempts:
+		delay := p.MaxDelay
+		if shift := t.Failures - p.FreeAttempts - 1; shift < 30 {
+			if d := p.BaseDelay << shift; d < delay {
+				delay = d
+			}
+		}
+		t.LockedUntil = now.Add(delay)
+	}
+	return t
+}
+
+// Release returns t with one failure taken back, for an attempt that was
+// counted up front but succeeded. The block is lifted once the remaining
+// failures are free again.
+func (p LoginPolicy) Release(t LoginThrottle) LoginThrottle {
+	if t.Failures > 0 {
+		t.Failures--
+	}
+	if t.Failures <= p.FreeAttempts {
+		t.LockedUntil = time.Time{}
+	}
+	return t
+}
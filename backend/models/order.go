@@ -3,26 +3,134 @@ package models
 import "time"
 
 type Order struct {
-	ID        string      `json:"id"`
-	UserID    int         `json:"user_id"`
-	Items     []OrderItem `json:"items,omitempty"`
-	Total     int         `json:"total"`
-	Status    string      `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID          int         `json:"id"`
+	OrderNumber string      `json:"order_number"`
+	UserID      int         `json:"user_id"`
+	Subtotal    int         `json:"subtotal"`
+	Discount    int         `json:"discount"`
+	Total       int         `json:"total"`
+	Status      string      `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Items       []OrderItem `json:"items,omitempty"`
 }
 
 type OrderItem struct {
-	ID        int    `json:"id"`
-	OrderID   string `json:"order_id"`
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
-	Price     int    `json:"price"`
-}
-
-type OrderCreateRequest struct {
-	UserID int `json:"user_id"`
-	Items  []struct {
-		ProductID string `json:"product_id"`
-		Quantity  int    `json:"quantity"`
-	} `json:"items"`
+	ID           int    `json:"id"`
+	OrderID      int    `json:"order_id"`
+	ProductID    int    `json:"product_id"`
+	ProductName  string `json:"product_name"`
+	ProductImage string `json:"product_image"`
+	Quantity     int    `json:"quantity"`
+	Price        int    `json:"price"`
+	Subtotal     int    `json:"subtotal"`
+	// Variant snapshot taken at checkout; empty for products without variants
+	VariantID int             `json:"variant_id,omitempty"`
+	SKU       string          `json:"sku,omitempty"`
+	Options   []VariantOption `json:"options,omitempty"`
+}
+
+// OrderSummary is one row of an order listing with its items collapsed
+type OrderSummary struct {
+	OrderNumber string    `json:"id"`
+	UserID      int       `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	Products    string    `json:"products"`
+	TotalQty    int       `json:"total_qty"`
+	Subtotal    int       `json:"subtotal"`
+	Discount    int       `json:"discount"`
+	Total       int       `json:"total"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	HasReviewed bool      `json:"has_reviewed"`
+}
+
+type CheckoutRequest struct {
+	UserID    int
+	Lines     []CartLine
+	VoucherID int
+}
+
+type CheckoutResult struct {
+	OrderNumber string `json:"order_id"`
+	Total       int    `json:"total"`
+	Discount    int    `json:"discount"`
+}
+
+type MonthlySpending struct {
+	Month      string  `json:"month"`
+	Amount     int     `json:"amount"`
+	Percentage float64 `json:"percentage"`
+}
+
+type CategorySpending struct {
+	Name       string  `json:"name"`
+	Value      int     `json:"value"`
+	Percentage float64 `json:"percentage"`
+}
+
+type OrderStats struct {
+	TotalOrders       int                `json:"total_orders"`
+	PendingOrders     int                `json:"pending_orders"`
+	CompletedOrders   int                `json:"completed_orders"`
+	ThisYearOrders    int                `json:"this_year_orders"`
+	MonthlySpending   []MonthlySpending  `json:"monthly_spending"`
+	CategoryBreakdown []CategorySpending `json:"category_breakdown"`
+}
+
+// Order lifecycle statuses
+const (
+	OrderPending    = "pending"
+	OrderProcessing = "processing"
+	OrderShipped    = "shipped"
+	OrderDelivered  = "delivered"
+	OrderCancelled  = "cancelled"
+)
+
+// orderTransitions lists, for each status, the statuses it may move to and
+// the roles allowed to make that move. Delivered and cancelled are final.
+var orderTransitions = map[string]map[string][]string{
+	OrderPending: {
+		OrderProcessing: {"admin"},
+		OrderCancelled:  {"admin", "customer"},
+	},
+	OrderProcessing: {
+		OrderShipped:   {"admin"},
+		OrderCancelled: {"admin"},
+	},
+	OrderShipped: {
+		OrderDelivered: {"admin"},
+	},
+}
+
+// IsOrderStatus reports whether status is one of the lifecycle statuses
+func IsOrderStatus(status string) bool {
+	switch status {
+	case OrderPending, OrderProcessing, OrderShipped, OrderDelivered, OrderCancelled:
+		return true
+	}
+	return false
+}
+
+// OrderTransition reports whether an order may go from -> to at all (valid)
+// and whether role is allowed to make that move (permitted)
+func OrderTransition(from, to, role string) (valid, permitted bool) {
+	roles, valid := orderTransitions[from][to]
+	for _, r := range roles {
+		if r == role {
+			return true, true
+		}
+	}
+	return valid, false
+}
+
+// OrderStatusChange is one entry of an order's status timeline
+type OrderStatusChange struct {
+	ID         int       `json:"id"`
+	OrderID    int       `json:"order_id"`
+	FromStatus string    `json:"from_status"` // empty for the entry created at checkout
+	ToStatus   string    `json:"to_status"`
+	ChangedBy  int       `json:"changed_by"` // user ID; 0 when unknown
+	ActorRole  string    `json:"actor_role"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
 }
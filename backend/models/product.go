@@ -2,7 +2,11 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -29,17 +33,24 @@ func (d Decimal) Value() (driver.Value, error) {
 	return float64(d), nil
 }
 
-// ProductSpec is a single key-value specification row
+// ProductSpec is a single key-value specification row. Value is the display
+// string; Type, Number and Unit carry the typed value the listing filters on
+// (Number is set for number fields, and 1/0 for booleans).
 type ProductSpec struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key    string   `json:"key"`
+	Value  string   `json:"value"`
+	Type   string   `json:"type,omitempty"`
+	Number *float64 `json:"number,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
 }
 
 type Product struct {
 	ID             int           `json:"id"`
 	Name           string        `json:"name"`
+	Slug           string        `json:"slug,omitempty"`
 	Price          int           `json:"price"`
 	Stock          int           `json:"stock"`
+	Reserved       int           `json:"reserved"` // units held by checkouts in progress
 	Category       string        `json:"category"`
 	Rating         Decimal       `json:"rating"`
 	TotalReviews   int           `json:"total_reviews"`
@@ -48,10 +59,196 @@ type Product struct {
 	Brand          string        `json:"brand,omitempty"`
 	CreatedAt      time.Time     `json:"created_at"`
 	Specifications []ProductSpec `json:"specifications,omitempty"`
+	// Options, Variants and Images are only filled in by GetByID. A product
+	// with variants has the lowest variant price and the total variant stock.
+	Options  []ProductOption  `json:"options,omitempty"`
+	Variants []ProductVariant `json:"variants,omitempty"`
+	Images   []ProductImage   `json:"images,omitempty"`
+	// DeletedAt is set while the product is archived. Archived products stay
+	// resolvable by id for order history and reviews but are not sold.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// MarshalJSON adds available_stock (stock minus reserved) to the product
+func (p Product) MarshalJSON() ([]byte, error) {
+	type product Product
+	return json.Marshal(struct {
+		product
+		AvailableStock int `json:"available_stock"`
+	}{product(p), AvailableStock(p.Stock, p.Reserved)})
+}
+
+// Sort orders accepted by GET /api/products?sort=
+const (
+	SortPriceAsc    = "price_asc"
+	SortPriceDesc   = "price_desc"
+	SortRating      = "rating"
+	SortNewest      = "newest"
+	SortBestSelling = "best_selling"
+)
+
+// IsProductSort reports whether s is one of the Sort* constants
+func IsProductSort(s string) bool {
+	switch s {
+	case SortPriceAsc, SortPriceDesc, SortRating, SortNewest, SortBestSelling:
+		return true
+	}
+	return false
+}
+
+// ProductQuery filters, sorts and pages the product listing.
+// Zero values mean "no filter"; Sort "" keeps the id order.
+type ProductQuery struct {
+	Categories []string // category names, matched case-insensitively
+	Brands     []string
+	MinPrice   int
+	MaxPrice   int  // 0 means no upper bound
+	InStock    bool // available (stock minus reserved) > 0
+	MinRating  float64
+	Specs      []SpecFilter // all must match
+	Archived   bool         // list archived products instead of the catalog
+	Sort       string
+	Limit      int
+	Offset     int
+}
+
+// Spec filter operators
+const (
+	SpecEq  = "="
+	SpecGt  = ">"
+	SpecGte = ">="
+	SpecLt  = "<"
+	SpecLte = "<="
+)
+
+// SpecFilter is one spec[Key]<op><value> listing filter. Key is the spec
+// label, matched case-insensitively. SpecEq matches any of Values (booleans
+// as Yes/No, numbers also by their numeric value); the other operators
+// compare Number with the spec's numeric value.
+type SpecFilter struct {
+	Key    string
+	Op     string
+	Values []string
+	Number float64
+}
+
+// SpecFacet lists the values one spec takes among the listed products of
+// one category. A spec label shared by several categories gets one facet per
+// category, since its values (and type) only compare within a template.
+type SpecFacet struct {
+	Category string           `json:"category"`
+	Key      string           `json:"key"`
+	Type     string           `json:"type"`
+	Unit     string           `json:"unit,omitempty"`
+	Min      *float64         `json:"min,omitempty"`
+	Max      *float64         `json:"max,omitempty"`
+	Values   []SpecFacetValue `json:"values"`
+}
+
+// SpecFacetValue is one value of a SpecFacet and how many products have it
+type SpecFacetValue struct {
+	Value  string   `json:"value"`
+	Number *float64 `json:"number,omitempty"`
+	Count  int      `json:"count"`
+}
+
+// Numbers returns the filter values that parse as numbers
+func (f SpecFilter) Numbers() []float64 {
+	var numbers []float64
+	for _, v := range f.Values {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// Matches reports whether spec row s satisfies the filter; the caller has
+// already matched the key
+func (f SpecFilter) Matches(s ProductSpec) bool {
+	numeric := s.Type == SpecNumber && s.Number != nil
+	switch f.Op {
+	case SpecEq:
+		for _, v := range f.Values {
+			if strings.EqualFold(v, s.Value) {
+				return true
+			}
+		}
+		if numeric {
+			for _, n := range f.Numbers() {
+				if n == *s.Number {
+					return true
+				}
+			}
+		}
+		return false
+	case SpecGt:
+		return numeric && *s.Number > f.Number
+	case SpecGte:
+		return numeric && *s.Number >= f.Number
+	case SpecLt:
+		return numeric && *s.Number < f.Number
+	case SpecLte:
+		return numeric && *s.Number <= f.Number
+	}
+	return false
+}
+
+// AddSpecFacetValue counts count products of category having spec s. Facets
+// keep the order in which their category and key are first added; a key
+// whose rows disagree on the type becomes a text facet.
+func AddSpecFacetValue(facets []SpecFacet, category string, s ProductSpec, count int) []SpecFacet {
+	i := 0
+	for i < len(facets) && !(facets[i].Category == category && strings.EqualFold(facets[i].Key, s.Key)) {
+		i++
+	}
+	if i == len(facets) {
+		facets = append(facets, SpecFacet{Category: category, Key: s.Key, Type: s.Type, Unit: s.Unit})
+	} else if facets[i].Type != s.Type {
+		facets[i].Type = SpecText
+	}
+	f := &facets[i]
+	for j := range f.Values {
+		if f.Values[j].Value == s.Value {
+			f.Values[j].Count += count
+			return facets
+		}
+	}
+	f.Values = append(f.Values, SpecFacetValue{Value: s.Value, Number: s.Number, Count: count})
+	return facets
+}
+
+// FinishSpecFacets groups the facets by category name, orders each facet's
+// values (numbers ascending, others by count) and sets the range of number
+// facets
+func FinishSpecFacets(facets []SpecFacet) {
+	sort.SliceStable(facets, func(a, b int) bool { return facets[a].Category < facets[b].Category })
+	for i := range facets {
+		f := &facets[i]
+		for _, v := range f.Values {
+			if f.Type == SpecNumber && v.Number == nil {
+				f.Type = SpecText
+			}
+		}
+		if f.Type != SpecNumber {
+			sort.SliceStable(f.Values, func(a, b int) bool {
+				if f.Values[a].Count != f.Values[b].Count {
+					return f.Values[a].Count > f.Values[b].Count
+				}
+				return f.Values[a].Value < f.Values[b].Value
+			})
+			continue
+		}
+		sort.SliceStable(f.Values, func(a, b int) bool { return *f.Values[a].Number < *f.Values[b].Number })
+		lo, hi := *f.Values[0].Number, *f.Values[len(f.Values)-1].Number
+		f.Min, f.Max = &lo, &hi
+	}
 }
 
 type ProductCreateRequest struct {
-	Name        string  `json:"name"`
+	Name string `json:"name"`
+	// Slug overrides the slug derived from the name
+	Slug        string  `json:"slug"`
 	Price       int     `json:"price"`
 	Stock       int     `json:"stock"`
 	Category    string  `json:"category"`
@@ -59,6 +256,10 @@ type ProductCreateRequest struct {
 	Description string  `json:"description"`
 	Image       string  `json:"image"`
 	Brand       string  `json:"brand"`
+	// Specs holds the spec values keyed by the category's template keys,
+	// e.g. {"ram": 12, "chipset": "Snapdragon 8 Gen 3"}
+	Specs map[string]interface{} `json:"specs"`
+	// Legacy flat spec fields, used when Specs is absent.
 	// Smartphone specs
 	Chipset          string  `json:"chipset"`
 	RamGB            int     `json:"ram_gb"`
@@ -0,0 +1,55 @@
+package models
+
+// ProductImportRow is one product of a bulk import or export. Rows are
+// matched to existing products by current or former slug, then by any of
+// their variant SKUs.
+// Specs is keyed by the category's template keys like
+// ProductCreateRequest.Specs; a nil Specs keeps an existing product's
+// specifications.
+type ProductImportRow struct {
+	Slug        string                 `json:"slug"`
+	Name        string                 `json:"name"`
+	Category    string                 `json:"category"`
+	Brand       string                 `json:"brand"`
+	Price       int                    `json:"price"`
+	Stock       int                    `json:"stock"`
+	Description string                 `json:"description"`
+	Image       string                 `json:"image"`
+	SKUs        []string               `json:"skus,omitempty"`
+	Specs       map[string]interface{} `json:"specs,omitempty"`
+}
+
+// Import row actions
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+)
+
+// ProductImportItem is one product saved by an import. A zero Product.ID
+// creates the product, any other updates it; Specs replace its
+// specifications when ReplaceSpecs is set.
+type ProductImportItem struct {
+	Product      Product
+	Specs        []ProductSpec
+	ReplaceSpecs bool
+}
+
+// ProductImportResult reports what the import does (or would do) with one row.
+// Row is the CSV line number or the 1-based index in a JSON array.
+type ProductImportResult struct {
+	Row       int      `json:"row"`
+	Slug      string   `json:"slug,omitempty"`
+	Action    string   `json:"action,omitempty"`
+	ProductID int      `json:"product_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ProductImportReport is the response of POST /api/admin/products/import
+type ProductImportReport struct {
+	DryRun  bool                  `json:"dry_run"`
+	Total   int                   `json:"total"`
+	Created int                   `json:"created"`
+	Updated int                   `json:"updated"`
+	Failed  int                   `json:"failed"`
+	Rows    []ProductImportResult `json:"rows"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// StockReservation holds Quantity units of a cart line for a user between
+// checkout start and payment. Held units count in the product's (and
+// variant's) Reserved until the checkout completes, the user cancels or
+// ExpiresAt passes and the sweeper releases them.
+type StockReservation struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	ProductID int       `json:"product_id"`
+	VariantID int       `json:"variant_id"` // 0 for products without variants
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Line returns the cart line the reservation holds stock for
+func (s StockReservation) Line() CartLine {
+	return CartLine{ProductID: s.ProductID, VariantID: s.VariantID}
+}
+
+// AvailableStock is stock minus reserved units, never below zero
+func AvailableStock(stock, reserved int) int {
+	if stock-reserved < 0 {
+		return 0
+	}
+	return stock - reserved
+}
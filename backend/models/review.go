@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Review struct {
+	ID         int       `json:"id"`
+	ProductID  int       `json:"product_id"`
+	UserID     int       `json:"user_id"`
+	OrderID    int       `json:"order_id"`
+	UserName   string    `json:"user_name"`
+	Rating     int       `json:"rating"`
+	ReviewText string    `json:"review_text"`
+	IsVerified bool      `json:"is_verified"`
+	CreatedAt  time.Time `json:"created_at"`
+}
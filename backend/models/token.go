@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// RefreshToken is a server-side refresh token record. Only the SHA-256 of the
+// opaque token is stored. Tokens rotate on every use; all tokens descending
+// from one login share a FamilyID so a replayed token can revoke the chain.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	FamilyID  string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *RefreshToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// Action token purposes
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// ActionToken is a single-use token mailed to the user to verify an email
+// address or reset a password. Only the SHA-256 of the token is stored.
+// Email is the address the token was sent to.
+type ActionToken struct {
+	ID        int
+	UserID    int
+	Purpose   string
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
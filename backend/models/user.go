@@ -3,17 +3,18 @@ package models
 import "time"
 
 type User struct {
-	ID         int       `json:"id"`
-	FullName   string    `json:"full_name"`
-	Email      string    `json:"email"`
-	Phone      string    `json:"phone,omitempty"`
-	Password   string    `json:"password,omitempty"`
-	AvatarURL  string    `json:"avatar_url,omitempty"`
-	Balance    int       `json:"balance"`
-	IsMember   bool      `json:"is_member"`
-	TotalSpent int       `json:"total_spent"`
-	Role       string    `json:"role"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	FullName      string    `json:"full_name"`
+	Email         string    `json:"email"`
+	Phone         string    `json:"phone,omitempty"`
+	Password      string    `json:"password,omitempty"`
+	AvatarURL     string    `json:"avatar_url,omitempty"`
+	Balance       int       `json:"balance"`
+	IsMember      bool      `json:"is_member"`
+	TotalSpent    int       `json:"total_spent"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type UserCreateRequest struct {
@@ -26,6 +27,6 @@ type UserCreateRequest struct {
 
 type UserUpdateRequest struct {
 	FullName string `json:"full_name,omitempty"`
-	Balance  int    `json:"balance,omitempty"`
+	Balance  *int   `json:"balance,omitempty"` // set to adjust the wallet to this amount
 	IsMember bool   `json:"is_member,omitempty"`
 }
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ProductOption is an option type of a product, e.g. Color with the values
+// Black and White. Values are listed in display order.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// VariantOption is the value a variant has for one option type
+type VariantOption struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ProductVariant is one purchasable combination of option values with its
+// own SKU, price, stock and (optional) image
+type ProductVariant struct {
+	ID        int             `json:"id"`
+	ProductID int             `json:"product_id"`
+	SKU       string          `json:"sku"`
+	Options   []VariantOption `json:"options"`
+	Price     int             `json:"price"`
+	Stock     int             `json:"stock"`
+	Reserved  int             `json:"reserved"`
+	Image     string          `json:"image,omitempty"`
+}
+
+// MarshalJSON adds available_stock (stock minus reserved) to the variant
+func (v ProductVariant) MarshalJSON() ([]byte, error) {
+	type variant ProductVariant
+	return json.Marshal(struct {
+		variant
+		AvailableStock int `json:"available_stock"`
+	}{variant(v), AvailableStock(v.Stock, v.Reserved)})
+}
+
+// Label describes the variant's options, e.g. "Black / 256 GB"
+func (v ProductVariant) Label() string {
+	values := make([]string, len(v.Options))
+	for i, o := range v.Options {
+		values[i] = o.Value
+	}
+	return strings.Join(values, " / ")
+}
+
+// ProductVariantsRequest is the body of PUT /api/products/{id}/variants.
+// Each variant names its value for every option, e.g.
+// {"sku": "IP15PM-BLK-256", "options": {"Color": "Black", "Storage": "256 GB"}, "price": 21999000, "stock": 5}.
+type ProductVariantsRequest struct {
+	Options  []ProductOption  `json:"options"`
+	Variants []VariantRequest `json:"variants"`
+}
+
+// VariantRequest is one variant of a ProductVariantsRequest
+type VariantRequest struct {
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	Price   int               `json:"price"`
+	Stock   int               `json:"stock"`
+	Image   string            `json:"image"`
+}
+
+// VariantTotals returns the lowest price and the total stock of variants,
+// which products with variants store as their own price and stock
+func VariantTotals(variants []ProductVariant) (price, stock int) {
+	for i, v := range variants {
+		if i == 0 || v.Price < price {
+			price = v.Price
+		}
+		stock += v.Stock
+	}
+	return price, stock
+}
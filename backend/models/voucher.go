@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type Voucher struct {
+	ID            int       `json:"id"`
+	Code          string    `json:"code"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Type          string    `json:"type"`
+	DiscountValue float64   `json:"discount_value"`
+	MinPurchase   float64   `json:"min_purchase"`
+	MaxDiscount   float64   `json:"max_discount"`
+	UsageLimit    int       `json:"usage_limit"`
+	UsedCount     int       `json:"used_count"`
+	ValidFrom     time.Time `json:"valid_from"`
+	ValidUntil    time.Time `json:"valid_until"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+}
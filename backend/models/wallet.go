@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Wallet transaction types
+const (
+	WalletTopUp      = "topup"
+	WalletPurchase   = "purchase"
+	WalletRefund     = "refund"
+	WalletAdjustment = "adjustment"
+)
+
+// Counter accounts: every wallet transaction moves Amount between the user's
+// wallet and one of these, so the two legs of each entry always balance
+const (
+	AccountTopUps      = "external:topup"
+	AccountSales       = "store:sales"
+	AccountAdjustments = "store:adjustments"
+)
+
+// AccountWallets groups every user wallet account in a trial balance
+const AccountWallets = "user:*"
+
+// UserAccount is the ledger account of a user's wallet
+func UserAccount(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// CounterAccount returns the account on the other side of a transaction type
+func CounterAccount(txType string) string {
+	switch txType {
+	case WalletTopUp:
+		return AccountTopUps
+	case WalletPurchase, WalletRefund:
+		return AccountSales
+	default:
+		return AccountAdjustments
+	}
+}
+
+// WalletTransaction is one ledger transaction, posted as two LedgerEntry legs.
+// Amount is signed from the wallet's point of view: credits (top-up, refund)
+// are positive, debits negative.
+type WalletTransaction struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Type           string    `json:"type"`
+	Amount         int       `json:"amount"`
+	BalanceAfter   int       `json:"balance_after"`
+	CounterAccount string    `json:"counter_account"`
+	OrderID        int       `json:"order_id,omitempty"`
+	Reference      string    `json:"reference"` // order number or top-up reference
+	Description    string    `json:"description"`
+	CreatedBy      int       `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LedgerEntry is one leg of a wallet transaction. Each transaction posts
+// Amount to the user's wallet account and -Amount to its counter account.
+type LedgerEntry struct {
+	TransactionID int    `json:"transaction_id"`
+	Account       string `json:"account"`
+	Amount        int    `json:"amount"`
+}
+
+// AccountBalance is the sum of one account's ledger legs; user wallets are
+// summed together as AccountWallets
+type AccountBalance struct {
+	Account string `json:"account"`
+	Balance int    `json:"balance"`
+}
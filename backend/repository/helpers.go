@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// VoucherDiscount computes the discount a voucher of discountType grants on subtotal
+func VoucherDiscount(discountType string, value, maxDiscount, subtotal int) int {
+	discount := 0
+	switch discountType {
+	case "percentage":
+		discount = subtotal * value / 100
+		if maxDiscount > 0 && discount > maxDiscount {
+			discount = maxDiscount
+		}
+	case "fixed_amount":
+		discount = value
+	}
+	return discount
+}
+
+// FillPercentages computes the chart percentages of s (monthly relative to the
+// best month, categories relative to their sum) and replaces nil slices with empty ones
+func FillPercentages(s *models.OrderStats) {
+	maxAmount := 0
+	for _, m := range s.MonthlySpending {
+		if m.Amount > maxAmount {
+			maxAmount = m.Amount
+		}
+	}
+	for i := range s.MonthlySpending {
+		if maxAmount > 0 {
+			s.MonthlySpending[i].Percentage = float64(s.MonthlySpending[i].Amount) / float64(maxAmount) * 100
+		}
+	}
+
+	totalCat := 0
+	for _, c := range s.CategoryBreakdown {
+		totalCat += c.Value
+	}
+	for i := range s.CategoryBreakdown {
+		if totalCat > 0 {
+			s.CategoryBreakdown[i].Percentage = float64(s.CategoryBreakdown[i].Value) / float64(totalCat) * 100
+		}
+	}
+
+	if s.MonthlySpending == nil {
+		s.MonthlySpending = []models.MonthlySpending{}
+	}
+	if s.CategoryBreakdown == nil {
+		s.CategoryBreakdown = []models.CategorySpending{}
+	}
+}
+
+// SortedUniqueLines returns lines sorted by product then variant without
+// duplicates. Checkout locks product and variant rows in this order so
+// concurrent transactions cannot deadlock.
+func SortedUniqueLines(lines []models.CartLine) []models.CartLine {
+	out := make([]models.CartLine, 0, len(lines))
+	seen := make(map[models.CartLine]bool, len(lines))
+	for _, l := range lines {
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ProductID != out[j].ProductID {
+			return out[i].ProductID < out[j].ProductID
+		}
+		return out[i].VariantID < out[j].VariantID
+	})
+	return out
+}
+
+// LineRef names a cart line in errors: "12", or "12 (variant 34)"
+func LineRef(l models.CartLine) string {
+	if l.VariantID == 0 {
+		return strconv.Itoa(l.ProductID)
+	}
+	return fmt.Sprintf("%d (variant %d)", l.ProductID, l.VariantID)
+}
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type cartRepo struct{ *db }
+
+func (r *cartRepo) List(ctx context.Context, userID int) ([]models.CartItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var items []models.CartItem
+	for _, item := range r.carts {
+		if p, ok := r.products[item.ProductID]; item.UserID == userID && ok && p.DeletedAt == nil {
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+	return items, nil
+}
+
+func (r *cartRepo) Add(ctx context.Context, userID int, line models.CartLine, quantity int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := cartKey{userID, line}
+	if item, ok := r.carts[key]; ok {
+		item.Quantity += quantity
+		return nil
+	}
+	r.carts[key] = &models.CartItem{
+		ID:        r.newID("cart_items"),
+		UserID:    userID,
+		ProductID: line.ProductID,
+		VariantID: line.VariantID,
+		Quantity:  quantity,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (r *cartRepo) UpdateQuantity(ctx context.Context, userID int, line models.CartLine, quantity int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.carts[cartKey{userID, line}]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	item.Quantity = quantity
+	return nil
+}
+
+func (r *cartRepo) Remove(ctx context.Context, userID int, line models.CartLine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := cartKey{userID, line}
+	if _, ok := r.carts[key]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.carts, key)
+	return nil
+}
@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type categoryRepo struct{ *db }
+
+// category returns a copy of the row with its product count; callers hold d.mu
+func (d *db) category(c *models.Category) models.Category {
+	cp := *c
+	cp.Children = nil
+	cp.ProductCount = 0
+	for _, p := range d.products {
+		if p.Category == c.Name && p.DeletedAt == nil {
+			cp.ProductCount++
+		}
+	}
+	return cp
+}
+
+// categoryTaken reports whether another category already uses name or slug; callers hold d.mu
+func (d *db) categoryTaken(c *models.Category) bool {
+	for _, existing := range d.categories {
+		if existing.ID != c.ID && (strings.EqualFold(existing.Name, c.Name) || strings.EqualFold(existing.Slug, c.Slug)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *categoryRepo) List(ctx context.Context) ([]models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	categories := make([]models.Category, 0, len(r.categories))
+	for _, c := range r.categories {
+		categories = append(categories, r.category(c))
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].SortOrder != categories[j].SortOrder {
+			return categories[i].SortOrder < categories[j].SortOrder
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.categories[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := r.category(c)
+	return &cp, nil
+}
+
+func (r *categoryRepo) Find(ctx context.Context, ref string) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var byName *models.Category
+	for _, c := range r.categories {
+		if strings.EqualFold(c.Slug, ref) {
+			cp := r.category(c)
+			return &cp, nil
+		}
+		if strings.EqualFold(c.Name, ref) {
+			byName = c
+		}
+	}
+	if byName == nil {
+		return nil, repository.ErrNotFound
+	}
+	cp := r.category(byName)
+	return &cp, nil
+}
+
+func (r *categoryRepo) Create(ctx context.Context, c *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.categoryTaken(c) {
+		return repository.ErrDuplicate
+	}
+	cp := *c
+	cp.ID = r.newID("categories")
+	cp.CreatedAt = time.Now()
+	cp.Children = nil
+	r.categories[cp.ID] = &cp
+	c.ID = cp.ID
+	return nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, c *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.categories[c.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if r.categoryTaken(c) {
+		return repository.ErrDuplicate
+	}
+	// Products reference their category by name here, so carry a rename over
+	if existing.Name != c.Name {
+		for _, p := range r.products {
+			if p.Category == existing.Name {
+				p.Category = c.Name
+			}
+		}
+	}
+	existing.ParentID = c.ParentID
+	existing.Name = c.Name
+	existing.Slug = c.Slug
+	existing.Description = c.Description
+	existing.Image = c.Image
+	existing.SortOrder = c.SortOrder
+	return nil
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.categories[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for _, other := range r.categories {
+		if other.ParentID != nil && *other.ParentID == id {
+			return repository.ErrCategoryInUse
+		}
+	}
+	for _, p := range r.products {
+		if p.Category == c.Name {
+			return repository.ErrCategoryInUse
+		}
+	}
+	delete(r.categories, id)
+	delete(r.specFields, id)
+	return nil
+}
+
+// defaultSpecFields mirrors the templates seeded by migration 0009
+var defaultSpecFields = map[string][]models.SpecField{
+	"Smartphones": {
+		{Key: "chipset", Label: "Chipset", Type: models.SpecText, DisplayOrder: 1},
+		{Key: "ram", Label: "RAM", Unit: "GB", Type: models.SpecNumber, DisplayOrder: 2},
+		{Key: "rom", Label: "ROM", Unit: "GB", Type: models.SpecNumber, DisplayOrder: 3},
+		{Key: "display", Label: "Display", Unit: "inch", Type: models.SpecNumber, DisplayOrder: 4},
+		{Key: "refresh_rate", Label: "Refresh Rate", Unit: "Hz", Type: models.SpecNumber, DisplayOrder: 5},
+		{Key: "battery", Label: "Battery", Unit: "mAh", Type: models.SpecNumber, DisplayOrder: 6},
+		{Key: "charging", Label: "Charging", Type: models.SpecText, DisplayOrder: 7},
+		{Key: "camera", Label: "Camera", Type: models.SpecText, DisplayOrder: 8},
+		{Key: "os", Label: "Operating System", Type: models.SpecText, DisplayOrder: 9},
+		{Key: "connectivity", Label: "Connectivity", Type: models.SpecText, DisplayOrder: 10},
+	},
+	"Laptops": {
+		{Key: "chipset", Label: "Chipset", Type: models.SpecText, DisplayOrder: 1},
+		{Key: "ram", Label: "RAM", Unit: "GB", Type: models.SpecNumber, DisplayOrder: 2},
+		{Key: "ram_type", Label: "RAM Type", Type: models.SpecText, DisplayOrder: 3},
+		{Key: "rom", Label: "ROM", Unit: "GB", Type: models.SpecNumber, DisplayOrder: 4},
+		{Key: "storage_type", Label: "Storage Type", Type: models.SpecText, DisplayOrder: 5},
+		{Key: "display", Label: "Display", Type: models.SpecText, DisplayOrder: 6},
+		{Key: "gpu", Label: "GPU", Type: models.SpecText, DisplayOrder: 7},
+		{Key: "battery", Label: "Battery", Unit: "Wh", Type: models.SpecNumber, DisplayOrder: 8},
+		{Key: "os", Label: "Operating System", Type: models.SpecText, DisplayOrder: 9},
+		{Key: "connectivity", Label: "Connectivity", Type: models.SpecText, DisplayOrder: 10},
+	},
+	"Audio": {
+		{Key: "frequency_response", Label: "Respon Frekuensi", Type: models.SpecText, DisplayOrder: 1},
+		{Key: "impedance", Label: "Impedansi", Unit: "Ω", Type: models.SpecNumber, DisplayOrder: 2},
+		{Key: "sensitivity", Label: "Sensitivitas", Unit: "dB", Type: models.SpecNumber, DisplayOrder: 3},
+		{Key: "driver_size", Label: "Ukuran Driver", Unit: "mm", Type: models.SpecNumber, DisplayOrder: 4},
+	},
+}
+
+func (r *categoryRepo) SpecFields(ctx context.Context, categoryID int) ([]models.SpecField, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]models.SpecField{}, r.specFields[categoryID]...), nil
+}
+
+func (r *categoryRepo) ReplaceSpecFields(ctx context.Context, categoryID int, fields []models.SpecField) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if seen[f.Key] {
+			return repository.ErrDuplicate
+		}
+		seen[f.Key] = true
+	}
+	sorted := append([]models.SpecField(nil), fields...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].DisplayOrder < sorted[j].DisplayOrder })
+	r.specFields[categoryID] = sorted
+	return nil
+}
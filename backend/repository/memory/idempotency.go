@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type idempotencyRepo struct{ *db }
+
+func (r *idempotencyRepo) Reserve(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyID{k.UserID, k.Key}
+	if existing, ok := r.idempotency[id]; ok && existing.ExpiresAt.After(k.CreatedAt) {
+		cp := *existing
+		return &cp, nil
+	}
+	cp := *k
+	cp.StatusCode = 0
+	cp.Body = nil
+	r.idempotency[id] = &cp
+	return nil, nil
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, userID int, key string, statusCode int, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.idempotency[idempotencyID{userID, key}]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	k.StatusCode = statusCode
+	k.Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (r *idempotencyRepo) Release(ctx context.Context, userID int, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.idempotency, idempotencyID{userID, key})
+	return nil
+}
+
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for id, k := range r.idempotency {
+		if !k.ExpiresAt.After(now) {
+			delete(r.idempotency, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type imageRepo struct{ *db }
+
+// setPrimary makes the image at index i the product's primary image and
+// mirrors its URL into the product; callers hold d.mu
+func (d *db) setPrimary(productID, i int) {
+	images := d.images[productID]
+	for j := range images {
+		images[j].IsPrimary = j == i
+	}
+	if p, ok := d.products[productID]; ok {
+		p.Image = images[i].URL
+	}
+}
+
+// imageIndex returns the position of the image in its product's gallery, or -1; callers hold d.mu
+func (d *db) imageIndex(productID, imageID int) int {
+	for i, img := range d.images[productID] {
+		if img.ID == imageID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *imageRepo) List(ctx context.Context, productID int) ([]models.ProductImage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[productID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	return append([]models.ProductImage{}, r.images[productID]...), nil
+}
+
+func (r *imageRepo) Add(ctx context.Context, img *models.ProductImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[img.ProductID]; !ok {
+		return repository.ErrNotFound
+	}
+	images := r.images[img.ProductID]
+	img.ID = r.newID("product_images")
+	img.DisplayOrder = len(images) + 1
+	img.CreatedAt = time.Now()
+	primary := img.IsPrimary || len(images) == 0
+	img.IsPrimary = false
+	r.images[img.ProductID] = append(images, *img)
+	if primary {
+		r.setPrimary(img.ProductID, len(images))
+		img.IsPrimary = true
+	}
+	return nil
+}
+
+func (r *imageRepo) Update(ctx context.Context, img *models.ProductImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.imageIndex(img.ProductID, img.ID)
+	if i < 0 {
+		return repository.ErrNotFound
+	}
+	r.images[img.ProductID][i].AltText = img.AltText
+	if img.IsPrimary {
+		r.setPrimary(img.ProductID, i)
+	}
+	*img = r.images[img.ProductID][i]
+	return nil
+}
+
+func (r *imageRepo) Reorder(ctx context.Context, productID int, ids []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[productID]; !ok {
+		return repository.ErrNotFound
+	}
+	images := r.images[productID]
+	if len(ids) != len(images) {
+		return repository.ErrImageOrder
+	}
+	ordered := make([]models.ProductImage, 0, len(ids))
+	seen := map[int]bool{}
+	for n, id := range ids {
+		i := r.imageIndex(productID, id)
+		if i < 0 || seen[id] {
+			return repository.ErrImageOrder
+		}
+		seen[id] = true
+		img := images[i]
+		img.DisplayOrder = n + 1
+		ordered = append(ordered, img)
+	}
+	r.images[productID] = ordered
+	return nil
+}
+
+func (r *imageRepo) Delete(ctx context.Context, productID, imageID int) (*models.ProductImage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.imageIndex(productID, imageID)
+	if i < 0 {
+		return nil, repository.ErrNotFound
+	}
+	images := r.images[productID]
+	removed := images[i]
+	images = append(images[:i:i], images[i+1:]...)
+	for n := range images {
+		images[n].DisplayOrder = n + 1
+	}
+	r.images[productID] = images
+	if removed.IsPrimary {
+		if len(images) > 0 {
+			r.setPrimary(productID, 0)
+		} else if p, ok := r.products[productID]; ok {
+			p.Image = ""
+		}
+	}
+	return &removed, nil
+}
+
+func (r *imageRepo) ReferencedURLs(ctx context.Context) (map[string]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	urls := map[string]bool{}
+	for id, p := range r.products {
+		urls[p.Image] = true
+		for _, img := range r.images[id] {
+			urls[img.URL] = true
+		}
+		for _, v := range r.variants[id] {
+			urls[v.Image] = true
+		}
+	}
+	for _, c := range r.categories {
+		urls[c.Image] = true
+	}
+	for _, u := range r.users {
+		urls[u.AvatarURL] = true
+	}
+	for _, o := range r.orders {
+		for _, item := range o.Items {
+			urls[item.ProductImage] = true
+		}
+	}
+	delete(urls, "")
+	return urls, nil
+}
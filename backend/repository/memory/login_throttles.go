@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type loginThrottleRepo struct{ *db }
+
+// staleThrottleAge is how long counters without new failures are kept
+const staleThrottleAge = 24 * time.Hour
+
+func (r *loginThrottleRepo) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.loginThrottles[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *loginThrottleRepo) Reserve(ctx context.Context, key string, policy models.LoginPolicy, now time.Time) (*models.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := models.LoginThrottle{Key: key}
+	if t, ok := r.loginThrottles[key]; ok {
+		current = *t
+	}
+	if current.RetryAfter(now) > 0 {
+		return &current, repository.ErrLoginThrottled
+	}
+	next := policy.Fail(current, now)
+	r.loginThrottles[key] = &next
+	cp := next
+	return &cp, nil
+}
+
+func (r *loginThrottleRepo) Release(ctx context.Context, key string, policy models.LoginPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.loginThrottles[key]; ok {
+		next := policy.Release(*t)
+		r.loginThrottles[key] = &next
+	}
+	return nil
+}
+
+func (r *loginThrottleRepo) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.loginThrottles, key)
+	return nil
+}
+
+func (r *loginThrottleRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for k, t := range r.loginThrottles {
+		if now.Sub(t.LastFailure) > staleThrottleAge {
+			delete(r.loginThrottles, k)
+			n++
+		}
+	}
+	return n, nil
+}
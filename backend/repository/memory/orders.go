@@ -0,0 +1,342 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type orderRepo struct{ *db }
+
+func (r *orderRepo) Checkout(ctx context.Context, req models.CheckoutRequest) (*models.CheckoutResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[req.UserID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	var items []models.OrderItem
+	subtotal := 0
+	for _, l := range repository.SortedUniqueLines(req.Lines) {
+		line, ok := r.carts[cartKey{req.UserID, l}]
+		if !ok {
+			return nil, &repository.ItemError{Err: repository.ErrNotInCart, Item: repository.LineRef(l)}
+		}
+		p, ok := r.products[l.ProductID]
+		if !ok || p.DeletedAt != nil {
+			return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+		}
+		item := models.OrderItem{
+			ProductID:    l.ProductID,
+			ProductName:  p.Name,
+			ProductImage: p.Image,
+			Quantity:     line.Quantity,
+			Price:        p.Price,
+		}
+		stock, reserved := p.Stock, p.Reserved
+		if l.VariantID != 0 {
+			v := r.variant(l.ProductID, l.VariantID)
+			if v == nil {
+				return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+			}
+			item.VariantID, item.SKU, item.Price, stock, reserved = v.ID, v.SKU, v.Price, v.Stock, v.Reserved
+			item.Options = append([]models.VariantOption(nil), v.Options...)
+			if v.Image != "" {
+				item.ProductImage = v.Image
+			}
+		}
+		// The buyer's own unexpired hold counts as available to them
+		if h, ok := r.holds[cartKey{req.UserID, l}]; ok && h.ExpiresAt.After(time.Now()) {
+			reserved -= h.Quantity
+		}
+		if models.AvailableStock(stock, reserved) < line.Quantity {
+			return nil, &repository.ItemError{Err: repository.ErrInsufficientStock, Item: item.ProductName}
+		}
+		item.Subtotal = item.Price * item.Quantity
+		subtotal += item.Subtotal
+		items = append(items, item)
+	}
+
+	discount := 0
+	var voucher *models.Voucher
+	if v, ok := r.vouchers[req.VoucherID]; ok {
+		if v.IsActive && v.ValidUntil.After(time.Now()) && (v.UsageLimit == 0 || v.UsedCount < v.UsageLimit) && subtotal >= int(v.MinPurchase) {
+			discount = repository.VoucherDiscount(v.Type, int(v.DiscountValue), int(v.MaxDiscount), subtotal)
+			voucher = v
+		}
+	}
+
+	total := subtotal - discount
+	if total < 0 {
+		total = 0
+	}
+	if user.Balance < total {
+		return nil, repository.ErrInsufficientBalance
+	}
+
+	// Every check passed — apply all writes
+	if voucher != nil {
+		voucher.UsedCount++
+	}
+	user.TotalSpent += total
+	buying := map[models.CartLine]bool{}
+	for _, l := range req.Lines {
+		buying[l] = true
+	}
+	r.releaseHolds(req.UserID, func(h models.StockReservation) bool { return buying[h.Line()] })
+
+	order := &models.Order{
+		ID:          r.newID("orders"),
+		OrderNumber: fmt.Sprintf("ORD-%d-%d", req.UserID, time.Now().UnixMilli()),
+		UserID:      req.UserID,
+		Subtotal:    subtotal,
+		Discount:    discount,
+		Total:       total,
+		Status:      models.OrderPending,
+		CreatedAt:   time.Now(),
+	}
+	for _, item := range items {
+		item.ID = r.newID("order_items")
+		item.OrderID = order.ID
+		order.Items = append(order.Items, item)
+		r.products[item.ProductID].Stock -= item.Quantity
+		if v := r.variant(item.ProductID, item.VariantID); v != nil {
+			v.Stock -= item.Quantity
+		}
+		delete(r.carts, cartKey{req.UserID, models.CartLine{ProductID: item.ProductID, VariantID: item.VariantID}})
+	}
+	r.orders[order.ID] = order
+	r.recordStatusChange(models.OrderStatusChange{
+		OrderID:   order.ID,
+		ToStatus:  models.OrderPending,
+		ChangedBy: req.UserID,
+		Note:      "Order placed",
+	})
+	if total > 0 {
+		r.applyWalletEntry(&models.WalletTransaction{
+			UserID:      req.UserID,
+			Type:        models.WalletPurchase,
+			Amount:      -total,
+			OrderID:     order.ID,
+			Reference:   order.OrderNumber,
+			Description: "Payment for order " + order.OrderNumber,
+			CreatedBy:   req.UserID,
+		})
+	}
+
+	return &models.CheckoutResult{OrderNumber: order.OrderNumber, Total: total, Discount: discount}, nil
+}
+
+// hasReviewed reports whether the order's owner reviewed anything from it; callers hold d.mu
+func (d *db) hasReviewed(o *models.Order) bool {
+	for key := range d.reviews {
+		if key.orderID == o.ID && key.userID == o.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *orderRepo) ListByUser(ctx context.Context, userID int, limit int) ([]models.OrderSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orders []*models.Order
+	for _, o := range r.orders {
+		if (userID == 0 || o.UserID == userID) && len(o.Items) > 0 {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID > orders[j].ID })
+	if limit > 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+
+	summaries := make([]models.OrderSummary, 0, len(orders))
+	for _, o := range orders {
+		s := models.OrderSummary{
+			OrderNumber: o.OrderNumber,
+			UserID:      o.UserID,
+			Subtotal:    o.Subtotal,
+			Discount:    o.Discount,
+			Total:       o.Total,
+			Status:      o.Status,
+			CreatedAt:   o.CreatedAt,
+			HasReviewed: r.hasReviewed(o),
+		}
+		if u, ok := r.users[o.UserID]; ok {
+			s.UserName = u.FullName
+		}
+		var names []string
+		for _, item := range o.Items {
+			names = append(names, item.ProductName)
+			s.TotalQty += item.Quantity
+		}
+		s.Products = strings.Join(names, ", ")
+		summaries = append(summaries, s)
+	}
+	return summaries, nil
+}
+
+func (r *orderRepo) Get(ctx context.Context, orderNumber string) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, o := range r.orders {
+		if o.OrderNumber == orderNumber {
+			cp := *o
+			cp.Items = append([]models.OrderItem(nil), o.Items...)
+			return &cp, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *orderRepo) Transition(ctx context.Context, change models.OrderStatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[change.OrderID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if o.Status != change.FromStatus {
+		return repository.ErrStatusConflict
+	}
+	o.Status = change.ToStatus
+	if change.ToStatus == models.OrderCancelled {
+		for _, item := range o.Items {
+			// A variant that was removed since checkout takes nothing back
+			if item.VariantID != 0 {
+				v := r.variant(item.ProductID, item.VariantID)
+				if v == nil {
+					continue
+				}
+				v.Stock += item.Quantity
+			}
+			if p, ok := r.products[item.ProductID]; ok {
+				p.Stock += item.Quantity
+			}
+		}
+		if u, ok := r.users[o.UserID]; ok {
+			u.TotalSpent -= o.Total
+			if u.TotalSpent < 0 {
+				u.TotalSpent = 0
+			}
+			if o.Total > 0 {
+				r.applyWalletEntry(&models.WalletTransaction{
+					UserID:      o.UserID,
+					Type:        models.WalletRefund,
+					Amount:      o.Total,
+					OrderID:     o.ID,
+					Reference:   o.OrderNumber,
+					Description: "Refund for cancelled order " + o.OrderNumber,
+					CreatedBy:   change.ChangedBy,
+				})
+			}
+		}
+	}
+	r.recordStatusChange(change)
+	return nil
+}
+
+// recordStatusChange appends c to the order history; callers hold r.mu
+func (r *orderRepo) recordStatusChange(c models.OrderStatusChange) {
+	c.ID = r.newID("order_status_history")
+	c.CreatedAt = time.Now()
+	r.statusHistory = append(r.statusHistory, c)
+}
+
+func (r *orderRepo) History(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var history []models.OrderStatusChange
+	for _, c := range r.statusHistory {
+		if c.OrderID == orderID {
+			history = append(history, c)
+		}
+	}
+	return history, nil
+}
+
+func (r *orderRepo) Stats(ctx context.Context, userID int) (*models.OrderStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	sixMonthsAgo := now.AddDate(0, -6, 0)
+	monthly := map[time.Time]int{}
+	byCategory := map[string]int{}
+
+	var s models.OrderStats
+	for _, o := range r.orders {
+		if userID > 0 && o.UserID != userID {
+			continue
+		}
+		s.TotalOrders++
+		switch o.Status {
+		case "pending":
+			s.PendingOrders++
+		case "delivered", "completed":
+			s.CompletedOrders++
+		}
+		if o.CreatedAt.Year() == now.Year() {
+			s.ThisYearOrders++
+		}
+		if o.Status != "delivered" {
+			continue
+		}
+		if o.CreatedAt.After(sixMonthsAgo) {
+			month := time.Date(o.CreatedAt.Year(), o.CreatedAt.Month(), 1, 0, 0, 0, 0, time.Local)
+			monthly[month] += o.Total
+		}
+		for _, item := range o.Items {
+			cat := "Other"
+			if p, ok := r.products[item.ProductID]; ok && p.Category != "" {
+				cat = p.Category
+			}
+			byCategory[cat] += item.Price * item.Quantity
+		}
+	}
+
+	var months []time.Time
+	for m := range monthly {
+		months = append(months, m)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	for _, m := range months {
+		s.MonthlySpending = append(s.MonthlySpending, models.MonthlySpending{Month: m.Format("Jan"), Amount: monthly[m]})
+	}
+
+	for name, value := range byCategory {
+		s.CategoryBreakdown = append(s.CategoryBreakdown, models.CategorySpending{Name: name, Value: value})
+	}
+	sort.Slice(s.CategoryBreakdown, func(i, j int) bool { return s.CategoryBreakdown[i].Value > s.CategoryBreakdown[j].Value })
+	if len(s.CategoryBreakdown) > 5 {
+		s.CategoryBreakdown = s.CategoryBreakdown[:5]
+	}
+
+	repository.FillPercentages(&s)
+	return &s, nil
+}
+
+func (r *orderRepo) Revenue(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := 0
+	for _, o := range r.orders {
+		if o.Status == "delivered" || o.Status == "completed" {
+			total += o.Total
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,407 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type productRepo struct{ *db }
+
+// categoryName resolves name against the categories table; callers hold d.mu
+func (d *db) categoryName(name string) (string, error) {
+	for _, c := range d.categories {
+		if strings.EqualFold(c.Name, name) {
+			return c.Name, nil
+		}
+	}
+	return "", repository.ErrUnknownCategory
+}
+
+// sortedProducts returns copies of the products ordered by id, either the
+// catalog or only the archived ones; callers hold d.mu
+func (d *db) sortedProducts(archived bool) []models.Product {
+	products := make([]models.Product, 0, len(d.products))
+	for _, p := range d.products {
+		if (p.DeletedAt != nil) != archived {
+			continue
+		}
+		cp := *p
+		cp.Specifications = nil
+		products = append(products, cp)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products
+}
+
+func (r *productRepo) List(ctx context.Context, limit int) ([]models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	products := r.sortedProducts(false)
+	if limit > 0 && len(products) > limit {
+		products = products[:limit]
+	}
+	return products, nil
+}
+
+func (r *productRepo) Query(ctx context.Context, q models.ProductQuery) ([]models.Product, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var products []models.Product
+	for _, p := range r.sortedProducts(q.Archived) {
+		if r.matches(p, q, true) {
+			products = append(products, p)
+		}
+	}
+
+	switch q.Sort {
+	case models.SortPriceAsc:
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Price < products[j].Price })
+	case models.SortPriceDesc:
+		sort.SliceStable(products, func(i, j int) bool { return products[i].Price > products[j].Price })
+	case models.SortRating:
+		sort.SliceStable(products, func(i, j int) bool {
+			if products[i].Rating != products[j].Rating {
+				return products[i].Rating > products[j].Rating
+			}
+			return products[i].TotalReviews > products[j].TotalReviews
+		})
+	case models.SortNewest:
+		sort.SliceStable(products, func(i, j int) bool {
+			if !products[i].CreatedAt.Equal(products[j].CreatedAt) {
+				return products[i].CreatedAt.After(products[j].CreatedAt)
+			}
+			return products[i].ID > products[j].ID
+		})
+	case models.SortBestSelling:
+		sold := map[int]int{}
+		for _, o := range r.orders {
+			if o.Status == models.OrderCancelled {
+				continue
+			}
+			for _, it := range o.Items {
+				sold[it.ProductID] += it.Quantity
+			}
+		}
+		sort.SliceStable(products, func(i, j int) bool { return sold[products[i].ID] > sold[products[j].ID] })
+	}
+
+	total := len(products)
+	if q.Offset >= total {
+		return nil, total, nil
+	}
+	products = products[max(q.Offset, 0):]
+	if q.Limit > 0 && len(products) > q.Limit {
+		products = products[:q.Limit]
+	}
+	return products, total, nil
+}
+
+// matches reports whether p passes the filters of q, including the spec
+// filters when withSpecs is set; callers hold d.mu
+func (d *db) matches(p models.Product, q models.ProductQuery, withSpecs bool) bool {
+	switch {
+	case len(q.Categories) > 0 && !containsFold(q.Categories, p.Category),
+		len(q.Brands) > 0 && !containsFold(q.Brands, p.Brand),
+		q.MinPrice > 0 && p.Price < q.MinPrice,
+		q.MaxPrice > 0 && p.Price > q.MaxPrice,
+		q.InStock && models.AvailableStock(p.Stock, p.Reserved) <= 0,
+		q.MinRating > 0 && float64(p.Rating) < q.MinRating:
+		return false
+	}
+	if !withSpecs {
+		return true
+	}
+	for _, f := range q.Specs {
+		found := false
+		for _, s := range d.products[p.ID].Specifications {
+			if strings.EqualFold(s.Key, f.Key) && f.Matches(s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *productRepo) SpecFacets(ctx context.Context, q models.ProductQuery) ([]models.SpecFacet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	facets := []models.SpecFacet{}
+	for _, p := range r.sortedProducts(q.Archived) {
+		if !r.matches(p, q, false) {
+			continue
+		}
+		for _, s := range r.products[p.ID].Specifications {
+			facets = models.AddSpecFacetValue(facets, p.Category, s, 1)
+		}
+	}
+	models.FinishSpecFacets(facets)
+	return facets, nil
+}
+
+// containsFold reports whether list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *productRepo) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *p
+	cp.Specifications = append([]models.ProductSpec(nil), p.Specifications...)
+	cp.Options = append([]models.ProductOption(nil), r.options[id]...)
+	cp.Variants = copyVariants(r.variants[id])
+	if len(cp.Variants) == 0 {
+		cp.Variants = nil
+	}
+	cp.Images = append([]models.ProductImage(nil), r.images[id]...)
+	return &cp, nil
+}
+
+func (r *productRepo) FindBySlug(ctx context.Context, slug string, includeArchived bool) (*models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found, ok := r.products[r.oldSlugs[slug]]
+	for _, p := range r.products {
+		if p.Slug == slug {
+			found, ok = p, true
+		}
+	}
+	if !ok || slug == "" || found.DeletedAt != nil && !includeArchived {
+		return nil, repository.ErrNotFound
+	}
+	cp := *found
+	cp.Specifications = nil
+	return &cp, nil
+}
+
+func (r *productRepo) FindBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for productID, list := range r.variants {
+		for _, v := range list {
+			if strings.EqualFold(v.SKU, sku) {
+				cp := *r.products[productID]
+				cp.Specifications = nil
+				return &cp, nil
+			}
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *productRepo) ListByIDs(ctx context.Context, ids []int) ([]models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var products []models.Product
+	for _, id := range ids {
+		if p, ok := r.products[id]; ok {
+			cp := *p
+			cp.Specifications = nil
+			products = append(products, cp)
+		}
+	}
+	return products, nil
+}
+
+func (r *productRepo) ListWithSpecifications(ctx context.Context) ([]models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	products := r.sortedProducts(false)
+	for i := range products {
+		products[i].Specifications = append([]models.ProductSpec(nil), r.products[products[i].ID].Specifications...)
+	}
+	return products, nil
+}
+
+func (r *productRepo) Create(ctx context.Context, p *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createProduct(p)
+}
+
+// createProduct is Create for callers holding d.mu
+func (d *db) createProduct(p *models.Product) error {
+	category, err := d.categoryName(p.Category)
+	if err != nil {
+		return err
+	}
+	cp := *p
+	cp.ID = d.newID("products")
+	cp.Slug = d.freeSlug(p.Slug, cp.ID)
+	cp.Category = category
+	cp.CreatedAt = time.Now()
+	cp.Specifications = nil
+	d.products[cp.ID] = &cp
+	p.ID, p.Slug = cp.ID, cp.Slug
+	return nil
+}
+
+// freeSlug returns base, or the first of base-2, base-3, ... that no other
+// product has as its current or former slug; callers hold d.mu
+func (d *db) freeSlug(base string, productID int) string {
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken := false
+		for _, p := range d.products {
+			taken = taken || p.Slug == slug && p.ID != productID
+		}
+		if id, ok := d.oldSlugs[slug]; ok && id != productID {
+			taken = true
+		}
+		if !taken {
+			return slug
+		}
+	}
+}
+
+func (r *productRepo) Update(ctx context.Context, p *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.updateProduct(p)
+}
+
+// updateProduct is Update for callers holding d.mu
+func (d *db) updateProduct(p *models.Product) error {
+	existing, ok := d.products[p.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	category, err := d.categoryName(p.Category)
+	if err != nil {
+		return err
+	}
+	if p.Slug != "" && p.Slug != existing.Slug {
+		slug := d.freeSlug(p.Slug, p.ID)
+		if slug != existing.Slug {
+			// The old slug keeps redirecting here; a slug taken back is no longer history
+			d.oldSlugs[existing.Slug] = p.ID
+			delete(d.oldSlugs, slug)
+			existing.Slug = slug
+		}
+	}
+	p.Slug = existing.Slug
+	existing.Category = category
+	existing.Name = p.Name
+	existing.Price = p.Price
+	existing.Stock = p.Stock
+	existing.Description = p.Description
+	existing.Image = p.Image
+	existing.Brand = p.Brand
+	return nil
+}
+
+func (r *productRepo) ReplaceSpecifications(ctx context.Context, productID int, specs []models.ProductSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.replaceSpecifications(productID, specs)
+	return nil
+}
+
+// replaceSpecifications is ReplaceSpecifications for callers holding d.mu
+func (d *db) replaceSpecifications(productID int, specs []models.ProductSpec) {
+	p, ok := d.products[productID]
+	if !ok {
+		return
+	}
+	p.Specifications = nil
+	for _, s := range specs {
+		if s.Value == "" {
+			continue
+		}
+		if s.Type == "" {
+			s.Type = models.SpecText
+		}
+		p.Specifications = append(p.Specifications, s)
+	}
+}
+
+func (r *productRepo) Import(ctx context.Context, items []models.ProductImportItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Check everything first so a failing item leaves the store untouched
+	for _, item := range items {
+		if _, err := r.categoryName(item.Product.Category); err != nil {
+			return err
+		}
+		if _, ok := r.products[item.Product.ID]; item.Product.ID != 0 && !ok {
+			return repository.ErrNotFound
+		}
+	}
+	for i := range items {
+		item := &items[i]
+		var err error
+		if item.Product.ID == 0 {
+			err = r.createProduct(&item.Product)
+		} else {
+			err = r.updateProduct(&item.Product)
+		}
+		if err != nil {
+			return err
+		}
+		if item.ReplaceSpecs {
+			r.replaceSpecifications(item.Product.ID, item.Specs)
+		}
+	}
+	return nil
+}
+
+func (r *productRepo) Archive(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if p.DeletedAt == nil {
+		now := time.Now()
+		p.DeletedAt = &now
+	}
+	return nil
+}
+
+func (r *productRepo) Restore(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	p.DeletedAt = nil
+	return nil
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type reservationRepo struct{ *db }
+
+// releaseHolds gives the units of the user's holds that match back to their
+// products and variants, drops those holds and returns how many there were;
+// callers hold d.mu
+func (d *db) releaseHolds(userID int, match func(models.StockReservation) bool) int {
+	n := 0
+	for key, h := range d.holds {
+		if key.userID != userID || !match(*h) {
+			continue
+		}
+		if v := d.variant(h.ProductID, h.VariantID); h.VariantID != 0 && v != nil {
+			v.Reserved = max(v.Reserved-h.Quantity, 0)
+		}
+		if p, ok := d.products[h.ProductID]; ok {
+			p.Reserved = max(p.Reserved-h.Quantity, 0)
+		}
+		delete(d.holds, key)
+		n++
+	}
+	return n
+}
+
+func (r *reservationRepo) Reserve(ctx context.Context, userID int, lines []models.CartLine, expiresAt time.Time) ([]models.StockReservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	lines = repository.SortedUniqueLines(lines)
+
+	// Check every line against the units other users hold before touching
+	// the current holds
+	own := func(l models.CartLine) int {
+		if h, ok := r.holds[cartKey{userID, l}]; ok {
+			return h.Quantity
+		}
+		return 0
+	}
+	ownOfProduct := map[int]int{}
+	for key, h := range r.holds {
+		if key.userID == userID {
+			ownOfProduct[h.ProductID] += h.Quantity
+		}
+	}
+	for _, l := range lines {
+		line, ok := r.carts[cartKey{userID, l}]
+		if !ok {
+			return nil, &repository.ItemError{Err: repository.ErrNotInCart, Item: repository.LineRef(l)}
+		}
+		p, ok := r.products[l.ProductID]
+		if !ok || p.DeletedAt != nil {
+			return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+		}
+		available := models.AvailableStock(p.Stock, p.Reserved-ownOfProduct[l.ProductID])
+		if l.VariantID != 0 {
+			v := r.variant(l.ProductID, l.VariantID)
+			if v == nil {
+				return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+			}
+			available = models.AvailableStock(v.Stock, v.Reserved-own(l))
+		}
+		if available < line.Quantity {
+			return nil, &repository.ItemError{Err: repository.ErrInsufficientStock, Item: p.Name}
+		}
+	}
+
+	r.releaseHolds(userID, func(models.StockReservation) bool { return true })
+	holds := make([]models.StockReservation, 0, len(lines))
+	for _, l := range lines {
+		h := &models.StockReservation{
+			ID:        r.newID("stock_reservations"),
+			UserID:    userID,
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Quantity:  r.carts[cartKey{userID, l}].Quantity,
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		}
+		r.holds[cartKey{userID, l}] = h
+		if v := r.variant(l.ProductID, l.VariantID); l.VariantID != 0 {
+			v.Reserved += h.Quantity
+		}
+		r.products[l.ProductID].Reserved += h.Quantity
+		holds = append(holds, *h)
+	}
+	return holds, nil
+}
+
+func (r *reservationRepo) List(ctx context.Context, userID int) ([]models.StockReservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var holds []models.StockReservation
+	for key, h := range r.holds {
+		if key.userID == userID {
+			holds = append(holds, *h)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool {
+		if holds[i].ProductID != holds[j].ProductID {
+			return holds[i].ProductID < holds[j].ProductID
+		}
+		return holds[i].VariantID < holds[j].VariantID
+	})
+	return holds, nil
+}
+
+func (r *reservationRepo) Release(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.releaseHolds(userID, func(models.StockReservation) bool { return true })
+	return nil
+}
+
+func (r *reservationRepo) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := map[int]bool{}
+	for key := range r.holds {
+		users[key.userID] = true
+	}
+	released := 0
+	for userID := range users {
+		released += r.releaseHolds(userID, func(h models.StockReservation) bool { return !h.ExpiresAt.After(now) })
+	}
+	return released, nil
+}
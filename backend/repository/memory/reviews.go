@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+type reviewRepo struct{ *db }
+
+func (r *reviewRepo) ListByProduct(ctx context.Context, productID int) ([]models.Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reviews []models.Review
+	for _, rev := range r.reviews {
+		if rev.ProductID != productID {
+			continue
+		}
+		cp := *rev
+		if u, ok := r.users[rev.UserID]; ok {
+			cp.UserName = u.FullName
+			if cp.UserName == "" {
+				cp.UserName = u.Email
+			}
+		}
+		if cp.UserName == "" {
+			cp.UserName = "Anonymous"
+		}
+		reviews = append(reviews, cp)
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID > reviews[j].ID })
+	return reviews, nil
+}
+
+func (r *reviewRepo) Upsert(ctx context.Context, rev models.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := reviewKey{rev.ProductID, rev.UserID, rev.OrderID}
+	if existing, ok := r.reviews[key]; ok {
+		existing.Rating = rev.Rating
+		existing.ReviewText = rev.ReviewText
+	} else {
+		rev.ID = r.newID("reviews")
+		rev.IsVerified = true
+		rev.CreatedAt = time.Now()
+		r.reviews[key] = &rev
+	}
+
+	// Recalculate product average rating and review count
+	if p, ok := r.products[rev.ProductID]; ok {
+		sum, n := 0, 0
+		for _, other := range r.reviews {
+			if other.ProductID == rev.ProductID {
+				sum += other.Rating
+				n++
+			}
+		}
+		p.TotalReviews = n
+		if n > 0 {
+			p.Rating = models.Decimal(float64(sum) / float64(n))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package memory implements the repository interfaces with in-process maps.
+// It is used by the HTTP tests and for running the API without MySQL.
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+// db holds every table; one mutex serialises access so multi-table
+// operations such as checkout are atomic just like a SQL transaction
+type db struct {
+	mu sync.Mutex
+
+	users      map[int]*userRow
+	categories map[int]*models.Category
+	specFields map[int][]models.SpecField // by category id
+	products   map[int]*models.Product
+	options    map[int][]models.ProductOption  // by product id
+	variants   map[int][]models.ProductVariant // by product id, in display order
+	images     map[int][]models.ProductImage   // by product id, in display order
+	oldSlugs   map[string]int                  // former product slug -> product id
+	carts      map[cartKey]*models.CartItem
+	holds      map[cartKey]*models.StockReservation
+	orders     map[int]*models.Order
+	vouchers   map[int]*models.Voucher
+	reviews    map[reviewKey]*models.Review
+
+	statusHistory []models.OrderStatusChange
+	wallet        []models.WalletTransaction
+	ledger        []models.LedgerEntry
+	refreshTokens map[string]*models.RefreshToken // by token hash
+	deniedTokens  map[string]time.Time            // jti -> expiry
+	actionTokens  map[string]*models.ActionToken  // by token hash
+	idempotency   map[idempotencyID]*models.IdempotencyKey
+
+	loginThrottles map[string]*models.LoginThrottle
+
+	nextID map[string]int
+}
+
+type userRow struct {
+	models.User
+	PasswordHash string
+}
+
+type cartKey struct {
+	userID int
+	line   models.CartLine
+}
+
+type reviewKey struct{ productID, userID, orderID int }
+
+type idempotencyID struct {
+	userID int
+	key    string
+}
+
+// New returns an empty repository.Store seeded with the default categories
+func New() *repository.Store {
+	d := &db{
+		users:      map[int]*userRow{},
+		categories: map[int]*models.Category{},
+		specFields: map[int][]models.SpecField{},
+		products:   map[int]*models.Product{},
+		options:    map[int][]models.ProductOption{},
+		variants:   map[int][]models.ProductVariant{},
+		images:     map[int][]models.ProductImage{},
+		oldSlugs:   map[string]int{},
+		carts:      map[cartKey]*models.CartItem{},
+		holds:      map[cartKey]*models.StockReservation{},
+		orders:     map[int]*models.Order{},
+		vouchers:   map[int]*models.Voucher{},
+		reviews:    map[reviewKey]*models.Review{},
+		nextID:     map[string]int{},
+
+		idempotency:   map[idempotencyID]*models.IdempotencyKey{},
+		refreshTokens: map[string]*models.RefreshToken{},
+		deniedTokens:  map[string]time.Time{},
+		actionTokens:  map[string]*models.ActionToken{},
+
+		loginThrottles: map[string]*models.LoginThrottle{},
+	}
+	for _, name := range []string{"Smartphones", "Laptops", "Audio"} {
+		id := d.newID("categories")
+		d.categories[id] = &models.Category{ID: id, Name: name, Slug: strings.ToLower(name), SortOrder: id, CreatedAt: time.Now()}
+		d.specFields[id] = defaultSpecFields[name]
+	}
+	return &repository.Store{
+		Users:          &userRepo{d},
+		Products:       &productRepo{d},
+		Categories:     &categoryRepo{d},
+		Images:         &imageRepo{d},
+		Carts:          &cartRepo{d},
+		Orders:         &orderRepo{d},
+		Reservations:   &reservationRepo{d},
+		Vouchers:       &voucherRepo{d},
+		Reviews:        &reviewRepo{d},
+		Wallet:         &walletRepo{d},
+		Tokens:         &tokenRepo{d},
+		LoginThrottles: &loginThrottleRepo{d},
+		Idempotency:    &idempotencyRepo{d},
+	}
+}
+
+// newID returns the next AUTO_INCREMENT value for table; callers hold d.mu
+func (d *db) newID(table string) int {
+	d.nextID[table]++
+	return d.nextID[table]
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type tokenRepo struct{ *db }
+
+// insertRefresh stores a copy of t; callers hold d.mu
+func (d *db) insertRefresh(t *models.RefreshToken) {
+	t.ID = d.newID("refresh_tokens")
+	t.CreatedAt = time.Now()
+	cp := *t
+	d.refreshTokens[t.TokenHash] = &cp
+}
+
+func (r *tokenRepo) CreateRefresh(ctx context.Context, t *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.refreshTokens[t.TokenHash]; ok {
+		return repository.ErrDuplicate
+	}
+	r.insertRefresh(t)
+	return nil
+}
+
+func (r *tokenRepo) GetRefresh(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.refreshTokens[hash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (r *tokenRepo) RotateRefresh(ctx context.Context, oldID int, next *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.refreshTokens {
+		if t.ID != oldID {
+			continue
+		}
+		if t.RevokedAt != nil {
+			return repository.ErrTokenReused
+		}
+		now := time.Now()
+		t.RevokedAt = &now
+		r.insertRefresh(next)
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (r *tokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range r.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *tokenRepo) RevokeUser(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range r.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *tokenRepo) DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range r.deniedTokens {
+		if exp.Before(now) {
+			delete(r.deniedTokens, id)
+		}
+	}
+	r.deniedTokens[jti] = expiresAt
+	return nil
+}
+
+func (r *tokenRepo) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, denied := r.deniedTokens[jti]
+	return denied, nil
+}
+
+func (r *tokenRepo) CreateAction(ctx context.Context, t *models.ActionToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.actionTokens[t.TokenHash]; ok {
+		return repository.ErrDuplicate
+	}
+	now := time.Now()
+	for _, old := range r.actionTokens {
+		if old.UserID == t.UserID && old.Purpose == t.Purpose && old.UsedAt == nil {
+			old.UsedAt = &now
+		}
+	}
+	t.ID = r.newID("action_tokens")
+	t.CreatedAt = now
+	cp := *t
+	r.actionTokens[t.TokenHash] = &cp
+	return nil
+}
+
+func (r *tokenRepo) ConsumeAction(ctx context.Context, purpose, hash string) (*models.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.actionTokens[hash]
+	if !ok || t.Purpose != purpose {
+		return nil, repository.ErrNotFound
+	}
+	now := time.Now()
+	if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return nil, repository.ErrTokenUsed
+	}
+	t.UsedAt = &now
+	cp := *t
+	return &cp, nil
+}
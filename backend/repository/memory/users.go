@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type userRepo struct{ *db }
+
+func (r *userRepo) List(ctx context.Context) ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var users []models.User
+	for _, u := range r.users {
+		users = append(users, u.User)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *userRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	user := u.User
+	return &user, nil
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			user := u.User
+			user.Password = u.PasswordHash
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r *userRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.GetByEmail(ctx, email)
+	if err == repository.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *userRepo) Create(ctx context.Context, u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == u.Email {
+			return repository.ErrDuplicate
+		}
+	}
+	row := &userRow{User: *u, PasswordHash: u.Password}
+	row.ID = r.newID("users")
+	row.Password = ""
+	if row.Role == "" {
+		row.Role = "customer"
+	}
+	row.CreatedAt = time.Now()
+	r.users[row.ID] = row
+	u.ID = row.ID
+	return nil
+}
+
+func (r *userRepo) Update(ctx context.Context, id int, req models.UserUpdateRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.FullName = req.FullName
+	u.IsMember = req.IsMember
+	return nil
+}
+
+func (r *userRepo) UpdateProfile(ctx context.Context, id int, fullName, phone, email, avatarURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if u.Email != email {
+		u.EmailVerified = false
+	}
+	// Links mailed to a previous address must not act on the new one
+	for hash, t := range r.actionTokens {
+		if t.UserID == id && t.UsedAt == nil && t.Email != email {
+			delete(r.actionTokens, hash)
+		}
+	}
+	u.FullName, u.Phone, u.Email, u.AvatarURL = fullName, phone, email, avatarURL
+	return nil
+}
+
+func (r *userRepo) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return "", repository.ErrNotFound
+	}
+	return u.PasswordHash, nil
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, id int, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[id]; ok {
+		u.PasswordHash = hash
+	}
+	return nil
+}
+
+func (r *userRepo) SetEmailVerified(ctx context.Context, id int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok || u.Email != email {
+		return repository.ErrNotFound
+	}
+	u.EmailVerified = true
+	return nil
+}
+
+func (r *userRepo) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	r.releaseHolds(id, func(models.StockReservation) bool { return true })
+	delete(r.users, id)
+	return nil
+}
+
+func (r *userRepo) GetBalance(ctx context.Context, id int) (int, error) {
+	u, err := r.GetByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return u.Balance, nil
+}
+
+func (r *userRepo) GetTotalSpent(ctx context.Context, id int) (int, error) {
+	u, err := r.GetByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return u.TotalSpent, nil
+}
+
+func (r *userRepo) CountByRole(ctx context.Context, role string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, u := range r.users {
+		if u.Role == role {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+// copyVariants returns deep copies of variants
+func copyVariants(variants []models.ProductVariant) []models.ProductVariant {
+	out := make([]models.ProductVariant, len(variants))
+	for i, v := range variants {
+		out[i] = v
+		out[i].Options = append([]models.VariantOption(nil), v.Options...)
+	}
+	return out
+}
+
+// variant returns the stored variant of a product; callers hold d.mu
+func (d *db) variant(productID, variantID int) *models.ProductVariant {
+	list := d.variants[productID]
+	for i := range list {
+		if list[i].ID == variantID {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func (r *productRepo) Variants(ctx context.Context, productID int) ([]models.ProductOption, []models.ProductVariant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.products[productID]; !ok {
+		return nil, nil, repository.ErrNotFound
+	}
+	return append([]models.ProductOption(nil), r.options[productID]...), copyVariants(r.variants[productID]), nil
+}
+
+func (r *productRepo) ReplaceVariants(ctx context.Context, productID int, options []models.ProductOption, variants []models.ProductVariant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.products[productID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	skus := map[string]bool{}
+	for _, v := range variants {
+		skus[v.SKU] = true
+	}
+	for pid, list := range r.variants {
+		for _, v := range list {
+			if pid != productID && skus[v.SKU] {
+				return repository.ErrDuplicate
+			}
+		}
+	}
+
+	existing := map[string]models.ProductVariant{}
+	for _, v := range r.variants[productID] {
+		existing[v.SKU] = v
+	}
+	keep := map[int]bool{}
+	for i := range variants {
+		v := &variants[i]
+		v.ProductID = productID
+		v.Reserved = 0
+		if old, ok := existing[v.SKU]; ok {
+			v.ID, v.Reserved = old.ID, old.Reserved
+		} else {
+			v.ID = r.newID("product_variants")
+		}
+		keep[v.ID] = true
+	}
+	for key := range r.carts {
+		if key.line.ProductID != productID {
+			continue
+		}
+		if key.line.VariantID == 0 && len(variants) > 0 || key.line.VariantID != 0 && !keep[key.line.VariantID] {
+			delete(r.carts, key)
+		}
+	}
+	p.Reserved = 0
+	for key, h := range r.holds {
+		if key.line.ProductID != productID {
+			continue
+		}
+		if key.line.VariantID == 0 && len(variants) > 0 || key.line.VariantID != 0 && !keep[key.line.VariantID] {
+			delete(r.holds, key)
+			continue
+		}
+		p.Reserved += h.Quantity
+	}
+
+	r.options[productID] = append([]models.ProductOption(nil), options...)
+	r.variants[productID] = copyVariants(variants)
+	if len(variants) > 0 {
+		p.Price, p.Stock = models.VariantTotals(variants)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type voucherRepo struct{ *db }
+
+func (r *voucherRepo) list(unexpiredOnly bool) []models.Voucher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var vouchers []models.Voucher
+	for _, v := range r.vouchers {
+		if unexpiredOnly && !v.ValidUntil.After(now) {
+			continue
+		}
+		vouchers = append(vouchers, *v)
+	}
+	sort.Slice(vouchers, func(i, j int) bool { return vouchers[i].ID > vouchers[j].ID })
+	return vouchers
+}
+
+func (r *voucherRepo) List(ctx context.Context) ([]models.Voucher, error) {
+	return r.list(false), nil
+}
+
+func (r *voucherRepo) ListUnexpired(ctx context.Context) ([]models.Voucher, error) {
+	return r.list(true), nil
+}
+
+func (r *voucherRepo) Create(ctx context.Context, v *models.Voucher) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.vouchers {
+		if strings.EqualFold(existing.Code, v.Code) {
+			return repository.ErrDuplicate
+		}
+	}
+	cp := *v
+	cp.ID = r.newID("vouchers")
+	cp.CreatedAt = time.Now()
+	r.vouchers[cp.ID] = &cp
+	v.ID = cp.ID
+	return nil
+}
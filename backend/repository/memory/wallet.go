@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type walletRepo struct{ *db }
+
+// applyWalletEntry updates the user's balance and appends t to the ledger; callers hold d.mu
+func (d *db) applyWalletEntry(t *models.WalletTransaction) error {
+	u, ok := d.users[t.UserID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if u.Balance+t.Amount < 0 {
+		return repository.ErrInsufficientBalance
+	}
+	u.Balance += t.Amount
+	t.ID = d.newID("wallet_transactions")
+	t.BalanceAfter = u.Balance
+	t.CounterAccount = models.CounterAccount(t.Type)
+	t.CreatedAt = time.Now()
+	d.wallet = append(d.wallet, *t)
+	d.ledger = append(d.ledger,
+		models.LedgerEntry{TransactionID: t.ID, Account: models.UserAccount(t.UserID), Amount: t.Amount},
+		models.LedgerEntry{TransactionID: t.ID, Account: t.CounterAccount, Amount: -t.Amount},
+	)
+	return nil
+}
+
+// accountBalance sums the legs on account; callers hold d.mu
+func (d *db) accountBalance(account string) int {
+	balance := 0
+	for _, e := range d.ledger {
+		if e.Account == account {
+			balance += e.Amount
+		}
+	}
+	return balance
+}
+
+func (r *walletRepo) Record(ctx context.Context, t *models.WalletTransaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.applyWalletEntry(t)
+}
+
+func (r *walletRepo) SetBalance(ctx context.Context, t *models.WalletTransaction, balance int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[t.UserID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if t.Amount = balance - u.Balance; t.Amount == 0 {
+		return nil
+	}
+	return r.applyWalletEntry(t)
+}
+
+func (r *walletRepo) Balance(ctx context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return 0, repository.ErrNotFound
+	}
+	return r.accountBalance(models.UserAccount(userID)), nil
+}
+
+func (r *walletRepo) Reconcile(ctx context.Context, userID int) (balance, drift int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[userID]
+	if !ok {
+		return 0, 0, repository.ErrNotFound
+	}
+	balance = r.accountBalance(models.UserAccount(userID))
+	drift = u.Balance - balance
+	u.Balance = balance
+	return balance, drift, nil
+}
+
+func (r *walletRepo) Statement(ctx context.Context, userID, limit, offset int) ([]models.WalletTransaction, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var all []models.WalletTransaction
+	for i := len(r.wallet) - 1; i >= 0; i-- {
+		if r.wallet[i].UserID == userID {
+			all = append(all, r.wallet[i])
+		}
+	}
+	total := len(all)
+	if offset >= total {
+		return nil, total, nil
+	}
+	all = all[max(offset, 0):]
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+	return all, total, nil
+}
+
+func (r *walletRepo) TrialBalance(ctx context.Context) ([]models.AccountBalance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sums := map[string]int{}
+	for _, e := range r.ledger {
+		account := e.Account
+		if strings.HasPrefix(account, "user:") {
+			account = models.AccountWallets
+		}
+		sums[account] += e.Amount
+	}
+	balances := make([]models.AccountBalance, 0, len(sums))
+	for account, balance := range sums {
+		balances = append(balances, models.AccountBalance{Account: account, Balance: balance})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Account < balances[j].Account })
+	return balances, nil
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+type cartRepo struct {
+	db *sql.DB
+}
+
+func (r *cartRepo) List(ctx context.Context, userID int) ([]models.CartItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT ci.id, ci.user_id, ci.product_id, ci.variant_id, ci.quantity, ci.created_at
+		 FROM cart_items ci JOIN products p ON p.id = ci.product_id
+		 WHERE ci.user_id = ? AND p.deleted_at IS NULL
+		 ORDER BY ci.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.CartItem
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.VariantID, &item.Quantity, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *cartRepo) Add(ctx context.Context, userID int, line models.CartLine, quantity int) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO cart_items (user_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)`,
+		userID, line.ProductID, line.VariantID, quantity)
+	return err
+}
+
+func (r *cartRepo) UpdateQuantity(ctx context.Context, userID int, line models.CartLine, quantity int) error {
+	return affectedOrNotFound(r.db.ExecContext(ctx,
+		`UPDATE cart_items SET quantity = ? WHERE user_id = ? AND product_id = ? AND variant_id = ?`,
+		quantity, userID, line.ProductID, line.VariantID))
+}
+
+func (r *cartRepo) Remove(ctx context.Context, userID int, line models.CartLine) error {
+	return affectedOrNotFound(r.db.ExecContext(ctx,
+		`DELETE FROM cart_items WHERE user_id = ? AND product_id = ? AND variant_id = ?`, userID, line.ProductID, line.VariantID))
+}
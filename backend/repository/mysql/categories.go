@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type categoryRepo struct {
+	db *sql.DB
+}
+
+const categoryColumns = `c.id, c.parent_id, c.name, c.slug, COALESCE(c.description,''), COALESCE(c.image_url,''),
+	c.sort_order, (SELECT COUNT(*) FROM products p WHERE p.category_id = c.id AND p.deleted_at IS NULL), c.created_at`
+
+func scanCategory(row interface{ Scan(...interface{}) error }) (*models.Category, error) {
+	var c models.Category
+	var parentID sql.NullInt64
+	var createdAt sql.NullTime
+	if err := row.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.Description, &c.Image,
+		&c.SortOrder, &c.ProductCount, &createdAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	c.CreatedAt = createdAt.Time
+	return &c, nil
+}
+
+func (r *categoryRepo) List(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories c ORDER BY c.sort_order, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+	return categories, rows.Err()
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	c, err := scanCategory(r.db.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories c WHERE c.id = ?`, id))
+	return c, notFound(err)
+}
+
+func (r *categoryRepo) Find(ctx context.Context, ref string) (*models.Category, error) {
+	c, err := scanCategory(r.db.QueryRowContext(ctx,
+		`SELECT `+categoryColumns+` FROM categories c WHERE LOWER(c.slug) = LOWER(?) OR LOWER(c.name) = LOWER(?)
+		 ORDER BY LOWER(c.slug) = LOWER(?) DESC LIMIT 1`, ref, ref, ref))
+	return c, notFound(err)
+}
+
+// nullableID stores a nil parent as NULL
+func nullableID(id *int) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func (r *categoryRepo) Create(ctx context.Context, c *models.Category) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO categories (parent_id, name, slug, description, image_url, sort_order) VALUES (?, ?, ?, ?, ?, ?)`,
+		nullableID(c.ParentID), c.Name, c.Slug, c.Description, c.Image, c.SortOrder)
+	if isDuplicate(err) {
+		return repository.ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	return nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, c *models.Category) error {
+	var id int
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, c.ID).Scan(&id); err != nil {
+		return notFound(err)
+	}
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE categories SET parent_id = ?, name = ?, slug = ?, description = ?, image_url = ?, sort_order = ? WHERE id = ?`,
+		nullableID(c.ParentID), c.Name, c.Slug, c.Description, c.Image, c.SortOrder, c.ID)
+	if isDuplicate(err) {
+		return repository.ErrDuplicate
+	}
+	return err
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, id int) error {
+	var inUse bool
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = ?) OR EXISTS(SELECT 1 FROM products WHERE category_id = ?)`,
+		id, id).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		return repository.ErrCategoryInUse
+	}
+	return affectedOrNotFound(r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id))
+}
+
+func (r *categoryRepo) SpecFields(ctx context.Context, categoryID int) ([]models.SpecField, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT spec_key, label, unit, type, COALESCE(options,''), required, display_order
+		 FROM category_spec_fields WHERE category_id = ? ORDER BY display_order, id`, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []models.SpecField{}
+	for rows.Next() {
+		var f models.SpecField
+		var options string
+		if err := rows.Scan(&f.Key, &f.Label, &f.Unit, &f.Type, &options, &f.Required, &f.DisplayOrder); err != nil {
+			return nil, err
+		}
+		// Enum options are stored as a JSON array
+		if options != "" {
+			if err := json.Unmarshal([]byte(options), &f.Options); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+func (r *categoryRepo) ReplaceSpecFields(ctx context.Context, categoryID int, fields []models.SpecField) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM category_spec_fields WHERE category_id = ?`, categoryID); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		var options interface{}
+		if len(f.Options) > 0 {
+			encoded, err := json.Marshal(f.Options)
+			if err != nil {
+				return err
+			}
+			options = string(encoded)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO category_spec_fields (category_id, spec_key, label, unit, type, options, required, display_order)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			categoryID, f.Key, f.Label, f.Unit, f.Type, options, f.Required, f.DisplayOrder); err != nil {
+			if isDuplicate(err) {
+				return repository.ErrDuplicate
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
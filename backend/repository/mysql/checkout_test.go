@@ -0,0 +1,243 @@
+package mysql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/migrations"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/mysql"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// These tests need a real MySQL server because the memory store serialises
+// everything behind one mutex. Point TEST_MYSQL_DSN at a scratch database,
+// e.g. "root:secret@tcp(127.0.0.1:3306)/shop_test?parseTime=true"; the
+// migrations are applied to it and every test adds its own rows.
+func openTestStore(t *testing.T) (*repository.Store, *sql.DB) {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return mysql.New(db), db
+}
+
+// fixture creates rows whose names are unique to this test run
+type fixture struct {
+	t        *testing.T
+	s        *repository.Store
+	suffix   string
+	category string
+}
+
+func newFixture(t *testing.T, s *repository.Store) *fixture {
+	t.Helper()
+	f := &fixture{t: t, s: s, suffix: fmt.Sprint(time.Now().UnixNano())}
+	c := &models.Category{Name: "Race " + f.suffix, Slug: "race-" + f.suffix}
+	if err := s.Categories.Create(context.Background(), c); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	f.category = c.Name
+	return f
+}
+
+func (f *fixture) user(n, balance int) int {
+	f.t.Helper()
+	ctx := context.Background()
+	u := &models.User{FullName: "Racer", Email: fmt.Sprintf("racer%d-%s@example.com", n, f.suffix)}
+	if err := f.s.Users.Create(ctx, u); err != nil {
+		f.t.Fatalf("create user: %v", err)
+	}
+	if balance > 0 {
+		if err := f.s.Wallet.Record(ctx, &models.WalletTransaction{UserID: u.ID, Type: models.WalletTopUp, Amount: balance}); err != nil {
+			f.t.Fatalf("fund user: %v", err)
+		}
+	}
+	return u.ID
+}
+
+func (f *fixture) product(n, price, stock int) int {
+	f.t.Helper()
+	p := &models.Product{
+		Name:     fmt.Sprintf("Race item %d", n),
+		Slug:     fmt.Sprintf("race-item-%d-%s", n, f.suffix),
+		Price:    price,
+		Stock:    stock,
+		Category: f.category,
+	}
+	if err := f.s.Products.Create(context.Background(), p); err != nil {
+		f.t.Fatalf("create product: %v", err)
+	}
+	return p.ID
+}
+
+func (f *fixture) addToCart(userID, productID int) models.CartLine {
+	f.t.Helper()
+	line := models.CartLine{ProductID: productID}
+	if err := f.s.Carts.Add(context.Background(), userID, line, 1); err != nil {
+		f.t.Fatalf("add to cart: %v", err)
+	}
+	return line
+}
+
+// checkoutAll runs the requests at the same time and returns their errors
+func checkoutAll(s *repository.Store, reqs []models.CheckoutRequest) []error {
+	errs := make([]error, len(reqs))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = s.Orders.Checkout(context.Background(), req)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// expectOneWinner fails unless exactly one checkout succeeded and every other
+// one failed with want
+func expectOneWinner(t *testing.T, errs []error, want error) {
+	t.Helper()
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, want):
+			t.Errorf("checkout failed with %v, want %v", err, want)
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d checkouts succeeded, want exactly 1", won)
+	}
+}
+
+const racers = 8
+
+func TestCheckoutLastUnitOfStock(t *testing.T) {
+	s, db := openTestStore(t)
+	f := newFixture(t, s)
+	productID := f.product(0, 1000, 1)
+
+	reqs := make([]models.CheckoutRequest, racers)
+	for i := range reqs {
+		userID := f.user(i, 1000)
+		reqs[i] = models.CheckoutRequest{UserID: userID, Lines: []models.CartLine{f.addToCart(userID, productID)}}
+	}
+	expectOneWinner(t, checkoutAll(s, reqs), repository.ErrInsufficientStock)
+
+	var stock int
+	if err := db.QueryRow("SELECT stock FROM products WHERE id = ?", productID).Scan(&stock); err != nil {
+		t.Fatal(err)
+	}
+	if stock != 0 {
+		t.Errorf("stock = %d, want 0", stock)
+	}
+}
+
+func TestCheckoutSingleWalletBalance(t *testing.T) {
+	s, db := openTestStore(t)
+	f := newFixture(t, s)
+	userID := f.user(0, 1000)
+
+	// Every checkout alone is affordable, any two together are not
+	reqs := make([]models.CheckoutRequest, racers)
+	for i := range reqs {
+		productID := f.product(i, 1000, 5)
+		reqs[i] = models.CheckoutRequest{UserID: userID, Lines: []models.CartLine{f.addToCart(userID, productID)}}
+	}
+	expectOneWinner(t, checkoutAll(s, reqs), repository.ErrInsufficientBalance)
+
+	var balance, ledger int
+	if err := db.QueryRow("SELECT balance FROM users WHERE id = ?", userID).Scan(&balance); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM wallet_transactions WHERE user_id = ?", userID).Scan(&ledger); err != nil {
+		t.Fatal(err)
+	}
+	if balance != 0 || ledger != 0 {
+		t.Errorf("balance = %d, ledger sum = %d, want both 0", balance, ledger)
+	}
+	var legs int
+	if err := db.QueryRow(`SELECT COALESCE(SUM(e.amount), 0) FROM ledger_entries e
+		JOIN wallet_transactions w ON w.id = e.transaction_id WHERE w.user_id = ?`, userID).Scan(&legs); err != nil {
+		t.Fatal(err)
+	}
+	if legs != 0 {
+		t.Errorf("ledger legs sum to %d, want 0", legs)
+	}
+}
+
+func TestCheckoutKeepsHoldsOnOtherLines(t *testing.T) {
+	s, db := openTestStore(t)
+	f := newFixture(t, s)
+	ctx := context.Background()
+	userID := f.user(0, 10000)
+	bought := f.addToCart(userID, f.product(0, 1000, 5))
+	kept := f.addToCart(userID, f.product(1, 1000, 5))
+	if _, err := s.Reservations.Reserve(ctx, userID, []models.CartLine{bought, kept}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Orders.Checkout(ctx, models.CheckoutRequest{UserID: userID, Lines: []models.CartLine{bought}}); err != nil {
+		t.Fatal(err)
+	}
+	holds, err := s.Reservations.List(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 1 || holds[0].Line() != kept {
+		t.Fatalf("holds after checkout = %+v, want only %+v", holds, kept)
+	}
+	var reserved int
+	if err := db.QueryRow("SELECT reserved FROM products WHERE id = ?", kept.ProductID).Scan(&reserved); err != nil {
+		t.Fatal(err)
+	}
+	if reserved != 1 {
+		t.Errorf("reserved = %d, want 1", reserved)
+	}
+}
+
+func TestCheckoutIgnoresExpiredHold(t *testing.T) {
+	s, _ := openTestStore(t)
+	f := newFixture(t, s)
+	ctx := context.Background()
+	userID := f.user(0, 10000)
+	line := f.addToCart(userID, f.product(0, 1000, 2))
+	if _, err := s.Reservations.Reserve(ctx, userID, []models.CartLine{line}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// Until it is swept the expired hold is still counted as reserved, so
+	// one of the two units is not available to the buyer
+	if err := s.Carts.UpdateQuantity(ctx, userID, line, 2); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Orders.Checkout(ctx, models.CheckoutRequest{UserID: userID, Lines: []models.CartLine{line}})
+	if !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("got %v, want ErrInsufficientStock", err)
+	}
+}
@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+type idempotencyRepo struct {
+	db *sql.DB
+}
+
+func (r *idempotencyRepo) Reserve(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	// An expired key is free to be claimed again
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? AND expires_at <= ?`,
+		k.UserID, k.Key, k.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		k.UserID, k.Key, k.Fingerprint, k.CreatedAt, k.ExpiresAt,
+	)
+	if err == nil {
+		return nil, nil
+	}
+	if !isDuplicate(err) {
+		return nil, err
+	}
+
+	existing := models.IdempotencyKey{UserID: k.UserID, Key: k.Key}
+	err = r.db.QueryRowContext(ctx,
+		`SELECT fingerprint, status_code, response_body, created_at, expires_at
+		 FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?`,
+		k.UserID, k.Key,
+	).Scan(&existing.Fingerprint, &existing.StatusCode, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &existing, nil
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, userID int, key string, statusCode int, body []byte) error {
+	return affectedOrNotFound(r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = ?, response_body = ? WHERE user_id = ? AND idempotency_key = ?`,
+		statusCode, body, userID, key,
+	))
+}
+
+func (r *idempotencyRepo) Release(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?`, userID, key)
+	return err
+}
+
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,239 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type imageRepo struct{ db *sql.DB }
+
+const imageColumns = `id, product_id, url, alt_text, is_primary, display_order, created_at`
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// productImages returns the images of a product in display order
+func productImages(ctx context.Context, q queryer, productID int) ([]models.ProductImage, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+imageColumns+` FROM product_images WHERE product_id = ? ORDER BY display_order, id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.ProductImage{}
+	for rows.Next() {
+		var img models.ProductImage
+		if err := rows.Scan(&img.ID, &img.ProductID, &img.URL, &img.AltText, &img.IsPrimary,
+			&img.DisplayOrder, &img.CreatedAt); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, rows.Err()
+}
+
+// lockImages locks the product row and returns its images; ErrNotFound for an unknown product
+func lockImages(ctx context.Context, tx *sql.Tx, productID int) ([]models.ProductImage, error) {
+	var id int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ? FOR UPDATE`, productID).Scan(&id); err != nil {
+		return nil, notFound(err)
+	}
+	return productImages(ctx, tx, productID)
+}
+
+// setPrimary makes the image the product's only primary image and mirrors its URL into products.image_url
+func setPrimary(ctx context.Context, tx *sql.Tx, productID int, img models.ProductImage) error {
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE product_images SET is_primary = (id = ?) WHERE product_id = ?`, img.ID, productID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE products SET image_url = ? WHERE id = ?`, img.URL, productID)
+	return err
+}
+
+func (r *imageRepo) List(ctx context.Context, productID int) ([]models.ProductImage, error) {
+	var id int
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ?`, productID).Scan(&id); err != nil {
+		return nil, notFound(err)
+	}
+	return productImages(ctx, r.db, productID)
+}
+
+func (r *imageRepo) Add(ctx context.Context, img *models.ProductImage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	images, err := lockImages(ctx, tx, img.ProductID)
+	if err != nil {
+		return err
+	}
+	img.DisplayOrder = len(images) + 1
+	img.CreatedAt = time.Now()
+	img.IsPrimary = img.IsPrimary || len(images) == 0
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO product_images (product_id, url, alt_text, is_primary, display_order, created_at) VALUES (?, ?, ?, FALSE, ?, ?)`,
+		img.ProductID, img.URL, img.AltText, img.DisplayOrder, img.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	img.ID = int(id)
+	if img.IsPrimary {
+		if err := setPrimary(ctx, tx, img.ProductID, *img); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *imageRepo) Update(ctx context.Context, img *models.ProductImage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	images, err := lockImages(ctx, tx, img.ProductID)
+	if err != nil {
+		return err
+	}
+	var current *models.ProductImage
+	for i := range images {
+		if images[i].ID == img.ID {
+			current = &images[i]
+		}
+	}
+	if current == nil {
+		return repository.ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE product_images SET alt_text = ? WHERE id = ?`, img.AltText, img.ID); err != nil {
+		return err
+	}
+	current.AltText = img.AltText
+	if img.IsPrimary {
+		if err := setPrimary(ctx, tx, img.ProductID, *current); err != nil {
+			return err
+		}
+		current.IsPrimary = true
+	}
+	*img = *current
+	return tx.Commit()
+}
+
+func (r *imageRepo) Reorder(ctx context.Context, productID int, ids []int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	images, err := lockImages(ctx, tx, productID)
+	if err != nil {
+		return err
+	}
+	if len(ids) != len(images) {
+		return repository.ErrImageOrder
+	}
+	known := map[int]bool{}
+	for _, img := range images {
+		known[img.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return repository.ErrImageOrder
+		}
+		delete(known, id)
+	}
+
+	for n, id := range ids {
+		if _, err := tx.ExecContext(ctx, `UPDATE product_images SET display_order = ? WHERE id = ?`, n+1, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *imageRepo) Delete(ctx context.Context, productID, imageID int) (*models.ProductImage, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	images, err := lockImages(ctx, tx, productID)
+	if err != nil {
+		return nil, err
+	}
+	var removed *models.ProductImage
+	rest := []models.ProductImage{}
+	for i := range images {
+		if images[i].ID == imageID {
+			removed = &images[i]
+		} else {
+			rest = append(rest, images[i])
+		}
+	}
+	if removed == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_images WHERE id = ?`, imageID); err != nil {
+		return nil, err
+	}
+	for n, img := range rest {
+		if _, err := tx.ExecContext(ctx, `UPDATE product_images SET display_order = ? WHERE id = ?`, n+1, img.ID); err != nil {
+			return nil, err
+		}
+	}
+	if removed.IsPrimary {
+		if len(rest) > 0 {
+			err = setPrimary(ctx, tx, productID, rest[0])
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE products SET image_url = '' WHERE id = ?`, productID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return removed, tx.Commit()
+}
+
+func (r *imageRepo) ReferencedURLs(ctx context.Context) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT image_url FROM products WHERE image_url IS NOT NULL
+		UNION SELECT url FROM product_images
+		UNION SELECT image_url FROM product_variants
+		UNION SELECT image_url FROM categories WHERE image_url IS NOT NULL
+		UNION SELECT avatar_url FROM users WHERE avatar_url IS NOT NULL
+		UNION SELECT product_image FROM order_items`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := map[string]bool{}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls[url] = true
+	}
+	delete(urls, "")
+	return urls, rows.Err()
+}
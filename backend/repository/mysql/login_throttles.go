@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type loginThrottleRepo struct {
+	db *sql.DB
+}
+
+// staleThrottleAge is how long counters without new failures are kept
+const staleThrottleAge = 24 * time.Hour
+
+func scanThrottle(row interface{ Scan(...interface{}) error }) (*models.LoginThrottle, error) {
+	var t models.LoginThrottle
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&t.Key, &t.Failures, &lockedUntil, &t.LastFailure); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		t.LockedUntil = lockedUntil.Time
+	}
+	return &t, nil
+}
+
+func (r *loginThrottleRepo) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	t, err := scanThrottle(r.db.QueryRowContext(ctx,
+		`SELECT throttle_key, failures, locked_until, last_failure_at FROM login_throttles WHERE throttle_key = ?`, key))
+	return t, notFound(err)
+}
+
+func (r *loginThrottleRepo) Reserve(ctx context.Context, key string, policy models.LoginPolicy, now time.Time) (_ *models.LoginThrottle, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Make sure the row exists so concurrent attempts serialise on its lock
+	if _, err = tx.ExecContext(ctx,
+		`INSERT IGNORE INTO login_throttles (throttle_key, failures, last_failure_at) VALUES (?, 0, ?)`,
+		key, now); err != nil {
+		return nil, err
+	}
+	current, err := lockThrottle(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if current.RetryAfter(now) > 0 {
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return current, repository.ErrLoginThrottled
+	}
+
+	next := policy.Fail(*current, now)
+	if err = saveThrottle(ctx, tx, next); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+func (r *loginThrottleRepo) Release(ctx context.Context, key string, policy models.LoginPolicy) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockThrottle(ctx, tx, key)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := saveThrottle(ctx, tx, policy.Release(*current)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockThrottle reads the counters for key and locks the row until tx ends
+func lockThrottle(ctx context.Context, tx *sql.Tx, key string) (*models.LoginThrottle, error) {
+	return scanThrottle(tx.QueryRowContext(ctx,
+		`SELECT throttle_key, failures, locked_until, last_failure_at FROM login_throttles WHERE throttle_key = ? FOR UPDATE`, key))
+}
+
+// saveThrottle writes t back to its row
+func saveThrottle(ctx context.Context, tx *sql.Tx, t models.LoginThrottle) error {
+	var lockedUntil interface{}
+	if !t.LockedUntil.IsZero() {
+		lockedUntil = t.LockedUntil
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE login_throttles SET failures = ?, locked_until = ?, last_failure_at = ? WHERE throttle_key = ?`,
+		t.Failures, lockedUntil, t.LastFailure, t.Key)
+	return err
+}
+
+func (r *loginThrottleRepo) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_throttles WHERE throttle_key = ?`, key)
+	return err
+}
+
+func (r *loginThrottleRepo) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM login_throttles WHERE last_failure_at < ?`, now.Add(-staleThrottleAge))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,561 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type orderRepo struct {
+	db *sql.DB
+}
+
+func (r *orderRepo) Checkout(ctx context.Context, req models.CheckoutRequest) (res *models.CheckoutResult, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Rows are locked in a fixed order — user, stock holds, cart lines,
+	// products by id, voucher — so concurrent checkouts queue up instead of
+	// deadlocking, and every check below sees the latest committed values.
+
+	// 1. Lock the user row and fetch the balance
+	var balance int
+	if err = tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = ? FOR UPDATE", req.UserID).Scan(&balance); err != nil {
+		return nil, notFound(err)
+	}
+	// The buyer's unexpired holds on the lines bought count as available to
+	// them and are released below; holds on other lines stay
+	holds, err := lockHolds(ctx, tx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	buying := map[models.CartLine]bool{}
+	for _, l := range req.Lines {
+		buying[l] = true
+	}
+	now := time.Now()
+	held := map[models.CartLine]int{}
+	var released []models.StockReservation
+	for _, h := range holds {
+		if !buying[h.Line()] {
+			continue
+		}
+		if h.ExpiresAt.After(now) {
+			held[h.Line()] = h.Quantity
+		}
+		released = append(released, h)
+	}
+
+	// 2. Lock cart lines, products and variants for the selected lines
+	var items []models.OrderItem
+	for _, l := range repository.SortedUniqueLines(req.Lines) {
+		item := models.OrderItem{ProductID: l.ProductID, VariantID: l.VariantID}
+		err = tx.QueryRowContext(ctx, "SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ? AND variant_id = ? FOR UPDATE",
+			req.UserID, l.ProductID, l.VariantID).Scan(&item.Quantity)
+		if err == sql.ErrNoRows {
+			return nil, &repository.ItemError{Err: repository.ErrNotInCart, Item: repository.LineRef(l)}
+		} else if err != nil {
+			return nil, err
+		}
+		// price is DECIMAL in some deployments so scan via float64 first
+		var stock, reserved int
+		var priceFloat float64
+		err = tx.QueryRowContext(ctx, "SELECT name, price, stock, reserved, COALESCE(image_url,'') FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE", l.ProductID).
+			Scan(&item.ProductName, &priceFloat, &stock, &reserved, &item.ProductImage)
+		if err != nil {
+			return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+		}
+		item.Price = int(priceFloat)
+		if l.VariantID != 0 {
+			// Variant lines are charged the variant's price and draw on its stock
+			var options, image string
+			err = tx.QueryRowContext(ctx,
+				"SELECT sku, options, price, stock, reserved, image_url FROM product_variants WHERE id = ? AND product_id = ? FOR UPDATE",
+				l.VariantID, l.ProductID).Scan(&item.SKU, &options, &item.Price, &stock, &reserved, &image)
+			if err != nil {
+				return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+			}
+			if err = json.Unmarshal([]byte(options), &item.Options); err != nil {
+				return nil, err
+			}
+			if image != "" {
+				item.ProductImage = image
+			}
+		}
+		if models.AvailableStock(stock, reserved-held[l]) < item.Quantity {
+			err = repository.ErrInsufficientStock
+			return nil, &repository.ItemError{Err: err, Item: item.ProductName}
+		}
+		item.Subtotal = item.Price * item.Quantity
+		items = append(items, item)
+	}
+
+	// 3. Compute subtotal
+	subtotal := 0
+	for _, item := range items {
+		subtotal += item.Subtotal
+	}
+
+	// 4. Apply voucher if provided
+	discount := 0
+	if req.VoucherID > 0 {
+		var discountType string
+		var discountValueF, maxDiscountF, minPurchaseF float64 // all DECIMAL in DB — must scan as float64
+		var usageLimit, usedCount int
+		var isActive bool
+		var validUntil time.Time
+		row := tx.QueryRowContext(ctx, `SELECT type, discount_value, COALESCE(max_discount,0), min_purchase, usage_limit, used_count, is_active, valid_until FROM vouchers WHERE id = ? FOR UPDATE`, req.VoucherID)
+		if scanErr := row.Scan(&discountType, &discountValueF, &maxDiscountF, &minPurchaseF, &usageLimit, &usedCount, &isActive, &validUntil); scanErr != nil {
+			// Voucher not found — ignore silently
+		} else if isActive && validUntil.After(time.Now()) && (usageLimit == 0 || usedCount < usageLimit) && subtotal >= int(minPurchaseF) {
+			discount = repository.VoucherDiscount(discountType, int(discountValueF), int(maxDiscountF), subtotal)
+			var result sql.Result
+			result, err = tx.ExecContext(ctx,
+				"UPDATE vouchers SET used_count = used_count + 1 WHERE id = ? AND (usage_limit = 0 OR used_count < usage_limit)",
+				req.VoucherID,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("update voucher: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				discount = 0 // usage limit reached by a concurrent checkout
+			}
+		}
+	}
+
+	total := subtotal - discount
+	if total < 0 {
+		total = 0
+	}
+
+	// 5. Check balance
+	if balance < total {
+		err = repository.ErrInsufficientBalance
+		return nil, err
+	}
+
+	// 6. Create order — orders.id is AUTO_INCREMENT INT, order_number is the user-visible string
+	orderNumber := fmt.Sprintf("ORD-%d-%d", req.UserID, time.Now().UnixMilli())
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO orders (order_number, user_id, address_id, subtotal, discount_amount, total_amount, status) VALUES (?, ?, NULL, ?, ?, ?, 'pending')",
+		orderNumber, req.UserID, subtotal, discount, total,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create order: %w", err)
+	}
+	orderID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("get order ID: %w", err)
+	}
+	if err = insertStatusChange(ctx, tx, models.OrderStatusChange{
+		OrderID:   int(orderID),
+		ToStatus:  models.OrderPending,
+		ChangedBy: req.UserID,
+		Note:      "Order placed",
+	}); err != nil {
+		return nil, err
+	}
+
+	// 7. Debit the wallet through the ledger and increment total_spent; the
+	// ledger's balance guard is a second line of defence behind the row lock
+	if total > 0 {
+		if err = applyWalletEntry(ctx, tx, &models.WalletTransaction{
+			UserID:      req.UserID,
+			Type:        models.WalletPurchase,
+			Amount:      -total,
+			OrderID:     int(orderID),
+			Reference:   orderNumber,
+			Description: "Payment for order " + orderNumber,
+			CreatedBy:   req.UserID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET total_spent = total_spent + ? WHERE id = ?", total, req.UserID); err != nil {
+		return nil, fmt.Errorf("update total spent: %w", err)
+	}
+
+	// 8. Release the buyer's holds on these lines, then insert order items,
+	// reduce stock and delete cart items
+	if err = releaseHolds(ctx, tx, released); err != nil {
+		return nil, fmt.Errorf("release stock holds: %w", err)
+	}
+	for _, item := range items {
+		var options interface{}
+		if len(item.Options) > 0 {
+			encoded, jsonErr := json.Marshal(item.Options)
+			if jsonErr != nil {
+				err = jsonErr
+				return nil, err
+			}
+			options = string(encoded)
+		}
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO order_items (order_id, product_id, variant_id, sku, variant_options, product_name, product_image, quantity, price, subtotal)
+			 VALUES (?, ?, NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?)`,
+			orderID, item.ProductID, item.VariantID, item.SKU, options, item.ProductName, item.ProductImage, item.Quantity, item.Price, item.Subtotal,
+		); err != nil {
+			return nil, fmt.Errorf("insert order item: %w", err)
+		}
+		if item.VariantID != 0 {
+			result, err = tx.ExecContext(ctx, "UPDATE product_variants SET stock = stock - ? WHERE id = ? AND stock - reserved >= ?", item.Quantity, item.VariantID, item.Quantity)
+			if err != nil {
+				return nil, fmt.Errorf("update variant stock: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				err = repository.ErrInsufficientStock
+				return nil, &repository.ItemError{Err: err, Item: item.ProductName}
+			}
+		}
+		result, err = tx.ExecContext(ctx, "UPDATE products SET stock = stock - ? WHERE id = ? AND stock - reserved >= ?", item.Quantity, item.ProductID, item.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("update stock: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			err = repository.ErrInsufficientStock
+			return nil, &repository.ItemError{Err: err, Item: item.ProductName}
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ? AND product_id = ? AND variant_id = ?",
+			req.UserID, item.ProductID, item.VariantID); err != nil {
+			return nil, fmt.Errorf("clear cart: %w", err)
+		}
+	}
+
+	// 9. Commit
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &models.CheckoutResult{OrderNumber: orderNumber, Total: total, Discount: discount}, nil
+}
+
+func (r *orderRepo) ListByUser(ctx context.Context, userID int, limit int) ([]models.OrderSummary, error) {
+	where := ""
+	args := []interface{}{}
+	if userID > 0 {
+		where = "WHERE o.user_id = ?"
+		args = append(args, userID)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT o.order_number, o.user_id, u.full_name as user_name,
+			GROUP_CONCAT(p.name ORDER BY oi.id SEPARATOR ', ') as products,
+			SUM(oi.quantity) as total_qty, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at,
+			(SELECT COUNT(*) FROM reviews r WHERE r.order_id = o.id AND r.user_id = o.user_id) > 0 as has_reviewed
+		FROM orders o
+		JOIN users u ON u.id = o.user_id
+		JOIN order_items oi ON oi.order_id = o.id
+		JOIN products p ON p.id = oi.product_id
+		`+where+`
+		GROUP BY o.id, o.order_number, o.user_id, u.full_name, o.subtotal, o.discount_amount, o.total_amount, o.status, o.created_at
+		ORDER BY o.created_at DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.OrderSummary
+	for rows.Next() {
+		var o models.OrderSummary
+		var subtotalF, discountF, totalF float64
+		var hasReviewed int
+		if err := rows.Scan(&o.OrderNumber, &o.UserID, &o.UserName, &o.Products, &o.TotalQty,
+			&subtotalF, &discountF, &totalF, &o.Status, &o.CreatedAt, &hasReviewed); err != nil {
+			return nil, err
+		}
+		o.Subtotal = int(subtotalF)
+		o.Discount = int(discountF)
+		o.Total = int(totalF)
+		o.HasReviewed = hasReviewed == 1
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *orderRepo) Get(ctx context.Context, orderNumber string) (*models.Order, error) {
+	var o models.Order
+	var subtotalF, discountF, totalF float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, order_number, user_id, status, subtotal, discount_amount, total_amount, created_at
+		FROM orders WHERE order_number = ?
+	`, orderNumber).Scan(&o.ID, &o.OrderNumber, &o.UserID, &o.Status, &subtotalF, &discountF, &totalF, &o.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	o.Subtotal = int(subtotalF)
+	o.Discount = int(discountF)
+	o.Total = int(totalF)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT oi.id, oi.order_id, COALESCE(oi.product_id, 0),
+		       COALESCE(oi.variant_id, 0), oi.sku, COALESCE(oi.variant_options, ''),
+		       oi.product_name,
+		       COALESCE(NULLIF(oi.product_image,''), p.image_url, '') as img,
+		       oi.quantity, oi.price, oi.subtotal
+		FROM order_items oi
+		LEFT JOIN products p ON p.id = oi.product_id
+		WHERE oi.order_id = ?
+		ORDER BY oi.id ASC
+	`, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		var pf, sf float64
+		var options string
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.VariantID, &item.SKU, &options,
+			&item.ProductName, &item.ProductImage, &item.Quantity, &pf, &sf); err != nil {
+			return nil, err
+		}
+		if options != "" {
+			if err := json.Unmarshal([]byte(options), &item.Options); err != nil {
+				return nil, err
+			}
+		}
+		item.Price = int(pf)
+		item.Subtotal = int(sf)
+		o.Items = append(o.Items, item)
+	}
+	return &o, rows.Err()
+}
+
+func (r *orderRepo) Transition(ctx context.Context, change models.OrderStatusChange) (err error) {
+	var userID int
+	var orderNumber string
+	var totalAmountF float64
+	if err = r.db.QueryRowContext(ctx, "SELECT user_id, order_number, total_amount FROM orders WHERE id = ?", change.OrderID).Scan(&userID, &orderNumber, &totalAmountF); err != nil {
+		return notFound(err)
+	}
+	totalAmount := int(totalAmountF)
+
+	// Collect items to restore BEFORE opening the transaction (avoid interleaving Query+Exec on same conn)
+	type stockItem struct {
+		productID int
+		variantID int
+		qty       int
+	}
+	var toRestore []stockItem
+	if change.ToStatus == models.OrderCancelled {
+		rows, err := r.db.QueryContext(ctx, "SELECT product_id, COALESCE(variant_id, 0), quantity FROM order_items WHERE order_id = ?", change.OrderID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var si stockItem
+			if err := rows.Scan(&si.productID, &si.variantID, &si.qty); err != nil {
+				rows.Close()
+				return err
+			}
+			toRestore = append(toRestore, si)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The update only matches while the order is still in FromStatus, so two
+	// concurrent changes (e.g. a double cancel) cannot both apply
+	result, err := tx.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ? AND status = ?",
+		change.ToStatus, change.OrderID, change.FromStatus)
+	if err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		err = repository.ErrStatusConflict
+		return err
+	}
+
+	if change.ToStatus == models.OrderCancelled {
+		// Refund through the ledger and decrement total_spent, then restore
+		// stock by product id — the same lock order Checkout uses
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE users SET total_spent = GREATEST(0, total_spent - ?) WHERE id = ?",
+			totalAmount, userID,
+		); err != nil {
+			return fmt.Errorf("update total spent: %w", err)
+		}
+		if totalAmount > 0 {
+			if err = applyWalletEntry(ctx, tx, &models.WalletTransaction{
+				UserID:      userID,
+				Type:        models.WalletRefund,
+				Amount:      totalAmount,
+				OrderID:     change.OrderID,
+				Reference:   orderNumber,
+				Description: "Refund for cancelled order " + orderNumber,
+				CreatedBy:   change.ChangedBy,
+			}); err != nil {
+				return fmt.Errorf("refund balance: %w", err)
+			}
+		}
+		sort.Slice(toRestore, func(i, j int) bool {
+			if toRestore[i].productID != toRestore[j].productID {
+				return toRestore[i].productID < toRestore[j].productID
+			}
+			return toRestore[i].variantID < toRestore[j].variantID
+		})
+		for _, si := range toRestore {
+			// Lock the product before its variant, as Checkout does; a
+			// product that was hard-deleted since checkout takes nothing back
+			var locked int
+			err = tx.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ? FOR UPDATE", si.productID).Scan(&locked)
+			if err == sql.ErrNoRows {
+				err = nil
+				continue
+			} else if err != nil {
+				return fmt.Errorf("lock product: %w", err)
+			}
+			if si.variantID != 0 {
+				// A variant that was removed since checkout takes nothing back
+				result, err = tx.ExecContext(ctx, "UPDATE product_variants SET stock = stock + ? WHERE id = ?", si.qty, si.variantID)
+				if err != nil {
+					return fmt.Errorf("restore variant stock: %w", err)
+				}
+				if n, _ := result.RowsAffected(); n == 0 {
+					continue
+				}
+			}
+			if _, err = tx.ExecContext(ctx, "UPDATE products SET stock = stock + ? WHERE id = ?", si.qty, si.productID); err != nil {
+				return fmt.Errorf("restore stock: %w", err)
+			}
+		}
+	}
+
+	if err = insertStatusChange(ctx, tx, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertStatusChange appends one row to order_status_history
+func insertStatusChange(ctx context.Context, tx *sql.Tx, c models.OrderStatusChange) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, actor_role, note)
+		 VALUES (?, NULLIF(?, ''), ?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''))`,
+		c.OrderID, c.FromStatus, c.ToStatus, c.ChangedBy, c.ActorRole, c.Note,
+	)
+	if err != nil {
+		return fmt.Errorf("record status history: %w", err)
+	}
+	return nil
+}
+
+func (r *orderRepo) History(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, order_id, COALESCE(from_status,''), to_status, COALESCE(changed_by,0),
+		       COALESCE(actor_role,''), COALESCE(note,''), created_at
+		FROM order_status_history
+		WHERE order_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.OrderStatusChange
+	for rows.Next() {
+		var c models.OrderStatusChange
+		if err := rows.Scan(&c.ID, &c.OrderID, &c.FromStatus, &c.ToStatus, &c.ChangedBy, &c.ActorRole, &c.Note, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+func (r *orderRepo) Stats(ctx context.Context, userID int) (*models.OrderStats, error) {
+	scope, args := "1=1", []interface{}{}
+	if userID > 0 {
+		scope, args = "user_id = ?", []interface{}{userID}
+	}
+
+	var s models.OrderStats
+	r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE "+scope, args...).Scan(&s.TotalOrders)
+	r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE "+scope+" AND status = 'pending'", args...).Scan(&s.PendingOrders)
+	r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE "+scope+" AND status IN ('delivered','completed')", args...).Scan(&s.CompletedOrders)
+	r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE "+scope+" AND YEAR(created_at) = YEAR(NOW())", args...).Scan(&s.ThisYearOrders)
+
+	// Monthly spending — last 6 months
+	mRows, err := r.db.QueryContext(ctx, `
+		SELECT DATE_FORMAT(created_at, '%b') as month, SUM(total_amount) as amount
+		FROM orders
+		WHERE `+scope+` AND status = 'delivered' AND created_at >= DATE_SUB(NOW(), INTERVAL 6 MONTH)
+		GROUP BY YEAR(created_at), MONTH(created_at), DATE_FORMAT(created_at, '%b')
+		ORDER BY YEAR(created_at), MONTH(created_at)
+	`, args...)
+	if err == nil {
+		defer mRows.Close()
+		for mRows.Next() {
+			var m models.MonthlySpending
+			var amountF float64
+			mRows.Scan(&m.Month, &amountF)
+			m.Amount = int(amountF)
+			s.MonthlySpending = append(s.MonthlySpending, m)
+		}
+	}
+
+	// Category breakdown (spending by product category)
+	catScope := "1=1"
+	if userID > 0 {
+		catScope = "o.user_id = ?"
+	}
+	cRows, err := r.db.QueryContext(ctx, `
+		SELECT COALESCE(c.name, 'Other') as cat, SUM(oi.price * oi.quantity) as total
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		JOIN products p ON p.id = oi.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE `+catScope+` AND o.status = 'delivered'
+		GROUP BY cat
+		ORDER BY total DESC
+		LIMIT 5
+	`, args...)
+	if err == nil {
+		defer cRows.Close()
+		for cRows.Next() {
+			var c models.CategorySpending
+			var valueF float64
+			cRows.Scan(&c.Name, &valueF)
+			c.Value = int(valueF)
+			s.CategoryBreakdown = append(s.CategoryBreakdown, c)
+		}
+	}
+
+	repository.FillPercentages(&s)
+	return &s, nil
+}
+
+func (r *orderRepo) Revenue(ctx context.Context) (int, error) {
+	var total sql.NullInt64
+	err := r.db.QueryRowContext(ctx, "SELECT SUM(total_amount) FROM orders WHERE status IN ('delivered','completed')").Scan(&total)
+	return int(total.Int64), err
+}
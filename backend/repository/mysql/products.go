@@ -0,0 +1,597 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type productRepo struct {
+	db *sql.DB
+}
+
+const productColumns = `p.id, p.name, COALESCE(p.slug,''), p.price, p.stock, p.reserved, c.name, p.rating, COALESCE(p.total_reviews,0),
+	p.description, p.image_url, p.brand, p.created_at, p.deleted_at`
+
+func scanProduct(row interface{ Scan(...interface{}) error }) (*models.Product, error) {
+	var p models.Product
+	var imageURL, description, brand, category sql.NullString
+	var priceFloat, ratingFloat float64
+	var deletedAt sql.NullTime
+
+	err := row.Scan(&p.ID, &p.Name, &p.Slug, &priceFloat, &p.Stock, &p.Reserved,
+		&category, &ratingFloat, &p.TotalReviews, &description, &imageURL, &brand, &p.CreatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Price = int(priceFloat)
+	p.Rating = models.Decimal(ratingFloat)
+	p.Description = description.String
+	p.Image = imageURL.String
+	p.Brand = brand.String
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+	if category.Valid {
+		p.Category = category.String
+	} else {
+		p.Category = "Uncategorized"
+	}
+	return &p, nil
+}
+
+func (r *productRepo) queryProducts(ctx context.Context, query string, args ...interface{}) ([]models.Product, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *p)
+	}
+	return products, rows.Err()
+}
+
+func (r *productRepo) List(ctx context.Context, limit int) ([]models.Product, error) {
+	query := `SELECT ` + productColumns + `
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.deleted_at IS NULL
+		ORDER BY p.id ASC`
+	if limit > 0 {
+		return r.queryProducts(ctx, query+` LIMIT ?`, limit)
+	}
+	return r.queryProducts(ctx, query)
+}
+
+// productFilter builds the WHERE clause (over products p and categories c)
+// for the filters of q, including the spec filters when withSpecs is set
+func productFilter(q models.ProductQuery, withSpecs bool) (string, []interface{}) {
+	where := []string{`p.deleted_at IS NULL`}
+	if q.Archived {
+		where[0] = `p.deleted_at IS NOT NULL`
+	}
+	var args []interface{}
+	if len(q.Categories) > 0 {
+		where = append(where, `LOWER(c.name) IN (`+placeholders(len(q.Categories))+`)`)
+		for _, c := range q.Categories {
+			args = append(args, strings.ToLower(c))
+		}
+	}
+	if len(q.Brands) > 0 {
+		where = append(where, `LOWER(p.brand) IN (`+placeholders(len(q.Brands))+`)`)
+		for _, b := range q.Brands {
+			args = append(args, strings.ToLower(b))
+		}
+	}
+	if q.MinPrice > 0 {
+		where = append(where, `p.price >= ?`)
+		args = append(args, q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		where = append(where, `p.price <= ?`)
+		args = append(args, q.MaxPrice)
+	}
+	if q.InStock {
+		where = append(where, `p.stock - p.reserved > 0`)
+	}
+	if q.MinRating > 0 {
+		where = append(where, `p.rating >= ?`)
+		args = append(args, q.MinRating)
+	}
+	if withSpecs {
+		for _, f := range q.Specs {
+			cond, condArgs := specCondition(f)
+			where = append(where, `EXISTS (SELECT 1 FROM product_specifications ps
+				WHERE ps.product_id = p.id AND LOWER(ps.spec_key) = ? AND `+cond+`)`)
+			args = append(args, strings.ToLower(f.Key))
+			args = append(args, condArgs...)
+		}
+	}
+
+	return ` WHERE ` + strings.Join(where, ` AND `), args
+}
+
+// specCondition is the SQL form of models.SpecFilter.Matches for the row ps
+func specCondition(f models.SpecFilter) (string, []interface{}) {
+	var args []interface{}
+	switch f.Op {
+	case models.SpecEq:
+		cond := `LOWER(ps.spec_value) IN (` + placeholders(len(f.Values)) + `)`
+		for _, v := range f.Values {
+			args = append(args, strings.ToLower(v))
+		}
+		if numbers := f.Numbers(); len(numbers) > 0 {
+			cond += ` OR (ps.value_type = 'number' AND ps.value_number IN (` + placeholders(len(numbers)) + `))`
+			for _, n := range numbers {
+				args = append(args, n)
+			}
+		}
+		return `(` + cond + `)`, args
+	case models.SpecGt, models.SpecGte, models.SpecLt, models.SpecLte:
+		return `ps.value_type = 'number' AND ps.value_number ` + f.Op + ` ?`, []interface{}{f.Number}
+	}
+	return `FALSE`, nil
+}
+
+func (r *productRepo) SpecFacets(ctx context.Context, q models.ProductQuery) ([]models.SpecFacet, error) {
+	filter, args := productFilter(q, false)
+	rows, err := r.db.QueryContext(ctx, `SELECT ps.spec_key, COALESCE(ps.spec_value,''), ps.value_type, ps.value_number, ps.unit,
+			COALESCE(c.name,''), COUNT(DISTINCT ps.product_id)
+		FROM product_specifications ps
+		JOIN products p ON p.id = ps.product_id
+		LEFT JOIN categories c ON p.category_id = c.id`+filter+`
+		GROUP BY c.name, ps.spec_key, ps.spec_value, ps.value_type, ps.value_number, ps.unit
+		ORDER BY c.name, MIN(ps.display_order), ps.spec_key`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets := []models.SpecFacet{}
+	for rows.Next() {
+		var category string
+		var count int
+		s, err := scanSpec(rows, &category, &count)
+		if err != nil {
+			return nil, err
+		}
+		facets = models.AddSpecFacetValue(facets, category, *s, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	models.FinishSpecFacets(facets)
+	return facets, nil
+}
+
+func (r *productRepo) Query(ctx context.Context, q models.ProductQuery) ([]models.Product, int, error) {
+	filter, args := productFilter(q, true)
+	from := ` FROM products p LEFT JOIN categories c ON p.category_id = c.id`
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*)`+from+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// p.id breaks ties so pages never overlap
+	order := ` ORDER BY p.id ASC`
+	switch q.Sort {
+	case models.SortPriceAsc:
+		order = ` ORDER BY p.price ASC, p.id ASC`
+	case models.SortPriceDesc:
+		order = ` ORDER BY p.price DESC, p.id ASC`
+	case models.SortRating:
+		order = ` ORDER BY p.rating DESC, p.total_reviews DESC, p.id ASC`
+	case models.SortNewest:
+		order = ` ORDER BY p.created_at DESC, p.id DESC`
+	case models.SortBestSelling:
+		from += ` LEFT JOIN (
+			SELECT oi.product_id, SUM(oi.quantity) AS sold
+			FROM order_items oi JOIN orders o ON o.id = oi.order_id
+			WHERE o.status <> 'cancelled'
+			GROUP BY oi.product_id
+		) s ON s.product_id = p.id`
+		order = ` ORDER BY COALESCE(s.sold, 0) DESC, p.id ASC`
+	}
+
+	query := `SELECT ` + productColumns + from + filter + order
+	if q.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, q.Limit, q.Offset)
+	}
+	products, err := r.queryProducts(ctx, query, args...)
+	return products, total, err
+}
+
+func (r *productRepo) GetByID(ctx context.Context, id int) (*models.Product, error) {
+	p, err := scanProduct(r.db.QueryRowContext(ctx, `SELECT `+productColumns+`
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	p.Specifications, err = r.specifications(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Options, p.Variants, err = r.Variants(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Images, err = productImages(ctx, r.db, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *productRepo) FindBySlug(ctx context.Context, slug string, includeArchived bool) (*models.Product, error) {
+	p, err := scanProduct(r.db.QueryRowContext(ctx, `SELECT `+productColumns+`
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE (p.slug = ? OR p.id = (SELECT product_id FROM product_slug_history WHERE slug = ?))
+		  AND (? OR p.deleted_at IS NULL)
+		ORDER BY p.slug = ? DESC LIMIT 1`, slug, slug, includeArchived, slug))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+func (r *productRepo) FindBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	p, err := scanProduct(r.db.QueryRowContext(ctx, `SELECT `+productColumns+`
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE v.sku = ?`, sku))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return p, nil
+}
+
+const specColumns = `spec_key, COALESCE(spec_value,''), value_type, value_number, unit`
+
+// scanSpec scans specColumns followed by extra columns
+func scanSpec(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.ProductSpec, error) {
+	var s models.ProductSpec
+	var number sql.NullFloat64
+	if err := row.Scan(append([]interface{}{&s.Key, &s.Value, &s.Type, &number, &s.Unit}, extra...)...); err != nil {
+		return nil, err
+	}
+	if number.Valid {
+		n := number.Float64
+		s.Number = &n
+	}
+	return &s, nil
+}
+
+// specifications returns all spec rows for a product
+func (r *productRepo) specifications(ctx context.Context, productID int) ([]models.ProductSpec, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+specColumns+` FROM product_specifications
+		 WHERE product_id = ? ORDER BY display_order ASC`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var specs []models.ProductSpec
+	for rows.Next() {
+		s, err := scanSpec(rows)
+		if err != nil {
+			continue
+		}
+		specs = append(specs, *s)
+	}
+	return specs, rows.Err()
+}
+
+func (r *productRepo) ListByIDs(ctx context.Context, ids []int) ([]models.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return r.queryProducts(ctx, `SELECT `+productColumns+`
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE p.id IN (`+placeholders(len(ids))+`)`, args...)
+}
+
+func (r *productRepo) ListWithSpecifications(ctx context.Context) ([]models.Product, error) {
+	products, err := r.List(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+specColumns+`, product_id FROM product_specifications ORDER BY product_id, display_order`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	specs := map[int][]models.ProductSpec{}
+	for rows.Next() {
+		var id int
+		s, err := scanSpec(rows, &id)
+		if err != nil {
+			return nil, err
+		}
+		specs[id] = append(specs[id], *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range products {
+		products[i].Specifications = specs[products[i].ID]
+	}
+	return products, nil
+}
+
+// categoryID resolves a category name; ErrUnknownCategory if there is none
+func categoryID(ctx context.Context, q rowQueryer, name string) (int, error) {
+	var id int
+	err := q.QueryRowContext(ctx, `SELECT id FROM categories WHERE LOWER(name) = LOWER(?)`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, repository.ErrUnknownCategory
+	}
+	return id, err
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowExecer is satisfied by both *sql.DB and *sql.Tx
+type rowExecer interface {
+	rowQueryer
+	execer
+}
+
+// freeSlug returns base, or the first of base-2, base-3, ... that no other
+// product has as its current or former slug
+func freeSlug(ctx context.Context, q rowQueryer, base string, productID int) (string, error) {
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		var taken bool
+		err := q.QueryRowContext(ctx, `SELECT
+			EXISTS (SELECT 1 FROM products WHERE slug = ? AND id <> ?)
+			OR EXISTS (SELECT 1 FROM product_slug_history WHERE slug = ? AND product_id <> ?)`,
+			slug, productID, slug, productID).Scan(&taken)
+		if err != nil || !taken {
+			return slug, err
+		}
+	}
+}
+
+// slugAttempts is how often Create, Update and Import retry when a
+// concurrent request takes the slug they picked
+const slugAttempts = 3
+
+// retrySlug runs fn until it does not fail on the unique slug index
+func retrySlug(fn func() error) error {
+	var err error
+	for i := 0; i < slugAttempts; i++ {
+		if err = fn(); !isDuplicate(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *productRepo) Create(ctx context.Context, p *models.Product) error {
+	category, err := categoryID(ctx, r.db, p.Category)
+	if err != nil {
+		return err
+	}
+	return retrySlug(func() error {
+		id, slug, err := insertProduct(ctx, r.db, p, category)
+		if err != nil {
+			return err
+		}
+		p.ID, p.Slug = id, slug
+		return nil
+	})
+}
+
+// insertProduct inserts p under the first free slug for p.Slug and returns
+// its ID and slug
+func insertProduct(ctx context.Context, q rowExecer, p *models.Product, category int) (int, string, error) {
+	slug, err := freeSlug(ctx, q, p.Slug, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	result, err := q.ExecContext(ctx,
+		`INSERT INTO products (name, slug, price, stock, category_id, rating, description, image_url, brand)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Name, slug, p.Price, p.Stock, category, float64(p.Rating), p.Description, p.Image, p.Brand)
+	if err != nil {
+		return 0, "", err
+	}
+	id, err := result.LastInsertId()
+	return int(id), slug, err
+}
+
+func (r *productRepo) Update(ctx context.Context, p *models.Product) error {
+	var id int
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ?`, p.ID).Scan(&id); err != nil {
+		return notFound(err)
+	}
+
+	category, err := categoryID(ctx, r.db, p.Category)
+	if err != nil {
+		return err
+	}
+
+	return retrySlug(func() error {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		slug, err := updateProduct(ctx, tx, p, category)
+		if err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		p.Slug = slug
+		return nil
+	})
+}
+
+// updateProduct saves p in tx and returns its slug, which is the current one
+// when p.Slug is empty and else the first free slug for p.Slug
+func updateProduct(ctx context.Context, tx *sql.Tx, p *models.Product, category int) (string, error) {
+	var current string
+	if err := tx.QueryRowContext(ctx, `SELECT slug FROM products WHERE id = ? FOR UPDATE`, p.ID).Scan(&current); err != nil {
+		return "", notFound(err)
+	}
+	slug := current
+	if p.Slug != "" && p.Slug != current {
+		var err error
+		if slug, err = freeSlug(ctx, tx, p.Slug, p.ID); err != nil {
+			return "", err
+		}
+	}
+	if slug != current {
+		// The old slug keeps redirecting here; a slug taken back is no longer history
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_slug_history (slug, product_id) VALUES (?, ?)
+			 ON DUPLICATE KEY UPDATE product_id = VALUES(product_id), created_at = CURRENT_TIMESTAMP`,
+			current, p.ID); err != nil {
+			return "", err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_slug_history WHERE slug = ?`, slug); err != nil {
+			return "", err
+		}
+	}
+
+	// rating is intentionally excluded — it is derived from reviews
+	_, err := tx.ExecContext(ctx,
+		`UPDATE products SET name = ?, slug = ?, price = ?, stock = ?, category_id = ?, description = ?, image_url = ?, brand = ? WHERE id = ?`,
+		p.Name, slug, p.Price, p.Stock, category, p.Description, p.Image, p.Brand, p.ID)
+	return slug, err
+}
+
+func (r *productRepo) ReplaceSpecifications(ctx context.Context, productID int, specs []models.ProductSpec) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceSpecifications(ctx, tx, productID, specs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceSpecifications deletes the product's spec rows and inserts specs in order
+func replaceSpecifications(ctx context.Context, tx *sql.Tx, productID int, specs []models.ProductSpec) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_specifications WHERE product_id = ?`, productID); err != nil {
+		return err
+	}
+	for i, s := range specs {
+		if s.Value == "" {
+			continue
+		}
+		valueType := s.Type
+		if valueType == "" {
+			valueType = models.SpecText
+		}
+		var number interface{}
+		if s.Number != nil {
+			number = *s.Number
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_specifications (product_id, spec_key, spec_value, value_type, value_number, unit, display_order)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			productID, s.Key, s.Value, valueType, number, s.Unit, i+1,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *productRepo) Import(ctx context.Context, items []models.ProductImportItem) error {
+	return retrySlug(func() error {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Items are only changed once the transaction committed so a retry
+		// starts over from the requested slugs
+		saved := make([]models.Product, len(items))
+		for i, item := range items {
+			p := item.Product
+			category, err := categoryID(ctx, tx, p.Category)
+			if err != nil {
+				return err
+			}
+			if p.ID == 0 {
+				p.ID, p.Slug, err = insertProduct(ctx, tx, &p, category)
+			} else {
+				p.Slug, err = updateProduct(ctx, tx, &p, category)
+			}
+			if err != nil {
+				return err
+			}
+			if item.ReplaceSpecs {
+				if err := replaceSpecifications(ctx, tx, p.ID, item.Specs); err != nil {
+					return err
+				}
+			}
+			saved[i] = p
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].Product.ID, items[i].Product.Slug = saved[i].ID, saved[i].Slug
+		}
+		return nil
+	})
+}
+
+// setDeletedAt sets deleted_at to value on the product, or returns ErrNotFound
+func (r *productRepo) setDeletedAt(ctx context.Context, id int, value string) error {
+	var found int
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ?`, id).Scan(&found); err != nil {
+		return notFound(err)
+	}
+	_, err := r.db.ExecContext(ctx, `UPDATE products SET deleted_at = `+value+` WHERE id = ?`, id)
+	return err
+}
+
+func (r *productRepo) Archive(ctx context.Context, id int) error {
+	return r.setDeletedAt(ctx, id, `COALESCE(deleted_at, CURRENT_TIMESTAMP)`)
+}
+
+func (r *productRepo) Restore(ctx context.Context, id int) error {
+	return r.setDeletedAt(ctx, id, `NULL`)
+}
@@ -0,0 +1,291 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type reservationRepo struct {
+	db *sql.DB
+}
+
+const reservationColumns = `id, user_id, product_id, variant_id, quantity, expires_at, created_at`
+
+func scanReservations(rows *sql.Rows) ([]models.StockReservation, error) {
+	defer rows.Close()
+	var holds []models.StockReservation
+	for rows.Next() {
+		var h models.StockReservation
+		if err := rows.Scan(&h.ID, &h.UserID, &h.ProductID, &h.VariantID, &h.Quantity, &h.ExpiresAt, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+// lockHolds locks and returns the user's holds
+func lockHolds(ctx context.Context, tx *sql.Tx, userID int) ([]models.StockReservation, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+reservationColumns+` FROM stock_reservations WHERE user_id = ? ORDER BY product_id, variant_id FOR UPDATE`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanReservations(rows)
+}
+
+// releaseHolds gives the held units back to their products and variants and
+// deletes the holds
+func releaseHolds(ctx context.Context, tx *sql.Tx, holds []models.StockReservation) error {
+	for _, h := range holds {
+		if h.VariantID != 0 {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE product_variants SET reserved = GREATEST(reserved - ?, 0) WHERE id = ?`, h.Quantity, h.VariantID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE products SET reserved = GREATEST(reserved - ?, 0) WHERE id = ?`, h.Quantity, h.ProductID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM stock_reservations WHERE id = ?`, h.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heldStock is a locked product or variant row seen by Reserve
+type heldStock struct {
+	name            string
+	stock, reserved int
+}
+
+func (r *reservationRepo) Reserve(ctx context.Context, userID int, lines []models.CartLine, expiresAt time.Time) ([]models.StockReservation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Rows are locked in Checkout's order — user, holds, cart lines, products
+	// by id — so a reservation and a checkout of the same items queue up
+	var id int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id); err != nil {
+		return nil, notFound(err)
+	}
+	old, err := lockHolds(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines = repository.SortedUniqueLines(lines)
+	quantities := make([]int, len(lines))
+	for i, l := range lines {
+		err := tx.QueryRowContext(ctx,
+			`SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ? AND variant_id = ? FOR UPDATE`,
+			userID, l.ProductID, l.VariantID).Scan(&quantities[i])
+		if err == sql.ErrNoRows {
+			return nil, &repository.ItemError{Err: repository.ErrNotInCart, Item: repository.LineRef(l)}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	// Lock every product and variant the old and new holds touch, and count
+	// the units held by other users
+	touched := make([]models.CartLine, 0, len(old)+len(lines))
+	for _, h := range old {
+		touched = append(touched, h.Line())
+	}
+	touched = append(touched, lines...)
+	products := map[int]*heldStock{}
+	variants := map[int]*heldStock{}
+	for _, l := range repository.SortedUniqueLines(touched) {
+		if _, ok := products[l.ProductID]; !ok {
+			s := &heldStock{}
+			var live bool
+			err := tx.QueryRowContext(ctx,
+				`SELECT name, stock, reserved, deleted_at IS NULL FROM products WHERE id = ? FOR UPDATE`, l.ProductID).
+				Scan(&s.name, &s.stock, &s.reserved, &live)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if err == nil && live {
+				products[l.ProductID] = s
+			}
+		}
+		if l.VariantID != 0 {
+			s := &heldStock{}
+			err := tx.QueryRowContext(ctx,
+				`SELECT stock, reserved FROM product_variants WHERE id = ? AND product_id = ? FOR UPDATE`, l.VariantID, l.ProductID).
+				Scan(&s.stock, &s.reserved)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+			if err == nil {
+				variants[l.VariantID] = s
+			}
+		}
+	}
+	for _, h := range old {
+		if p := products[h.ProductID]; p != nil {
+			p.reserved -= h.Quantity
+		}
+		if v := variants[h.VariantID]; h.VariantID != 0 && v != nil {
+			v.reserved -= h.Quantity
+		}
+	}
+
+	for i, l := range lines {
+		p := products[l.ProductID]
+		if p == nil {
+			return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+		}
+		stock := p
+		if l.VariantID != 0 {
+			if stock = variants[l.VariantID]; stock == nil {
+				return nil, &repository.ItemError{Err: repository.ErrProductNotFound, Item: repository.LineRef(l)}
+			}
+		}
+		if models.AvailableStock(stock.stock, stock.reserved) < quantities[i] {
+			return nil, &repository.ItemError{Err: repository.ErrInsufficientStock, Item: p.name}
+		}
+		stock.reserved += quantities[i]
+		if l.VariantID != 0 {
+			p.reserved += quantities[i]
+		}
+	}
+
+	// Every line fits — swap the old holds for the new ones
+	if err := releaseHolds(ctx, tx, old); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	holds := make([]models.StockReservation, 0, len(lines))
+	for i, l := range lines {
+		h := models.StockReservation{
+			UserID:    userID,
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Quantity:  quantities[i],
+			ExpiresAt: expiresAt,
+			CreatedAt: now,
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO stock_reservations (user_id, product_id, variant_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)`,
+			userID, l.ProductID, l.VariantID, h.Quantity, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		h.ID = int(newID)
+		if l.VariantID != 0 {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE product_variants SET reserved = reserved + ? WHERE id = ?`, h.Quantity, l.VariantID); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE products SET reserved = reserved + ? WHERE id = ?`, h.Quantity, l.ProductID); err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (r *reservationRepo) List(ctx context.Context, userID int) ([]models.StockReservation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+reservationColumns+` FROM stock_reservations WHERE user_id = ? ORDER BY product_id, variant_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanReservations(rows)
+}
+
+func (r *reservationRepo) Release(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	holds, err := lockHolds(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := releaseHolds(ctx, tx, holds); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *reservationRepo) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT user_id FROM stock_reservations WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	var users []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		users = append(users, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// One transaction per user keeps the product locks short; a hold that
+	// was renewed in the meantime is skipped by the expiry re-check
+	released := 0
+	for _, userID := range users {
+		n, err := r.releaseExpiredOf(ctx, userID, now)
+		if err != nil {
+			return released, err
+		}
+		released += n
+	}
+	return released, nil
+}
+
+func (r *reservationRepo) releaseExpiredOf(ctx context.Context, userID int, now time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	holds, err := lockHolds(ctx, tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.StockReservation
+	for _, h := range holds {
+		if !h.ExpiresAt.After(now) {
+			expired = append(expired, h)
+		}
+	}
+	if err := releaseHolds(ctx, tx, expired); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
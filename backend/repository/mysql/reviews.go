@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+type reviewRepo struct {
+	db *sql.DB
+}
+
+func (r *reviewRepo) ListByProduct(ctx context.Context, productID int) ([]models.Review, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.id, r.product_id, r.user_id, r.order_id,
+		       COALESCE(NULLIF(u.full_name,''), u.email, 'Anonymous'),
+		       r.rating,
+		       COALESCE(r.review_text, ''),
+		       r.is_verified,
+		       r.created_at
+		FROM reviews r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.product_id = ?
+		ORDER BY r.created_at DESC
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []models.Review
+	for rows.Next() {
+		var rev models.Review
+		var isVerified int
+		if err := rows.Scan(&rev.ID, &rev.ProductID, &rev.UserID, &rev.OrderID, &rev.UserName,
+			&rev.Rating, &rev.ReviewText, &isVerified, &rev.CreatedAt); err != nil {
+			continue
+		}
+		rev.IsVerified = isVerified == 1
+		reviews = append(reviews, rev)
+	}
+	return reviews, rows.Err()
+}
+
+func (r *reviewRepo) Upsert(ctx context.Context, rev models.Review) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO reviews (product_id, user_id, order_id, rating, review_text, is_verified, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 1, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE rating = VALUES(rating), review_text = VALUES(review_text), updated_at = NOW()
+	`, rev.ProductID, rev.UserID, rev.OrderID, rev.Rating, rev.ReviewText)
+	if err != nil {
+		return err
+	}
+
+	// Recalculate product average rating and review count
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE products SET
+			rating        = (SELECT AVG(rating)   FROM reviews WHERE product_id = ?),
+			total_reviews = (SELECT COUNT(*)       FROM reviews WHERE product_id = ?)
+		WHERE id = ?
+	`, rev.ProductID, rev.ProductID, rev.ProductID)
+	return err
+}
@@ -0,0 +1,61 @@
+// Package mysql implements the repository interfaces on top of a MySQL *sql.DB.
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// New returns a repository.Store backed by db
+func New(db *sql.DB) *repository.Store {
+	return &repository.Store{
+		Users:          &userRepo{db: db},
+		Products:       &productRepo{db: db},
+		Categories:     &categoryRepo{db: db},
+		Images:         &imageRepo{db: db},
+		Carts:          &cartRepo{db: db},
+		Orders:         &orderRepo{db: db},
+		Reservations:   &reservationRepo{db: db},
+		Vouchers:       &voucherRepo{db: db},
+		Reviews:        &reviewRepo{db: db},
+		Wallet:         &walletRepo{db: db},
+		Tokens:         &tokenRepo{db: db},
+		Idempotency:    &idempotencyRepo{db: db},
+		LoginThrottles: &loginThrottleRepo{db: db},
+	}
+}
+
+// notFound maps sql.ErrNoRows to repository.ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// isDuplicate reports whether err is a MySQL unique-key violation
+func isDuplicate(err error) bool {
+	var myErr *driver.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1062
+}
+
+// affectedOrNotFound returns ErrNotFound when an UPDATE/DELETE touched no rows
+func affectedOrNotFound(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// placeholders returns "?, ?, ..." with n markers for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,163 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type tokenRepo struct {
+	db *sql.DB
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertRefresh(ctx context.Context, exec execer, t *models.RefreshToken) error {
+	result, err := exec.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at) VALUES (?, ?, ?, ?)`,
+		t.UserID, t.TokenHash, t.FamilyID, t.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	t.ID = int(id)
+	return nil
+}
+
+func (r *tokenRepo) CreateRefresh(ctx context.Context, t *models.RefreshToken) error {
+	return insertRefresh(ctx, r.db, t)
+}
+
+func (r *tokenRepo) GetRefresh(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, created_at
+		 FROM refresh_tokens WHERE token_hash = ?`, hash,
+	).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.FamilyID, &t.ExpiresAt, &revokedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+func (r *tokenRepo) RotateRefresh(ctx context.Context, oldID int, next *models.RefreshToken) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now(), oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		err = repository.ErrTokenReused
+		return err
+	}
+	if err = insertRefresh(ctx, tx, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *tokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`, time.Now(), familyID)
+	return err
+}
+
+func (r *tokenRepo) RevokeUser(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, time.Now(), userID)
+	return err
+}
+
+func (r *tokenRepo) DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	// Entries are only needed until the token would have expired anyway
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < ?`, time.Now()); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_access_tokens (jti, expires_at) VALUES (?, ?)
+		 ON DUPLICATE KEY UPDATE expires_at = VALUES(expires_at)`, jti, expiresAt)
+	return err
+}
+
+func (r *tokenRepo) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM revoked_access_tokens WHERE jti = ?`, jti).Scan(&n)
+	return n > 0, err
+}
+
+func (r *tokenRepo) CreateAction(ctx context.Context, t *models.ActionToken) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE action_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		time.Now(), t.UserID, t.Purpose); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO action_tokens (user_id, purpose, email, token_hash, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		t.UserID, t.Purpose, t.Email, t.TokenHash, t.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	t.ID = int(id)
+	return tx.Commit()
+}
+
+func (r *tokenRepo) ConsumeAction(ctx context.Context, purpose, hash string) (*models.ActionToken, error) {
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE action_tokens SET used_at = ?
+		 WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		now, hash, purpose, now)
+	if err != nil {
+		return nil, err
+	}
+	consumed, _ := result.RowsAffected()
+
+	var t models.ActionToken
+	var usedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, purpose, email, token_hash, expires_at, used_at, created_at
+		 FROM action_tokens WHERE token_hash = ? AND purpose = ?`, hash, purpose,
+	).Scan(&t.ID, &t.UserID, &t.Purpose, &t.Email, &t.TokenHash, &t.ExpiresAt, &usedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if consumed == 0 {
+		return nil, repository.ErrTokenUsed
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	return &t, nil
+}
@@ -0,0 +1,189 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+type userRepo struct {
+	db *sql.DB
+}
+
+const userColumns = `id, full_name, email, COALESCE(phone,''), balance, is_member, total_spent, avatar_url, role, COALESCE(email_verified, FALSE), created_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var u models.User
+	var avatarURL sql.NullString
+	err := row.Scan(&u.ID, &u.FullName, &u.Email, &u.Phone, &u.Balance, &u.IsMember, &u.TotalSpent, &avatarURL, &u.Role, &u.EmailVerified, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if avatarURL.Valid {
+		u.AvatarURL = avatarURL.String
+	}
+	return &u, nil
+}
+
+func (r *userRepo) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (r *userRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	u, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	return u, notFound(err)
+}
+
+func (r *userRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var u models.User
+	var avatarURL sql.NullString
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, full_name, email, password, COALESCE(phone,''), created_at, avatar_url, COALESCE(email_verified, FALSE), total_spent, role FROM users WHERE email = ?`,
+		email,
+	).Scan(&u.ID, &u.FullName, &u.Email, &u.Password, &u.Phone, &u.CreatedAt, &avatarURL, &u.EmailVerified, &u.TotalSpent, &u.Role)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if avatarURL.Valid {
+		u.AvatarURL = avatarURL.String
+	}
+	return &u, nil
+}
+
+func (r *userRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM users WHERE email = ?`, email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *userRepo) Create(ctx context.Context, u *models.User) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	if u.Password != "" {
+		result, err = r.db.ExecContext(ctx,
+			`INSERT INTO users (full_name, email, password, phone) VALUES (?, ?, ?, ?)`,
+			u.FullName, u.Email, u.Password, u.Phone)
+	} else {
+		result, err = r.db.ExecContext(ctx, `INSERT INTO users (full_name, email) VALUES (?, ?)`, u.FullName, u.Email)
+	}
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	u.ID = int(id)
+	return nil
+}
+
+func (r *userRepo) Update(ctx context.Context, id int, req models.UserUpdateRequest) error {
+	return affectedOrNotFound(r.db.ExecContext(ctx,
+		`UPDATE users SET full_name = ?, is_member = ? WHERE id = ?`,
+		req.FullName, req.IsMember, id))
+}
+
+func (r *userRepo) UpdateProfile(ctx context.Context, id int, fullName, phone, email, avatarURL string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// email_verified is assigned first so it still compares against the old email
+	if err := affectedOrNotFound(tx.ExecContext(ctx,
+		`UPDATE users SET email_verified = (email_verified AND email = ?), full_name = ?, phone = ?, email = ?, avatar_url = ? WHERE id = ?`,
+		email, fullName, phone, email, avatarURL, id)); err != nil {
+		return err
+	}
+	// Links mailed to a previous address must not act on the new one
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM action_tokens WHERE user_id = ? AND used_at IS NULL AND email <> ?`,
+		id, email); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *userRepo) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	var hash string
+	err := r.db.QueryRowContext(ctx, `SELECT password FROM users WHERE id = ?`, id).Scan(&hash)
+	return hash, notFound(err)
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, id int, hash string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET password = ? WHERE id = ?`, hash, id)
+	return err
+}
+
+func (r *userRepo) SetEmailVerified(ctx context.Context, id int, email string) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?`, id, email)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	// Zero rows also means the address was already verified
+	var one int
+	err = r.db.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = ? AND email = ?`, id, email).Scan(&one)
+	return notFound(err)
+}
+
+func (r *userRepo) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The cascade would drop the user's stock holds without giving the
+	// reserved units back, so release them first
+	holds, err := lockHolds(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := releaseHolds(ctx, tx, holds); err != nil {
+		return err
+	}
+	if err := affectedOrNotFound(tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *userRepo) GetBalance(ctx context.Context, id int) (int, error) {
+	var balance int
+	err := r.db.QueryRowContext(ctx, `SELECT balance FROM users WHERE id = ?`, id).Scan(&balance)
+	return balance, notFound(err)
+}
+
+func (r *userRepo) GetTotalSpent(ctx context.Context, id int) (int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT total_spent FROM users WHERE id = ?`, id).Scan(&total)
+	return total, notFound(err)
+}
+
+func (r *userRepo) CountByRole(ctx context.Context, role string) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,185 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+func (r *productRepo) Variants(ctx context.Context, productID int) ([]models.ProductOption, []models.ProductVariant, error) {
+	var id int
+	if err := r.db.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ?`, productID).Scan(&id); err != nil {
+		return nil, nil, notFound(err)
+	}
+
+	options, err := r.productOptions(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, sku, options, price, stock, reserved, image_url FROM product_variants
+		 WHERE product_id = ? ORDER BY display_order, id`, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.ProductVariant
+	for rows.Next() {
+		v := models.ProductVariant{ProductID: productID}
+		var choices string
+		if err := rows.Scan(&v.ID, &v.SKU, &choices, &v.Price, &v.Stock, &v.Reserved, &v.Image); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal([]byte(choices), &v.Options); err != nil {
+			return nil, nil, err
+		}
+		variants = append(variants, v)
+	}
+	return options, variants, rows.Err()
+}
+
+func (r *productRepo) productOptions(ctx context.Context, productID int) ([]models.ProductOption, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT name, option_values FROM product_options WHERE product_id = ? ORDER BY display_order, id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []models.ProductOption
+	for rows.Next() {
+		var o models.ProductOption
+		var values string
+		if err := rows.Scan(&o.Name, &values); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(values), &o.Values); err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	return options, rows.Err()
+}
+
+func (r *productRepo) ReplaceVariants(ctx context.Context, productID int, options []models.ProductOption, variants []models.ProductVariant) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ? FOR UPDATE`, productID).Scan(&id); err != nil {
+		return notFound(err)
+	}
+
+	// Read the current variants before any write on this connection
+	rows, err := tx.QueryContext(ctx, `SELECT id, sku FROM product_variants WHERE product_id = ?`, productID)
+	if err != nil {
+		return err
+	}
+	existing := map[string]int{}
+	for rows.Next() {
+		var vid int
+		var sku string
+		if err := rows.Scan(&vid, &sku); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[sku] = vid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_options WHERE product_id = ?`, productID); err != nil {
+		return err
+	}
+	for i, o := range options {
+		values, err := json.Marshal(o.Values)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_options (product_id, name, option_values, display_order) VALUES (?, ?, ?, ?)`,
+			productID, o.Name, string(values), i+1); err != nil {
+			if isDuplicate(err) {
+				return repository.ErrDuplicate
+			}
+			return err
+		}
+	}
+
+	keep := map[int]bool{}
+	for i := range variants {
+		v := &variants[i]
+		v.ProductID = productID
+		choices, err := json.Marshal(v.Options)
+		if err != nil {
+			return err
+		}
+		if vid, ok := existing[v.SKU]; ok {
+			v.ID = vid
+			_, err = tx.ExecContext(ctx,
+				`UPDATE product_variants SET options = ?, price = ?, stock = ?, image_url = ?, display_order = ? WHERE id = ?`,
+				string(choices), v.Price, v.Stock, v.Image, i+1, vid)
+		} else {
+			var result sql.Result
+			result, err = tx.ExecContext(ctx,
+				`INSERT INTO product_variants (product_id, sku, options, price, stock, image_url, display_order) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				productID, v.SKU, string(choices), v.Price, v.Stock, v.Image, i+1)
+			if err == nil {
+				var newID int64
+				newID, err = result.LastInsertId()
+				v.ID = int(newID)
+			}
+		}
+		if isDuplicate(err) {
+			return repository.ErrDuplicate
+		}
+		if err != nil {
+			return err
+		}
+		keep[v.ID] = true
+	}
+
+	// Drop removed variants along with the cart lines and holds that point at
+	// them; once a product has variants, lines without one can no longer be
+	// checked out
+	for _, vid := range existing {
+		if keep[vid] {
+			continue
+		}
+		for _, table := range []string{"cart_items", "stock_reservations"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE product_id = ? AND variant_id = ?`, productID, vid); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_variants WHERE id = ?`, vid); err != nil {
+			return err
+		}
+	}
+	if len(variants) > 0 {
+		for _, table := range []string{"cart_items", "stock_reservations"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE product_id = ? AND variant_id = 0`, productID); err != nil {
+				return err
+			}
+		}
+		price, stock := models.VariantTotals(variants)
+		if _, err := tx.ExecContext(ctx, `UPDATE products SET price = ?, stock = ? WHERE id = ?`, price, stock, productID); err != nil {
+			return err
+		}
+	}
+	// The product's reserved units are those of the holds that are left
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE products SET reserved = (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = ?) WHERE id = ?`,
+		productID, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type voucherRepo struct {
+	db *sql.DB
+}
+
+const voucherColumns = `id, code, name, COALESCE(description,''), type, discount_value, min_purchase, max_discount,
+	usage_limit, used_count, valid_from, valid_until, is_active, created_at`
+
+func (r *voucherRepo) query(ctx context.Context, where string) ([]models.Voucher, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+voucherColumns+` FROM vouchers `+where+` ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vouchers []models.Voucher
+	for rows.Next() {
+		var v models.Voucher
+		var validFrom, validUntil sql.NullTime
+		if err := rows.Scan(
+			&v.ID, &v.Code, &v.Name, &v.Description, &v.Type,
+			&v.DiscountValue, &v.MinPurchase, &v.MaxDiscount,
+			&v.UsageLimit, &v.UsedCount, &validFrom, &validUntil,
+			&v.IsActive, &v.CreatedAt,
+		); err != nil {
+			continue
+		}
+		v.ValidFrom = validFrom.Time
+		v.ValidUntil = validUntil.Time
+		vouchers = append(vouchers, v)
+	}
+	return vouchers, rows.Err()
+}
+
+func (r *voucherRepo) List(ctx context.Context) ([]models.Voucher, error) {
+	return r.query(ctx, "")
+}
+
+func (r *voucherRepo) ListUnexpired(ctx context.Context) ([]models.Voucher, error) {
+	return r.query(ctx, "WHERE valid_until > NOW()")
+}
+
+func (r *voucherRepo) Create(ctx context.Context, v *models.Voucher) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO vouchers
+		 (code, name, description, type, discount_value, min_purchase, max_discount, usage_limit, valid_from, valid_until, is_active)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Code, v.Name, v.Description, v.Type,
+		v.DiscountValue, v.MinPurchase, v.MaxDiscount,
+		v.UsageLimit, v.ValidFrom, v.ValidUntil, v.IsActive,
+	)
+	if isDuplicate(err) {
+		return repository.ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	v.ID = int(id)
+	return nil
+}
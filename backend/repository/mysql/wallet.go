@@ -0,0 +1,191 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
+)
+
+type walletRepo struct {
+	db *sql.DB
+}
+
+// applyWalletEntry moves t.Amount in or out of the user's cached balance and
+// appends t to wallet_transactions with its two ledger_entries legs. It runs
+// inside the caller's transaction so the ledger and users.balance can never
+// disagree.
+func applyWalletEntry(ctx context.Context, tx *sql.Tx, t *models.WalletTransaction) error {
+	result, err := tx.ExecContext(ctx,
+		"UPDATE users SET balance = balance + ? WHERE id = ? AND balance + ? >= 0",
+		t.Amount, t.UserID, t.Amount,
+	)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists int
+		if err := tx.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ?", t.UserID).Scan(&exists); err != nil {
+			return notFound(err)
+		}
+		return repository.ErrInsufficientBalance
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = ?", t.UserID).Scan(&t.BalanceAfter); err != nil {
+		return err
+	}
+
+	t.CounterAccount = models.CounterAccount(t.Type)
+	result, err = tx.ExecContext(ctx,
+		`INSERT INTO wallet_transactions
+		 (user_id, type, amount, balance_after, counter_account, order_id, reference, description, created_by)
+		 VALUES (?, ?, ?, ?, ?, NULLIF(?, 0), ?, NULLIF(?, ''), NULLIF(?, 0))`,
+		t.UserID, t.Type, t.Amount, t.BalanceAfter, t.CounterAccount,
+		t.OrderID, t.Reference, t.Description, t.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("insert wallet transaction: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	t.ID = int(id)
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO ledger_entries (transaction_id, account, amount) VALUES (?, ?, ?), (?, ?, ?)",
+		t.ID, models.UserAccount(t.UserID), t.Amount, t.ID, t.CounterAccount, -t.Amount,
+	); err != nil {
+		return fmt.Errorf("insert ledger entries: %w", err)
+	}
+	return tx.QueryRowContext(ctx, "SELECT created_at FROM wallet_transactions WHERE id = ?", t.ID).Scan(&t.CreatedAt)
+}
+
+func (r *walletRepo) Record(ctx context.Context, t *models.WalletTransaction) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = applyWalletEntry(ctx, tx, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *walletRepo) SetBalance(ctx context.Context, t *models.WalletTransaction, balance int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var current int
+	if err = tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = ? FOR UPDATE", t.UserID).Scan(&current); err != nil {
+		return notFound(err)
+	}
+	if t.Amount = balance - current; t.Amount != 0 {
+		if err = applyWalletEntry(ctx, tx, t); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *walletRepo) Balance(ctx context.Context, userID int) (int, error) {
+	var balance int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT (SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = ?)
+		 FROM users u WHERE u.id = ?`, models.UserAccount(userID), userID,
+	).Scan(&balance)
+	return balance, notFound(err)
+}
+
+func (r *walletRepo) Reconcile(ctx context.Context, userID int) (balance, drift int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var cached int
+	if err = tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = ? FOR UPDATE", userID).Scan(&cached); err != nil {
+		return 0, 0, notFound(err)
+	}
+	if err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = ?", models.UserAccount(userID),
+	).Scan(&balance); err != nil {
+		return 0, 0, err
+	}
+	if drift = cached - balance; drift != 0 {
+		if _, err = tx.ExecContext(ctx, "UPDATE users SET balance = ? WHERE id = ?", balance, userID); err != nil {
+			return 0, 0, err
+		}
+	}
+	return balance, drift, tx.Commit()
+}
+
+func (r *walletRepo) Statement(ctx context.Context, userID, limit, offset int) ([]models.WalletTransaction, int, error) {
+	var total int
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM wallet_transactions WHERE user_id = ?", userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, type, amount, balance_after, counter_account, COALESCE(order_id,0),
+		       reference, COALESCE(description,''), COALESCE(created_by,0), created_at
+		FROM wallet_transactions
+		WHERE user_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.WalletTransaction
+	for rows.Next() {
+		var t models.WalletTransaction
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Type, &t.Amount, &t.BalanceAfter, &t.CounterAccount,
+			&t.OrderID, &t.Reference, &t.Description, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, t)
+	}
+	return entries, total, rows.Err()
+}
+
+func (r *walletRepo) TrialBalance(ctx context.Context) ([]models.AccountBalance, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT IF(account LIKE 'user:%', ?, account) AS grouped, SUM(amount)
+		FROM ledger_entries
+		GROUP BY grouped
+		ORDER BY grouped`, models.AccountWallets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.AccountBalance
+	for rows.Next() {
+		var b models.AccountBalance
+		if err := rows.Scan(&b.Account, &b.Balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
@@ -0,0 +1,353 @@
+// Package repository defines the storage interfaces used by the controllers.
+// Implementations live in the mysql (production) and memory (dev/tests) subpackages.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a unique constraint would be violated
+	ErrDuplicate = errors.New("duplicate entry")
+	// ErrNotInCart is returned by checkout when a selected product is not in the user's cart
+	ErrNotInCart = errors.New("product not in cart")
+	// ErrProductNotFound is returned by checkout when a cart product no longer exists
+	ErrProductNotFound = errors.New("product not found")
+	// ErrInsufficientStock is returned by checkout when stock is lower than the cart quantity
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInsufficientBalance is returned by checkout when the user cannot pay the total
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrStatusConflict is returned when an order is no longer in the status a transition expects
+	ErrStatusConflict = errors.New("order status changed")
+	// ErrTokenReused is returned when a refresh token that was already rotated is presented again
+	ErrTokenReused = errors.New("refresh token reused")
+	// ErrTokenUsed is returned when an action token was already used, superseded or has expired
+	ErrTokenUsed = errors.New("token already used or expired")
+	// ErrUnknownCategory is returned when a product names a category that does not exist
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrCategoryInUse is returned when deleting a category that still has subcategories or products
+	ErrCategoryInUse = errors.New("category in use")
+	// ErrImageOrder is returned when a new image order does not list each of the product's images once
+	ErrImageOrder = errors.New("image order does not match the product's images")
+	// ErrLoginThrottled is returned when a login attempt arrives while its throttle key is blocked
+	ErrLoginThrottled = errors.New("login attempts throttled")
+)
+
+// ItemError ties a checkout error to the product it was raised for
+type ItemError struct {
+	Err  error
+	Item string // product ID or name, depending on Err
+}
+
+func (e *ItemError) Error() string { return e.Err.Error() + ": " + e.Item }
+func (e *ItemError) Unwrap() error { return e.Err }
+
+// UserRepository stores user accounts and their wallet balance
+type UserRepository interface {
+	List(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	// GetByEmail returns the user with Password set to the bcrypt hash
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	// Create inserts the user (Password must already be hashed) and sets u.ID
+	Create(ctx context.Context, u *models.User) error
+	// Update saves name and membership; balance changes go through WalletRepository
+	Update(ctx context.Context, id int, req models.UserUpdateRequest) error
+	// UpdateProfile saves the profile. Changing the email clears email_verified
+	// and deletes the unused action tokens mailed to the old address.
+	UpdateProfile(ctx context.Context, id int, fullName, phone, email, avatarURL string) error
+	// GetPasswordHash returns the stored bcrypt hash
+	GetPasswordHash(ctx context.Context, id int) (string, error)
+	UpdatePassword(ctx context.Context, id int, hash string) error
+	// SetEmailVerified marks email as verified. Returns ErrNotFound unless it
+	// is still the user's current address.
+	SetEmailVerified(ctx context.Context, id int, email string) error
+	Delete(ctx context.Context, id int) error
+	// GetBalance returns the cached users.balance; WalletRepository.Balance
+	// reads the ledger and Reconcile repairs the cache
+	GetBalance(ctx context.Context, id int) (int, error)
+	GetTotalSpent(ctx context.Context, id int) (int, error)
+	CountByRole(ctx context.Context, role string) (int, error)
+}
+
+// ProductRepository stores the catalog and product specifications
+type ProductRepository interface {
+	// List returns the catalog (products that are not archived) ordered by
+	// id; limit <= 0 means no limit
+	List(ctx context.Context, limit int) ([]models.Product, error)
+	// Query returns one page of products matching q and the total number of matches.
+	// Best-selling counts units in orders that were not cancelled.
+	Query(ctx context.Context, q models.ProductQuery) ([]models.Product, int, error)
+	// SpecFacets counts the spec values of the products matching q. Only the
+	// category, brand, price, stock and rating filters apply; q.Specs, the
+	// sort order and paging are ignored.
+	SpecFacets(ctx context.Context, q models.ProductQuery) ([]models.SpecFacet, error)
+	// GetByID returns the product including its specifications. Archived
+	// products are returned too, with DeletedAt set.
+	GetByID(ctx context.Context, id int) (*models.Product, error)
+	// FindBySlug returns the product (without specifications) whose current
+	// or former slug is slug; ErrNotFound if there is none. Archived products
+	// are only found with includeArchived. A former slug was replaced when
+	// p.Slug differs from it.
+	FindBySlug(ctx context.Context, slug string, includeArchived bool) (*models.Product, error)
+	// FindBySKU returns the product (without specifications) owning the
+	// variant with the given SKU; ErrNotFound if no variant has it
+	FindBySKU(ctx context.Context, sku string) (*models.Product, error)
+	// ListByIDs returns the products with the given ids (without specifications), in no particular order.
+	// Archived products are included with DeletedAt set.
+	ListByIDs(ctx context.Context, ids []int) ([]models.Product, error)
+	// ListWithSpecifications returns the catalog including specifications; used to build the search index
+	ListWithSpecifications(ctx context.Context) ([]models.Product, error)
+	// Create inserts the product (resolving p.Category by name) and sets p.ID.
+	// p.Slug is made unique by appending -2, -3, ... and set to the slug saved.
+	// Returns ErrUnknownCategory when no category has that name.
+	Create(ctx context.Context, p *models.Product) error
+	// Update saves the product; like Create it returns ErrUnknownCategory for
+	// an unknown category. An empty p.Slug keeps the current slug; a new one
+	// is made unique like in Create and the old one is kept as a former slug.
+	// p.Slug is set to the slug saved.
+	Update(ctx context.Context, p *models.Product) error
+	// ReplaceSpecifications deletes existing spec rows and inserts specs in order
+	ReplaceSpecifications(ctx context.Context, productID int, specs []models.ProductSpec) error
+	// Import creates or updates every item like Create, Update and
+	// ReplaceSpecifications, all or nothing: on error none of them is saved.
+	// The ID and Slug of each item's Product are set to what was saved.
+	Import(ctx context.Context, items []models.ProductImportItem) error
+	// Variants returns the product's option types and variants in display order
+	Variants(ctx context.Context, productID int) ([]models.ProductOption, []models.ProductVariant, error)
+	// ReplaceVariants replaces the product's option types and variants and sets
+	// their IDs. A variant keeps its ID (and reserved units) while its SKU
+	// stays; cart lines and stock reservations of removed variants (and
+	// variant-less ones once there are variants) are deleted. With variants, the product's price and stock become their
+	// lowest price and total stock. Returns ErrNotFound for an unknown product
+	// and ErrDuplicate when a SKU belongs to another product.
+	ReplaceVariants(ctx context.Context, productID int, options []models.ProductOption, variants []models.ProductVariant) error
+	// Archive soft-deletes the product by setting deleted_at; archiving an
+	// archived product keeps its original time
+	Archive(ctx context.Context, id int) error
+	// Restore clears deleted_at so the product is sold again
+	Restore(ctx context.Context, id int) error
+}
+
+// CategoryRepository stores the category tree
+type CategoryRepository interface {
+	// List returns every category ordered by sort order and name, with the
+	// number of products directly in each (archived products not counted)
+	List(ctx context.Context) ([]models.Category, error)
+	GetByID(ctx context.Context, id int) (*models.Category, error)
+	// Find returns the category whose slug equals ref, ignoring case, or
+	// failing that the one whose name does
+	Find(ctx context.Context, ref string) (*models.Category, error)
+	// Create inserts c and sets c.ID; returns ErrDuplicate on a taken name or slug
+	Create(ctx context.Context, c *models.Category) error
+	// Update saves every editable field of c; returns ErrDuplicate on a taken name or slug
+	Update(ctx context.Context, c *models.Category) error
+	// Delete removes the category, or returns ErrCategoryInUse while it still
+	// has subcategories or products, archived ones included
+	Delete(ctx context.Context, id int) error
+	// SpecFields returns the category's own spec template fields in display order
+	SpecFields(ctx context.Context, categoryID int) ([]models.SpecField, error)
+	// ReplaceSpecFields replaces the category's spec template with fields
+	ReplaceSpecFields(ctx context.Context, categoryID int, fields []models.SpecField) error
+}
+
+// CartRepository stores per-user cart lines
+type CartRepository interface {
+	// List returns the user's cart lines; lines of archived products are
+	// hidden but kept, so they come back when the product is restored
+	List(ctx context.Context, userID int) ([]models.CartItem, error)
+	// Add inserts the line or increments the quantity of an existing one
+	Add(ctx context.Context, userID int, line models.CartLine, quantity int) error
+	UpdateQuantity(ctx context.Context, userID int, line models.CartLine, quantity int) error
+	Remove(ctx context.Context, userID int, line models.CartLine) error
+}
+
+// OrderRepository stores orders and runs the checkout/cancel transactions
+type OrderRepository interface {
+	// Checkout turns the selected cart lines into an order in a single transaction,
+	// charging variant prices and taking stock from the variant and the product.
+	// The user's unexpired reservations on those lines are consumed, so only
+	// stock reserved by others is unavailable; reservations on other lines stay. Failures on a specific product are
+	// returned as *ItemError; an archived product fails with ErrProductNotFound.
+	Checkout(ctx context.Context, req models.CheckoutRequest) (*models.CheckoutResult, error)
+	// ListByUser returns the user's orders, newest first; userID 0 lists every order
+	ListByUser(ctx context.Context, userID int, limit int) ([]models.OrderSummary, error)
+	// Get returns the order header and items by order number
+	Get(ctx context.Context, orderNumber string) (*models.Order, error)
+	// Transition moves the order from change.FromStatus to change.ToStatus and
+	// appends change to the order's history in one transaction. Moving to
+	// cancelled also restores stock (of the variant too, if it still exists)
+	// and refunds the user. Returns ErrStatusConflict when the order is no
+	// longer in change.FromStatus.
+	Transition(ctx context.Context, change models.OrderStatusChange) error
+	// History returns the order's status changes, oldest first
+	History(ctx context.Context, orderID int) ([]models.OrderStatusChange, error)
+	// Stats aggregates order statistics for the user; userID 0 aggregates every order
+	Stats(ctx context.Context, userID int) (*models.OrderStats, error)
+	// Revenue sums the totals of delivered/completed orders across all users
+	Revenue(ctx context.Context) (int, error)
+}
+
+// ReservationRepository stores the stock a user holds while checking out.
+// A user has at most one set of holds; each held quantity is also counted in
+// the reserved column of the product and, for variant lines, the variant.
+type ReservationRepository interface {
+	// Reserve replaces the user's holds with holds on lines for their current
+	// cart quantities until expiresAt. Fails like Checkout with *ItemError
+	// (ErrNotInCart, ErrProductNotFound, ErrInsufficientStock) and leaves the
+	// previous holds in place; ErrNotFound for an unknown user.
+	Reserve(ctx context.Context, userID int, lines []models.CartLine, expiresAt time.Time) ([]models.StockReservation, error)
+	// List returns the user's holds, including expired ones not yet released
+	List(ctx context.Context, userID int) ([]models.StockReservation, error)
+	// Release drops every hold of the user
+	Release(ctx context.Context, userID int) error
+	// ReleaseExpired drops the holds that expired at or before now and
+	// returns how many were released
+	ReleaseExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// ImageRepository stores the image gallery of each product. The primary
+// image's URL is mirrored into products.image_url, and a product with images
+// always has exactly one primary image.
+type ImageRepository interface {
+	// List returns the product's images in display order; ErrNotFound for an unknown product
+	List(ctx context.Context, productID int) ([]models.ProductImage, error)
+	// Add appends img to the gallery and sets its ID, order and CreatedAt. It
+	// becomes primary when img.IsPrimary is set or it is the product's first image.
+	Add(ctx context.Context, img *models.ProductImage) error
+	// Update saves the alt text and makes the image primary when img.IsPrimary
+	// is set; the primary image cannot be demoted directly
+	Update(ctx context.Context, img *models.ProductImage) error
+	// Reorder sets the display order to the order of ids, which must name each
+	// image of the product exactly once (ErrImageOrder otherwise)
+	Reorder(ctx context.Context, productID int, ids []int) error
+	// Delete removes the image and returns it; the next image in order
+	// becomes primary when the primary one is removed
+	Delete(ctx context.Context, productID, imageID int) (*models.ProductImage, error)
+	// ReferencedURLs returns every image URL still stored on a product,
+	// gallery, variant, category, user avatar or order item snapshot
+	ReferencedURLs(ctx context.Context) (map[string]bool, error)
+}
+
+// VoucherRepository stores discount vouchers
+type VoucherRepository interface {
+	List(ctx context.Context) ([]models.Voucher, error)
+	// ListUnexpired returns vouchers whose valid_until is in the future
+	ListUnexpired(ctx context.Context) ([]models.Voucher, error)
+	// Create inserts the voucher and sets v.ID; returns ErrDuplicate on a taken code
+	Create(ctx context.Context, v *models.Voucher) error
+}
+
+// ReviewRepository stores product reviews
+type ReviewRepository interface {
+	ListByProduct(ctx context.Context, productID int) ([]models.Review, error)
+	// Upsert saves the review and recalculates the product's rating and review count
+	Upsert(ctx context.Context, rev models.Review) error
+}
+
+// WalletRepository stores the wallet ledger. Checkout and order cancellation
+// write their purchase/refund entries through OrderRepository in the same
+// transaction as the order change.
+type WalletRepository interface {
+	// Record applies t.Amount to the user's balance and appends t to the ledger
+	// with both of its legs, setting ID, BalanceAfter, CounterAccount and
+	// CreatedAt. A debit that
+	// would make the balance negative returns ErrInsufficientBalance.
+	Record(ctx context.Context, t *models.WalletTransaction) error
+	// SetBalance records t as the adjustment that brings the user's balance to
+	// balance, setting t.Amount to the difference under the user's row lock.
+	// Nothing is recorded and t.Amount stays 0 when the balance already matches.
+	SetBalance(ctx context.Context, t *models.WalletTransaction, balance int) error
+	// Balance sums the legs on the user's wallet account without locking or
+	// repairing anything
+	Balance(ctx context.Context, userID int) (int, error)
+	// Reconcile recomputes the balance from the ledger, repairs the cached
+	// users.balance if it drifted and returns the ledger balance and the drift
+	Reconcile(ctx context.Context, userID int) (balance, drift int, err error)
+	// Statement returns the user's entries newest first and the total entry count
+	Statement(ctx context.Context, userID, limit, offset int) ([]models.WalletTransaction, int, error)
+	// TrialBalance sums the ledger legs per counter account, with every user
+	// wallet summed as models.AccountWallets. The balances add up to zero.
+	TrialBalance(ctx context.Context) ([]models.AccountBalance, error)
+}
+
+// TokenRepository stores refresh tokens, the access-token (jti) denylist and
+// the single-use action tokens sent by email
+type TokenRepository interface {
+	// CreateRefresh stores a new refresh token and sets t.ID
+	CreateRefresh(ctx context.Context, t *models.RefreshToken) error
+	// GetRefresh looks a refresh token up by its hash, including revoked ones
+	GetRefresh(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// RotateRefresh revokes the token oldID and stores next in its place.
+	// Returns ErrTokenReused if oldID was already revoked by a concurrent call.
+	RotateRefresh(ctx context.Context, oldID int, next *models.RefreshToken) error
+	// RevokeFamily revokes every refresh token descending from the same login
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes every refresh token of the user, e.g. after a password reset
+	RevokeUser(ctx context.Context, userID int) error
+	// DenyAccessToken blocks the access token jti until expiresAt
+	DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenDenied(ctx context.Context, jti string) (bool, error)
+	// CreateAction stores t and sets t.ID. Earlier unused tokens of the same
+	// user and purpose are marked used so only the latest email works.
+	CreateAction(ctx context.Context, t *models.ActionToken) error
+	// ConsumeAction marks the token used and returns it. Returns ErrNotFound for
+	// an unknown hash/purpose and ErrTokenUsed if it was used or has expired.
+	ConsumeAction(ctx context.Context, purpose, hash string) (*models.ActionToken, error)
+}
+
+// IdempotencyRepository stores Idempotency-Key records and their cached responses
+type IdempotencyRepository interface {
+	// Reserve claims (k.UserID, k.Key). When the key is already held and not
+	// expired the stored record is returned and nothing is written; otherwise
+	// k is saved as in-flight and Reserve returns nil.
+	Reserve(ctx context.Context, k *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// Complete stores the response of the request that reserved the key
+	Complete(ctx context.Context, userID int, key string, statusCode int, body []byte) error
+	// Release forgets the key so the client may retry, e.g. after a 5xx
+	Release(ctx context.Context, userID int, key string) error
+	// DeleteExpired drops the keys that expired at or before now and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// LoginThrottleRepository stores failed-login counters per account and per client IP
+type LoginThrottleRepository interface {
+	// Get returns the counters for key, or ErrNotFound if it has none
+	Get(ctx context.Context, key string) (*models.LoginThrottle, error)
+	// Reserve atomically counts an attempt at now against key as a failure
+	// under policy, before the password is checked, and returns the updated
+	// counters. If key is still blocked nothing is counted and the current
+	// counters are returned with ErrLoginThrottled.
+	Reserve(ctx context.Context, key string, policy models.LoginPolicy, now time.Time) (*models.LoginThrottle, error)
+	// Release gives back one attempt taken by Reserve, e.g. for a login that succeeded
+	Release(ctx context.Context, key string, policy models.LoginPolicy) error
+	// Reset clears the counters for key, e.g. after a successful login
+	Reset(ctx context.Context, key string) error
+	// DeleteExpired drops the counters that saw no attempt in the day before
+	// now and returns how many were removed
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// Store groups every repository the controllers depend on
+type Store struct {
+	Users          UserRepository
+	Products       ProductRepository
+	Categories     CategoryRepository
+	Images         ImageRepository
+	Carts          CartRepository
+	Orders         OrderRepository
+	Reservations   ReservationRepository
+	Vouchers       VoucherRepository
+	Reviews        ReviewRepository
+	Wallet         WalletRepository
+	Tokens         TokenRepository
+	Idempotency    IdempotencyRepository
+	LoginThrottles LoginThrottleRepository
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/HHHAAAANNNNN/go-commerce-backend/controllers"
 	"github.com/HHHAAAANNNNN/go-commerce-backend/middlewares"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository"
 	"github.com/gorilla/mux"
 )
 
@@ -15,13 +16,32 @@ func adminOnly(h http.HandlerFunc) http.Handler {
 	)
 }
 
-func SetupRoutes() *mux.Router {
+// ownerOnly wraps a handler with RequireAuth + RequireOwner("id") so a customer
+// can only reach /users/{id}/* for their own ID (admins may access any ID)
+func ownerOnly(h http.HandlerFunc) http.Handler {
+	return middlewares.RequireAuth(
+		middlewares.RequireOwner("id")(http.HandlerFunc(h)),
+	)
+}
+
+// SetupRoutes builds the API router; handlers read and write through s
+func SetupRoutes(s *repository.Store) *mux.Router {
+	controllers.SetStore(s)
+	middlewares.SetTokenStore(s.Tokens)
 	router := mux.NewRouter()
 
+	// idempotent lets clients retry a POST safely with an Idempotency-Key header
+	idempotent := func(h http.HandlerFunc) http.HandlerFunc {
+		return middlewares.Idempotency(s.Idempotency)(h).ServeHTTP
+	}
+
 	// Apply global middlewares
 	router.Use(middlewares.RecoverPanic)
 	router.Use(middlewares.Logger)
 
+	// Public signing keys for services that verify our access tokens
+	router.HandleFunc("/.well-known/jwks.json", controllers.GetJWKS).Methods("GET", "OPTIONS")
+
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
 
@@ -34,49 +54,88 @@ func SetupRoutes() *mux.Router {
 	// Auth routes (public)
 	api.HandleFunc("/auth/register", controllers.Register).Methods("POST", "OPTIONS")
 	api.HandleFunc("/auth/login", controllers.Login).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/refresh", controllers.RefreshToken).Methods("POST", "OPTIONS")
+	api.Handle("/auth/logout", middlewares.RequireAuth(http.HandlerFunc(controllers.Logout))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/verify-email", controllers.VerifyEmail).Methods("POST", "OPTIONS")
+	api.Handle("/auth/verify-email/resend", middlewares.RequireAuth(http.HandlerFunc(controllers.ResendVerification))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/forgot-password", controllers.ForgotPassword).Methods("POST", "OPTIONS")
+	api.HandleFunc("/auth/reset-password", controllers.ResetPassword).Methods("POST", "OPTIONS")
 
 	// Upload — admin only
 	api.Handle("/upload", adminOnly(controllers.UploadProductImage)).Methods("POST", "OPTIONS")
+	api.Handle("/admin/uploads/cleanup", adminOnly(controllers.CleanupUploads)).Methods("POST", "OPTIONS")
 
-	// User routes — list/create/update/delete are admin-only; per-user routes are open
+	// User routes — list/create/update/delete are admin-only; per-user routes are owner-only
 	api.Handle("/users", adminOnly(controllers.GetAllUsers)).Methods("GET", "OPTIONS")
 	api.Handle("/users", adminOnly(controllers.CreateUser)).Methods("POST", "OPTIONS")
-	api.Handle("/users/{id}", middlewares.RequireAuth(http.HandlerFunc(controllers.GetUserByID))).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}", ownerOnly(controllers.GetUserByID)).Methods("GET", "OPTIONS")
 	api.Handle("/users/{id}", adminOnly(controllers.UpdateUser)).Methods("PUT", "OPTIONS")
 	api.Handle("/users/{id}", adminOnly(controllers.DeleteUser)).Methods("DELETE", "OPTIONS")
-	api.Handle("/users/{id}/profile", middlewares.RequireAuth(http.HandlerFunc(controllers.UpdateProfile))).Methods("PUT", "OPTIONS")
-	api.Handle("/users/{id}/balance", middlewares.RequireAuth(http.HandlerFunc(controllers.GetBalance))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/topup", middlewares.RequireAuth(http.HandlerFunc(controllers.TopUp))).Methods("POST", "OPTIONS")
-	api.Handle("/users/{id}/total-spent", middlewares.RequireAuth(http.HandlerFunc(controllers.GetTotalSpent))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/dashboard", middlewares.RequireAuth(http.HandlerFunc(controllers.GetDashboard))).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/profile", ownerOnly(controllers.UpdateProfile)).Methods("PUT", "OPTIONS")
+	api.Handle("/users/{id}/balance", ownerOnly(controllers.GetBalance)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/topup", ownerOnly(idempotent(controllers.TopUp))).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/total-spent", ownerOnly(controllers.GetTotalSpent)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/wallet/transactions", ownerOnly(controllers.GetWalletTransactions)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/wallet/adjustments", adminOnly(controllers.AdjustWallet)).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/wallet/reconcile", adminOnly(controllers.ReconcileWallet)).Methods("POST", "OPTIONS")
+	api.Handle("/admin/wallet/trial-balance", adminOnly(controllers.GetTrialBalance)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/dashboard", ownerOnly(controllers.GetDashboard)).Methods("GET", "OPTIONS")
 
 	// Cart routes
-	api.Handle("/users/{id}/cart", middlewares.RequireAuth(http.HandlerFunc(controllers.GetCartItems))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/cart", middlewares.RequireAuth(http.HandlerFunc(controllers.AddToCart))).Methods("POST", "OPTIONS")
-	api.Handle("/users/{id}/cart/{productId}", middlewares.RequireAuth(http.HandlerFunc(controllers.UpdateCartItem))).Methods("PUT", "OPTIONS")
-	api.Handle("/users/{id}/cart/{productId}", middlewares.RequireAuth(http.HandlerFunc(controllers.RemoveFromCart))).Methods("DELETE", "OPTIONS")
+	api.Handle("/users/{id}/cart", ownerOnly(controllers.GetCartItems)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/cart", ownerOnly(controllers.AddToCart)).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/cart/{productId}", ownerOnly(controllers.UpdateCartItem)).Methods("PUT", "OPTIONS")
+	api.Handle("/users/{id}/cart/{productId}", ownerOnly(controllers.RemoveFromCart)).Methods("DELETE", "OPTIONS")
 
 	// Checkout & order routes
-	api.Handle("/users/{id}/checkout", middlewares.RequireAuth(http.HandlerFunc(controllers.Checkout))).Methods("POST", "OPTIONS")
-	api.Handle("/users/{id}/stats", middlewares.RequireAuth(http.HandlerFunc(controllers.GetUserStats))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/orders", middlewares.RequireAuth(http.HandlerFunc(controllers.GetUserOrders))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/orders/{orderNumber}", middlewares.RequireAuth(http.HandlerFunc(controllers.GetOrderDetail))).Methods("GET", "OPTIONS")
-	api.Handle("/users/{id}/orders/{orderNumber}/status", middlewares.RequireAuth(http.HandlerFunc(controllers.UpdateOrderStatus))).Methods("PATCH", "OPTIONS")
-	api.Handle("/users/{id}/orders/{orderNumber}/reviews", middlewares.RequireAuth(http.HandlerFunc(controllers.SubmitReviews))).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/checkout", ownerOnly(idempotent(controllers.Checkout))).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/checkout/start", ownerOnly(controllers.StartCheckout)).Methods("POST", "OPTIONS")
+	api.Handle("/users/{id}/checkout/reservation", ownerOnly(controllers.GetCheckoutReservation)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/checkout/reservation", ownerOnly(controllers.ReleaseCheckoutReservation)).Methods("DELETE", "OPTIONS")
+	api.Handle("/users/{id}/stats", ownerOnly(controllers.GetUserStats)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/orders", ownerOnly(controllers.GetUserOrders)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/orders/{orderNumber}", ownerOnly(controllers.GetOrderDetail)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/orders/{orderNumber}/status", ownerOnly(controllers.UpdateOrderStatus)).Methods("PATCH", "OPTIONS")
+	api.Handle("/users/{id}/orders/{orderNumber}/history", ownerOnly(controllers.GetOrderHistory)).Methods("GET", "OPTIONS")
+	api.Handle("/users/{id}/orders/{orderNumber}/reviews", ownerOnly(controllers.SubmitReviews)).Methods("POST", "OPTIONS")
 
 	// Admin-only order management
 	api.Handle("/orders", adminOnly(http.HandlerFunc(controllers.GetAllOrders))).Methods("GET", "OPTIONS")
 	api.Handle("/orders/{orderNumber}", adminOnly(http.HandlerFunc(controllers.GetOrderDetailAdmin))).Methods("GET", "OPTIONS")
 	api.Handle("/orders/{orderNumber}/status", adminOnly(http.HandlerFunc(controllers.UpdateOrderStatusAdmin))).Methods("PATCH", "OPTIONS")
+	api.Handle("/orders/{orderNumber}/history", adminOnly(controllers.GetOrderHistoryAdmin)).Methods("GET", "OPTIONS")
 
 	// Product routes — GET is public, mutations are admin only
 	api.HandleFunc("/products", controllers.GetAllProducts).Methods("GET", "OPTIONS")
 	api.HandleFunc("/products/search", controllers.SearchProducts).Methods("GET", "OPTIONS")
-	api.HandleFunc("/products/{id}", controllers.GetProductByID).Methods("GET", "OPTIONS")
+	api.HandleFunc("/products/suggest", controllers.SuggestProducts).Methods("GET", "OPTIONS")
+	api.Handle("/products/by-slug/{slug}", middlewares.OptionalAuth(http.HandlerFunc(controllers.GetProductBySlug))).Methods("GET", "OPTIONS")
+	api.Handle("/products/{id}", middlewares.OptionalAuth(http.HandlerFunc(controllers.GetProductByID))).Methods("GET", "OPTIONS")
 	api.HandleFunc("/products/{id}/reviews", controllers.GetProductReviews).Methods("GET", "OPTIONS")
+	api.HandleFunc("/products/{id}/variants", controllers.GetProductVariants).Methods("GET", "OPTIONS")
+	api.HandleFunc("/products/{id}/images", controllers.GetProductImages).Methods("GET", "OPTIONS")
 	api.Handle("/products", adminOnly(controllers.CreateProduct)).Methods("POST", "OPTIONS")
 	api.Handle("/products/{id}", adminOnly(controllers.UpdateProduct)).Methods("PUT", "OPTIONS")
-	api.Handle("/products/{id}", adminOnly(controllers.DeleteProduct)).Methods("DELETE", "OPTIONS")
+	api.Handle("/products/{id}", adminOnly(controllers.ArchiveProduct)).Methods("DELETE", "OPTIONS")
+	api.Handle("/products/{id}/archive", adminOnly(controllers.ArchiveProduct)).Methods("POST", "OPTIONS")
+	api.Handle("/products/{id}/restore", adminOnly(controllers.RestoreProduct)).Methods("POST", "OPTIONS")
+	api.Handle("/admin/products/archived", adminOnly(controllers.GetArchivedProducts)).Methods("GET", "OPTIONS")
+	api.Handle("/admin/products/import", adminOnly(controllers.ImportProducts)).Methods("POST", "OPTIONS")
+	api.Handle("/admin/products/export", adminOnly(controllers.ExportProducts)).Methods("GET", "OPTIONS")
+	api.Handle("/products/{id}/variants", adminOnly(controllers.ReplaceProductVariants)).Methods("PUT", "OPTIONS")
+	api.Handle("/products/{id}/images", adminOnly(controllers.AddProductImage)).Methods("POST", "OPTIONS")
+	api.Handle("/products/{id}/images/order", adminOnly(controllers.ReorderProductImages)).Methods("PUT", "OPTIONS")
+	api.Handle("/products/{id}/images/{imageId}", adminOnly(controllers.UpdateProductImage)).Methods("PATCH", "OPTIONS")
+	api.Handle("/products/{id}/images/{imageId}", adminOnly(controllers.DeleteProductImage)).Methods("DELETE", "OPTIONS")
+
+	// Category routes — the tree is public, management is admin only
+	api.HandleFunc("/categories", controllers.GetCategoryTree).Methods("GET", "OPTIONS")
+	api.HandleFunc("/categories/{id}", controllers.GetCategory).Methods("GET", "OPTIONS")
+	api.Handle("/categories", adminOnly(controllers.CreateCategory)).Methods("POST", "OPTIONS")
+	api.Handle("/categories/{id}", adminOnly(controllers.UpdateCategory)).Methods("PUT", "OPTIONS")
+	api.Handle("/categories/{id}", adminOnly(controllers.DeleteCategory)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/categories/{id}/spec-template", controllers.GetSpecTemplate).Methods("GET", "OPTIONS")
+	api.Handle("/categories/{id}/spec-template", adminOnly(controllers.ReplaceSpecTemplate)).Methods("PUT", "OPTIONS")
 
 	// Voucher routes — GET is public, creation is admin only
 	api.HandleFunc("/vouchers", controllers.ListVouchers).Methods("GET", "OPTIONS")
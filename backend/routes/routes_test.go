@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/repository/memory"
+	"github.com/HHHAAAANNNNN/go-commerce-backend/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "routes-test-secret")
+	os.Exit(m.Run())
+}
+
+// ownerFixture is a router over a fresh memory store with a customer who
+// owns a cart line and an order, a second customer and an admin
+type ownerFixture struct {
+	router              *mux.Router
+	owner, other, admin int
+	productID           int
+	orderNumber         string
+}
+
+func newOwnerFixture(t *testing.T) *ownerFixture {
+	t.Helper()
+	ctx := context.Background()
+	s := memory.New()
+	f := &ownerFixture{}
+
+	for _, u := range []struct {
+		id    *int
+		email string
+		role  string
+	}{
+		{&f.owner, "owner@example.com", "customer"},
+		{&f.other, "other@example.com", "customer"},
+		{&f.admin, "admin@example.com", "admin"},
+	} {
+		user := &models.User{Email: u.email, FullName: u.email, Role: u.role}
+		if err := s.Users.Create(ctx, user); err != nil {
+			t.Fatalf("create %s: %v", u.email, err)
+		}
+		*u.id = user.ID
+	}
+	if err := s.Wallet.Record(ctx, &models.WalletTransaction{UserID: f.owner, Type: models.WalletTopUp, Amount: 1000000}); err != nil {
+		t.Fatalf("fund owner: %v", err)
+	}
+
+	product := &models.Product{Name: "Phone", Price: 1000, Stock: 10, Category: "Smartphones"}
+	if err := s.Products.Create(ctx, product); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	f.productID = product.ID
+	line := models.CartLine{ProductID: product.ID}
+	if err := s.Carts.Add(ctx, f.owner, line, 1); err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+	result, err := s.Orders.Checkout(ctx, models.CheckoutRequest{UserID: f.owner, Lines: []models.CartLine{line}})
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	f.orderNumber = result.OrderNumber
+	if err := s.Carts.Add(ctx, f.owner, line, 1); err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+
+	f.router = SetupRoutes(s)
+	return f
+}
+
+func (f *ownerFixture) do(t *testing.T, asUser int, role, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := utils.GenerateToken(asUser, role)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// ownerRoutes lists every route behind ownerOnly with a body the owner's
+// request succeeds with
+var ownerRoutes = []struct {
+	method, path, body string
+}{
+	{"GET", "/api/users/{id}", ""},
+	{"PUT", "/api/users/{id}/profile", `{"full_name":"Owner","email":"owner@example.com"}`},
+	{"GET", "/api/users/{id}/balance", ""},
+	{"POST", "/api/users/{id}/topup", `{"amount":5000}`},
+	{"GET", "/api/users/{id}/total-spent", ""},
+	{"GET", "/api/users/{id}/wallet/transactions", ""},
+	{"GET", "/api/users/{id}/dashboard", ""},
+	{"GET", "/api/users/{id}/cart", ""},
+	{"POST", "/api/users/{id}/cart", `{"product_id":{productId},"quantity":1}`},
+	{"PUT", "/api/users/{id}/cart/{productId}", `{"quantity":2}`},
+	{"DELETE", "/api/users/{id}/cart/{productId}", ""},
+	{"POST", "/api/users/{id}/checkout", `{"items":[{"product_id":{productId}}]}`},
+	{"POST", "/api/users/{id}/checkout/start", `{"items":[{"product_id":{productId}}]}`},
+	{"GET", "/api/users/{id}/checkout/reservation", ""},
+	{"DELETE", "/api/users/{id}/checkout/reservation", ""},
+	{"GET", "/api/users/{id}/stats", ""},
+	{"GET", "/api/users/{id}/orders", ""},
+	{"GET", "/api/users/{id}/orders/{orderNumber}", ""},
+	{"PATCH", "/api/users/{id}/orders/{orderNumber}/status", `{"status":"cancelled"}`},
+	{"GET", "/api/users/{id}/orders/{orderNumber}/history", ""},
+	{"POST", "/api/users/{id}/orders/{orderNumber}/reviews", `[]`},
+}
+
+// adminUserRoutes are /users/{id} routes that are admin-only instead
+var adminUserRoutes = map[string]bool{
+	"PUT /api/users/{id}":                     true,
+	"DELETE /api/users/{id}":                  true,
+	"POST /api/users/{id}/wallet/adjustments": true,
+	"POST /api/users/{id}/wallet/reconcile":   true,
+}
+
+func (f *ownerFixture) expand(s string) string {
+	return strings.NewReplacer(
+		"{id}", fmt.Sprint(f.owner),
+		"{productId}", fmt.Sprint(f.productID),
+		"{orderNumber}", f.orderNumber,
+	).Replace(s)
+}
+
+func TestOwnerOnlyRoutes(t *testing.T) {
+	callers := []struct {
+		name   string
+		caller func(f *ownerFixture) (int, string)
+		want   func(code int) bool
+	}{
+		{"owner", func(f *ownerFixture) (int, string) { return f.owner, "customer" }, func(code int) bool { return code >= 200 && code < 300 }},
+		{"other customer", func(f *ownerFixture) (int, string) { return f.other, "customer" }, func(code int) bool { return code == http.StatusForbidden }},
+		{"admin", func(f *ownerFixture) (int, string) { return f.admin, "admin" }, func(code int) bool { return code >= 200 && code < 300 }},
+	}
+	for _, rt := range ownerRoutes {
+		for _, c := range callers {
+			t.Run(rt.method+" "+rt.path+" as "+c.name, func(t *testing.T) {
+				f := newOwnerFixture(t)
+				id, role := c.caller(f)
+				rec := f.do(t, id, role, rt.method, f.expand(rt.path), f.expand(rt.body))
+				if !c.want(rec.Code) {
+					t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestOwnerOnlyRoutesRequireToken(t *testing.T) {
+	f := newOwnerFixture(t)
+	for _, rt := range ownerRoutes {
+		req := httptest.NewRequest(rt.method, f.expand(rt.path), strings.NewReader(f.expand(rt.body)))
+		rec := httptest.NewRecorder()
+		f.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token: got %d, want 401", rt.method, rt.path, rec.Code)
+		}
+	}
+}
+
+// TestEveryUserRouteIsCovered fails when a /users/{id}/... route is added
+// without an entry in ownerRoutes or adminUserRoutes
+func TestEveryUserRouteIsCovered(t *testing.T) {
+	covered := map[string]bool{}
+	for _, rt := range ownerRoutes {
+		covered[rt.method+" "+rt.path] = true
+	}
+	f := newOwnerFixture(t)
+	err := f.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, "/api/users/{id}") {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, m := range methods {
+			if m == http.MethodOptions {
+				continue
+			}
+			if key := m + " " + tpl; !covered[key] && !adminUserRoutes[key] {
+				t.Errorf("route %s has no ownership test", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
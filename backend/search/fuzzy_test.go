@@ -0,0 +1,44 @@
+package search
+
+import "testing"
+
+func TestMaxTypos(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"hp", 0},
+		{"oppo", 1},
+		{"samsung", 1},
+		{"keyboard", 2},
+		{"12gb", 0},
+		{"s24", 0},
+	}
+	for _, tt := range tests {
+		if got := maxTypos(tt.word); got != tt.want {
+			t.Errorf("maxTypos(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"galaxy", "galaxy", 2, 0},
+		{"samsnug", "samsung", 2, 1}, // adjacent transposition
+		{"samsun", "samsung", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 2, 3}, // stops at max+1
+		{"abc", "abcdef", 2, 3},
+		{"", "ab", 2, 2},
+		{"kamera", "kämera", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Package search is the in-process full-text index behind product search.
+// It indexes name, brand, category, specification values and description
+// with per-field weights, matches exact words, stems (Indonesian and English)
+// and near-miss spellings, and computes facet counts for the result set.
+// Suggester is the prefix index behind search-box autocomplete.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// Field weights: a hit in the name counts five times a hit in the description
+const (
+	weightName        = 5.0
+	weightBrand       = 4.0
+	weightCategory    = 3.0
+	weightSpec        = 2.0
+	weightDescription = 1.0
+)
+
+// How much each kind of match is worth relative to an exact word
+const (
+	matchExact = 1.0
+	matchStem  = 0.7
+	matchTypo  = 0.5
+)
+
+// Query is a search request. Filters narrow the results; each facet is
+// counted with every filter applied except its own, so the client can
+// show how many results picking another value would give.
+type Query struct {
+	Text       string
+	Categories []string
+	Brands     []string
+	MinPrice   int
+	MaxPrice   int // 0 means no upper bound
+	// Only, when not nil, restricts the search to these product ids
+	// (filters the index cannot evaluate, such as specifications)
+	Only   map[int]bool
+	Limit  int
+	Offset int
+}
+
+// FacetCount is one facet value and the number of matching products
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket is one price range facet; Max 0 means no upper bound
+type PriceBucket struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Facets are the counts returned next to the results
+type Facets struct {
+	Categories []FacetCount  `json:"category"`
+	Brands     []FacetCount  `json:"brand"`
+	Price      []PriceBucket `json:"price"`
+}
+
+// Result is one page of product IDs, best match first
+type Result struct {
+	IDs    []int
+	Total  int
+	Facets Facets
+}
+
+// priceBuckets are the price facet ranges in rupiah
+var priceBuckets = []PriceBucket{
+	{Label: "< 1jt", Min: 0, Max: 1_000_000},
+	{Label: "1jt - 3jt", Min: 1_000_000, Max: 3_000_000},
+	{Label: "3jt - 5jt", Min: 3_000_000, Max: 5_000_000},
+	{Label: "5jt - 10jt", Min: 5_000_000, Max: 10_000_000},
+	{Label: "10jt - 20jt", Min: 10_000_000, Max: 20_000_000},
+	{Label: "> 20jt", Min: 20_000_000},
+}
+
+type docInfo struct {
+	category string
+	brand    string
+	price    int
+	words    []string // distinct exact terms, for removal
+	stems    []string
+}
+
+// postings maps a term to the weighted term frequency per document
+type postings map[string]map[int]float64
+
+func (p postings) add(term string, id int, weight float64) {
+	docs := p[term]
+	if docs == nil {
+		docs = map[int]float64{}
+		p[term] = docs
+	}
+	docs[id] += weight
+}
+
+func (p postings) remove(terms []string, id int) {
+	for _, term := range terms {
+		delete(p[term], id)
+		if len(p[term]) == 0 {
+			delete(p, term)
+		}
+	}
+}
+
+// Index is safe for concurrent use
+type Index struct {
+	mu    sync.RWMutex
+	docs  map[int]*docInfo
+	words postings
+	stems postings
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{docs: map[int]*docInfo{}, words: postings{}, stems: postings{}}
+}
+
+// Build replaces the whole index with products (which should carry their specifications)
+func (ix *Index) Build(products []models.Product) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.docs, ix.words, ix.stems = map[int]*docInfo{}, postings{}, postings{}
+	for _, p := range products {
+		ix.add(p)
+	}
+}
+
+// Upsert indexes p, replacing any previous version
+func (ix *Index) Upsert(p models.Product) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(p.ID)
+	ix.add(p)
+}
+
+// Remove drops the product from the index
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+// Len returns the number of indexed products
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+func (ix *Index) add(p models.Product) {
+	doc := &docInfo{category: p.Category, brand: p.Brand, price: p.Price}
+	words, stems := map[string]bool{}, map[string]bool{}
+	index := func(text string, weight float64) {
+		for _, tok := range documentTerms(text) {
+			ix.words.add(tok, p.ID, weight)
+			ix.stems.add(stem(tok), p.ID, weight)
+			words[tok], stems[stem(tok)] = true, true
+		}
+	}
+
+	index(p.Name, weightName)
+	index(p.Brand, weightBrand)
+	index(p.Category, weightCategory)
+	for _, s := range p.Specifications {
+		index(s.Value, weightSpec)
+	}
+	index(p.Description, weightDescription)
+
+	for w := range words {
+		doc.words = append(doc.words, w)
+	}
+	for s := range stems {
+		doc.stems = append(doc.stems, s)
+	}
+	ix.docs[p.ID] = doc
+}
+
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	ix.words.remove(doc.words, id)
+	ix.stems.remove(doc.stems, id)
+	delete(ix.docs, id)
+}
+
+// Search ranks the products matching q.Text. Every query word must match
+// (exactly, by stem or within a typo or two); if no product matches all of
+// them the best partial matches are returned instead.
+func (ix *Index) Search(q Query) Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	terms := tokenize(q.Text)
+	scores := map[int]float64{}
+	matched := map[int]int{}
+	for _, term := range terms {
+		for id, s := range ix.scoreTerm(term) {
+			if q.Only != nil && !q.Only[id] {
+				continue
+			}
+			scores[id] += s
+			matched[id]++
+		}
+	}
+
+	// Prefer products matching every word; fall back to any word
+	candidates := make([]int, 0, len(scores))
+	for id := range scores {
+		if matched[id] == len(terms) {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		for id := range scores {
+			candidates = append(candidates, id)
+			scores[id] *= float64(matched[id]) / float64(len(terms))
+		}
+	}
+
+	res := Result{Facets: ix.facets(candidates, q)}
+	var hits []int
+	for _, id := range candidates {
+		if ix.passes(ix.docs[id], q, "") {
+			hits = append(hits, id)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if scores[hits[i]] != scores[hits[j]] {
+			return scores[hits[i]] > scores[hits[j]]
+		}
+		return hits[i] < hits[j]
+	})
+
+	res.Total = len(hits)
+	if q.Offset < len(hits) {
+		hits = hits[max(q.Offset, 0):]
+		if q.Limit > 0 && len(hits) > q.Limit {
+			hits = hits[:q.Limit]
+		}
+		res.IDs = hits
+	}
+	return res
+}
+
+// scoreTerm returns each matching document's score for one query word,
+// taking the best of its exact, stemmed and misspelled matches
+func (ix *Index) scoreTerm(term string) map[int]float64 {
+	n := float64(len(ix.docs))
+	best := map[int]float64{}
+	collect := func(docs map[int]float64, match float64) {
+		idf := math.Log(1 + n/float64(len(docs)))
+		for id, tf := range docs {
+			// tf/(tf+k) saturates so repeating a word does not dominate
+			if s := match * idf * tf / (tf + 1.2); s > best[id] {
+				best[id] = s
+			}
+		}
+	}
+
+	if docs, ok := ix.words[term]; ok {
+		collect(docs, matchExact)
+	}
+	if docs, ok := ix.stems[stem(term)]; ok {
+		collect(docs, matchStem)
+	}
+	if typos := maxTypos(term); typos > 0 {
+		for word, docs := range ix.words {
+			if word == term {
+				continue
+			}
+			if d := editDistance(term, word, typos); d > 0 && d <= typos {
+				collect(docs, matchTypo/float64(d))
+			}
+		}
+	}
+	return best
+}
+
+// passes reports whether doc satisfies q's filters, ignoring the one named skip
+func (ix *Index) passes(doc *docInfo, q Query, skip string) bool {
+	if skip != "category" && len(q.Categories) > 0 && !containsFold(q.Categories, doc.category) {
+		return false
+	}
+	if skip != "brand" && len(q.Brands) > 0 && !containsFold(q.Brands, doc.brand) {
+		return false
+	}
+	if skip != "price" {
+		if q.MinPrice > 0 && doc.price < q.MinPrice || q.MaxPrice > 0 && doc.price > q.MaxPrice {
+			return false
+		}
+	}
+	return true
+}
+
+func (ix *Index) facets(candidates []int, q Query) Facets {
+	categories, brands := map[string]int{}, map[string]int{}
+	buckets := append([]PriceBucket(nil), priceBuckets...)
+	for _, id := range candidates {
+		doc := ix.docs[id]
+		if doc.category != "" && ix.passes(doc, q, "category") {
+			categories[doc.category]++
+		}
+		if doc.brand != "" && ix.passes(doc, q, "brand") {
+			brands[doc.brand]++
+		}
+		if ix.passes(doc, q, "price") {
+			for i := range buckets {
+				if doc.price >= buckets[i].Min && (buckets[i].Max == 0 || doc.price < buckets[i].Max) {
+					buckets[i].Count++
+				}
+			}
+		}
+	}
+	return Facets{Categories: facetCounts(categories), Brands: facetCounts(brands), Price: buckets}
+}
+
+// facetCounts orders values by count, then name
+func facetCounts(counts map[string]int) []FacetCount {
+	out := make([]FacetCount, 0, len(counts))
+	for v, c := range counts {
+		out = append(out, FacetCount{Value: v, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// testIndex indexes a small catalog:
+//
+//	1 Galaxy S24    Samsung  Smartphones 12jt  RAM "12 GB"
+//	2 Galaxy Buds   Samsung  Audio       1.5jt "noise cancelling"
+//	3 Pixel 8       Google   Smartphones 9jt   "Samsung" only in the description
+//	4 ThinkPad X1   Lenovo   Laptops     25jt  "pekerjaan"
+func testIndex() *Index {
+	ix := New()
+	ix.Build([]models.Product{
+		{ID: 1, Name: "Galaxy S24", Brand: "Samsung", Category: "Smartphones", Price: 12_000_000,
+			Description: "Kamera terbaik", Specifications: []models.ProductSpec{{Key: "RAM", Value: "12 GB"}}},
+		{ID: 2, Name: "Galaxy Buds", Brand: "Samsung", Category: "Audio", Price: 1_500_000,
+			Description: "Earbuds dengan noise cancelling"},
+		{ID: 3, Name: "Pixel 8", Brand: "Google", Category: "Smartphones", Price: 9_000_000,
+			Description: "Ponsel pesaing Samsung"},
+		{ID: 4, Name: "ThinkPad X1", Brand: "Lenovo", Category: "Laptops", Price: 25_000_000,
+			Description: "Laptop untuk pekerjaan kantor"},
+	})
+	return ix
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := testIndex()
+	tests := []struct {
+		name string
+		text string
+		want []int
+	}{
+		{"brand outranks description", "samsung", []int{1, 2, 3}},
+		{"name", "galaxy", []int{1, 2}},
+		{"case and accents", "GALAXÝ", []int{1, 2}},
+		{"every word must match", "galaxy kamera", []int{1}},
+		{"partial matches when nothing matches every word", "galaxy xyzzy", []int{1, 2}},
+		{"spec value", "12gb", []int{1}},
+		{"spec value with a space", "12 gb", []int{1}},
+		{"Indonesian stem", "bekerja", []int{4}},
+		{"English stem", "cancel", []int{2}},
+		{"transposed letters", "samsnug", []int{1, 2, 3}},
+		{"two typos in a long word", "thnikpda", []int{4}},
+		{"no typos in words with digits", "s23", nil},
+		{"short words must be exact", "pxl", nil},
+		{"stopwords only", "dan untuk", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ix.Search(Query{Text: tt.text})
+			if !reflect.DeepEqual(res.IDs, tt.want) || res.Total != len(tt.want) {
+				t.Errorf("Search(%q) = %v (total %d), want %v", tt.text, res.IDs, res.Total, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchExactBeatsTypo(t *testing.T) {
+	ix := New()
+	ix.Build([]models.Product{
+		{ID: 1, Name: "Casing Samsun"},
+		{ID: 2, Name: "Casing Samsung"},
+	})
+	if res := ix.Search(Query{Text: "samsung"}); !reflect.DeepEqual(res.IDs, []int{2, 1}) {
+		t.Fatalf("got %v, want the exact match first", res.IDs)
+	}
+}
+
+func TestSearchFiltersAndFacets(t *testing.T) {
+	ix := testIndex()
+
+	res := ix.Search(Query{Text: "samsung", Categories: []string{"smartphones"}})
+	if !reflect.DeepEqual(res.IDs, []int{1, 3}) {
+		t.Fatalf("category filter: got %v", res.IDs)
+	}
+	// Each facet ignores its own filter, so other categories still show counts
+	want := Facets{
+		Categories: []FacetCount{{"Smartphones", 2}, {"Audio", 1}},
+		Brands:     []FacetCount{{"Google", 1}, {"Samsung", 1}},
+	}
+	if !reflect.DeepEqual(res.Facets.Categories, want.Categories) || !reflect.DeepEqual(res.Facets.Brands, want.Brands) {
+		t.Fatalf("facets = %+v, want %+v", res.Facets, want)
+	}
+	prices := map[string]int{}
+	for _, b := range res.Facets.Price {
+		prices[b.Label] = b.Count
+	}
+	if len(res.Facets.Price) != len(priceBuckets) || prices["5jt - 10jt"] != 1 || prices["10jt - 20jt"] != 1 || prices["1jt - 3jt"] != 0 {
+		t.Fatalf("price facet = %+v", res.Facets.Price)
+	}
+
+	res = ix.Search(Query{Text: "samsung", Brands: []string{"SAMSUNG"}})
+	if !reflect.DeepEqual(res.IDs, []int{1, 2}) || !reflect.DeepEqual(res.Facets.Brands, []FacetCount{{"Samsung", 2}, {"Google", 1}}) {
+		t.Fatalf("brand filter: got %v, facets %+v", res.IDs, res.Facets.Brands)
+	}
+
+	res = ix.Search(Query{Text: "samsung", MinPrice: 2_000_000, MaxPrice: 10_000_000})
+	if !reflect.DeepEqual(res.IDs, []int{3}) {
+		t.Fatalf("price filter: got %v", res.IDs)
+	}
+
+	res = ix.Search(Query{Text: "samsung", Only: map[int]bool{2: true, 4: true}})
+	if !reflect.DeepEqual(res.IDs, []int{2}) || !reflect.DeepEqual(res.Facets.Categories, []FacetCount{{"Audio", 1}}) {
+		t.Fatalf("only: got %v, facets %+v", res.IDs, res.Facets.Categories)
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	ix := testIndex()
+	tests := []struct {
+		limit, offset int
+		want          []int
+	}{
+		{0, 0, []int{1, 2, 3}},
+		{2, 0, []int{1, 2}},
+		{2, 2, []int{3}},
+		{1, 1, []int{2}},
+		{2, 3, nil},
+		{2, 100, nil},
+		{2, -5, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		res := ix.Search(Query{Text: "samsung", Limit: tt.limit, Offset: tt.offset})
+		if !reflect.DeepEqual(res.IDs, tt.want) || res.Total != 3 {
+			t.Errorf("limit %d offset %d: got %v (total %d), want %v", tt.limit, tt.offset, res.IDs, res.Total, tt.want)
+		}
+	}
+}
+
+func TestIndexUpsertAndRemove(t *testing.T) {
+	ix := testIndex()
+
+	ix.Upsert(models.Product{ID: 3, Name: "Pixel 8", Brand: "Google", Category: "Smartphones", Price: 9_000_000})
+	if res := ix.Search(Query{Text: "samsung"}); !reflect.DeepEqual(res.IDs, []int{1, 2}) {
+		t.Fatalf("after upsert: got %v", res.IDs)
+	}
+	if res := ix.Search(Query{Text: "pesaing"}); res.Total != 0 {
+		t.Fatalf("old description still indexed: %v", res.IDs)
+	}
+
+	ix.Remove(2)
+	ix.Remove(99)
+	if res := ix.Search(Query{Text: "galaxy"}); !reflect.DeepEqual(res.IDs, []int{1}) {
+		t.Fatalf("after remove: got %v", res.IDs)
+	}
+	if ix.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", ix.Len())
+	}
+}
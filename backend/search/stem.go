@@ -0,0 +1,106 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stem reduces a folded token to the form used for matching. Product text
+// mixes Indonesian and English, so both light stemmers run in sequence; the
+// exact word is indexed as well, so over-stemming only costs ranking, never
+// recall. Tokens containing digits ("12gb", "s24") are left alone.
+func stem(token string) string {
+	for _, r := range token {
+		if unicode.IsDigit(r) {
+			return token
+		}
+	}
+	return stemEnglish(stemIndonesian(token))
+}
+
+// stemIndonesian strips particles, possessives, derivational suffixes and
+// prefixes in the order of the Nazief-Adriani algorithm, without a root
+// dictionary: an affix is only removed when at least 4 letters remain.
+func stemIndonesian(w string) string {
+	const minRoot = 4
+	strip := func(w, suffix string) (string, bool) {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= minRoot {
+			return w[:len(w)-len(suffix)], true
+		}
+		return w, false
+	}
+
+	for _, p := range []string{"lah", "kah", "tah", "pun"} {
+		if s, ok := strip(w, p); ok {
+			w = s
+			break
+		}
+	}
+	for _, p := range []string{"nya", "ku", "mu"} {
+		if s, ok := strip(w, p); ok {
+			w = s
+			break
+		}
+	}
+	for _, p := range []string{"kan", "an", "i"} {
+		if s, ok := strip(w, p); ok {
+			w = s
+			break
+		}
+	}
+
+	// Prefixes with their recoded first letter (menulis -> tulis, memakai -> pakai)
+	prefixes := []struct{ prefix, recode string }{
+		{"meny", "s"}, {"peny", "s"}, {"meng", ""}, {"peng", ""},
+		{"mem", "p"}, {"pem", "p"}, {"men", "t"}, {"pen", "t"},
+		{"ber", ""}, {"ter", ""}, {"per", ""},
+		{"me", ""}, {"pe", ""}, {"be", ""}, {"di", ""}, {"ke", ""}, {"se", ""},
+	}
+	for _, p := range prefixes {
+		if !strings.HasPrefix(w, p.prefix) {
+			continue
+		}
+		root := w[len(p.prefix):]
+		// The nasal replaced the root's first letter only before a vowel
+		// (memakai -> pakai); before a consonant it is just dropped (membeli -> beli)
+		if p.recode != "" && root != "" && !strings.ContainsRune("aiueo", rune(root[0])) {
+			p.recode = ""
+		}
+		root = p.recode + root
+		if len(root) >= minRoot {
+			return root
+		}
+		break
+	}
+	return w
+}
+
+// stemEnglish is a light suffix stripper covering plurals and the common
+// verb/adjective endings seen in product text ("chargers" -> "charg")
+func stemEnglish(w string) string {
+	const minStem = 3
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w)-1 >= minStem:
+		w = w[:len(w)-1]
+	}
+	for _, suffix := range []string{"ing", "ed", "er", "ly", "ness", "ment"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= minStem {
+			return undouble(w[:len(w)-len(suffix)])
+		}
+	}
+	return w
+}
+
+// undouble drops a doubled final consonant left by a stripped suffix
+// ("cancelling" -> "cancel", "stopped" -> "stop")
+func undouble(w string) string {
+	n := len(w)
+	if n >= 4 && w[n-1] == w[n-2] && !strings.ContainsRune("aiueos", rune(w[n-1])) {
+		return w[:n-1]
+	}
+	return w
+}
@@ -0,0 +1,48 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// Indonesian affixes, with the nasal prefix recoded before a vowel
+		{"pekerjaan", "kerja"},
+		{"bekerja", "kerja"},
+		{"menulis", "tuli"},
+		{"memakai", "paka"},
+		{"pakai", "paka"},
+		{"bukunya", "buku"},
+		// Too short to strip without leaving a root under 4 letters
+		{"beli", "beli"},
+		// English plurals and verb endings
+		{"chargers", "charg"},
+		{"charging", "charg"},
+		{"cancelling", "cancel"},
+		{"batteries", "battery"},
+		{"headphones", "headphone"},
+		{"glass", "glass"},
+		// Tokens with digits are never stemmed
+		{"12gb", "12gb"},
+		{"s24", "s24"},
+	}
+	for _, tt := range tests {
+		if got := stem(tt.in); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestStemSharedRoots checks word forms the README promises to match
+func TestStemSharedRoots(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"pekerjaan", "bekerja"},
+		{"cancelling", "cancel"},
+		{"memakai", "pakai"},
+		{"chargers", "charging"},
+	} {
+		if a, b := stem(pair[0]), stem(pair[1]); a != b {
+			t.Errorf("stem(%q) = %q but stem(%q) = %q", pair[0], a, pair[1], b)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+// Suggestion kinds
+const (
+	SuggestProduct  = "product"
+	SuggestBrand    = "brand"
+	SuggestCategory = "category"
+)
+
+// Suggestion is one autocomplete entry. ProductID is set for product names.
+type Suggestion struct {
+	Text      string `json:"text"`
+	Type      string `json:"type"`
+	ProductID int    `json:"product_id,omitempty"`
+}
+
+// suggestEntry is a distinct completion and how popular it is: the rating
+// for a product, the number of products for a brand or category
+type suggestEntry struct {
+	Suggestion
+	popularity float64
+}
+
+// prefixKey points from a folded word-start of an entry's text to the entry.
+// "Galaxy S24 Ultra" gets the keys "galaxy s24 ultra", "s24 ultra" and "ultra".
+type prefixKey struct {
+	key   string
+	entry int
+	whole bool // key starts at the beginning of the text
+}
+
+type suggestDoc struct {
+	name     string
+	brand    string
+	category string
+	rating   float64
+}
+
+// Suggester is a prefix index over product names, brands and categories.
+// It is safe for concurrent use.
+type Suggester struct {
+	mu      sync.RWMutex
+	docs    map[int]suggestDoc
+	entries []suggestEntry
+	keys    []prefixKey // sorted by key
+}
+
+// NewSuggester returns an empty suggester
+func NewSuggester() *Suggester {
+	return &Suggester{docs: map[int]suggestDoc{}}
+}
+
+// Build replaces the indexed products
+func (sg *Suggester) Build(products []models.Product) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	sg.docs = make(map[int]suggestDoc, len(products))
+	for _, p := range products {
+		sg.docs[p.ID] = docFor(p)
+	}
+	sg.rebuild()
+}
+
+// Upsert adds or refreshes one product
+func (sg *Suggester) Upsert(p models.Product) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	sg.docs[p.ID] = docFor(p)
+	sg.rebuild()
+}
+
+// Remove drops one product
+func (sg *Suggester) Remove(id int) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if _, ok := sg.docs[id]; ok {
+		delete(sg.docs, id)
+		sg.rebuild()
+	}
+}
+
+func docFor(p models.Product) suggestDoc {
+	return suggestDoc{
+		name:     strings.TrimSpace(p.Name),
+		brand:    strings.TrimSpace(p.Brand),
+		category: strings.TrimSpace(p.Category),
+		rating:   float64(p.Rating),
+	}
+}
+
+// rebuild recomputes the entries and the sorted key list from docs. Brands and
+// categories are merged case-insensitively, keeping the most common spelling.
+func (sg *Suggester) rebuild() {
+	type group struct {
+		count     int
+		spellings map[string]int
+	}
+	groups := map[string]map[string]*group{SuggestBrand: {}, SuggestCategory: {}}
+	count := func(kind, text string) {
+		if text == "" {
+			return
+		}
+		k := fold(text)
+		g := groups[kind][k]
+		if g == nil {
+			g = &group{spellings: map[string]int{}}
+			groups[kind][k] = g
+		}
+		g.count++
+		g.spellings[text]++
+	}
+
+	entries := make([]suggestEntry, 0, len(sg.docs))
+	for id, d := range sg.docs {
+		if d.name != "" {
+			entries = append(entries, suggestEntry{
+				Suggestion: Suggestion{Text: d.name, Type: SuggestProduct, ProductID: id},
+				popularity: d.rating,
+			})
+		}
+		count(SuggestBrand, d.brand)
+		count(SuggestCategory, d.category)
+	}
+	for kind, byKey := range groups {
+		for _, g := range byKey {
+			best := ""
+			for s, n := range g.spellings {
+				if best == "" || n > g.spellings[best] || n == g.spellings[best] && s < best {
+					best = s
+				}
+			}
+			entries = append(entries, suggestEntry{
+				Suggestion: Suggestion{Text: best, Type: kind},
+				popularity: float64(g.count),
+			})
+		}
+	}
+
+	keys := make([]prefixKey, 0, len(entries)*2)
+	for i, e := range entries {
+		words := suggestWords(e.Text)
+		for w := range words {
+			keys = append(keys, prefixKey{key: strings.Join(words[w:], " "), entry: i, whole: w == 0})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+
+	sg.entries, sg.keys = entries, keys
+}
+
+// suggestWords splits folded text into words at anything but letters and
+// digits, keeping stopwords since they are part of what the user types
+func suggestWords(s string) []string {
+	return strings.FieldsFunc(fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Suggest returns up to limit completions for the typed prefix. Matches at the
+// start of the text rank before matches at a later word; categories rank
+// before brands before product names, then by popularity.
+func (sg *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	prefix = strings.Join(suggestWords(prefix), " ")
+	if prefix == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+
+	start := sort.Search(len(sg.keys), func(i int) bool { return sg.keys[i].key >= prefix })
+	whole := map[int]bool{}
+	for i := start; i < len(sg.keys) && strings.HasPrefix(sg.keys[i].key, prefix); i++ {
+		k := sg.keys[i]
+		whole[k.entry] = whole[k.entry] || k.whole
+	}
+
+	matches := make([]int, 0, len(whole))
+	for i := range whole {
+		matches = append(matches, i)
+	}
+	kindRank := map[string]int{SuggestCategory: 0, SuggestBrand: 1, SuggestProduct: 2}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := sg.entries[matches[i]], sg.entries[matches[j]]
+		if whole[matches[i]] != whole[matches[j]] {
+			return whole[matches[i]]
+		}
+		if kindRank[a.Type] != kindRank[b.Type] {
+			return kindRank[a.Type] < kindRank[b.Type]
+		}
+		if a.popularity != b.popularity {
+			return a.popularity > b.popularity
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	out := make([]Suggestion, len(matches))
+	for i, m := range matches {
+		out[i] = sg.entries[m].Suggestion
+	}
+	return out
+}
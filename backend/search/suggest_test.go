@@ -0,0 +1,98 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HHHAAAANNNNN/go-commerce-backend/models"
+)
+
+func testSuggester() *Suggester {
+	sg := NewSuggester()
+	sg.Build([]models.Product{
+		{ID: 1, Name: "Galaxy S24 Ultra", Brand: "Samsung", Category: "Smartphones", Rating: 4.8},
+		{ID: 2, Name: "Galaxy A15", Brand: "samsung", Category: "Smartphones", Rating: 4.2},
+		{ID: 3, Name: "Galaxy Buds", Brand: "Samsung", Category: "Audio", Rating: 4.5},
+		{ID: 4, Name: "Pixel 8", Brand: "Google", Category: "Smartphones", Rating: 4.6},
+		{ID: 5, Name: "Gaming Headset", Brand: "Logitech", Category: "Audio", Rating: 4.0},
+	})
+	return sg
+}
+
+func product(id int, name string) Suggestion {
+	return Suggestion{Text: name, Type: SuggestProduct, ProductID: id}
+}
+
+func TestSuggest(t *testing.T) {
+	sg := testSuggester()
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   []Suggestion
+	}{
+		{"products by rating", "ga", 10, []Suggestion{
+			product(1, "Galaxy S24 Ultra"), product(3, "Galaxy Buds"), product(2, "Galaxy A15"), product(5, "Gaming Headset"),
+		}},
+		{"brands before products", "g", 10, []Suggestion{
+			{Text: "Google", Type: SuggestBrand},
+			product(1, "Galaxy S24 Ultra"), product(3, "Galaxy Buds"), product(2, "Galaxy A15"), product(5, "Gaming Headset"),
+		}},
+		// Categories come before brands; brand spellings merge into the most common one
+		{"categories before brands", "s", 10, []Suggestion{
+			{Text: "Smartphones", Type: SuggestCategory},
+			{Text: "Samsung", Type: SuggestBrand},
+			product(1, "Galaxy S24 Ultra"),
+		}},
+		{"start of text before a later word", "a", 10, []Suggestion{
+			{Text: "Audio", Type: SuggestCategory},
+			product(2, "Galaxy A15"),
+		}},
+		{"later word", "ultra", 10, []Suggestion{product(1, "Galaxy S24 Ultra")}},
+		{"several words", "galaxy b", 10, []Suggestion{product(3, "Galaxy Buds")}},
+		{"case, accents and spacing", "  GALÁXY   s2", 10, []Suggestion{product(1, "Galaxy S24 Ultra")}},
+		{"limit", "ga", 2, []Suggestion{product(1, "Galaxy S24 Ultra"), product(3, "Galaxy Buds")}},
+		{"zero limit", "ga", 0, []Suggestion{}},
+		{"empty prefix", " - ", 10, []Suggestion{}},
+		{"no match", "zz", 10, []Suggestion{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sg.Suggest(tt.prefix, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest(%q, %d) = %+v, want %+v", tt.prefix, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestTieBreaks(t *testing.T) {
+	sg := NewSuggester()
+	sg.Build([]models.Product{
+		{ID: 1, Name: "Case Bening Tebal", Rating: 4},
+		{ID: 2, Name: "Case Bening", Rating: 4},
+		{ID: 3, Name: "Case Armor", Rating: 4},
+	})
+	want := []Suggestion{product(3, "Case Armor"), product(2, "Case Bening"), product(1, "Case Bening Tebal")}
+	if got := sg.Suggest("case", 10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want shorter text first, then alphabetical", got)
+	}
+}
+
+func TestSuggesterUpsertAndRemove(t *testing.T) {
+	sg := testSuggester()
+
+	sg.Remove(1)
+	sg.Remove(99)
+	if got := sg.Suggest("ultra", 10); len(got) != 0 {
+		t.Fatalf("removed product still suggested: %+v", got)
+	}
+	sg.Upsert(models.Product{ID: 4, Name: "Pixel 9", Brand: "Google", Category: "Smartphones"})
+	if got := sg.Suggest("pixel", 10); !reflect.DeepEqual(got, []Suggestion{product(4, "Pixel 9")}) {
+		t.Fatalf("after upsert: %+v", got)
+	}
+	// Brand and category counts follow the products
+	sg.Remove(5)
+	if got := sg.Suggest("logi", 10); len(got) != 0 {
+		t.Fatalf("brand of removed product still suggested: %+v", got)
+	}
+}
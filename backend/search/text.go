@@ -0,0 +1,65 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopwords are dropped from documents and queries (English and Indonesian)
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "for": true, "with": true,
+	"in": true, "on": true, "to": true, "or": true, "is": true, "by": true,
+	"dan": true, "yang": true, "di": true, "ke": true, "dari": true, "untuk": true,
+	"dengan": true, "atau": true, "ini": true, "itu": true, "pada": true, "adalah": true,
+}
+
+// fold lower-cases s and strips diacritics ("Café" -> "cafe")
+func fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+// tokenize splits s into folded words of letters and digits, without stopwords.
+// "12GB" stays one token so spec values like RAM sizes remain searchable.
+func tokenize(s string) []string {
+	words := strings.FieldsFunc(fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if !stopwords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// documentTerms is tokenize plus each number joined to the unit after it, so
+// a spec value "12 GB" is also found by the query "12gb"
+func documentTerms(s string) []string {
+	tokens := tokenize(s)
+	terms := tokens
+	for i := 0; i+1 < len(tokens); i++ {
+		if isNumber(tokens[i]) && !isNumber(tokens[i+1]) && len(tokens[i+1]) <= 3 {
+			terms = append(terms, tokens[i]+tokens[i+1])
+		}
+	}
+	return terms
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,41 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Galaxy S24 Ultra", []string{"galaxy", "s24", "ultra"}},
+		{"Café Crème, 12GB & the Best-Seller", []string{"cafe", "creme", "12gb", "best", "seller"}},
+		{"Samsung dan Apple untuk Anda", []string{"samsung", "apple", "anda"}},
+		{"  --  ", []string{}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"RAM 12 GB", []string{"ram", "12", "gb", "12gb"}},
+		{"5000 mAh", []string{"5000", "mah", "5000mah"}},
+		// Only short units are joined to the number before them
+		{"Layar 6.7 inch", []string{"layar", "6", "7", "inch"}},
+		{"12GB", []string{"12gb"}},
+	}
+	for _, tt := range tests {
+		if got := documentTerms(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("documentTerms(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
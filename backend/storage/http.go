@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Handler serves objects by key, taken from the request path left over after
+// http.StripPrefix. An S3 storage with RedirectTTL set redirects to a signed
+// URL instead, so the bytes never pass through the API server.
+func Handler(s Storage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if checkKey(key) != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if s3, ok := s.(*S3); ok && s3.RedirectTTL > 0 {
+			signed, err := s3.SignedURL(r.Context(), key, s3.RedirectTTL)
+			if err != nil {
+				http.Error(w, "Failed to sign URL", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, signed, http.StatusFound)
+			return
+		}
+
+		body, obj, err := s.Get(r.Context(), key)
+		if err == ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Failed to read upload %s: %v", key, err)
+			http.Error(w, "Failed to read file", http.StatusBadGateway)
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", obj.ContentType)
+		// Uploads never change under the same key
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if rs, ok := body.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, key, obj.ModTime, rs)
+			return
+		}
+		if !obj.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", obj.ModTime.UTC().Format(http.TimeFormat))
+		}
+		if obj.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.Copy(w, body)
+	})
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) (*Local, string) {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	l, err := NewLocal(dir, "/assets/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, root
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLocal(t)
+
+	if err := l.Put(ctx, "product_1_large.jpg", strings.NewReader("jpeg bytes"), 10, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Put(ctx, "avatar_2.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, obj, err := l.Get(ctx, "product_1_large.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "jpeg bytes" || obj.Size != 10 || obj.ContentType != "image/jpeg" {
+		t.Fatalf("Get = %q, %+v", data, obj)
+	}
+
+	// Replacing keeps one file and no temporary leftovers
+	if err := l.Put(ctx, "product_1_large.jpg", strings.NewReader("new"), 3, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err := l.Stat(ctx, "product_1_large.jpg"); err != nil || obj.Size != 3 {
+		t.Fatalf("Stat = %+v, %v", obj, err)
+	}
+	objects, err := l.List(ctx, "product_")
+	if err != nil || len(objects) != 1 || objects[0].Key != "product_1_large.jpg" {
+		t.Fatalf("List(product_) = %+v, %v", objects, err)
+	}
+	entries, _ := os.ReadDir(l.Dir)
+	if len(entries) != 2 {
+		t.Fatalf("upload dir holds %d entries, want 2", len(entries))
+	}
+
+	if url, err := l.SignedURL(ctx, "avatar_2.png", time.Minute); err != nil || url != "/assets/uploads/avatar_2.png" {
+		t.Fatalf("SignedURL = %q, %v", url, err)
+	}
+
+	if err := l.Delete(ctx, "product_1_large.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "product_1_large.jpg"); err != nil {
+		t.Fatalf("deleting a missing key: %v", err)
+	}
+	if _, _, err := l.Get(ctx, "product_1_large.jpg"); err != ErrNotFound {
+		t.Fatalf("Get after Delete: %v", err)
+	}
+	if _, err := l.Stat(ctx, "missing.jpg"); err != ErrNotFound {
+		t.Fatalf("Stat missing: %v", err)
+	}
+}
+
+func TestLocalRejectsPathTraversal(t *testing.T) {
+	ctx := context.Background()
+	l, root := newTestLocal(t)
+	secret := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(secret, []byte("keep out"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(l.Dir, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{
+		"", ".", "..", "../secret.txt", "../../etc/passwd", "nested/file.jpg",
+		"/etc/passwd", `..\secret.txt`, `nested\file.jpg`, "nested/../../secret.txt",
+	} {
+		if err := l.Put(ctx, key, strings.NewReader("x"), 1, ""); err != ErrInvalidKey {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, _, err := l.Get(ctx, key); err != ErrInvalidKey {
+			t.Errorf("Get(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.Stat(ctx, key); err != ErrInvalidKey {
+			t.Errorf("Stat(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if err := l.Delete(ctx, key); err != ErrInvalidKey {
+			t.Errorf("Delete(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.SignedURL(ctx, key, time.Minute); err != ErrInvalidKey {
+			t.Errorf("SignedURL(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if data, err := os.ReadFile(secret); err != nil || string(data) != "keep out" {
+		t.Fatalf("file outside the upload dir changed: %q, %v", data, err)
+	}
+	// A directory inside the upload dir is not an object either
+	if _, err := l.Stat(ctx, "nested"); err != ErrNotFound {
+		t.Fatalf("Stat(nested) = %v, want ErrNotFound", err)
+	}
+	if objects, _ := l.List(ctx, ""); len(objects) != 0 {
+		t.Fatalf("List lists %+v", objects)
+	}
+}
+
+func TestHandlerServesOnlyFlatKeys(t *testing.T) {
+	ctx := context.Background()
+	l, root := newTestLocal(t)
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte("keep out"), 0644)
+	if err := l.Put(ctx, "product_1.jpg", strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	h := http.StripPrefix("/assets/uploads/", Handler(l))
+
+	for path, want := range map[string]int{
+		"/assets/uploads/product_1.jpg":       http.StatusOK,
+		"/assets/uploads/missing.jpg":         http.StatusNotFound,
+		"/assets/uploads/%2e%2e%2fsecret.txt": http.StatusNotFound,
+		"/assets/uploads/..%5csecret.txt":     http.StatusNotFound,
+		"/assets/uploads/":                    http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+		if strings.Contains(rec.Body.String(), "keep out") {
+			t.Errorf("GET %s leaked a file outside the upload dir", path)
+		}
+	}
+}
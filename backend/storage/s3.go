@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in a bucket of an S3-compatible service, signing every
+// request with AWS Signature Version 4
+type S3 struct {
+	Endpoint  string // e.g. https://s3.ap-southeast-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle puts the bucket in the path (http://host/bucket/key), as MinIO
+	// expects, instead of the host name (http://bucket.host/key)
+	PathStyle bool
+	// RedirectTTL, when set, makes Handler redirect to signed URLs valid this
+	// long instead of streaming objects through the API server
+	RedirectTTL time.Duration
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+const (
+	sigAlgorithm    = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+	maxPresignTTL   = 7 * 24 * time.Hour
+)
+
+// s3Error is the XML body S3 sends with a failed request
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// listResult is the XML body of a ListObjectsV2 response
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// The payload hash is part of the signature, so the body is buffered
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodPut, s.objectURL(key), body, contentType)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, nil, err
+	}
+	res, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Body, objectFromHeader(key, res), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	res, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	return objectFromHeader(key, res), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, "")
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		u := s.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = canonicalQuery(q)
+
+		res, err := s.do(ctx, http.MethodGet, u, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list: %w", err)
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, ContentType: contentType(c.Key), ModTime: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// SignedURL presigns a GET with query-string authentication; S3 caps expires at 7 days
+func (s *S3) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	if expires <= 0 || expires > maxPresignTTL {
+		return "", fmt.Errorf("signed URL lifetime must be between 1s and %s", maxPresignTTL)
+	}
+	return s.presign(http.MethodGet, s.objectURL(key), time.Now(), expires), nil
+}
+
+// objectURL addresses key in the bucket; an empty key addresses the bucket itself
+func (s *S3) objectURL(key string) *url.URL {
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		u = &url.URL{Scheme: "https", Host: s.Endpoint}
+	}
+	p := "/" + key
+	if s.PathStyle {
+		p = "/" + s.Bucket + p
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+	u.Path = u.Path + p
+	u.RawPath = uriEscape(u.Path, false)
+	return u
+}
+
+// do sends a signed request and turns error statuses into errors; a 404
+// becomes ErrNotFound
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var e s3Error
+	xml.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&e)
+	if e.Code == "" {
+		e.Code = res.Status
+	}
+	return nil, fmt.Errorf("s3 %s %s: %s", method, u.Path, strings.TrimSpace(e.Code+" "+e.Message))
+}
+
+// sign adds the Authorization header of Signature Version 4, covering the
+// host, the Content-Type if any and the x-amz-* headers
+func (s *S3) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope, signature := s.signature(amzDate, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign returns u with query-string authentication for method, signing
+// only the host header
+func (s *S3) presign(method string, u *url.URL, t time.Time, expires time.Duration) string {
+	amzDate := t.UTC().Format(amzDateFormat)
+	scope := amzDate[:8] + "/" + s.Region + "/s3/aws4_request"
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", sigAlgorithm)
+	q.Set("X-Amz-Credential", s.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	query := canonicalQuery(q)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	_, signature := s.signature(amzDate, canonicalRequest)
+
+	signed := *u
+	signed.RawQuery = query + "&X-Amz-Signature=" + signature
+	return signed.String()
+}
+
+// signature derives the day's signing key and signs the canonical request
+func (s *S3) signature(amzDate, canonicalRequest string) (scope, signature string) {
+	day := amzDate[:8]
+	scope = day + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes query parameters the way SigV4 expects
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEscape(k, true)+"="+uriEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEscape percent-encodes everything but unreserved characters (and '/'
+// unless encodeSlash), as SigV4 requires
+func uriEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// objectFromHeader describes the object of a GET or HEAD response
+func objectFromHeader(key string, res *http.Response) *Object {
+	obj := &Object{Key: key, Size: res.ContentLength, ContentType: res.Header.Get("Content-Type")}
+	if obj.ContentType == "" {
+		obj.ContentType = contentType(key)
+	}
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = t
+	}
+	return obj
+}
@@ -0,0 +1,108 @@
+// Package storage keeps uploaded files. The implementation is picked with
+// STORAGE_DRIVER: "local" (default) writes to a directory on disk and "s3"
+// talks to any S3-compatible object store (AWS S3, MinIO, R2, ...). Keys are
+// flat file names such as product_123_large.jpg.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for keys that are empty or contain a path
+var ErrInvalidKey = errors.New("invalid object key")
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores uploaded files under flat keys
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object; the caller closes the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Stat describes the object without reading it
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL that reads the object without credentials
+	// until expires has passed
+	SignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// checkKey rejects keys that could escape the storage root
+func checkKey(key string) error {
+	if key == "" || key == "." || key == ".." || key != path.Base(key) || strings.ContainsAny(key, `\`) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// contentType guesses the MIME type of a key from its extension
+func contentType(key string) string {
+	if t := mime.TypeByExtension(filepath.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// FromEnv builds the storage configured by STORAGE_DRIVER, UPLOADS_DIR and S3_*
+func FromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("UPLOADS_DIR")
+		if dir == "" {
+			dir = "./uploads" // Local development
+			if os.Getenv("RAILWAY_ENVIRONMENT_NAME") != "" {
+				dir = "/app/uploads"
+			}
+		}
+		return NewLocal(dir, "/assets/uploads/")
+	case "s3":
+		s := &S3{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+		}
+		if s.Endpoint == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT is required for STORAGE_DRIVER=s3")
+		}
+		if s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for STORAGE_DRIVER=s3")
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if ttl := os.Getenv("S3_REDIRECT_TTL"); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid S3_REDIRECT_TTL %q", ttl)
+			}
+			s.RedirectTTL = d
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q (use local or s3)", driver)
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidActionToken is returned for a malformed, forged, expired or
+// wrong-purpose action token
+var ErrInvalidActionToken = errors.New("invalid or expired token")
+
+// NewActionToken returns a signed token for purpose (e.g. "verify_email") that
+// expires after ttl. The token is "<payload>.<signature>", both base64url,
+// with payload "purpose|userID|unix expiry|nonce"; the random nonce keeps every
+// token unique so the caller can store its hash and enforce single use.
+func NewActionToken(purpose string, userID int, ttl time.Duration) (string, time.Time, error) {
+	r, err := keys()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nonce, err := RandomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	payload := fmt.Sprintf("%s|%d|%d|%s", purpose, userID, expiresAt.Unix(), nonce)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signAction(r.actionSecret, encoded), expiresAt, nil
+}
+
+// VerifyActionToken checks the signature, purpose and expiry of token and
+// returns the user it was issued for. Single use is enforced by the caller.
+func VerifyActionToken(token, purpose string) (int, error) {
+	r, err := keys()
+	if err != nil {
+		return 0, err
+	}
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signAction(r.actionSecret, encoded))) {
+		return 0, ErrInvalidActionToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, ErrInvalidActionToken
+	}
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 4 || parts[0] != purpose {
+		return 0, ErrInvalidActionToken
+	}
+	userID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, ErrInvalidActionToken
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() >= exp {
+		return 0, ErrInvalidActionToken
+	}
+	return userID, nil
+}
+
+func signAction(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -8,42 +12,91 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Claims holds the JWT payload
+// Claims holds the JWT payload; RegisteredClaims.ID carries the jti used for revocation
 type Claims struct {
 	UserID int    `json:"user_id"`
 	Role   string `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func getJWTSecret() []byte {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "go-commerce-secret-key-change-in-production"
+// envDuration reads a Go duration (e.g. "15m", "720h") from key, falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
 	}
-	return []byte(secret)
+	return def
 }
 
-// GenerateToken creates a signed JWT for the given user ID and role (24h expiry)
+// AccessTokenTTL is the lifetime of access tokens (JWT_ACCESS_TTL, default 15m)
+func AccessTokenTTL() time.Duration {
+	return envDuration("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL is the lifetime of refresh tokens (JWT_REFRESH_TTL, default 30 days)
+func RefreshTokenTTL() time.Duration {
+	return envDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+// RandomToken returns n random bytes encoded as URL-safe base64
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token; only hashes are stored server-side
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken creates a short-lived access token for the given user ID and role,
+// signed with the active key and tagged with its kid
 func GenerateToken(userID int, role string) (string, error) {
+	r, err := keys()
+	if err != nil {
+		return "", err
+	}
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := Claims{
 		UserID: userID,
 		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(getJWTSecret())
+	token := jwt.NewWithClaims(r.signing.method, claims)
+	token.Header["kid"] = r.signing.kid
+	return token.SignedString(r.signing.signKey)
 }
 
-// ValidateToken parses and validates a JWT string, returning its claims
+// ValidateToken parses and validates a JWT string, returning its claims.
+// The kid header selects the key, so tokens signed with a key that is being
+// rotated out stay valid as long as that key is still configured.
 func ValidateToken(tokenString string) (*Claims, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		key := r.signing
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key = r.keys[kid]; key == nil {
+				return nil, errors.New("unknown key id")
+			}
+		}
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return getJWTSecret(), nil
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return nil, err
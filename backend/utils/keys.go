@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultJWTSecret is only accepted in development
+const defaultJWTSecret = "go-commerce-secret-key-change-in-production"
+
+// jwtKey is one entry of the key ring. signKey is nil for verification-only
+// keys, e.g. the public half of a retired key kept around during rotation.
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type keyRing struct {
+	keys    map[string]*jwtKey
+	signing *jwtKey
+	// actionSecret signs the tokens mailed for email verification and password reset
+	actionSecret []byte
+}
+
+var (
+	ringOnce sync.Once
+	ring     *keyRing
+	ringErr  error
+)
+
+// IsDevelopment reports whether the server runs in development mode, i.e.
+// APP_ENV is development/dev/local/test. An unset APP_ENV means production.
+func IsDevelopment() bool {
+	switch strings.ToLower(os.Getenv("APP_ENV")) {
+	case "development", "dev", "local", "test":
+		return true
+	}
+	return false
+}
+
+// InitJWTKeys loads the signing keys; main calls it so a bad configuration
+// fails at startup instead of on the first login
+func InitJWTKeys() error {
+	_, err := keys()
+	return err
+}
+
+func keys() (*keyRing, error) {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeyRing()
+	})
+	return ring, ringErr
+}
+
+// loadKeyRing builds the key ring from the environment:
+//
+//	JWT_KEYS=kid:alg:material[,kid:alg:material...]   alg is HS256, RS256 or EdDSA
+//	JWT_SIGNING_KEY_ID=kid                             defaults to the first key
+//	JWT_SECRET=secret                                  single HS256 key, kid "default"
+//
+// For HS256 the material is the secret itself; for RS256/EdDSA it is a PEM
+// file path or inline PEM. A public-key PEM makes a verification-only key.
+func loadKeyRing() (*keyRing, error) {
+	r := &keyRing{keys: map[string]*jwtKey{}}
+	var order []string
+
+	if spec := strings.TrimSpace(os.Getenv("JWT_KEYS")); spec != "" {
+		for _, entry := range strings.Split(spec, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+			if len(parts) != 3 || parts[0] == "" {
+				return nil, fmt.Errorf("JWT_KEYS: entry %q must be kid:alg:material", entry)
+			}
+			k, err := parseJWTKey(parts[0], parts[1], parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("JWT_KEYS: key %q: %w", parts[0], err)
+			}
+			if _, dup := r.keys[k.kid]; dup {
+				return nil, fmt.Errorf("JWT_KEYS: duplicate kid %q", k.kid)
+			}
+			r.keys[k.kid] = k
+			order = append(order, k.kid)
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if _, ok := r.keys["default"]; !ok {
+			r.keys["default"] = &jwtKey{kid: "default", method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+			order = append(order, "default")
+		}
+	}
+
+	if len(r.keys) == 0 {
+		if !IsDevelopment() {
+			return nil, errors.New("JWT_SECRET or JWT_KEYS must be set outside development (APP_ENV)")
+		}
+		log.Println("⚠️  JWT_SECRET is not set. Using the insecure development secret.")
+		r.keys["default"] = &jwtKey{kid: "default", method: jwt.SigningMethodHS256, signKey: []byte(defaultJWTSecret), verifyKey: []byte(defaultJWTSecret)}
+		order = append(order, "default")
+	}
+
+	kid := os.Getenv("JWT_SIGNING_KEY_ID")
+	if kid == "" {
+		kid = order[0]
+	}
+	r.signing = r.keys[kid]
+	if r.signing == nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KEY_ID %q does not match any key", kid)
+	}
+	if r.signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", kid)
+	}
+
+	r.actionSecret = actionSecret(r.signing)
+
+	if !IsDevelopment() {
+		for _, k := range r.keys {
+			if secret, ok := k.signKey.([]byte); ok && string(secret) == defaultJWTSecret {
+				return nil, fmt.Errorf("key %q uses the default development secret", k.kid)
+			}
+		}
+	}
+	return r, nil
+}
+
+// actionSecret is ACTION_TOKEN_SECRET or, when unset, a secret derived from
+// the JWT signing key. Rotating that key invalidates outstanding emailed links.
+func actionSecret(k *jwtKey) []byte {
+	if secret := os.Getenv("ACTION_TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	var material []byte
+	switch key := k.signKey.(type) {
+	case []byte:
+		material = key
+	case *rsa.PrivateKey:
+		material = key.D.Bytes()
+	case ed25519.PrivateKey:
+		material = key.Seed()
+	}
+	sum := sha256.Sum256(append([]byte("go-commerce action tokens:"), material...))
+	return sum[:]
+}
+
+// parseJWTKey builds a key from its JWT_KEYS parts
+func parseJWTKey(kid, alg, material string) (*jwtKey, error) {
+	if material == "" {
+		return nil, errors.New("empty key material")
+	}
+	k := &jwtKey{kid: kid}
+	switch alg {
+	case "HS256":
+		k.method = jwt.SigningMethodHS256
+		k.signKey, k.verifyKey = []byte(material), []byte(material)
+		return k, nil
+	case "RS256":
+		k.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported alg %q (use HS256, RS256 or EdDSA)", alg)
+	}
+
+	pem, err := readPEM(material)
+	if err != nil {
+		return nil, err
+	}
+	isPublic := strings.Contains(string(pem), "PUBLIC KEY")
+	switch {
+	case alg == "RS256" && isPublic:
+		k.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+	case alg == "RS256":
+		var priv *rsa.PrivateKey
+		if priv, err = jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			k.signKey, k.verifyKey = priv, &priv.PublicKey
+		}
+	case isPublic:
+		k.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem)
+	default:
+		var priv crypto.PrivateKey
+		if priv, err = jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+			k.signKey, k.verifyKey = priv, priv.(ed25519.PrivateKey).Public()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// readPEM accepts inline PEM (with literal \n allowed) or a path to a PEM file
+func readPEM(material string) ([]byte, error) {
+	if strings.HasPrefix(material, "-----BEGIN") {
+		return []byte(strings.ReplaceAll(material, `\n`, "\n")), nil
+	}
+	return os.ReadFile(material)
+}
+
+// JWK is one public key in a JSON Web Key Set
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys other services can use to verify our tokens.
+// HS256 secrets are symmetric and never published.
+func JWKS() ([]JWK, error) {
+	r, err := keys()
+	if err != nil {
+		return nil, err
+	}
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := []JWK{}
+	for _, kid := range kids {
+		k := r.keys[kid]
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set = append(set, JWK{
+				Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+				N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set = append(set, JWK{
+				Kty: "OKP", Kid: k.kid, Use: "sig", Alg: k.method.Alg(),
+				Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,29 @@
+package utils
+
+import "testing"
+
+func TestDefaultSecretNeedsExplicitDevelopment(t *testing.T) {
+	for _, tc := range []struct {
+		appEnv, railway string
+		dev             bool
+	}{
+		{"", "", false},
+		{"", "production", false},
+		{"production", "", false},
+		{"development", "", true},
+		{"Dev", "", true},
+		{"test", "production", true},
+	} {
+		t.Setenv("APP_ENV", tc.appEnv)
+		t.Setenv("RAILWAY_ENVIRONMENT_NAME", tc.railway)
+		t.Setenv("JWT_SECRET", "")
+		t.Setenv("JWT_KEYS", "")
+
+		if got := IsDevelopment(); got != tc.dev {
+			t.Errorf("APP_ENV=%q RAILWAY_ENVIRONMENT_NAME=%q: IsDevelopment() = %v, want %v", tc.appEnv, tc.railway, got, tc.dev)
+		}
+		if _, err := loadKeyRing(); (err == nil) != tc.dev {
+			t.Errorf("APP_ENV=%q RAILWAY_ENVIRONMENT_NAME=%q without keys: err = %v", tc.appEnv, tc.railway, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ClientIP returns the caller's IP address. Behind a reverse proxy
+// (TRUST_PROXY=true, or on Railway) it is the last X-Forwarded-For entry,
+// i.e. the address our own proxy saw; entries before it are client-supplied.
+func ClientIP(r *http.Request) string {
+	trustProxy := os.Getenv("TRUST_PROXY") == "true" ||
+		(os.Getenv("TRUST_PROXY") == "" && os.Getenv("RAILWAY_ENVIRONMENT_NAME") != "")
+	if trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
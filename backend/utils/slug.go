@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugify lower-cases s, strips diacritics and joins its runs of letters and
+// digits with "-" ("Smart Watches & Bands" -> "smart-watches-bands",
+// "Café Crème" -> "cafe-creme"). Letters without a Latin form are kept.
+func Slugify(s string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if folded, _, err := transform.String(t, s); err == nil {
+		s = folded
+	}
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// TruncateSlug shortens slug to at most max bytes, cutting at a "-" where it can
+func TruncateSlug(slug string, max int) string {
+	if len(slug) <= max {
+		return slug
+	}
+	cut := slug[:max]
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	if i := strings.LastIndexByte(cut, '-'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut
+}